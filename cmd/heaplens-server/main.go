@@ -0,0 +1,213 @@
+// ABOUTME: Command heaplens-server serves the HeapLens web UI for one dump
+// ABOUTME: Parses the dump once at startup and keeps the graph in memory
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prateek/heaplens/graph"
+	"github.com/prateek/heaplens/heapdump"
+	"github.com/prateek/heaplens/heapdump/goheap"
+	_ "github.com/prateek/heaplens/heapdump/pprof"
+	"github.com/prateek/heaplens/server"
+)
+
+// progressInterval is how many records pass between progress lines while
+// the dump parses
+const progressInterval = 1_000_000
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	payloads := flag.Bool("payloads", false, "keep object payloads to show string contents (uses more memory)")
+	cache := flag.String("cache", "", "load the parsed graph from this file, and its dominators from this file plus .dom, writing them first if missing or stale")
+	compress := flag.Bool("compress-cache", false, "gzip object payloads and type names when writing the cache")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: heaplens-server [-addr host:port] [-payloads] [-cache file [-compress-cache]] <dump>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	path := flag.Arg(0)
+
+	heapdump.Register(&goheap.GoHeapParser{
+		KeepPayloads:     *payloads,
+		ProgressInterval: progressInterval,
+		ProgressFunc: func(objects, types, roots int) {
+			log.Printf("parsing %s: %d objects, %d types, %d roots", path, objects, types, roots)
+		},
+	})
+
+	start := time.Now()
+	g, err := loadGraph(path, *cache, *payloads, graph.EncodeOpts{Compress: *compress})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("loaded %s: %d objects in %v", path, g.NumObjects(), time.Since(start).Round(time.Millisecond))
+
+	var di *graph.DomInfo
+	if *cache != "" {
+		start = time.Now()
+		if di, err = loadDominators(g, *cache+".dom"); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("loaded dominators in %v", time.Since(start).Round(time.Millisecond))
+	}
+
+	srv, err := server.NewWithDominators(g, filepath.Base(path), di)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("serving on http://%s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, srv))
+}
+
+// cacheKey identifies the dump and settings a cached graph was parsed
+// with. It is written as a JSON line before the encoded graph, and a cache
+// whose key differs is rewritten.
+type cacheKey struct {
+	Path     string
+	Size     int64
+	ModTime  int64 // Unix nanoseconds
+	Payloads bool
+}
+
+// dumpKey returns the cache key for parsing the dump at path
+func dumpKey(path string, payloads bool) (cacheKey, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return cacheKey{}, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return cacheKey{}, err
+	}
+	return cacheKey{Path: abs, Size: info.Size(), ModTime: info.ModTime().UnixNano(), Payloads: payloads}, nil
+}
+
+// loadGraph parses the dump at path. If cache is set, it reads the graph
+// from the cache file instead when one exists for the same dump and
+// payloads setting, and otherwise writes the parsed graph there, encoded
+// with opts, for the next start.
+func loadGraph(path, cache string, payloads bool, opts graph.EncodeOpts) (graph.Graph, error) {
+	var key cacheKey
+	if cache != "" {
+		var err error
+		if key, err = dumpKey(path, payloads); err != nil {
+			return nil, err
+		}
+		g, err := readGraphCache(cache, key)
+		if g != nil || err != nil {
+			return g, err
+		}
+	}
+
+	g, err := heapdump.OpenFile(path)
+	if err != nil || cache == "" {
+		return g, err
+	}
+	mg, ok := g.(*graph.MemGraph)
+	if !ok {
+		return g, nil
+	}
+
+	err = writeCache(cache, func(w io.Writer) error {
+		if err := json.NewEncoder(w).Encode(key); err != nil {
+			return err
+		}
+		return mg.EncodeWith(w, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("cached parsed graph in %s", cache)
+	return g, nil
+}
+
+// readGraphCache reads the graph cached for key. It returns a nil graph
+// if the cache is missing or was written for another dump or setting.
+func readGraphCache(cache string, key cacheKey) (graph.Graph, error) {
+	f, err := os.Open(cache)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	line, err := br.ReadBytes('\n')
+	var cached cacheKey
+	if err != nil || json.Unmarshal(line, &cached) != nil || cached != key {
+		log.Printf("graph cache %s is for a different dump or -payloads setting; reparsing", cache)
+		return nil, nil
+	}
+	g, err := graph.DecodeGraph(br)
+	if err != nil {
+		return nil, fmt.Errorf("reading cache %s: %w", cache, err)
+	}
+	return g, nil
+}
+
+// writeCache writes a cache file through write. It writes to a temporary
+// file beside path and renames it into place, so an interrupted write
+// never leaves a truncated cache for the next start to read.
+func writeCache(path string, write func(io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// loadDominators reads g's dominators from the cache file, or computes
+// them and writes the cache if it is missing or was made for another graph
+func loadDominators(g graph.Graph, cache string) (*graph.DomInfo, error) {
+	f, err := os.Open(cache)
+	if err == nil {
+		di, err := graph.DecodeDominators(f, g)
+		f.Close()
+		if err == nil {
+			return di, nil
+		}
+		if !errors.Is(err, graph.ErrStaleDominators) {
+			return nil, fmt.Errorf("reading cache %s: %w", cache, err)
+		}
+		log.Printf("dominator cache %s is for a different graph; recomputing", cache)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	di := graph.ComputeDominators(g)
+	if err := writeCache(cache, di.Encode); err != nil {
+		return nil, err
+	}
+	log.Printf("cached dominators in %s", cache)
+	return di, nil
+}
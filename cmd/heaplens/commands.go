@@ -0,0 +1,642 @@
+// ABOUTME: Subcommand implementations for the heaplens command
+// ABOUTME: Each prints a tab-aligned report produced by the graph algorithms
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/prateek/heaplens/graph"
+	"github.com/prateek/heaplens/heapdump"
+	"github.com/prateek/heaplens/heapdump/goheap"
+	"github.com/prateek/heaplens/heapdump/sizeclass"
+)
+
+func runTopTypes(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("top-types", flag.ContinueOnError)
+	top := fs.Int("top", 20, "number of types to show (0 for all)")
+	path, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	g, err := heapdump.OpenFile(path)
+	if err != nil {
+		return err
+	}
+
+	stats := graph.TypeHistogram(g)
+	if *top > 0 && len(stats) > *top {
+		stats = stats[:*top]
+	}
+	fanout := graph.TypeFanout(g)
+
+	tw := newTable(stdout)
+	fmt.Fprintln(tw, "TYPE\tCOUNT\tTOTAL SIZE\tAVG SIZE\tAVG PTRS\t% HEAP")
+	for _, stat := range stats {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f\t%.1f%%\n", stat.Type, stat.Count, stat.TotalSize, stat.AvgSize(), fanout[stat.Type], stat.PercentOfTotal)
+	}
+	return tw.Flush()
+}
+
+func runRetained(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("retained", flag.ContinueOnError)
+	top := fs.Int("top", 20, "number of objects to show")
+	path, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	g, err := heapdump.OpenFile(path)
+	if err != nil {
+		return err
+	}
+
+	di := graph.ComputeDominators(g)
+	retained, counts := di.RetainedSize(), di.DominatedCounts()
+
+	tw := newTable(stdout)
+	fmt.Fprintln(tw, "ID\tTYPE\tSIZE\tRETAINED\tOBJECTS\t% HEAP")
+	for _, stat := range graph.TopRetainedStats(g, retained, *top) {
+		obj := g.GetObject(stat.ID)
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%.1f%%\n", stat.ID, obj.Type, obj.Size, stat.Retained, counts[stat.ID], stat.PercentOfTotal)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout)
+
+	// One giant allocation can hide below the objects that retain it
+	tw = newTable(stdout)
+	fmt.Fprintln(tw, "LARGEST\tTYPE\tSIZE\tRETAINED")
+	for _, id := range graph.LargestObjects(g, *top) {
+		obj := g.GetObject(id)
+		size, ok := retained[id]
+		if !ok {
+			size = obj.Size // unreachable objects retain only themselves
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\n", id, obj.Type, obj.Size, size)
+	}
+	return tw.Flush()
+}
+
+func runWaste(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("waste", flag.ContinueOnError)
+	top := fs.Int("top", 20, "number of slices and maps to show")
+	path, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	g, params, err := openWithPayloads(path)
+	if err != nil {
+		return err
+	}
+
+	slices := graph.FindSliceWaste(g, int(params.PointerSize), params.BigEndian, sizeclass.RoundToSizeClass)
+	if len(slices) == 0 {
+		fmt.Fprintln(stdout, "no oversized slices found")
+	} else {
+		tw := newTable(stdout)
+		fmt.Fprintln(tw, "ID\tTYPE\tLEN\tCAP\tBACKING\tWASTED")
+		for _, w := range slices[:min(*top, len(slices))] {
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%d\n", w.ID, g.GetObject(w.ID).Type, w.Len, w.Cap, w.Backing, w.Wasted)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(stdout)
+
+	maps := graph.FindMapWaste(g, int(params.PointerSize), params.BigEndian)
+	if len(maps) == 0 {
+		fmt.Fprintln(stdout, "no oversized maps found")
+		return nil
+	}
+	tw := newTable(stdout)
+	fmt.Fprintln(tw, "ID\tTYPE\tENTRIES\tRETAINED\tWASTED")
+	for _, w := range maps[:min(*top, len(maps))] {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\n", w.ID, g.GetObject(w.ID).Type, w.Entries, w.Retained, w.Wasted)
+	}
+	return tw.Flush()
+}
+
+func runGate(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("gate", flag.ContinueOnError)
+	baselinePath := fs.String("baseline", "", "dump to compare against")
+	var rules graph.GateRules
+	fs.Int64Var(&rules.PerType.Bytes, "max-growth", 0, "maximum growth of any type's retained bytes")
+	fs.Float64Var(&rules.PerType.Percent, "max-growth-pct", 10, "maximum growth of any type's retained bytes, in percent")
+	fs.Int64Var(&rules.Total.Bytes, "total-max-growth", 0, "maximum growth of the reachable heap in bytes")
+	fs.Float64Var(&rules.Total.Percent, "total-max-growth-pct", 0, "maximum growth of the reachable heap, in percent")
+	minGrowth := fs.Int64("min-growth", 64<<10, "growth in bytes under which percent limits don't apply")
+	path, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	rules.PerType.MinBytes = *minGrowth
+	rules.Total.MinBytes = *minGrowth
+	if *baselinePath == "" {
+		return fmt.Errorf("--baseline is required: %w", errUsage)
+	}
+
+	baseline, err := heapdump.OpenFile(*baselinePath)
+	if err != nil {
+		return err
+	}
+	g, err := heapdump.OpenFile(path)
+	if err != nil {
+		return err
+	}
+
+	violations := graph.LeakGate(baseline, g, rules)
+	for _, v := range violations {
+		fmt.Fprintln(stdout, v)
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("%d growth limits exceeded", len(violations))
+	}
+	fmt.Fprintln(stdout, "no growth over limits")
+	return nil
+}
+
+func runPaths(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("paths", flag.ContinueOnError)
+	id := fs.Uint64("id", 0, "object ID to find paths for")
+	maxPaths := fs.Int("max", 5, "maximum number of paths")
+	through := fs.String("through", "", "only show paths through a referrer whose type matches this regexp")
+	path, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *id == 0 {
+		return fmt.Errorf("--id is required: %w", errUsage)
+	}
+	var throughRe *regexp.Regexp
+	if *through != "" {
+		if throughRe, err = regexp.Compile(*through); err != nil {
+			return fmt.Errorf("invalid --through pattern: %w", err)
+		}
+	}
+
+	g, err := heapdump.OpenFile(path)
+	if err != nil {
+		return err
+	}
+
+	target := graph.ObjID(*id)
+	if g.GetObject(target) == nil {
+		return fmt.Errorf("object %d not found", target)
+	}
+
+	var paths []graph.Path
+	if throughRe != nil {
+		paths = graph.PathsToRootsFiltered(g, target, *maxPaths, func(obj *graph.Object) bool {
+			return throughRe.MatchString(obj.Type)
+		})
+	} else {
+		paths = graph.PathsToRoots(g, target, *maxPaths)
+	}
+	if len(paths) == 0 {
+		if throughRe != nil {
+			fmt.Fprintf(stdout, "object %d is not retained by any root through %q\n", target, *through)
+		} else {
+			fmt.Fprintf(stdout, "object %d is not retained by any root\n", target)
+		}
+		return nil
+	}
+
+	for i, p := range paths {
+		fmt.Fprintf(stdout, "path %d:\n", i+1)
+		tw := newTable(stdout)
+		for _, pid := range p.IDs {
+			fmt.Fprintf(tw, "  %d\t%s\t%d\n", pid, g.GetObject(pid).Type, g.GetObject(pid).Size)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runExplain(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	id := fs.Uint64("id", 0, "object ID to explain")
+	path, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *id == 0 {
+		return fmt.Errorf("--id is required: %w", errUsage)
+	}
+
+	g, err := heapdump.OpenFile(path)
+	if err != nil {
+		return err
+	}
+
+	target := graph.ObjID(*id)
+	if g.GetObject(target) == nil {
+		return fmt.Errorf("object %d not found", target)
+	}
+
+	e := graph.ExplainRetention(g, target)
+	fmt.Fprintln(stdout, strings.Join(explainSentences(g, e), " "))
+	return nil
+}
+
+// explainSentences describes e in plain sentences
+func explainSentences(g graph.Graph, e graph.RetentionExplanation) []string {
+	describe := func(id graph.ObjID) string {
+		return fmt.Sprintf("%d (%s)", id, g.GetObject(id).Type)
+	}
+
+	var s []string
+	if !e.Reachable {
+		s = append(s, fmt.Sprintf("Object %s is %d bytes and is not reachable from any root, so the next GC frees it.", describe(e.ID), e.Size))
+	} else {
+		s = append(s, fmt.Sprintf("Object %s is %d bytes and retains %d bytes.", describe(e.ID), e.Size, e.Retained))
+
+		if len(e.PathToRoot.IDs) == 1 {
+			s = append(s, "It is a GC root.")
+		} else {
+			steps := make([]string, len(e.PathToRoot.IDs))
+			for i, pid := range e.PathToRoot.IDs {
+				steps[i] = describe(pid)
+			}
+			s = append(s, fmt.Sprintf("Its shortest path to a root is %s.", strings.Join(steps, " <- ")))
+		}
+
+		if e.Dominator == 0 {
+			s = append(s, "No single object dominates it, so it stays alive while any of its roots or referrers does.")
+		} else {
+			s = append(s, fmt.Sprintf("Its immediate dominator is %s: every path from a root to it goes through that object.", describe(e.Dominator)))
+		}
+	}
+
+	if e.InCycle {
+		s = append(s, "It is part of a reference cycle.")
+	} else if e.Reachable {
+		s = append(s, "It is not part of a reference cycle.")
+	}
+	if e.HasFinalizer {
+		s = append(s, "It has a finalizer, which must run before it can be freed.")
+	}
+
+	if len(e.TopTypes) > 0 {
+		types := make([]string, len(e.TopTypes))
+		for i, ts := range e.TopTypes {
+			noun := "objects"
+			if ts.Count == 1 {
+				noun = "object"
+			}
+			types[i] = fmt.Sprintf("%s (%d bytes in %d %s)", ts.Type, ts.TotalSize, ts.Count, noun)
+		}
+		s = append(s, fmt.Sprintf("What it retains is mostly %s.", strings.Join(types, ", ")))
+	}
+	return s
+}
+
+func runQuery(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	expr := fs.String("expr", "", `query expression, such as 'type =~ "Cache" && retained > 1MB'`)
+	top := fs.Int("top", 50, "number of objects to show (0 for all)")
+	path, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *expr == "" {
+		return fmt.Errorf("--expr is required: %w", errUsage)
+	}
+
+	g, err := heapdump.OpenFile(path)
+	if err != nil {
+		return err
+	}
+
+	ids, err := graph.Query(g, *expr)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Fprintln(stdout, "no objects match")
+		return nil
+	}
+
+	shown := ids
+	if *top > 0 && len(shown) > *top {
+		shown = shown[:*top]
+	}
+	tw := newTable(stdout)
+	fmt.Fprintln(tw, "ID\tTYPE\tSIZE")
+	for _, id := range shown {
+		obj := g.GetObject(id)
+		fmt.Fprintf(tw, "%d\t%s\t%d\n", id, obj.Type, obj.Size)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%d of %d objects match\n", len(ids), g.NumObjects())
+	return nil
+}
+
+func runExport(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	id := fs.Uint64("id", 0, "object whose retained objects to export")
+	path, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *id == 0 {
+		return fmt.Errorf("--id is required: %w", errUsage)
+	}
+
+	g, err := heapdump.OpenFile(path)
+	if err != nil {
+		return err
+	}
+
+	target := graph.ObjID(*id)
+	if g.GetObject(target) == nil {
+		return fmt.Errorf("object %d not found", target)
+	}
+
+	sub := graph.Subgraph(g, graph.DominatedSet(g, target))
+	sub.SetRoots(graph.Roots{IDs: []graph.ObjID{target}})
+	return heapdump.WriteJSON(stdout, sub)
+}
+
+func runAnonymize(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("anonymize", flag.ContinueOnError)
+	legendPath := fs.String("legend", "", "file to write the pseudonym to type name legend to")
+	path, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	g, err := heapdump.OpenFile(path)
+	if err != nil {
+		return err
+	}
+
+	opts := graph.AnonOpts{KeepBuiltins: true}
+	if *legendPath != "" {
+		opts.Legend = make(map[string]string)
+	}
+	if err := heapdump.WriteJSON(stdout, graph.Anonymize(g, opts)); err != nil {
+		return err
+	}
+	if opts.Legend == nil {
+		return nil
+	}
+	return writeLegend(*legendPath, opts.Legend)
+}
+
+// writeLegend writes one "pseudonym<TAB>type" line per entry, sorted
+func writeLegend(path string, legend map[string]string) error {
+	aliases := make([]string, 0, len(legend))
+	for alias := range legend {
+		aliases = append(aliases, alias)
+	}
+	sort.Slice(aliases, func(i, j int) bool {
+		if len(aliases[i]) != len(aliases[j]) {
+			return len(aliases[i]) < len(aliases[j])
+		}
+		return aliases[i] < aliases[j]
+	})
+
+	var b strings.Builder
+	for _, alias := range aliases {
+		fmt.Fprintf(&b, "%s\t%s\n", alias, legend[alias])
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		return fmt.Errorf("writing legend: %w", err)
+	}
+	return nil
+}
+
+func runValidate(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	compare := fs.Bool("compare-parsers", false, "also check that both Go heap dump parsers read the dump alike")
+	path, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	g, err := heapdump.OpenFile(path)
+	if err != nil {
+		return err
+	}
+
+	issues := graph.Validate(g)
+	for _, issue := range issues {
+		fmt.Fprintln(stdout, issue)
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("%d integrity issues found", len(issues))
+	}
+	fmt.Fprintf(stdout, "%d objects, no issues found\n", g.NumObjects())
+	if *compare {
+		return compareParsers(path, stdout)
+	}
+	return nil
+}
+
+// compareParsers reads the Go heap dump at path with both the buffered and
+// streaming parsers and prints where they differ
+func compareParsers(path string, stdout io.Writer) error {
+	r1, close1, err := openGoDump(path)
+	if err != nil {
+		return err
+	}
+	if r1 == nil {
+		return fmt.Errorf("--compare-parsers needs a Go heap dump: %w", errUsage)
+	}
+	defer close1()
+	r2, close2, err := openGoDump(path)
+	if err != nil {
+		return err
+	}
+	defer close2()
+
+	report, err := goheap.CompareParsers(r1, r2)
+	if err != nil {
+		return fmt.Errorf("comparing parsers on %s: %w", path, err)
+	}
+	for _, d := range report.Discrepancies {
+		fmt.Fprintln(stdout, d)
+	}
+	if !report.Match() {
+		if n := report.Total - len(report.Discrepancies); n > 0 {
+			fmt.Fprintf(stdout, "... and %d more\n", n)
+		}
+		return fmt.Errorf("parsers disagree in %d places", report.Total)
+	}
+	fmt.Fprintf(stdout, "parsers agree on %d objects and %d roots\n", report.BufferedObjects, report.BufferedRoots)
+	return nil
+}
+
+// dumpDetails holds what info reports beyond the graph itself. Only Go heap
+// dumps carry these, so they are read with a separate streaming pass.
+type dumpDetails struct {
+	params     *goheap.DumpParams
+	memStats   *goheap.MemStatsFull
+	goroutines int
+}
+
+// infoLargest is how many of the largest objects info lists
+const infoLargest = 5
+
+func runInfo(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("info", flag.ContinueOnError)
+	path, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	g, err := heapdump.OpenFile(path)
+	if err != nil {
+		return err
+	}
+
+	details, err := readDumpDetails(path)
+	if err != nil {
+		return err
+	}
+
+	tw := newTable(stdout)
+	fmt.Fprintf(tw, "Objects:\t%d\n", g.NumObjects())
+	fmt.Fprintf(tw, "Edges:\t%d (%.2f per object)\n", graph.NumEdges(g), graph.EdgeDensity(g))
+	fmt.Fprintf(tw, "Total size:\t%d\n", graph.TotalSize(g))
+	fmt.Fprintf(tw, "Roots:\t%d\n", len(g.GetRoots().IDs))
+	if largest := graph.LargestObjects(g, infoLargest); len(largest) > 0 {
+		fmt.Fprintln(tw, "Largest:\t")
+		for _, id := range largest {
+			obj := g.GetObject(id)
+			fmt.Fprintf(tw, "  %d\t%s, %d bytes\n", id, obj.Type, obj.Size)
+		}
+	}
+
+	if details != nil {
+		fmt.Fprintf(tw, "Goroutines:\t%d\n", details.goroutines)
+		if p := details.params; p != nil {
+			fmt.Fprintf(tw, "Go version:\t%s\n", p.GoVersion)
+			if warning := p.Compatibility().Warning(); warning != "" {
+				fmt.Fprintf(tw, "Warning:\t%s\n", warning)
+			}
+			fmt.Fprintf(tw, "Arch:\t%s\n", archLabel(p.Arch, runtime.GOARCH))
+			fmt.Fprintf(tw, "Pointer size:\t%d\n", p.PointerSize)
+			fmt.Fprintf(tw, "Big endian:\t%t\n", p.BigEndian)
+			fmt.Fprintf(tw, "Heap:\t%#x-%#x\n", p.HeapStart, p.HeapEnd)
+			fmt.Fprintf(tw, "CPUs:\t%d\n", p.NumCPUs)
+		}
+		if ms := details.memStats; ms != nil {
+			fmt.Fprintln(tw, "MemStats:\t")
+			fmt.Fprintf(tw, "  Alloc\t%d\n", ms.Alloc)
+			fmt.Fprintf(tw, "  TotalAlloc\t%d\n", ms.TotalAlloc)
+			fmt.Fprintf(tw, "  Sys\t%d\n", ms.Sys)
+			fmt.Fprintf(tw, "  Mallocs\t%d\n", ms.Mallocs)
+			fmt.Fprintf(tw, "  Frees\t%d\n", ms.Frees)
+			fmt.Fprintf(tw, "  HeapAlloc\t%d\n", ms.HeapAlloc)
+			fmt.Fprintf(tw, "  HeapSys\t%d\n", ms.HeapSys)
+			fmt.Fprintf(tw, "  HeapIdle\t%d\n", ms.HeapIdle)
+			fmt.Fprintf(tw, "  HeapInuse\t%d\n", ms.HeapInuse)
+			fmt.Fprintf(tw, "  HeapReleased\t%d\n", ms.HeapReleased)
+			fmt.Fprintf(tw, "  HeapObjects\t%d\n", ms.HeapObjects)
+		}
+	}
+	return tw.Flush()
+}
+
+// archLabel describes a dump's architecture, noting when it differs from
+// the host's so pointer sizes and addresses are not read as the host's
+func archLabel(arch, host string) string {
+	if arch == host {
+		return arch
+	}
+	return fmt.Sprintf("%s (analyzed on %s)", arch, host)
+}
+
+// openGoDump opens path, decompressing it if gzipped, and returns a reader
+// positioned at the start of the dump and a function that closes it. The
+// reader is nil if the file is not a Go heap dump.
+func openGoDump(path string) (*bufio.Reader, func(), error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := heapdump.MaybeDecompress(bufio.NewReader(f), strings.HasSuffix(path, ".gz"))
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("decompressing %s: %w", path, err)
+	}
+
+	zbr := bufio.NewReader(r)
+	header, _ := zbr.Peek(16)
+	if !(&goheap.GoHeapParser{}).CanParse(bytes.NewReader(header)) {
+		f.Close()
+		return nil, nil, nil
+	}
+	return zbr, func() { f.Close() }, nil
+}
+
+// openWithPayloads is like heapdump.OpenFile but keeps object payloads for
+// Go heap dumps and reports their params, for decoding the payloads. Other
+// formats carry no payloads and are opened as usual, with zero params.
+func openWithPayloads(path string) (graph.Graph, goheap.DumpParams, error) {
+	r, closeDump, err := openGoDump(path)
+	if err != nil {
+		return nil, goheap.DumpParams{}, err
+	}
+	if r == nil {
+		g, err := heapdump.OpenFile(path)
+		return g, goheap.DumpParams{}, err
+	}
+	defer closeDump()
+
+	result, err := (&goheap.GoHeapParser{KeepPayloads: true, ProgressFunc: logProgress}).ParseFull(r)
+	if err != nil {
+		return nil, goheap.DumpParams{}, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return result.Graph, result.Params, nil
+}
+
+// readDumpDetails streams a Go heap dump for its parameters, goroutines,
+// and MemStats. Returns nil details for other formats.
+func readDumpDetails(path string) (*dumpDetails, error) {
+	zbr, closeDump, err := openGoDump(path)
+	if err != nil || zbr == nil {
+		return nil, err
+	}
+	defer closeDump()
+
+	details := &dumpDetails{}
+	parser := goheap.NewStreamingParser(zbr, goheap.StreamCallbacks{
+		OnParams: func(params goheap.DumpParams) error {
+			details.params = &params
+			return nil
+		},
+		OnGoroutine: func(id, status uint64, waitReason string) error {
+			details.goroutines++
+			return nil
+		},
+		OnMemStats: func(stats *goheap.MemStatsFull) error {
+			details.memStats = stats
+			return nil
+		},
+	})
+	if err := parser.Parse(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return details, nil
+}
@@ -0,0 +1,135 @@
+// ABOUTME: Command heaplens analyzes heap dumps offline from the command line
+// ABOUTME: Provides top-types, retained, waste, gate, paths, explain, query, export, anonymize, validate, and info subcommands
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prateek/heaplens/heapdump"
+	"github.com/prateek/heaplens/heapdump/goheap"
+	_ "github.com/prateek/heaplens/heapdump/pprof"
+)
+
+const usage = `usage: heaplens <command> [flags] <dump>
+
+commands:
+  top-types <dump> [--top N]     memory usage grouped by type
+  retained <dump> [--top N]      top retainers, with how many objects each
+                                 retains, and largest single objects
+  waste <dump> [--top N]         oversized slices and maps
+  gate <dump> --baseline B [--max-growth N] [--max-growth-pct P]
+       [--total-max-growth N] [--total-max-growth-pct P] [--min-growth N]
+                                 fail if memory grew past limits since B
+  paths <dump> --id N [--max K] [--through RE]
+                                 paths from an object to GC roots
+  explain <dump> --id N          why an object is alive, in one report
+  query <dump> --expr E [--top N]
+                                 objects matching a query expression
+  export <dump> --id N           JSON dump of everything an object retains
+  anonymize <dump> [--legend F]  JSON dump with type names replaced
+  validate <dump> [--compare-parsers]
+                                 check the graph for integrity issues, and
+                                 that both Go heap dump parsers agree
+  info <dump>                    dump parameters, counts, largest objects, and MemStats
+`
+
+// errUsage signals that usage should be printed and the exit code is 2
+var errUsage = errors.New("invalid usage")
+
+// command is a subcommand entry point
+type command func(args []string, stdout io.Writer) error
+
+var commands = map[string]command{
+	"top-types": runTopTypes,
+	"retained":  runRetained,
+	"waste":     runWaste,
+	"gate":      runGate,
+	"paths":     runPaths,
+	"explain":   runExplain,
+	"query":     runQuery,
+	"export":    runExport,
+	"anonymize": runAnonymize,
+	"validate":  runValidate,
+	"info":      runInfo,
+}
+
+func main() {
+	progressOut, lastProgress = os.Stderr, time.Now()
+	heapdump.Register(&goheap.GoHeapParser{ProgressFunc: logProgress})
+	err := run(os.Args[1:], os.Stdout)
+	if errors.Is(err, errUsage) {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "heaplens: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// progressInterval is the least time between progress lines, so dumps
+// that parse quickly print none
+const progressInterval = time.Second
+
+// progressOut receives progress lines while Go heap dumps parse. main sets
+// it to stderr, keeping stdout clean for scripts; it is nil in tests.
+var progressOut io.Writer
+
+// lastProgress is when the last progress line was written, or when the
+// command started
+var lastProgress time.Time
+
+// logProgress is the GoHeapParser.ProgressFunc for every parse the command
+// makes
+func logProgress(objects, types, roots int) {
+	if progressOut == nil || time.Since(lastProgress) < progressInterval {
+		return
+	}
+	lastProgress = time.Now()
+	fmt.Fprintf(progressOut, "heaplens: parsing: %d objects, %d types, %d roots\n", objects, types, roots)
+}
+
+// run dispatches to the subcommand named by args[0]
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q: %w", args[0], errUsage)
+	}
+	return cmd(args[1:], stdout)
+}
+
+// parseArgs parses flags that may appear before or after the single dump
+// path argument and returns the path
+func parseArgs(fs *flag.FlagSet, args []string) (string, error) {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return "", fmt.Errorf("%v: %w", err, errUsage)
+	}
+	if fs.NArg() == 0 {
+		return "", fmt.Errorf("missing dump path: %w", errUsage)
+	}
+
+	path := fs.Arg(0)
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return "", fmt.Errorf("%v: %w", err, errUsage)
+	}
+	if fs.NArg() != 0 {
+		return "", fmt.Errorf("unexpected arguments %v: %w", fs.Args(), errUsage)
+	}
+	return path, nil
+}
+
+// newTable returns a writer that aligns tab-separated columns
+func newTable(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+}
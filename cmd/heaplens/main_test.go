@@ -0,0 +1,335 @@
+// ABOUTME: Tests for the heaplens command's subcommands
+// ABOUTME: Runs each subcommand against the shared JSON test dump
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prateek/heaplens/heapdump/goheap"
+)
+
+const testDump = "../../testdata/simple.json"
+
+func TestSubcommands(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "top-types",
+			args: []string{"top-types", testDump},
+			want: []string{"TYPE", "AVG PTRS", "% HEAP", "array", "element  2"},
+		},
+		{
+			name: "top-types limited",
+			args: []string{"top-types", "--top", "1", testDump},
+			want: []string{"array"},
+		},
+		{
+			name: "retained",
+			args: []string{"retained", testDump, "--top", "2"},
+			want: []string{"RETAINED", "OBJECTS", "root", "array", "100.0%", "LARGEST", "3        array  200   260"},
+		},
+		{
+			name: "waste",
+			args: []string{"waste", testDump},
+			want: []string{"no oversized slices found", "no oversized maps found"},
+		},
+		{
+			name: "paths",
+			args: []string{"paths", testDump, "--id", "4"},
+			want: []string{"path 1:", "4", "3", "1"},
+		},
+		{
+			name: "paths through type",
+			args: []string{"paths", testDump, "--id", "4", "--through", "^array$"},
+			want: []string{"path 1:", "array"},
+		},
+		{
+			name: "paths through missing type",
+			args: []string{"paths", testDump, "--id", "4", "--through", "string"},
+			want: []string{`not retained by any root through "string"`},
+		},
+		{
+			name: "explain",
+			args: []string{"explain", testDump, "--id", "3"},
+			want: []string{
+				"Object 3 (array) is 200 bytes and retains 260 bytes.",
+				"Its shortest path to a root is 3 (array) <- 1 (root).",
+				"Its immediate dominator is 1 (root)",
+				"not part of a reference cycle",
+				"mostly array (200 bytes in 1 object), element (60 bytes in 2 objects).",
+			},
+		},
+		{
+			name: "explain root",
+			args: []string{"explain", testDump, "--id", "1"},
+			want: []string{"It is a GC root.", "No single object dominates it"},
+		},
+		{
+			name: "query",
+			args: []string{"query", testDump, "--expr", `type =~ "^elem" || size > 100 && indegree == 0`},
+			want: []string{"ID", "element", "of 5 objects match"},
+		},
+		{
+			name: "query no match",
+			args: []string{"query", testDump, "--expr", "size > 1GB"},
+			want: []string{"no objects match"},
+		},
+		{
+			name: "export",
+			args: []string{"export", testDump, "--id", "3"},
+			want: []string{`"id":3`, `"id":4`, `"id":5`, `"roots":[{"id":3,"kind":"other"}]`},
+		},
+		{
+			name: "validate",
+			args: []string{"validate", testDump},
+			want: []string{"5 objects, no issues found"},
+		},
+		{
+			name: "info",
+			args: []string{"info", testDump},
+			want: []string{"Objects:", "Edges:       4 (0.80 per object)", "Roots:", "Largest:", "  3          array, 200 bytes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := run(tt.args, &out); err != nil {
+				t.Fatalf("run(%v) error = %v", tt.args, err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	bad := [][]string{
+		nil,
+		{"bogus"},
+		{"top-types"},
+		{"paths", testDump},
+		{"explain", testDump},
+		{"query", testDump},
+		{"export", testDump},
+		{"info", testDump, "extra"},
+		{"retained", "--top"},
+		{"gate", testDump},
+		{"validate", testDump, "--compare-parsers"},
+	}
+	for _, args := range bad {
+		if err := run(args, &bytes.Buffer{}); !errors.Is(err, errUsage) {
+			t.Errorf("run(%v) error = %v, want usage error", args, err)
+		}
+	}
+
+	if err := run([]string{"paths", testDump, "--id", "99"}, &bytes.Buffer{}); err == nil {
+		t.Error("expected error for missing object")
+	}
+	if err := run([]string{"query", testDump, "--expr", "size >"}, &bytes.Buffer{}); err == nil || errors.Is(err, errUsage) {
+		t.Errorf("query with a bad expression error = %v, want a parse error", err)
+	}
+}
+
+func TestAnonymizeWritesLegend(t *testing.T) {
+	legend := filepath.Join(t.TempDir(), "legend.txt")
+
+	var out bytes.Buffer
+	if err := run([]string{"anonymize", testDump, "--legend", legend}, &out); err != nil {
+		t.Fatalf("anonymize error = %v", err)
+	}
+	if strings.Contains(out.String(), "element") || !strings.Contains(out.String(), `"types":["Type1",`) {
+		t.Errorf("output not anonymized:\n%s", out.String())
+	}
+
+	data, err := os.ReadFile(legend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "Type1\tarray\n") {
+		t.Errorf("legend =\n%s\nwant Type1 mapped to array first", data)
+	}
+}
+
+func TestAnonymizeDropsIdentifyingFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.json")
+	fixture := `{"version":2,"types":["corp/billing.Account","string"],` +
+		`"objects":[{"id":1,"type":0,"size":64,"addr":824633786368,"ptrs":[2]},{"id":2,"type":1,"size":16,"addr":824633786432,"ptrs":[]}],` +
+		`"roots":[{"id":1,"kind":"global","desc":"billing.sessionCache"}]}`
+	if err := os.WriteFile(path, []byte(fixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := run([]string{"anonymize", path}, &out); err != nil {
+		t.Fatalf("anonymize error = %v", err)
+	}
+	for _, leak := range []string{"billing", "sessionCache", `"addr"`, `"desc"`} {
+		if strings.Contains(out.String(), leak) {
+			t.Errorf("anonymized output contains %q:\n%s", leak, out.String())
+		}
+	}
+}
+
+func TestValidateReportsIssues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.json")
+	fixture := `{"objects":[{"id":1,"type":"root","size":10,"ptrs":[2]}],"roots":[1,3]}`
+	if err := os.WriteFile(path, []byte(fixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := run([]string{"validate", path}, &out); err == nil {
+		t.Error("validate should fail on a broken dump")
+	}
+	for _, want := range []string{"dangling pointer to missing object 2", "root 3"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// writeGoDump writes a minimal Go heap dump: a main.T at 0x2000 pointing
+// to a second at 0x2100, the first a root
+func writeGoDump(t *testing.T) string {
+	b := []byte("go1.7 heap dump\n")
+	uv := func(vs ...uint64) {
+		for _, v := range vs {
+			b = binary.AppendUvarint(b, v)
+		}
+	}
+	str := func(s string) { uv(uint64(len(s))); b = append(b, s...) }
+
+	uv(6, 0, 8, 0x1000, 0x10000) // params: little endian, 8-byte pointers, heap range
+	str("amd64")
+	str("go1.20.0")
+	uv(4)
+	uv(3, 0x100, 16) // type
+	str("main.T")
+	uv(0)
+	for _, obj := range [][2]uint64{{0x2000, 0x2100}, {0x2100, 0}} {
+		data := make([]byte, 16)
+		binary.LittleEndian.PutUint64(data, 0x100)
+		binary.LittleEndian.PutUint64(data[8:], obj[1])
+		uv(1, obj[0], 16) // object
+		b = append(b, data...)
+		uv(1, 8, 0) // pointer at offset 8, end of fields
+	}
+	uv(2) // root
+	str("data")
+	uv(0x2000, 0)
+
+	path := filepath.Join(t.TempDir(), "heap.dump")
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateCompareParsers(t *testing.T) {
+	goheap.RegisterParser()
+	var out bytes.Buffer
+	if err := run([]string{"validate", "--compare-parsers", writeGoDump(t)}, &out); err != nil {
+		t.Fatalf("validate --compare-parsers error = %v\n%s", err, out.String())
+	}
+	for _, want := range []string{"2 objects, no issues found", "parsers agree on 2 objects and 1 roots"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestInfoGzippedGoDump(t *testing.T) {
+	goheap.RegisterParser()
+	raw, err := os.ReadFile(writeGoDump(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(raw)
+	zw.Close()
+	path := filepath.Join(t.TempDir(), "heap.dump.gz")
+	if err := os.WriteFile(path, gz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := run([]string{"info", path}, &out); err != nil {
+		t.Fatalf("info error = %v", err)
+	}
+	for _, want := range []string{"Objects:", "Go version:", "go1.20.0"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestGate(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"gate", "--baseline", testDump, testDump}, &out); err != nil {
+		t.Fatalf("gate against itself error = %v", err)
+	}
+	if !strings.Contains(out.String(), "no growth over limits") {
+		t.Errorf("output = %q, want a pass", out.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "grown.json")
+	fixture := `{"objects":[{"id":1,"type":"root","size":10,"ptrs":[2]},{"id":2,"type":"array","size":5000}],"roots":[1]}`
+	if err := os.WriteFile(path, []byte(fixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := run([]string{"gate", path, "--baseline", testDump}, &out); err != nil {
+		t.Errorf("gate on growth under --min-growth error = %v, want a pass", err)
+	}
+	out.Reset()
+	if err := run([]string{"gate", path, "--baseline", testDump, "--min-growth", "0"}, &out); err == nil || errors.Is(err, errUsage) {
+		t.Errorf("gate on a grown heap error = %v, want a failure", err)
+	}
+	if !strings.Contains(out.String(), "root grew by") {
+		t.Errorf("output missing the root violation:\n%s", out.String())
+	}
+}
+
+func TestLogProgress(t *testing.T) {
+	var out bytes.Buffer
+	progressOut = &out
+	defer func() { progressOut = nil }()
+
+	lastProgress = time.Now()
+	logProgress(1, 2, 3)
+	if out.Len() != 0 {
+		t.Errorf("logProgress() within the interval wrote %q", out.String())
+	}
+
+	lastProgress = time.Now().Add(-progressInterval)
+	logProgress(100, 20, 3)
+	if want := "heaplens: parsing: 100 objects, 20 types, 3 roots\n"; out.String() != want {
+		t.Errorf("logProgress() wrote %q, want %q", out.String(), want)
+	}
+}
+
+func TestArchLabel(t *testing.T) {
+	if got := archLabel("amd64", "amd64"); got != "amd64" {
+		t.Errorf("archLabel(same) = %q, want amd64", got)
+	}
+	if got := archLabel("arm64", "amd64"); got != "arm64 (analyzed on amd64)" {
+		t.Errorf("archLabel(different) = %q, want the host noted", got)
+	}
+}
@@ -0,0 +1,67 @@
+// ABOUTME: Scrubs identifying type names from a heap graph so it can be shared
+// ABOUTME: Keeps structure, sizes, and edges intact so analysis still works
+
+package graph
+
+import "strconv"
+
+// AnonOpts configures Anonymize
+type AnonOpts struct {
+	// KeepBuiltins leaves basic types and string unrenamed, since they
+	// reveal nothing about the program
+	KeepBuiltins bool
+
+	// Legend, if non-nil, is filled with each pseudonym's original type
+	// name so the caller can de-anonymize results locally
+	Legend map[string]string
+}
+
+// Anonymize returns a copy of g with every type name replaced by a
+// pseudonym: Type1 for the type using the most memory, Type2 for the next,
+// and so on in TypeHistogram order, so the same graph always yields the same
+// names. IDs, sizes, pointers, root kinds, and finalizer flags are
+// preserved. Payloads, content hashes, addresses, and root descriptions are
+// dropped, since each can identify the program or the data it held.
+func Anonymize(g Graph, opts AnonOpts) Graph {
+	names := make(map[string]string)
+	for _, stat := range TypeHistogram(g) {
+		if opts.KeepBuiltins && isBuiltinType(stat.Type) {
+			continue
+		}
+		alias := "Type" + strconv.Itoa(len(names)+1)
+		names[stat.Type] = alias
+		if opts.Legend != nil {
+			opts.Legend[alias] = stat.Type
+		}
+	}
+
+	anon := NewMemGraph()
+	g.ForEachObject(func(obj *Object) {
+		typ, ok := names[obj.Type]
+		if !ok {
+			typ = obj.Type
+		}
+		anon.AddObject(&Object{
+			ID:   obj.ID,
+			Type: typ,
+			Size: obj.Size,
+			Ptrs: append([]ObjID(nil), obj.Ptrs...),
+
+			HasFinalizer: obj.HasFinalizer,
+			AllocSize:    obj.AllocSize,
+			TypeSize:     obj.TypeSize,
+		})
+	})
+	roots := g.GetRoots()
+	anon.SetRoots(Roots{
+		IDs:        append([]ObjID(nil), roots.IDs...),
+		Kinds:      append([]RootKind(nil), roots.Kinds...),
+		Goroutines: append([]uint64(nil), roots.Goroutines...),
+	})
+	return anon
+}
+
+// isBuiltinType reports whether name is a predeclared basic type or string
+func isBuiltinType(name string) bool {
+	return name == "string" || basicTypes[name]
+}
@@ -0,0 +1,52 @@
+// ABOUTME: Tests for anonymizing heap graph type names
+// ABOUTME: Checks pseudonym order, the legend, and that only structure is preserved
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnonymize(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "corp/internal/billing.Account", Size: 64, Ptrs: []ObjID{2, 3}, Addr: 0xc000010000, ContentHash: 0xabc})
+	g.AddObject(&Object{ID: 2, Type: "string", Size: 16})
+	g.AddObject(&Object{ID: 3, Type: "*corp/internal/billing.Card", Size: 8, Ptrs: []ObjID{2}, HasFinalizer: true})
+	g.AddObject(&Object{ID: 4, Type: "corp/internal/billing.Account", Size: 64})
+	g.SetRoots(Roots{IDs: []ObjID{1}, Kinds: []RootKind{RootGlobal}, Descs: []string{"billing.accounts"}})
+
+	legend := make(map[string]string)
+	anon := Anonymize(g, AnonOpts{KeepBuiltins: true, Legend: legend})
+
+	wantTypes := map[ObjID]string{1: "Type1", 2: "string", 3: "Type2", 4: "Type1"}
+	for id, want := range wantTypes {
+		got, orig := anon.GetObject(id), g.GetObject(id)
+		if got.Type != want {
+			t.Errorf("object %d type = %q, want %q", id, got.Type, want)
+		}
+		if got.Size != orig.Size || !reflect.DeepEqual(got.Ptrs, orig.Ptrs) || got.HasFinalizer != orig.HasFinalizer {
+			t.Errorf("object %d = %+v, want structure of %+v", id, got, orig)
+		}
+	}
+	if obj := anon.GetObject(1); obj.Addr != 0 || obj.ContentHash != 0 {
+		t.Errorf("object 1 Addr, ContentHash = %#x, %#x, want both dropped", obj.Addr, obj.ContentHash)
+	}
+	wantRoots := Roots{IDs: []ObjID{1}, Kinds: []RootKind{RootGlobal}}
+	if !reflect.DeepEqual(anon.GetRoots(), wantRoots) {
+		t.Errorf("roots = %+v, want %+v", anon.GetRoots(), wantRoots)
+	}
+
+	wantLegend := map[string]string{
+		"Type1": "corp/internal/billing.Account",
+		"Type2": "*corp/internal/billing.Card",
+	}
+	if !reflect.DeepEqual(legend, wantLegend) {
+		t.Errorf("legend = %v, want %v", legend, wantLegend)
+	}
+
+	// Without KeepBuiltins every type is renamed, and no legend is required
+	if typ := Anonymize(g, AnonOpts{}).GetObject(2).Type; typ != "Type2" {
+		t.Errorf("string renamed to %q, want Type2", typ)
+	}
+}
@@ -0,0 +1,52 @@
+// ABOUTME: Finds the edge of what an object retains in the dominator tree
+// ABOUTME: Separates objects freeing it would reclaim from shared ones
+
+package graph
+
+// RetentionBoundary returns the objects referenced from id's retained set
+// that are not themselves part of it: they are dominated by a different
+// node, so freeing id would not reclaim them. The retained set is id plus
+// everything it dominates. A leaf's boundary is its own pointers other than
+// itself; a root's boundary is whatever it shares with other roots. IDs are
+// sorted, and nil is returned for objects not in the graph. Use
+// DomInfo.RetentionBoundary to query several objects.
+func RetentionBoundary(g Graph, id ObjID) []ObjID {
+	if id == 0 || g.GetObject(id) == nil {
+		return nil
+	}
+	return ComputeDominators(g).RetentionBoundary(id)
+}
+
+// RetentionPurity returns the fraction of the bytes reachable from id that
+// id alone retains, between 0 and 1. At 1 nothing id reaches is shared, so
+// cutting the reference to id frees everything behind it; lower values mean
+// more of what id references is also held elsewhere and would survive the
+// cut. It returns 0 for objects not in the graph, and 1 if everything id
+// reaches has size zero. Use DomInfo.RetentionPurity to query several
+// objects.
+func RetentionPurity(g Graph, id ObjID) float64 {
+	if id == 0 || g.GetObject(id) == nil {
+		return 0
+	}
+	return ComputeDominators(g).RetentionPurity(id)
+}
+
+// RetentionPurity is like RetentionPurity(g, id) on di's graph
+func (di *DomInfo) RetentionPurity(id ObjID) float64 {
+	set := di.DominatedSet(id)
+	if set == nil {
+		return 0
+	}
+
+	var retained, reachable uint64
+	for _, n := range set {
+		retained += di.g.GetObject(n).Size
+	}
+	for n := range reachableFrom(di.g, []ObjID{id}) {
+		reachable += di.g.GetObject(n).Size
+	}
+	if reachable == 0 {
+		return 1
+	}
+	return float64(retained) / float64(reachable)
+}
@@ -0,0 +1,81 @@
+// ABOUTME: Tests for the retention boundary of an object's dominator subtree
+// ABOUTME: Covers shared children, roots, leaves, and unknown objects
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRetentionBoundary(t *testing.T) {
+	// 1 and 2 are roots. 1 -> 3 -> 4 (owned by 1), 3 -> 5 (shared with 2),
+	// 1 -> 6 (shared with 2), 5 -> 1 (back edge to a root).
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "a", Size: 10, Ptrs: []ObjID{3, 6}})
+	g.AddObject(&Object{ID: 2, Type: "b", Size: 10, Ptrs: []ObjID{5, 6}})
+	g.AddObject(&Object{ID: 3, Type: "c", Size: 10, Ptrs: []ObjID{4, 5}})
+	g.AddObject(&Object{ID: 4, Type: "d", Size: 10, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 5, Type: "e", Size: 10, Ptrs: []ObjID{1}})
+	g.AddObject(&Object{ID: 6, Type: "f", Size: 10})
+	g.AddObject(&Object{ID: 7, Type: "garbage", Size: 10, Ptrs: []ObjID{6}})
+	g.SetRoots(Roots{IDs: []ObjID{1, 2}})
+
+	tests := []struct {
+		name string
+		id   ObjID
+		want []ObjID
+	}{
+		{name: "root sharing children", id: 1, want: []ObjID{5, 6}},
+		{name: "inner node", id: 3, want: []ObjID{5}},
+		{name: "leaf with self pointer", id: 4, want: nil},
+		{name: "shared object pointing at a root", id: 5, want: []ObjID{1}},
+		{name: "unreachable object", id: 7, want: []ObjID{6}},
+		{name: "unknown object", id: 99, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RetentionBoundary(g, tt.id); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RetentionBoundary(%d) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetentionPurity(t *testing.T) {
+	// Same shape as TestRetentionBoundary, with sizes that tell the sets
+	// apart. 1 reaches everything but 7 and retains 1, 3, and 4.
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "a", Size: 100, Ptrs: []ObjID{3, 6}})
+	g.AddObject(&Object{ID: 2, Type: "b", Size: 10, Ptrs: []ObjID{5, 6}})
+	g.AddObject(&Object{ID: 3, Type: "c", Size: 200, Ptrs: []ObjID{4, 5}})
+	g.AddObject(&Object{ID: 4, Type: "d", Size: 300, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 5, Type: "e", Size: 150, Ptrs: []ObjID{1}})
+	g.AddObject(&Object{ID: 6, Type: "f", Size: 250})
+	g.AddObject(&Object{ID: 7, Type: "empty"})
+	g.SetRoots(Roots{IDs: []ObjID{1, 2, 7}})
+
+	tests := []struct {
+		name string
+		id   ObjID
+		want float64
+	}{
+		{name: "root sharing children", id: 1, want: 600.0 / 1000},
+		{name: "leaf", id: 4, want: 1},
+		{name: "object shared by two roots", id: 6, want: 1},
+		{name: "zero size", id: 7, want: 1},
+		{name: "unknown", id: 99, want: 0},
+	}
+	di := ComputeDominators(g)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RetentionPurity(g, tt.id); got != tt.want {
+				t.Errorf("RetentionPurity(%d) = %v, want %v", tt.id, got, tt.want)
+			}
+			if got := di.RetentionPurity(tt.id); got != tt.want {
+				t.Errorf("DomInfo.RetentionPurity(%d) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
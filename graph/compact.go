@@ -0,0 +1,271 @@
+// ABOUTME: Slice-backed Graph implementation for large heap graphs
+// ABOUTME: Stores objects contiguously with all pointers in one flat edge array
+
+package graph
+
+import (
+	"sort"
+	"sync"
+)
+
+// CompactGraph is a read-mostly Graph that keeps objects in a contiguous
+// slice sorted by ID, with every object's Ptrs pointing into one shared edge
+// array (a CSR layout). Internally objects are addressed by their dense
+// position; Object.ID keeps the original ID so results are interchangeable
+// with MemGraph. Lookups are O(1) when IDs are consecutive and O(log n)
+// otherwise.
+//
+// CompactGraph is built with Compact and is not safe for concurrent
+// mutation. AddObject is supported but costs O(n) per call. Graphs from
+// MemGraph.Snapshot are frozen: they are safe for concurrent reads, and
+// AddObject and SetRoots panic.
+type CompactGraph struct {
+	objects []Object // sorted by ID
+	edges   []ObjID  // backing store for every object's Ptrs
+	roots   Roots
+	dense   bool // objects[i].ID == objects[0].ID + i for all i
+	frozen  bool
+
+	// numEdges is the total length of every object's Ptrs. It can be less
+	// than len(edges), since replacing an object leaves its old edges behind.
+	numEdges int
+
+	// Reverse edges for Referrers in the same CSR layout, built on first
+	// use: the referrers of objects[i] are revEdges[revStart[i]:revStart[i+1]].
+	// revStart is nil when stale. revMu guards both, since frozen graphs
+	// are read concurrently.
+	revMu    sync.Mutex
+	revStart []int
+	revEdges []ObjID
+}
+
+// Compact copies g into a CompactGraph
+func Compact(g Graph) *CompactGraph {
+	objs := make([]*Object, 0, g.NumObjects())
+	g.ForEachObject(func(obj *Object) {
+		objs = append(objs, obj)
+	})
+	return compactObjects(objs, g.GetRoots())
+}
+
+// compactObjects copies objs, in any order, into a CompactGraph
+func compactObjects(objs []*Object, roots Roots) *CompactGraph {
+	numEdges := 0
+	for _, obj := range objs {
+		numEdges += len(obj.Ptrs)
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].ID < objs[j].ID })
+
+	cg := &CompactGraph{
+		objects: make([]Object, len(objs)),
+		edges:   make([]ObjID, 0, numEdges),
+	}
+	for i, obj := range objs {
+		cg.objects[i] = Object{
+			ID:   obj.ID,
+			Type: obj.Type,
+			Size: obj.Size,
+			Ptrs: cg.appendEdges(obj.Ptrs),
+
+			HasFinalizer: obj.HasFinalizer,
+			Addr:         obj.Addr,
+			AllocSize:    obj.AllocSize,
+			TypeSize:     obj.TypeSize,
+			ContentHash:  obj.ContentHash,
+			Data:         obj.Data,
+		}
+	}
+	cg.numEdges = numEdges
+	cg.updateDense()
+	cg.SetRoots(roots)
+	return cg
+}
+
+// appendEdges copies ptrs into the shared edge array and returns the
+// capped subslice holding them, or nil if there are none
+func (g *CompactGraph) appendEdges(ptrs []ObjID) []ObjID {
+	if len(ptrs) == 0 {
+		return nil
+	}
+	start := len(g.edges)
+	g.edges = append(g.edges, ptrs...)
+	return g.edges[start:len(g.edges):len(g.edges)]
+}
+
+// updateDense records whether IDs are consecutive, enabling O(1) lookups
+func (g *CompactGraph) updateDense() {
+	n := len(g.objects)
+	g.dense = n == 0 || g.objects[n-1].ID-g.objects[0].ID == ObjID(n-1)
+}
+
+// index returns the dense position of id
+func (g *CompactGraph) index(id ObjID) (int, bool) {
+	n := len(g.objects)
+	if n == 0 {
+		return 0, false
+	}
+	if g.dense {
+		if id < g.objects[0].ID || id > g.objects[n-1].ID {
+			return 0, false
+		}
+		return int(id - g.objects[0].ID), true
+	}
+	i := sort.Search(n, func(i int) bool { return g.objects[i].ID >= id })
+	return i, i < n && g.objects[i].ID == id
+}
+
+// AddObject inserts or replaces an object, keeping objects sorted by ID.
+// Objects previously returned by GetObject may be invalidated.
+func (g *CompactGraph) AddObject(obj *Object) {
+	g.checkMutable()
+	copied := Object{
+		ID:   obj.ID,
+		Type: obj.Type,
+		Size: obj.Size,
+		Ptrs: g.appendEdges(obj.Ptrs),
+
+		HasFinalizer: obj.HasFinalizer,
+		Addr:         obj.Addr,
+		AllocSize:    obj.AllocSize,
+		TypeSize:     obj.TypeSize,
+		ContentHash:  obj.ContentHash,
+		Data:         obj.Data,
+	}
+
+	g.revMu.Lock()
+	g.revStart = nil
+	g.revMu.Unlock()
+
+	g.numEdges += len(copied.Ptrs)
+	i, found := g.index(obj.ID)
+	if found {
+		g.numEdges -= len(g.objects[i].Ptrs)
+		g.objects[i] = copied
+		return
+	}
+	if g.dense && len(g.objects) > 0 && obj.ID > g.objects[0].ID {
+		// Dense lookups only miss outside the ID range
+		i = len(g.objects)
+	}
+	g.objects = append(g.objects, Object{})
+	copy(g.objects[i+1:], g.objects[i:])
+	g.objects[i] = copied
+	g.updateDense()
+}
+
+// Referrers implements ReferrerGraph, caching until the next AddObject
+func (g *CompactGraph) Referrers(id ObjID) []ObjID {
+	g.revMu.Lock()
+	defer g.revMu.Unlock()
+	if g.revStart == nil {
+		g.buildReverse()
+	}
+	i, ok := g.index(id)
+	if !ok {
+		return nil
+	}
+	return g.revEdges[g.revStart[i]:g.revStart[i+1]:g.revStart[i+1]]
+}
+
+// buildReverse fills revStart and revEdges. Sources are visited in ID
+// order, so each referrer list comes out sorted; a source pointing at an
+// object more than once is listed once.
+func (g *CompactGraph) buildReverse() {
+	n := len(g.objects)
+	start := make([]int, n+1)
+	last := make([]int, n) // 1 + the last source counted for each target
+	for i := range g.objects {
+		for _, ptr := range g.objects[i].Ptrs {
+			if j, ok := g.index(ptr); ok && last[j] != i+1 {
+				last[j] = i + 1
+				start[j+1]++
+			}
+		}
+	}
+	for j := 0; j < n; j++ {
+		start[j+1] += start[j]
+	}
+
+	edges := make([]ObjID, start[n])
+	next := make([]int, n)
+	copy(next, start[:n])
+	for i := range g.objects {
+		for _, ptr := range g.objects[i].Ptrs {
+			j, ok := g.index(ptr)
+			if !ok || (next[j] > start[j] && edges[next[j]-1] == g.objects[i].ID) {
+				continue
+			}
+			edges[next[j]] = g.objects[i].ID
+			next[j]++
+		}
+	}
+	g.revStart, g.revEdges = start, edges
+}
+
+// GetObject retrieves an object by ID
+func (g *CompactGraph) GetObject(id ObjID) *Object {
+	if i, ok := g.index(id); ok {
+		return &g.objects[i]
+	}
+	return nil
+}
+
+// NumObjects returns the total number of objects
+func (g *CompactGraph) NumObjects() int {
+	return len(g.objects)
+}
+
+// NumEdges returns the total number of pointers held by all objects
+func (g *CompactGraph) NumEdges() int {
+	return g.numEdges
+}
+
+// ForEachObject iterates over all objects in ascending ID order
+func (g *CompactGraph) ForEachObject(fn func(*Object)) {
+	for i := range g.objects {
+		fn(&g.objects[i])
+	}
+}
+
+// SetRoots sets the GC roots
+func (g *CompactGraph) SetRoots(roots Roots) {
+	g.checkMutable()
+	g.roots = Roots{
+		IDs:        append([]ObjID(nil), roots.IDs...),
+		Kinds:      append([]RootKind(nil), roots.Kinds...),
+		Descs:      append([]string(nil), roots.Descs...),
+		Goroutines: append([]uint64(nil), roots.Goroutines...),
+	}
+}
+
+// GetRoots returns the GC roots
+func (g *CompactGraph) GetRoots() Roots {
+	return g.roots
+}
+
+// checkMutable panics if g is a frozen snapshot
+func (g *CompactGraph) checkMutable() {
+	if g.frozen {
+		panic("graph: cannot modify a frozen snapshot")
+	}
+}
+
+// denseGraph exposes the existing ordering to the dominator computation,
+// avoiding the ID index map needed for other graphs
+func (g *CompactGraph) denseGraph(roots Roots) *denseGraph {
+	n := len(g.objects) + 1
+	d := &denseGraph{
+		ids:  make([]ObjID, n),
+		objs: make([]*Object, n),
+	}
+	for i := range g.objects {
+		d.ids[i+1] = g.objects[i].ID
+		d.objs[i+1] = &g.objects[i]
+	}
+
+	d.buildEdges(roots, g.numEdges, func(id ObjID) (int32, bool) {
+		i, ok := g.index(id)
+		return int32(i + 1), ok
+	})
+	return d
+}
@@ -0,0 +1,144 @@
+// ABOUTME: Tests and benchmarks for the slice-backed CompactGraph
+// ABOUTME: Checks it matches MemGraph and compares algorithm performance
+
+package graph
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  []ObjID
+	}{
+		{name: "dense ids", ids: []ObjID{3, 1, 2, 4}},
+		{name: "sparse ids", ids: []ObjID{100, 7, 4096, 12}},
+		{name: "empty", ids: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := NewMemGraph()
+			for i, id := range tt.ids {
+				obj := &Object{ID: id, Type: fmt.Sprintf("t%d", i), Size: uint64(10 * (i + 1))}
+				if i > 0 {
+					obj.Ptrs = []ObjID{tt.ids[i-1], 999999}
+				}
+				mem.AddObject(obj)
+			}
+			mem.SetRoots(Roots{IDs: tt.ids})
+
+			cg := Compact(mem)
+			if cg.NumObjects() != mem.NumObjects() {
+				t.Fatalf("NumObjects() = %d, want %d", cg.NumObjects(), mem.NumObjects())
+			}
+			if !reflect.DeepEqual(cg.GetRoots(), mem.GetRoots()) {
+				t.Errorf("GetRoots() = %v, want %v", cg.GetRoots(), mem.GetRoots())
+			}
+
+			var prev ObjID
+			cg.ForEachObject(func(obj *Object) {
+				if obj.ID < prev {
+					t.Errorf("ForEachObject not in ID order: %d after %d", obj.ID, prev)
+				}
+				prev = obj.ID
+				if want := mem.GetObject(obj.ID); !reflect.DeepEqual(obj, want) {
+					t.Errorf("object %d = %+v, want %+v", obj.ID, obj, want)
+				}
+			})
+
+			for _, missing := range []ObjID{0, 5, 999999} {
+				if mem.GetObject(missing) == nil && cg.GetObject(missing) != nil {
+					t.Errorf("GetObject(%d) should be nil", missing)
+				}
+			}
+		})
+	}
+}
+
+func TestCompactAddObject(t *testing.T) {
+	cg := Compact(NewMemGraph())
+	for _, id := range []ObjID{5, 6, 3, 10} {
+		cg.AddObject(&Object{ID: id, Type: "node", Ptrs: []ObjID{id + 1}})
+	}
+	cg.AddObject(&Object{ID: 6, Type: "replaced"})
+
+	var ids []ObjID
+	cg.ForEachObject(func(obj *Object) {
+		ids = append(ids, obj.ID)
+	})
+	if want := []ObjID{3, 5, 6, 10}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+	if obj := cg.GetObject(6); obj == nil || obj.Type != "replaced" || len(obj.Ptrs) != 0 {
+		t.Errorf("GetObject(6) = %+v, want replaced object", obj)
+	}
+	if obj := cg.GetObject(10); obj == nil || !reflect.DeepEqual(obj.Ptrs, []ObjID{11}) {
+		t.Errorf("GetObject(10) = %+v", obj)
+	}
+}
+
+// TestCompactAlgorithmsMatch checks that the dominator-based algorithms give
+// the same answers on both graph implementations
+func TestCompactAlgorithmsMatch(t *testing.T) {
+	mem := benchmarkGraph(500)
+	cg := Compact(mem)
+
+	if !reflect.DeepEqual(Dominators(cg), Dominators(mem)) {
+		t.Error("Dominators differ between MemGraph and CompactGraph")
+	}
+	if !reflect.DeepEqual(RetainedSize(cg), RetainedSize(mem)) {
+		t.Error("RetainedSize differs between MemGraph and CompactGraph")
+	}
+}
+
+// benchmarkGraph builds a binary tree with back edges to each parent
+func benchmarkGraph(n int) *MemGraph {
+	g := NewMemGraph()
+	for i := 1; i <= n; i++ {
+		obj := &Object{ID: ObjID(i), Type: "node", Size: uint64(16 + i%64)}
+		if i > 1 {
+			obj.Ptrs = append(obj.Ptrs, ObjID(i/2))
+		}
+		if i*2 <= n {
+			obj.Ptrs = append(obj.Ptrs, ObjID(i*2))
+		}
+		if i*2+1 <= n {
+			obj.Ptrs = append(obj.Ptrs, ObjID(i*2+1))
+		}
+		g.AddObject(obj)
+	}
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+	return g
+}
+
+func BenchmarkGraphImplementations(b *testing.B) {
+	for _, n := range []int{10000, 100000} {
+		mem := benchmarkGraph(n)
+		graphs := []struct {
+			name string
+			g    Graph
+		}{
+			{name: "MemGraph", g: mem},
+			{name: "CompactGraph", g: Compact(mem)},
+		}
+
+		for _, impl := range graphs {
+			b.Run(fmt.Sprintf("Dominators/%s/n=%d", impl.name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					_ = Dominators(impl.g)
+				}
+			})
+			b.Run(fmt.Sprintf("RetainedSize/%s/n=%d", impl.name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					_ = RetainedSize(impl.g)
+				}
+			})
+		}
+	}
+}
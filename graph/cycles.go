@@ -0,0 +1,158 @@
+// ABOUTME: Retained size that treats reference cycles as single units
+// ABOUTME: Contracts strongly connected components before dominator analysis
+
+package graph
+
+import "math/bits"
+
+// RetainedSizeCycleAware is like RetainedSize, but first contracts every
+// strongly connected component (a set of objects that all reach each other,
+// such as a doubly linked list) into a single node. Dominators and retained
+// sizes are computed on that acyclic condensation, where a cycle is retained
+// as a whole or not at all.
+//
+// A component's retained size is then split among its members in
+// proportion to their own sizes (equally if they are all zero bytes), with
+// any rounding remainder going to the lowest ID. Members of a component
+// therefore sum to exactly what the component retains, so a cycle is never
+// counted once per member. Objects outside cycles get the same value as
+// from RetainedSize. Unreachable objects are left out.
+func RetainedSizeCycleAware(g Graph) map[ObjID]uint64 {
+	d := newDenseGraph(g)
+	comp, count := d.components()
+
+	// Build the condensation with component c as object c+1
+	members := make([][]int32, count)
+	cond := NewMemGraph()
+	for c := 0; c < count; c++ {
+		cond.AddObject(&Object{ID: ObjID(c + 1)})
+	}
+	for v := 1; v < len(d.ids); v++ {
+		c := comp[v]
+		if c < 0 {
+			continue
+		}
+		members[c] = append(members[c], int32(v))
+		obj := cond.GetObject(ObjID(c + 1))
+		obj.Size += d.objs[v].Size
+		for _, w := range d.succ[d.start[v]:d.start[v+1]] {
+			if comp[w] != c {
+				obj.Ptrs = append(obj.Ptrs, ObjID(comp[w]+1))
+			}
+		}
+	}
+	var roots Roots
+	for _, w := range d.succ[d.start[0]:d.start[1]] {
+		roots.IDs = append(roots.IDs, ObjID(comp[w]+1))
+	}
+	cond.SetRoots(roots)
+
+	result := make(map[ObjID]uint64)
+	for id, retained := range RetainedSize(cond) {
+		d.distribute(members[id-1], retained, result)
+	}
+	return result
+}
+
+// distribute splits retained among the member indexes in proportion to
+// their sizes, giving the rounding remainder to the lowest ID
+func (d *denseGraph) distribute(members []int32, retained uint64, result map[ObjID]uint64) {
+	var total uint64
+	lowest := members[0]
+	for _, v := range members {
+		total += d.objs[v].Size
+		if d.ids[v] < d.ids[lowest] {
+			lowest = v
+		}
+	}
+
+	var given uint64
+	for _, v := range members {
+		var share uint64
+		if total == 0 {
+			share = retained / uint64(len(members))
+		} else {
+			// retained*size/total without overflowing; size <= total so
+			// the quotient fits
+			hi, lo := bits.Mul64(retained, d.objs[v].Size)
+			share, _ = bits.Div64(hi, lo, total)
+		}
+		result[d.ids[v]] = share
+		given += share
+	}
+	result[d.ids[lowest]] += retained - given
+}
+
+// components labels the strongly connected components reachable from the
+// super-root using an iterative Tarjan's algorithm. comp[v] is the
+// component of index v, or -1 if v is unreachable. The super-root is always
+// a component of its own.
+func (d *denseGraph) components() (comp []int32, count int) {
+	n := len(d.ids)
+	index := make([]int32, n) // DFS discovery order, -1 if unvisited
+	low := make([]int32, n)
+	onStack := make([]bool, n)
+	comp = make([]int32, n)
+	for i := range index {
+		index[i] = -1
+		comp[i] = -1
+	}
+
+	type frame struct {
+		v    int32
+		next int
+	}
+	var (
+		next  int32
+		stack []int32 // Tarjan's component stack
+		calls []frame // DFS call stack
+	)
+	visit := func(v int32) {
+		index[v], low[v] = next, next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+		calls = append(calls, frame{v: v, next: d.start[v]})
+	}
+
+	visit(0)
+	for len(calls) > 0 {
+		f := &calls[len(calls)-1]
+		v := f.v
+		if f.next < d.start[v+1] {
+			w := d.succ[f.next]
+			f.next++
+			if index[w] < 0 {
+				visit(w)
+			} else if onStack[w] {
+				low[v] = min(low[v], index[w])
+			}
+			continue
+		}
+
+		calls = calls[:len(calls)-1]
+		if len(calls) > 0 {
+			p := calls[len(calls)-1].v
+			low[p] = min(low[p], low[v])
+		}
+		if low[v] != index[v] {
+			continue
+		}
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			if w == 0 {
+				break // the super-root is not an object
+			}
+			comp[w] = int32(count)
+			if w == v {
+				break
+			}
+		}
+		if v != 0 {
+			count++
+		}
+	}
+	return comp, count
+}
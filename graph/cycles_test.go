@@ -0,0 +1,71 @@
+// ABOUTME: Tests for cycle-aware retained sizes
+// ABOUTME: Covers linked cycles, proportional splits, and acyclic agreement
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRetainedSizeCycleAware(t *testing.T) {
+	// 1 (root) -> 2 <-> 3 -> 4, a two-node cycle holding a leaf; 5 is garbage
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Size: 10, Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "node", Size: 30, Ptrs: []ObjID{3}})
+	g.AddObject(&Object{ID: 3, Type: "node", Size: 10, Ptrs: []ObjID{2, 4}})
+	g.AddObject(&Object{ID: 4, Type: "leaf", Size: 40})
+	g.AddObject(&Object{ID: 5, Type: "garbage", Size: 99, Ptrs: []ObjID{2}})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	// The cycle retains 30+10+40 = 80, split 3:1 by member size
+	want := map[ObjID]uint64{1: 90, 2: 60, 3: 20, 4: 40}
+	for name, graph := range map[string]Graph{"MemGraph": g, "CompactGraph": Compact(g)} {
+		if got := RetainedSizeCycleAware(graph); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: RetainedSizeCycleAware() = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestRetainedSizeCycleAwareRemainder(t *testing.T) {
+	// Three zero-size objects in a cycle with a 10 byte leaf: 10/3 each
+	// with the remainder going to the lowest ID
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "a", Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "b", Ptrs: []ObjID{3}})
+	g.AddObject(&Object{ID: 3, Type: "c", Ptrs: []ObjID{1, 4}})
+	g.AddObject(&Object{ID: 4, Type: "leaf", Size: 10})
+	g.SetRoots(Roots{IDs: []ObjID{2}})
+
+	want := map[ObjID]uint64{1: 4, 2: 3, 3: 3, 4: 10}
+	if got := RetainedSizeCycleAware(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("RetainedSizeCycleAware() = %v, want %v", got, want)
+	}
+}
+
+func TestRetainedSizeCycleAwareSumsToHeap(t *testing.T) {
+	// Every node is in one big cycle through the parent back edges
+	g := benchmarkGraph(1000)
+	var sum uint64
+	for _, size := range RetainedSizeCycleAware(g) {
+		sum += size
+	}
+	if total := TotalSize(g); sum != total {
+		t.Errorf("retained sizes sum to %d, want %d", sum, total)
+	}
+}
+
+func TestRetainedSizeCycleAwareMatchesAcyclic(t *testing.T) {
+	// 1 (root) -> 2, 3; 2 -> 4; 3 -> 4; 4 -> 5
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "a", Size: 1, Ptrs: []ObjID{2, 3}})
+	g.AddObject(&Object{ID: 2, Type: "b", Size: 2, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 3, Type: "c", Size: 4, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 4, Type: "d", Size: 8, Ptrs: []ObjID{5}})
+	g.AddObject(&Object{ID: 5, Type: "e", Size: 16})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	if got, want := RetainedSizeCycleAware(g), RetainedSize(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("RetainedSizeCycleAware() = %v, want RetainedSize() = %v", got, want)
+	}
+}
@@ -0,0 +1,105 @@
+// ABOUTME: In-degree and out-degree statistics for heap objects
+// ABOUTME: Helps spot hub objects with huge fan-out or many referrers
+
+package graph
+
+import "sort"
+
+// Degree counts the pointers into and out of an object
+type Degree struct {
+	In  int // Number of pointers to this object
+	Out int // Number of pointers held by this object
+}
+
+// DegreeStats computes the in-degree and out-degree of every object.
+// Duplicate pointers count once per occurrence, matching Ptrs.
+func DegreeStats(g Graph) map[ObjID]Degree {
+	stats := make(map[ObjID]Degree, g.NumObjects())
+	g.ForEachObject(func(obj *Object) {
+		stats[obj.ID] = Degree{Out: len(obj.Ptrs)}
+	})
+
+	for id, referrers := range BuildReverseEdges(g) {
+		if d, ok := stats[id]; ok {
+			d.In = len(referrers)
+			stats[id] = d
+		}
+	}
+
+	return stats
+}
+
+// NumEdges returns the total number of pointers held by all objects,
+// counting duplicates once per occurrence. MemGraph and CompactGraph answer
+// without a scan once counted; other graphs are scanned.
+func NumEdges(g Graph) int {
+	switch g := g.(type) {
+	case *CompactGraph:
+		return g.NumEdges()
+	case *MemGraph:
+		return g.NumEdges()
+	}
+	n := 0
+	g.ForEachObject(func(obj *Object) {
+		n += len(obj.Ptrs)
+	})
+	return n
+}
+
+// EdgeDensity returns the average number of pointers per object, a measure
+// of how sparse the graph is, or 0 for an empty graph
+func EdgeDensity(g Graph) float64 {
+	n := g.NumObjects()
+	if n == 0 {
+		return 0
+	}
+	return float64(NumEdges(g)) / float64(n)
+}
+
+// TypeFanout returns the average number of outgoing pointers per object
+// of each type. A type averaging thousands is usually a large map or slice
+// of pointers. Duplicate pointers count once per occurrence.
+func TypeFanout(g Graph) map[string]float64 {
+	type acc struct{ ptrs, count int }
+	byType := make(map[string]*acc)
+	g.ForEachObject(func(obj *Object) {
+		a, ok := byType[obj.Type]
+		if !ok {
+			a = &acc{}
+			byType[obj.Type] = a
+		}
+		a.ptrs += len(obj.Ptrs)
+		a.count++
+	})
+
+	fanout := make(map[string]float64, len(byType))
+	for typ, a := range byType {
+		fanout[typ] = float64(a.ptrs) / float64(a.count)
+	}
+	return fanout
+}
+
+// TopByInDegree returns the n most referenced objects, most referenced
+// first. Ties are ordered by ID.
+func TopByInDegree(g Graph, n int) []ObjID {
+	if n <= 0 {
+		return nil
+	}
+
+	stats := DegreeStats(g)
+	ids := make([]ObjID, 0, len(stats))
+	for id := range stats {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if stats[ids[i]].In != stats[ids[j]].In {
+			return stats[ids[i]].In > stats[ids[j]].In
+		}
+		return ids[i] < ids[j]
+	})
+
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+	return ids
+}
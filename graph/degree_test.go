@@ -0,0 +1,79 @@
+// ABOUTME: Tests for object in-degree and out-degree statistics
+// ABOUTME: Verifies hub detection on a small fan-in graph
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDegreeStats(t *testing.T) {
+	// 1 -> 2, 3, 4; 2 -> 4; 3 -> 4, 99 (dangling)
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Ptrs: []ObjID{2, 3, 4}})
+	g.AddObject(&Object{ID: 2, Type: "a", Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 3, Type: "b", Ptrs: []ObjID{4, 99}})
+	g.AddObject(&Object{ID: 4, Type: "cache"})
+
+	want := map[ObjID]Degree{
+		1: {In: 0, Out: 3},
+		2: {In: 1, Out: 1},
+		3: {In: 1, Out: 2},
+		4: {In: 3, Out: 0},
+	}
+	if got := DegreeStats(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("DegreeStats() = %v, want %v", got, want)
+	}
+
+	if got, want := TopByInDegree(g, 3), []ObjID{4, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TopByInDegree() = %v, want %v", got, want)
+	}
+	if got := TopByInDegree(g, 0); got != nil {
+		t.Errorf("TopByInDegree(0) = %v, want nil", got)
+	}
+}
+
+func TestNumEdges(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Ptrs: []ObjID{2, 3, 3}})
+	g.AddObject(&Object{ID: 2, Ptrs: []ObjID{1}})
+	g.AddObject(&Object{ID: 3})
+	cg := Compact(g)
+
+	for name, graph := range map[string]Graph{"MemGraph": g, "CompactGraph": cg, "scan": scanGraph{g}} {
+		if got := NumEdges(graph); got != 4 {
+			t.Errorf("%s: NumEdges() = %d, want 4", name, got)
+		}
+	}
+	if got := EdgeDensity(g); got != 4.0/3 {
+		t.Errorf("EdgeDensity() = %v, want %v", got, 4.0/3)
+	}
+	if got := EdgeDensity(NewMemGraph()); got != 0 {
+		t.Errorf("EdgeDensity(empty) = %v, want 0", got)
+	}
+
+	// Adding and replacing objects keeps both counts current
+	g.AddObject(&Object{ID: 3, Ptrs: []ObjID{1, 2}})
+	cg.AddObject(&Object{ID: 3, Ptrs: []ObjID{1, 2}})
+	cg.AddObject(&Object{ID: 1, Ptrs: []ObjID{2}})
+	if got := NumEdges(g); got != 6 {
+		t.Errorf("MemGraph after AddObject: NumEdges() = %d, want 6", got)
+	}
+	if got := NumEdges(cg); got != 4 {
+		t.Errorf("CompactGraph after AddObject: NumEdges() = %d, want 4", got)
+	}
+}
+
+func TestTypeFanout(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "map", Ptrs: []ObjID{2, 3, 4, 4}})
+	g.AddObject(&Object{ID: 2, Type: "node", Ptrs: []ObjID{3}})
+	g.AddObject(&Object{ID: 3, Type: "node", Ptrs: []ObjID{4, 2}})
+	g.AddObject(&Object{ID: 4, Type: "leaf"})
+
+	want := map[string]float64{"map": 4, "node": 1.5, "leaf": 0}
+	if got := TypeFanout(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("TypeFanout() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,27 @@
+// ABOUTME: Compares two heap snapshots to find what changed between them
+// ABOUTME: Reports per-type deltas so growing footprints stand out
+
+package graph
+
+// RetainedDiff returns the change in retained bytes per type between two
+// snapshots, matched by type name. Positive values mean the type retains more
+// memory in after than in before; types that shrank get negative deltas.
+// Types whose retained size did not change are omitted.
+func RetainedDiff(before, after Graph) map[string]int64 {
+	beforeSizes := RetainedSizeByType(before)
+	afterSizes := RetainedSizeByType(after)
+
+	diff := make(map[string]int64)
+	for typeName, size := range afterSizes {
+		if delta := int64(size) - int64(beforeSizes[typeName]); delta != 0 {
+			diff[typeName] = delta
+		}
+	}
+	for typeName, size := range beforeSizes {
+		if _, ok := afterSizes[typeName]; !ok && size != 0 {
+			diff[typeName] = -int64(size)
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,51 @@
+// ABOUTME: Tests for comparing heap snapshots
+// ABOUTME: Verifies per-type retained deltas including shrinking types
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRetainedDiff(t *testing.T) {
+	before := NewMemGraph()
+	before.AddObject(&Object{ID: 1, Type: "cache", Size: 10, Ptrs: []ObjID{2}})
+	before.AddObject(&Object{ID: 2, Type: "entry", Size: 100})
+	before.AddObject(&Object{ID: 3, Type: "pool", Size: 500})
+	before.AddObject(&Object{ID: 4, Type: "config", Size: 30})
+	before.SetRoots(Roots{IDs: []ObjID{1, 3, 4}})
+
+	after := NewMemGraph()
+	after.AddObject(&Object{ID: 1, Type: "cache", Size: 10, Ptrs: []ObjID{2, 3}})
+	after.AddObject(&Object{ID: 2, Type: "entry", Size: 100})
+	after.AddObject(&Object{ID: 3, Type: "entry", Size: 100})
+	after.AddObject(&Object{ID: 4, Type: "pool", Size: 200})
+	after.AddObject(&Object{ID: 5, Type: "config", Size: 30})
+	after.SetRoots(Roots{IDs: []ObjID{1, 4, 5}})
+
+	got := RetainedDiff(before, after)
+	want := map[string]int64{
+		"cache": 100,  // 110 -> 210
+		"entry": 100,  // 100 -> 200
+		"pool":  -300, // 500 -> 200
+		// config unchanged, omitted
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RetainedDiff() = %v, want %v", got, want)
+	}
+}
+
+func TestRetainedDiffRemovedType(t *testing.T) {
+	before := NewMemGraph()
+	before.AddObject(&Object{ID: 1, Type: "legacy", Size: 64})
+	before.SetRoots(Roots{IDs: []ObjID{1}})
+
+	after := NewMemGraph()
+
+	got := RetainedDiff(before, after)
+	if got["legacy"] != -64 {
+		t.Errorf("expected legacy delta -64, got %v", got)
+	}
+}
@@ -0,0 +1,35 @@
+// ABOUTME: Breadth-first distance from GC roots to every reachable object
+// ABOUTME: Separates objects held directly by roots from deeply nested ones
+
+package graph
+
+// RootDistance returns how many pointer hops each reachable object is from
+// its nearest root, using a multi-source BFS over forward edges. Roots have
+// distance 0. Unreachable objects are left out of the map.
+func RootDistance(g Graph) map[ObjID]int {
+	dist := make(map[ObjID]int)
+
+	var queue []ObjID
+	for _, id := range g.GetRoots().IDs {
+		if _, seen := dist[id]; seen || g.GetObject(id) == nil {
+			continue
+		}
+		dist[id] = 0
+		queue = append(queue, id)
+	}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		for _, ptr := range g.GetObject(id).Ptrs {
+			if _, seen := dist[ptr]; seen || g.GetObject(ptr) == nil {
+				continue
+			}
+			dist[ptr] = dist[id] + 1
+			queue = append(queue, ptr)
+		}
+	}
+
+	return dist
+}
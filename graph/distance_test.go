@@ -0,0 +1,65 @@
+// ABOUTME: Tests for BFS distance from roots
+// ABOUTME: Uses the chain and diamond topologies from the dominator tests
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRootDistance(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph Graph
+		want  map[ObjID]int
+	}{
+		{
+			name: "simple chain",
+			graph: func() Graph {
+				g := NewMemGraph()
+				g.AddObject(&Object{ID: 1, Type: "root", Ptrs: []ObjID{2}})
+				g.AddObject(&Object{ID: 2, Type: "middle", Ptrs: []ObjID{3}})
+				g.AddObject(&Object{ID: 3, Type: "leaf"})
+				g.SetRoots(Roots{IDs: []ObjID{1}})
+				return g
+			}(),
+			want: map[ObjID]int{1: 0, 2: 1, 3: 2},
+		},
+		{
+			name: "diamond",
+			graph: func() Graph {
+				g := NewMemGraph()
+				g.AddObject(&Object{ID: 1, Type: "root", Ptrs: []ObjID{2, 3}})
+				g.AddObject(&Object{ID: 2, Type: "left", Ptrs: []ObjID{4}})
+				g.AddObject(&Object{ID: 3, Type: "right", Ptrs: []ObjID{4}})
+				g.AddObject(&Object{ID: 4, Type: "bottom"})
+				g.SetRoots(Roots{IDs: []ObjID{1}})
+				return g
+			}(),
+			want: map[ObjID]int{1: 0, 2: 1, 3: 1, 4: 2},
+		},
+		{
+			name: "nearest of several roots",
+			graph: func() Graph {
+				g := NewMemGraph()
+				g.AddObject(&Object{ID: 1, Type: "root1", Ptrs: []ObjID{2}})
+				g.AddObject(&Object{ID: 2, Type: "a", Ptrs: []ObjID{3}})
+				g.AddObject(&Object{ID: 3, Type: "b", Ptrs: []ObjID{1}})
+				g.AddObject(&Object{ID: 4, Type: "root2", Ptrs: []ObjID{3}})
+				g.AddObject(&Object{ID: 5, Type: "garbage", Ptrs: []ObjID{1}})
+				g.SetRoots(Roots{IDs: []ObjID{1, 4}})
+				return g
+			}(),
+			want: map[ObjID]int{1: 0, 2: 1, 3: 1, 4: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RootDistance(tt.graph); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RootDistance() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
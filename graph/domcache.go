@@ -0,0 +1,130 @@
+// ABOUTME: Binary serialization of a dominator analysis for caching next to a graph
+// ABOUTME: Keys the encoding to a fingerprint of the graph so a stale cache is rejected
+
+package graph
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// domEncodingMagic starts every encoded DomInfo and carries its format version
+const domEncodingMagic = "heaplens dominators 1\n"
+
+// ErrStaleDominators is returned by DecodeDominators for an encoding made
+// from a different graph
+var ErrStaleDominators = errors.New("dominators were computed for a different graph")
+
+// Encode writes di in a compact binary format that DecodeDominators reads
+// back without rerunning the analysis. Only the DFS order and immediate
+// dominators are written; Tree and Depth are rebuilt from them. The
+// encoding is keyed to a fingerprint of the graph's edges and roots.
+func (di *DomInfo) Encode(w io.Writer) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+	e.w.WriteString(domEncodingMagic)
+	var fp [8]byte
+	binary.LittleEndian.PutUint64(fp[:], dominatorFingerprint(di.g))
+	e.w.Write(fp[:])
+
+	// Objects follow in DFS order after the super-root, each with the
+	// distance back to its dominator, which always comes earlier
+	pos := make([]int, len(di.d.ids))
+	for i, v := range di.order {
+		pos[v] = i
+	}
+	e.uvarint(uint64(len(di.order) - 1))
+	var prev ObjID
+	for i, v := range di.order[1:] {
+		id := di.d.ids[v]
+		e.varint(int64(id - prev))
+		prev = id
+		e.uvarint(uint64(i + 1 - pos[di.idom[v]]))
+	}
+
+	if err := e.w.Flush(); err != nil {
+		return fmt.Errorf("encoding dominators: %w", err)
+	}
+	return nil
+}
+
+// DecodeDominators reads a DomInfo written by DomInfo.Encode for g. It
+// fails with ErrStaleDominators if the encoding was made from a graph
+// whose objects, pointers, or roots differ from g's.
+func DecodeDominators(r io.Reader, g Graph) (*DomInfo, error) {
+	d := &decoder{r: bufio.NewReader(r)}
+	magic := make([]byte, len(domEncodingMagic))
+	if _, err := io.ReadFull(d.r, magic); err != nil || string(magic) != domEncodingMagic {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidEncoding)
+	}
+	fp := d.read(8)
+	if d.err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEncoding, d.err)
+	}
+	if binary.LittleEndian.Uint64(fp) != dominatorFingerprint(g) {
+		return nil, ErrStaleDominators
+	}
+
+	// Lay the dense graph out in DFS order, so positions in the encoding
+	// are dense indexes. Retained sizes only need the objects, so the
+	// edges are left out.
+	dense := &denseGraph{ids: []ObjID{0}, objs: []*Object{nil}}
+	idom := []int32{0}
+	order := []int32{0}
+	var id ObjID
+	for i, n := 1, d.length(); i <= n && d.err == nil; i++ {
+		id += ObjID(d.varint())
+		back := d.uvarint()
+		obj := g.GetObject(id)
+		if d.err == nil && (obj == nil || back == 0 || back > uint64(i)) {
+			d.fail(fmt.Errorf("entry %d: bad object %d or dominator", i, id))
+		}
+		dense.ids = append(dense.ids, id)
+		dense.objs = append(dense.objs, obj)
+		idom = append(idom, int32(uint64(i)-back))
+		order = append(order, int32(i))
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEncoding, d.err)
+	}
+
+	di := newDomInfo(g, dense, idom, order)
+	if len(di.Idom) != len(order)-1 {
+		return nil, fmt.Errorf("%w: repeated objects", ErrInvalidEncoding)
+	}
+	return di, nil
+}
+
+// dominatorFingerprint hashes everything the dominator analysis depends
+// on: each object's ID and pointers, and the roots. Objects are hashed
+// separately and summed, so the graph's iteration order doesn't matter and
+// no sort is needed.
+func dominatorFingerprint(g Graph) uint64 {
+	var sum uint64
+	g.ForEachObject(func(obj *Object) {
+		h := fnvAdd(fnvOffset, uint64(obj.ID))
+		for _, ptr := range obj.Ptrs {
+			h = fnvAdd(h, uint64(ptr))
+		}
+		sum += fnvAdd(h, uint64(len(obj.Ptrs)))
+	})
+
+	h := fnvAdd(fnvOffset, sum)
+	h = fnvAdd(h, uint64(g.NumObjects()))
+	for _, id := range g.GetRoots().IDs {
+		h = fnvAdd(h, uint64(id))
+	}
+	return h
+}
+
+// FNV-1a over 64-bit words rather than bytes
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+func fnvAdd(h, v uint64) uint64 {
+	return (h ^ v) * fnvPrime
+}
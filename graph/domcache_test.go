@@ -0,0 +1,119 @@
+// ABOUTME: Tests for caching a dominator analysis alongside its graph
+// ABOUTME: Round-trips DomInfo, rejects caches for a changed graph, and rejects corrupt input
+
+package graph
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDominatorsRoundTrip(t *testing.T) {
+	g := benchmarkGraph(1000)
+	for name, tg := range map[string]Graph{"MemGraph": g, "CompactGraph": Compact(g)} {
+		di := ComputeDominators(tg)
+		var buf bytes.Buffer
+		if err := di.Encode(&buf); err != nil {
+			t.Fatalf("%s: Encode() error = %v", name, err)
+		}
+		decoded, err := DecodeDominators(&buf, tg)
+		if err != nil {
+			t.Fatalf("%s: DecodeDominators() error = %v", name, err)
+		}
+
+		if !reflect.DeepEqual(decoded.Idom, di.Idom) || !reflect.DeepEqual(decoded.Order, di.Order) {
+			t.Errorf("%s: decoded Idom or Order differ", name)
+		}
+		if !reflect.DeepEqual(decoded.Tree, di.Tree) || !reflect.DeepEqual(decoded.Depth, di.Depth) {
+			t.Errorf("%s: decoded Tree or Depth differ", name)
+		}
+		if !reflect.DeepEqual(decoded.RetainedSize(), di.RetainedSize()) {
+			t.Errorf("%s: decoded RetainedSize() differs", name)
+		}
+	}
+}
+
+func TestDecodeDominatorsRejectsChangedGraph(t *testing.T) {
+	build := func() *MemGraph {
+		g := NewMemGraph()
+		g.AddObject(&Object{ID: 1, Type: "A", Size: 8, Ptrs: []ObjID{2, 3}})
+		g.AddObject(&Object{ID: 2, Type: "B", Size: 8, Ptrs: []ObjID{3}})
+		g.AddObject(&Object{ID: 3, Type: "C", Size: 8})
+		g.SetRoots(Roots{IDs: []ObjID{1}})
+		return g
+	}
+	var buf bytes.Buffer
+	if err := ComputeDominators(build()).Encode(&buf); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	encoded := buf.Bytes()
+
+	// Types and sizes don't affect dominators, so changing them is fine
+	same := build()
+	same.GetObject(3).Size = 100
+	same.GetObject(3).Type = "D"
+	if _, err := DecodeDominators(bytes.NewReader(encoded), same); err != nil {
+		t.Errorf("DecodeDominators(resized graph) error = %v", err)
+	}
+
+	changes := map[string]func(g *MemGraph){
+		"pointer removed": func(g *MemGraph) { g.GetObject(1).Ptrs = []ObjID{2} },
+		"pointer added":   func(g *MemGraph) { g.GetObject(3).Ptrs = []ObjID{1} },
+		"object added":    func(g *MemGraph) { g.AddObject(&Object{ID: 4, Type: "D"}) },
+		"root added":      func(g *MemGraph) { g.SetRoots(Roots{IDs: []ObjID{1, 3}}) },
+	}
+	for name, change := range changes {
+		g := build()
+		change(g)
+		if _, err := DecodeDominators(bytes.NewReader(encoded), g); !errors.Is(err, ErrStaleDominators) {
+			t.Errorf("%s: DecodeDominators() error = %v, want ErrStaleDominators", name, err)
+		}
+	}
+}
+
+func TestDecodeDominatorsRejectsCorruptInput(t *testing.T) {
+	g := benchmarkGraph(50)
+	var buf bytes.Buffer
+	if err := ComputeDominators(g).Encode(&buf); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	data := buf.Bytes()
+
+	for n := 0; n < len(data); n++ {
+		if _, err := DecodeDominators(bytes.NewReader(data[:n]), g); !errors.Is(err, ErrInvalidEncoding) {
+			t.Fatalf("DecodeDominators(first %d bytes) error = %v, want ErrInvalidEncoding", n, err)
+		}
+	}
+
+	// An object claiming to be its own dominator
+	bad := append([]byte(nil), data[:len(domEncodingMagic)+8]...)
+	bad = append(bad, 1, 2, 0)
+	if _, err := DecodeDominators(bytes.NewReader(bad), g); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("DecodeDominators(bad dominator) error = %v, want ErrInvalidEncoding", err)
+	}
+}
+
+// BenchmarkDominatorsCache compares computing dominators with decoding a
+// cached analysis of the same graph
+func BenchmarkDominatorsCache(b *testing.B) {
+	g := benchmarkGraph(100000)
+	var buf bytes.Buffer
+	if err := ComputeDominators(g).Encode(&buf); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("compute", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ComputeDominators(g)
+		}
+	})
+	b.Run("decode", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := DecodeDominators(bytes.NewReader(buf.Bytes()), g); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -2,184 +2,307 @@
 // ABOUTME: Provides O(E α(E,V)) time complexity for finding immediate dominators
 package graph
 
+import "context"
+
 // Dominators computes the immediate dominator for each reachable object in the graph.
 // Uses the Lengauer-Tarjan algorithm for O(E α(E,V)) time complexity.
 // Returns a map from object ID to its immediate dominator ID.
 // The super-root (ID 0) dominates all roots and has no dominator itself.
+// Pointers and roots that name objects missing from the graph are ignored.
 func Dominators(g Graph) map[ObjID]ObjID {
-	// Build adjacency list for forward traversal
-	adj := make(map[ObjID][]ObjID)
-	allObjects := make([]*Object, 0, g.NumObjects())
+	d := newDenseGraph(g)
+	idom, order := d.dominators()
+	return d.idomMap(idom, order)
+}
+
+// DominatorsContext is like Dominators but stops early with ctx's error if
+// ctx is canceled. If progress is not nil, it is called periodically with
+// the steps done so far out of total.
+func DominatorsContext(ctx context.Context, g Graph, progress func(done, total int)) (map[ObjID]ObjID, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d := newDenseGraph(g)
+	t := newTracker(ctx, progress, d.dominatorSteps())
+	idom, order, err := d.dominatorsTracked(t)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.skipTo(t.total); err != nil {
+		return nil, err
+	}
+	return d.idomMap(idom, order), nil
+}
+
+// idomMap converts dense immediate dominators to object IDs
+func (d *denseGraph) idomMap(idom, order []int32) map[ObjID]ObjID {
+	result := make(map[ObjID]ObjID, len(order))
+	for _, v := range order[1:] {
+		if d.ids[v] != 0 {
+			result[d.ids[v]] = d.ids[idom[v]]
+		}
+	}
+	return result
+}
+
+// denseGraph renumbers a graph's objects to consecutive indexes so the
+// dominator computation can run on flat arrays instead of maps. Index 0 is
+// the super-root, whose successors are the GC roots.
+type denseGraph struct {
+	ids   []ObjID   // index -> object ID
+	objs  []*Object // index -> object (nil for the super-root)
+	start []int     // successors of v are succ[start[v]:start[v+1]]
+	succ  []int32
+}
+
+func newDenseGraph(g Graph) *denseGraph {
+	return newDenseGraphFrom(g, g.GetRoots())
+}
+
+// newDenseGraphFrom is like newDenseGraph but gives the super-root the
+// successors roots instead of g's own roots
+func newDenseGraphFrom(g Graph, roots Roots) *denseGraph {
+	if cg, ok := g.(*CompactGraph); ok {
+		return cg.denseGraph(roots)
+	}
+
+	n := g.NumObjects() + 1
+	d := &denseGraph{
+		ids:  make([]ObjID, 1, n),
+		objs: make([]*Object, 1, n),
+	}
+	index := make(map[ObjID]int32, n)
 	g.ForEachObject(func(obj *Object) {
-		allObjects = append(allObjects, obj)
+		index[obj.ID] = int32(len(d.ids))
+		d.ids = append(d.ids, obj.ID)
+		d.objs = append(d.objs, obj)
+	})
+
+	d.buildEdges(roots, NumEdges(g), func(id ObjID) (int32, bool) {
+		v, ok := index[id]
+		return v, ok
 	})
-	
-	// Add super-root that points to all roots
-	roots := g.GetRoots()
-	if len(roots.IDs) > 0 {
-		adj[0] = roots.IDs // super-root points to all roots
-	}
-	
-	// Build regular edges
-	for _, obj := range allObjects {
-		if obj.Ptrs != nil {
-			adj[obj.ID] = append([]ObjID{}, obj.Ptrs...)
+	return d
+}
+
+// buildEdges fills the successor lists, using lookup to map object IDs
+// to dense indexes and dropping pointers it cannot resolve. numEdges sizes
+// the successor array up front.
+func (d *denseGraph) buildEdges(roots Roots, numEdges int, lookup func(ObjID) (int32, bool)) {
+	d.start = make([]int, len(d.ids)+1)
+	d.succ = make([]int32, 0, len(roots.IDs)+numEdges)
+	for _, id := range roots.IDs {
+		if w, ok := lookup(id); ok {
+			d.succ = append(d.succ, w)
 		}
 	}
-	
-	// Run DFS to number nodes and build spanning tree
-	var dfsNum int
-	vertex := make([]ObjID, 0, len(allObjects)+1)     // DFS number -> vertex ID
-	parent := make(map[ObjID]int)                     // vertex -> DFS number of parent in spanning tree
-	dfnum := make(map[ObjID]int)                      // vertex -> DFS number
-	semi := make(map[ObjID]int)                       // vertex -> DFS number of semidominator
-	ancestor := make(map[ObjID]int)                   // for link-eval forest
-	idom := make(map[ObjID]ObjID)                     // vertex -> immediate dominator
-	samedom := make(map[ObjID]ObjID)                  // for link-eval forest
-	best := make(map[ObjID]ObjID)                     // for link-eval forest
-	bucket := make(map[int][]ObjID)                   // semidominator -> list of vertices
-	
-	// DFS from super-root
-	var dfs func(v ObjID, p int)
-	dfs = func(v ObjID, p int) {
-		if _, visited := dfnum[v]; visited {
-			return
+	d.start[1] = len(d.succ)
+
+	for v := 1; v < len(d.ids); v++ {
+		for _, ptr := range d.objs[v].Ptrs {
+			if w, ok := lookup(ptr); ok {
+				d.succ = append(d.succ, w)
+			}
 		}
-		
-		dfnum[v] = dfsNum
-		vertex = append(vertex, v)
-		parent[v] = p
-		semi[v] = dfsNum
-		ancestor[v] = -1
-		best[v] = v
-		samedom[v] = v
-		dfsNum++
-		
-		for _, w := range adj[v] {
-			dfs(w, dfnum[v])
+		d.start[v+1] = len(d.succ)
+	}
+}
+
+// predecessors inverts the successor lists into the same layout
+func (d *denseGraph) predecessors() (start []int, pred []int32) {
+	n := len(d.ids)
+	start = make([]int, n+1)
+	for _, w := range d.succ {
+		start[w+1]++
+	}
+	for i := 0; i < n; i++ {
+		start[i+1] += start[i]
+	}
+
+	pred = make([]int32, len(d.succ))
+	next := make([]int, n)
+	copy(next, start[:n])
+	for v := 0; v < n; v++ {
+		for _, w := range d.succ[d.start[v]:d.start[v+1]] {
+			pred[next[w]] = int32(v)
+			next[w]++
 		}
 	}
-	
-	dfs(0, -1) // Start from super-root
-	
-	// Link-eval functions for path compression
-	var compress func(v ObjID)
-	compress = func(v ObjID) {
-		anc := ancestor[v]
-		if anc == -1 {
-			return
+	return start, pred
+}
+
+// dominators runs Lengauer-Tarjan from the super-root. It returns the
+// immediate dominator of every index (-1 if unreachable) and the reachable
+// indexes in DFS preorder, starting with the super-root.
+func (d *denseGraph) dominators() (idom []int32, order []int32) {
+	idom, order, _ = d.dominatorsTracked(nil)
+	return idom, order
+}
+
+// dominatorSteps is the number of steps dominatorsTracked counts: one per
+// index for the DFS and one per index for the semidominator pass
+func (d *denseGraph) dominatorSteps() int {
+	return 2 * len(d.ids)
+}
+
+// dominatorsTracked is dominators with its progress counted by t, which
+// may be nil. It returns early with t's error when t's context is done.
+func (d *denseGraph) dominatorsTracked(t *tracker) (idom []int32, order []int32, err error) {
+	n := len(d.ids)
+	predStart, pred := d.predecessors()
+
+	dfnum := make([]int32, n)    // index -> DFS number, -1 if unvisited
+	parent := make([]int32, n)   // index -> parent in the DFS spanning tree
+	semi := make([]int32, n)     // index -> semidominator
+	ancestor := make([]int32, n) // link-eval forest
+	best := make([]int32, n)     // link-eval forest
+	samedom := make([]int32, n)  // deferred idom, -1 if none
+	bucketHead := make([]int32, n)
+	bucketNext := make([]int32, n)
+	idom = make([]int32, n)
+	for i := range dfnum {
+		dfnum[i] = -1
+		ancestor[i] = -1
+		samedom[i] = -1
+		bucketHead[i] = -1
+		idom[i] = -1
+	}
+
+	// Iterative DFS so deep object chains cannot exhaust the stack
+	order = make([]int32, 0, n)
+	type frame struct {
+		v    int32
+		next int
+	}
+	dfnum[0] = 0
+	parent[0] = -1
+	order = append(order, 0)
+	stack := []frame{{v: 0, next: d.start[0]}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.next == d.start[top.v+1] {
+			stack = stack[:len(stack)-1]
+			continue
 		}
-		ancID := vertex[anc]
-		if ancestor[ancID] != -1 {
-			compress(ancID)
-			if semi[best[ancID]] < semi[best[v]] {
-				best[v] = best[ancID]
-			}
-			ancestor[v] = ancestor[ancID]
+		w := d.succ[top.next]
+		top.next++
+		if dfnum[w] >= 0 {
+			continue
 		}
+		dfnum[w] = int32(len(order))
+		parent[w] = top.v
+		order = append(order, w)
+		stack = append(stack, frame{v: w, next: d.start[w]})
+		if err := t.step(); err != nil {
+			return nil, nil, err
+		}
+	}
+	// Unreachable indexes count as visited
+	if err := t.skipTo(n); err != nil {
+		return nil, nil, err
 	}
-	
-	eval := func(v ObjID) ObjID {
-		if ancestor[v] == -1 {
-			return v
+
+	// eval returns the ancestor of v in the link-eval forest with the
+	// lowest semidominator, compressing the path as it goes
+	var path []int32
+	eval := func(v int32) int32 {
+		path = path[:0]
+		for u := v; ancestor[ancestor[u]] != -1; u = ancestor[u] {
+			path = append(path, u)
+		}
+		for i := len(path) - 1; i >= 0; i-- {
+			u := path[i]
+			a := ancestor[u]
+			if dfnum[semi[best[a]]] < dfnum[semi[best[u]]] {
+				best[u] = best[a]
+			}
+			ancestor[u] = ancestor[a]
 		}
-		compress(v)
 		return best[v]
 	}
-	
-	link := func(v ObjID, w int) {
-		ancestor[v] = w
-	}
-	
+
 	// Process vertices in reverse DFS order
-	for i := dfsNum - 1; i > 0; i-- {
-		w := vertex[i]
-		
-		// Step 2: Compute semidominators
-		// Consider all predecessors v of w
-		for _, v := range allObjects {
-			for _, ptr := range v.Ptrs {
-				if ptr == w {
-					processEdge(v.ID, w, &semi, dfnum, eval, vertex)
-				}
-			}
+	for i := len(order) - 1; i > 0; i-- {
+		if err := t.step(); err != nil {
+			return nil, nil, err
 		}
-		// Also check super-root edges
-		for _, ptr := range adj[0] {
-			if ptr == w {
-				processEdge(0, w, &semi, dfnum, eval, vertex)
+		w := order[i]
+		p := parent[w]
+
+		// Compute the semidominator from w's predecessors
+		s := p
+		for _, v := range pred[predStart[w]:predStart[w+1]] {
+			if dfnum[v] < 0 {
+				continue // v is not reachable
+			}
+			candidate := v
+			if dfnum[v] > dfnum[w] {
+				candidate = semi[eval(v)]
+			}
+			if dfnum[candidate] < dfnum[s] {
+				s = candidate
 			}
 		}
-		
-		// Add w to bucket of its semidominator
-		bucket[semi[w]] = append(bucket[semi[w]], w)
-		
+		semi[w] = s
+		bucketNext[w] = bucketHead[s]
+		bucketHead[s] = w
+
 		// Link w to its parent in the spanning tree
-		if parent[w] != -1 {
-			link(w, parent[w])
-		}
-		
-		// Step 3: Implicitly compute immediate dominators
-		for _, v := range bucket[parent[w]] {
-			u := eval(v)
-			if semi[u] == semi[v] {
-				idom[v] = vertex[parent[w]]
+		ancestor[w] = p
+		best[w] = w
+
+		// Implicitly compute immediate dominators of p's bucket
+		for v := bucketHead[p]; v != -1; v = bucketNext[v] {
+			if u := eval(v); semi[u] == semi[v] {
+				idom[v] = p
 			} else {
 				samedom[v] = u
 			}
 		}
-		bucket[parent[w]] = nil
+		bucketHead[p] = -1
 	}
-	
-	// Step 4: Explicitly compute immediate dominators
-	for i := 1; i < dfsNum; i++ {
-		w := vertex[i]
-		if samedom[w] != w {
+
+	// Explicitly compute the deferred immediate dominators
+	for _, w := range order[1:] {
+		if samedom[w] != -1 {
 			idom[w] = idom[samedom[w]]
 		}
 	}
-	
-	// Remove super-root from results
-	delete(idom, 0)
-	
-	return idom
+
+	return idom, order, nil
 }
 
-func processEdge(v, w ObjID, semi *map[ObjID]int, dfnum map[ObjID]int, eval func(ObjID) ObjID, vertex []ObjID) {
-	vNum, vReachable := dfnum[v]
-	wNum := dfnum[w]
-	
-	if !vReachable {
-		return // v is not reachable, skip
+// retainedSizes sums each reachable index's size with the sizes of
+// everything it dominates. A dominator always precedes the nodes it
+// dominates in DFS preorder, so one reverse sweep completes every subtree
+// before adding it to its dominator. sizeOf gives each object's own size.
+func (d *denseGraph) retainedSizes(idom, order []int32, sizeOf func(*Object) uint64) []uint64 {
+	retained := make([]uint64, len(d.ids))
+	for _, v := range order[1:] {
+		retained[v] = sizeOf(d.objs[v])
 	}
-	
-	var u ObjID
-	if vNum <= wNum {
-		u = v
-	} else {
-		u = eval(v)
-	}
-	
-	if (*semi)[u] < (*semi)[w] {
-		(*semi)[w] = (*semi)[u]
+	for i := len(order) - 1; i > 0; i-- {
+		v := order[i]
+		retained[idom[v]] += retained[v]
 	}
+	return retained
 }
 
 // DominatorTree builds a tree structure from immediate dominators.
 // Returns a map from each node to its list of immediately dominated nodes.
 func DominatorTree(idom map[ObjID]ObjID) map[ObjID][]ObjID {
-	tree := make(map[ObjID][]ObjID)
-	
+	tree := make(map[ObjID][]ObjID, len(idom)+1)
+
 	// Initialize with empty slices for all dominators
 	for node := range idom {
 		tree[node] = []ObjID{}
 	}
 	tree[0] = []ObjID{} // super-root
-	
+
 	// Build tree by reversing idom relationships
 	for node, dom := range idom {
 		tree[dom] = append(tree[dom], node)
 	}
-	
+
 	return tree
-}
\ No newline at end of file
+}
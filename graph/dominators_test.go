@@ -3,7 +3,10 @@
 package graph
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"reflect"
 	"sort"
 	"testing"
@@ -287,4 +290,107 @@ func BenchmarkDominators(b *testing.B) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// naiveDominators computes immediate dominators by definition: d dominates v
+// if v becomes unreachable from the super-root once d is removed
+func naiveDominators(g Graph) map[ObjID]ObjID {
+	reachable := func(skip ObjID) map[ObjID]bool {
+		seen := map[ObjID]bool{}
+		var stack []ObjID
+		for _, id := range g.GetRoots().IDs {
+			if id != skip && g.GetObject(id) != nil && !seen[id] {
+				seen[id] = true
+				stack = append(stack, id)
+			}
+		}
+		for len(stack) > 0 {
+			id := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, ptr := range g.GetObject(id).Ptrs {
+				if ptr != skip && g.GetObject(ptr) != nil && !seen[ptr] {
+					seen[ptr] = true
+					stack = append(stack, ptr)
+				}
+			}
+		}
+		return seen
+	}
+
+	all := reachable(0)
+	strict := make(map[ObjID][]ObjID) // v -> strict dominators other than the super-root
+	for d := range all {
+		without := reachable(d)
+		for v := range all {
+			if v != d && !without[v] {
+				strict[v] = append(strict[v], d)
+			}
+		}
+	}
+
+	// The immediate dominator is the strict dominator with the most dominators
+	idom := make(map[ObjID]ObjID)
+	for v := range all {
+		best := ObjID(0)
+		for _, d := range strict[v] {
+			if best == 0 || len(strict[d]) > len(strict[best]) {
+				best = d
+			}
+		}
+		idom[v] = best
+	}
+	return idom
+}
+
+// TestDominatorsMatchNaive cross-checks Lengauer-Tarjan against the
+// definition on random graphs with cycles, dangling pointers, and
+// multiple roots
+func TestDominatorsMatchNaive(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for iter := 0; iter < 200; iter++ {
+		n := 2 + rng.Intn(25)
+		g := NewMemGraph()
+		for i := 1; i <= n; i++ {
+			obj := &Object{ID: ObjID(i), Type: "node"}
+			for j := rng.Intn(4); j > 0; j-- {
+				obj.Ptrs = append(obj.Ptrs, ObjID(1+rng.Intn(n+2))) // may dangle
+			}
+			g.AddObject(obj)
+		}
+		roots := []ObjID{1}
+		if rng.Intn(2) == 0 {
+			roots = append(roots, ObjID(1+rng.Intn(n)))
+		}
+		g.SetRoots(Roots{IDs: roots})
+
+		want := naiveDominators(g)
+		if got := Dominators(g); !reflect.DeepEqual(got, want) {
+			t.Fatalf("iteration %d: Dominators() = %v, want %v", iter, got, want)
+		}
+	}
+}
+
+func TestDominatorsContext(t *testing.T) {
+	g := benchmarkGraph(3 * checkInterval)
+
+	got, err := DominatorsContext(context.Background(), g, nil)
+	if err != nil {
+		t.Fatalf("DominatorsContext() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, Dominators(g)) {
+		t.Error("DominatorsContext() differs from Dominators()")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int
+	_, err = DominatorsContext(ctx, g, func(done, total int) {
+		calls++
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if calls != 1 {
+		t.Errorf("progress called %d times after cancellation, want 1", calls)
+	}
+}
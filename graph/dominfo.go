@@ -0,0 +1,164 @@
+// ABOUTME: Computes dominators once and keeps everything derived from them
+// ABOUTME: Lets retained size, dominated set, and boundary queries share one pass
+
+package graph
+
+import "sort"
+
+// DomInfo holds the dominator analysis of a graph. Computing it runs the
+// DFS and Lengauer-Tarjan once; its methods then answer retained size and
+// dominated set queries without recomputing. Like any analysis, it is only
+// valid until the graph changes.
+type DomInfo struct {
+	// Idom maps each reachable object to its immediate dominator, as
+	// Dominators returns
+	Idom map[ObjID]ObjID
+
+	// Order lists the super-root and then every reachable object in DFS
+	// preorder, so an object's index is its DFS number. A dominator
+	// always comes before the objects it dominates.
+	Order []ObjID
+
+	// Tree maps each node to the nodes it immediately dominates, sorted by
+	// ID, as DominatorTree returns
+	Tree map[ObjID][]ObjID
+
+	// Depth is each node's depth in Tree; the super-root is 0
+	Depth map[ObjID]int
+
+	g     Graph
+	d     *denseGraph // without edges if decoded by DecodeDominators
+	idom  []int32     // dense index -> immediate dominator index, -1 if unreachable
+	order []int32     // Order as dense indexes
+}
+
+// ComputeDominators runs the dominator analysis of g
+func ComputeDominators(g Graph) *DomInfo {
+	d := newDenseGraph(g)
+	idom, order := d.dominators()
+	return newDomInfo(g, d, idom, order)
+}
+
+// newDomInfo fills in a DomInfo from the dense dominator analysis of g
+func newDomInfo(g Graph, d *denseGraph, idom, order []int32) *DomInfo {
+	di := &DomInfo{
+		Idom:  make(map[ObjID]ObjID, len(order)),
+		Order: make([]ObjID, len(order)),
+		Tree:  make(map[ObjID][]ObjID, len(order)),
+		Depth: make(map[ObjID]int, len(order)),
+		g:     g,
+		d:     d,
+		idom:  idom,
+		order: order,
+	}
+	di.Tree[0] = []ObjID{}
+	di.Depth[0] = 0
+	for i, v := range order {
+		di.Order[i] = d.ids[v]
+		if v == 0 {
+			continue
+		}
+		id, dom := d.ids[v], d.ids[idom[v]]
+		di.Idom[id] = dom
+		di.Tree[dom] = append(di.Tree[dom], id)
+		if di.Tree[id] == nil {
+			di.Tree[id] = []ObjID{}
+		}
+		di.Depth[id] = di.Depth[dom] + 1
+	}
+	for _, children := range di.Tree {
+		sort.Slice(children, func(i, j int) bool { return children[i] < children[j] })
+	}
+	return di
+}
+
+// RetainedSize returns the retained size of every reachable object, as
+// RetainedSize(g) does
+func (di *DomInfo) RetainedSize() map[ObjID]uint64 {
+	return di.retainedSize(func(obj *Object) uint64 { return obj.Size })
+}
+
+// RetainedAllocSize is like RetainedSize but counts each object's
+// AllocatedSize, as RetainedAllocSize(g) does
+func (di *DomInfo) RetainedAllocSize() map[ObjID]uint64 {
+	return di.retainedSize((*Object).AllocatedSize)
+}
+
+func (di *DomInfo) retainedSize(sizeOf func(*Object) uint64) map[ObjID]uint64 {
+	retained := di.d.retainedSizes(di.idom, di.order, sizeOf)
+	result := make(map[ObjID]uint64, len(di.order))
+	for _, v := range di.order[1:] {
+		if di.d.ids[v] != 0 {
+			result[di.d.ids[v]] = retained[v]
+		}
+	}
+	return result
+}
+
+// DominatedCounts returns how many objects each reachable object retains,
+// itself included, as DominatedCounts(g) does
+func (di *DomInfo) DominatedCounts() map[ObjID]int {
+	return di.d.dominatedCountResult(di.idom, di.order)
+}
+
+// RetainedSizeSubsets returns the retained sizes of targetIDs, as
+// RetainedSizeSubsets(g, targetIDs) does
+func (di *DomInfo) RetainedSizeSubsets(targetIDs []ObjID) map[ObjID]uint64 {
+	if len(targetIDs) == 0 {
+		return make(map[ObjID]uint64)
+	}
+	return retainedSubset(di.g, di.RetainedSize(), targetIDs)
+}
+
+// DominatedSet returns id and every object it dominates, sorted by ID, as
+// DominatedSet(g, id) does
+func (di *DomInfo) DominatedSet(id ObjID) []ObjID {
+	if id == 0 || di.g.GetObject(id) == nil {
+		return nil
+	}
+
+	set := []ObjID{id}
+	stack := []ObjID{id}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		set = append(set, di.Tree[n]...)
+		stack = append(stack, di.Tree[n]...)
+	}
+
+	sort.Slice(set, func(i, j int) bool { return set[i] < set[j] })
+	return set
+}
+
+// RetentionBoundary returns the objects referenced from id's retained set
+// that are not part of it, as RetentionBoundary(g, id) does
+func (di *DomInfo) RetentionBoundary(id ObjID) []ObjID {
+	set := di.DominatedSet(id)
+	if set == nil {
+		return nil
+	}
+
+	retained := make(map[ObjID]bool, len(set))
+	for _, n := range set {
+		retained[n] = true
+	}
+
+	seen := make(map[ObjID]bool)
+	var boundary []ObjID
+	for _, n := range set {
+		obj := di.g.GetObject(n)
+		if obj == nil {
+			continue
+		}
+		for _, ptr := range obj.Ptrs {
+			if retained[ptr] || seen[ptr] || di.g.GetObject(ptr) == nil {
+				continue
+			}
+			seen[ptr] = true
+			boundary = append(boundary, ptr)
+		}
+	}
+
+	sort.Slice(boundary, func(i, j int) bool { return boundary[i] < boundary[j] })
+	return boundary
+}
@@ -0,0 +1,86 @@
+// ABOUTME: Tests for the reusable dominator analysis
+// ABOUTME: Checks DomInfo against the one-shot dominator functions
+
+package graph
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestComputeDominators(t *testing.T) {
+	// 1 -> 2, 3; 2 -> 4; 3 -> 4; 4 -> 5; 6 is unreachable
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Size: 10, Ptrs: []ObjID{2, 3}})
+	g.AddObject(&Object{ID: 2, Size: 20, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 3, Size: 30, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 4, Size: 40, Ptrs: []ObjID{5}})
+	g.AddObject(&Object{ID: 5, Size: 50})
+	g.AddObject(&Object{ID: 6, Size: 60, Ptrs: []ObjID{5}})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	di := ComputeDominators(g)
+
+	wantIdom := map[ObjID]ObjID{1: 0, 2: 1, 3: 1, 4: 1, 5: 4}
+	if !reflect.DeepEqual(di.Idom, wantIdom) {
+		t.Errorf("Idom = %v, want %v", di.Idom, wantIdom)
+	}
+	wantTree := map[ObjID][]ObjID{0: {1}, 1: {2, 3, 4}, 2: {}, 3: {}, 4: {5}, 5: {}}
+	if !reflect.DeepEqual(di.Tree, wantTree) {
+		t.Errorf("Tree = %v, want %v", di.Tree, wantTree)
+	}
+	if want := DominatorDepth(wantTree); !reflect.DeepEqual(di.Depth, want) {
+		t.Errorf("Depth = %v, want %v", di.Depth, want)
+	}
+	if want := RetainedSize(g); !reflect.DeepEqual(di.RetainedSize(), want) {
+		t.Errorf("RetainedSize() = %v, want %v", di.RetainedSize(), want)
+	}
+	if want := DominatedCounts(g); !reflect.DeepEqual(di.DominatedCounts(), want) {
+		t.Errorf("DominatedCounts() = %v, want %v", di.DominatedCounts(), want)
+	}
+
+	// Order is a preorder: the super-root first, dominators before what
+	// they dominate
+	if len(di.Order) != 6 || di.Order[0] != 0 {
+		t.Fatalf("Order = %v, want the super-root and 5 objects", di.Order)
+	}
+	dfnum := make(map[ObjID]int)
+	for i, id := range di.Order {
+		dfnum[id] = i
+	}
+	for id, dom := range di.Idom {
+		if dfnum[dom] >= dfnum[id] {
+			t.Errorf("dominator %d of %d comes later in Order %v", dom, id, di.Order)
+		}
+	}
+
+	for _, id := range []ObjID{1, 4, 6, 99} {
+		if got, want := di.DominatedSet(id), DominatedSet(g, id); !reflect.DeepEqual(got, want) {
+			t.Errorf("DominatedSet(%d) = %v, want %v", id, got, want)
+		}
+		if got, want := di.RetentionBoundary(id), RetentionBoundary(g, id); !reflect.DeepEqual(got, want) {
+			t.Errorf("RetentionBoundary(%d) = %v, want %v", id, got, want)
+		}
+	}
+	ids := []ObjID{2, 4, 6}
+	if got, want := di.RetainedSizeSubsets(ids), RetainedSizeSubsets(g, ids); !reflect.DeepEqual(got, want) {
+		t.Errorf("RetainedSizeSubsets() = %v, want %v", got, want)
+	}
+}
+
+func TestComputeDominatorsMatchesDominatorTree(t *testing.T) {
+	g := benchmarkGraph(1000)
+	di := ComputeDominators(g)
+
+	want := DominatorTree(Dominators(g))
+	for _, children := range want {
+		sort.Slice(children, func(i, j int) bool { return children[i] < children[j] })
+	}
+	if !reflect.DeepEqual(di.Tree, want) {
+		t.Error("Tree differs from DominatorTree")
+	}
+	if !reflect.DeepEqual(di.Idom, Dominators(g)) {
+		t.Error("Idom differs from Dominators")
+	}
+}
@@ -5,31 +5,36 @@ package graph
 // DominatorDepth computes the depth of each node in the dominator tree.
 // Returns a map from node ID to its depth (root has depth 0).
 func DominatorDepth(tree map[ObjID][]ObjID) map[ObjID]int {
-	depth := make(map[ObjID]int)
-	
-	// BFS to compute depths
-	var computeDepth func(node ObjID, d int)
-	computeDepth = func(node ObjID, d int) {
-		depth[node] = d
+	depth := map[ObjID]int{0: 0}
+
+	// BFS from the super-root; a queue keeps deep trees off the call stack
+	queue := []ObjID{0}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
 		for _, child := range tree[node] {
-			computeDepth(child, d+1)
+			if _, seen := depth[child]; seen {
+				continue
+			}
+			depth[child] = depth[node] + 1
+			queue = append(queue, child)
 		}
 	}
-	
-	// Start from super-root
-	computeDepth(0, 0)
-	
+
 	return depth
 }
 
 // DominatorPath returns the path from a node to the root in the dominator tree.
 // The path includes the node itself and ends with the root (or super-root).
+// A malformed idom map with a cycle cannot loop forever: the path stops
+// before the first repeated node and does not end with the super-root.
 func DominatorPath(idom map[ObjID]ObjID, node ObjID) []ObjID {
 	var path []ObjID
 	current := node
-	
-	// Follow immediate dominators up to root
-	for {
+
+	// Follow immediate dominators up to root. A valid chain has at most
+	// len(idom) steps, so any longer walk is a cycle.
+	for steps := 0; steps <= len(idom); steps++ {
 		path = append(path, current)
 		dom, exists := idom[current]
 		if !exists || dom == 0 {
@@ -37,22 +42,31 @@ func DominatorPath(idom map[ObjID]ObjID, node ObjID) []ObjID {
 			if current != 0 {
 				path = append(path, 0) // Add super-root
 			}
-			break
+			return path
 		}
 		current = dom
 	}
-	
+
+	// Cycle: trim the path back to where it starts repeating
+	seen := make(map[ObjID]bool, len(path))
+	for i, id := range path {
+		if seen[id] {
+			return path[:i]
+		}
+		seen[id] = true
+	}
 	return path
 }
 
 // IsDominated returns true if node is dominated by dominator.
+// It returns false if following idom from node runs into a cycle.
 func IsDominated(idom map[ObjID]ObjID, node, dominator ObjID) bool {
 	if node == dominator {
 		return true // A node dominates itself
 	}
-	
+
 	current := node
-	for {
+	for steps := 0; steps <= len(idom); steps++ {
 		dom, exists := idom[current]
 		if !exists {
 			return false // Reached root without finding dominator
@@ -65,4 +79,16 @@ func IsDominated(idom map[ObjID]ObjID, node, dominator ObjID) bool {
 		}
 		current = dom
 	}
-}
\ No newline at end of file
+	return false
+}
+
+// DominatedSet returns id and every object it dominates, sorted by ID:
+// everything that would be freed if id died. An unreachable object
+// dominates only itself; nil is returned for objects not in the graph.
+// Use DomInfo.DominatedSet to query several objects.
+func DominatedSet(g Graph, id ObjID) []ObjID {
+	if id == 0 || g.GetObject(id) == nil {
+		return nil
+	}
+	return ComputeDominators(g).DominatedSet(id)
+}
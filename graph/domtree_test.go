@@ -0,0 +1,85 @@
+// ABOUTME: Tests for dominator tree utilities
+// ABOUTME: Covers deep chains and malformed idom maps with cycles
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDominatorUtilitiesDeepChain(t *testing.T) {
+	const n = 200_000
+
+	g := NewMemGraph()
+	for i := 1; i <= n; i++ {
+		obj := &Object{ID: ObjID(i), Type: "node", Size: 8}
+		if i < n {
+			obj.Ptrs = []ObjID{ObjID(i + 1)}
+		}
+		g.AddObject(obj)
+	}
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	idom := Dominators(g)
+	depth := DominatorDepth(DominatorTree(idom))
+	if depth[n] != n {
+		t.Errorf("depth of last node = %d, want %d", depth[n], n)
+	}
+
+	path := DominatorPath(idom, n)
+	if len(path) != n+1 || path[0] != n || path[n] != 0 {
+		t.Errorf("DominatorPath has %d nodes from %d to %d, want %d from %d to 0",
+			len(path), path[0], path[len(path)-1], n+1, n)
+	}
+	if !IsDominated(idom, n, 1) {
+		t.Error("last node should be dominated by the first")
+	}
+}
+
+func TestDominatorUtilitiesCycle(t *testing.T) {
+	// 3 -> 1 -> 2 -> 1 never reaches the super-root
+	idom := map[ObjID]ObjID{1: 2, 2: 1, 3: 1}
+
+	if got, want := DominatorPath(idom, 3), []ObjID{3, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DominatorPath() = %v, want %v", got, want)
+	}
+	if IsDominated(idom, 3, 4) {
+		t.Error("IsDominated() should be false when idom cycles")
+	}
+	if !IsDominated(idom, 3, 2) {
+		t.Error("IsDominated() should still find a dominator on the cycle")
+	}
+}
+
+func TestDominatedSet(t *testing.T) {
+	// Diamond: 1 -> 2, 1 -> 3, 2 -> 4, 3 -> 4
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Size: 100, Ptrs: []ObjID{2, 3}})
+	g.AddObject(&Object{ID: 2, Type: "left", Size: 30, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 3, Type: "right", Size: 40, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 4, Type: "merge", Size: 20})
+	g.AddObject(&Object{ID: 5, Type: "garbage", Size: 10, Ptrs: []ObjID{4}})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	tests := []struct {
+		id   ObjID
+		want []ObjID
+	}{
+		{id: 1, want: []ObjID{1, 2, 3, 4}},
+		{id: 2, want: []ObjID{2}},
+		{id: 3, want: []ObjID{3}},
+		{id: 5, want: []ObjID{5}},
+		{id: 99, want: nil},
+	}
+	for _, tt := range tests {
+		if got := DominatedSet(g, tt.id); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DominatedSet(%d) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+
+	sub := Subgraph(g, DominatedSet(g, 1))
+	if sub.NumObjects() != 4 || sub.GetObject(5) != nil {
+		t.Errorf("Subgraph of root's dominated set has %d objects, want 1-4", sub.NumObjects())
+	}
+}
@@ -0,0 +1,113 @@
+// ABOUTME: Tests for Graphviz DOT export and subgraph extraction
+// ABOUTME: Verifies node labels, root styling, edges, and the size guard
+
+package graph
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Size: 100, Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: `map[string]"quoted"`, Size: 48, Ptrs: []ObjID{99}})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	var buf bytes.Buffer
+	if err := WriteDOT(&buf, g); err != nil {
+		t.Fatalf("WriteDOT failed: %v", err)
+	}
+	out := buf.String()
+
+	wants := []string{
+		"digraph heap {",
+		`n1 [label="#1 root\n100 bytes", style=filled`,
+		`n2 [label="#2 map[string]\"quoted\"\n48 bytes"];`,
+		"n1 -> n2;",
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, out)
+		}
+	}
+
+	// Dangling pointers must not produce edges to undeclared nodes
+	if strings.Contains(out, "n99") {
+		t.Errorf("DOT output contains dangling edge:\n%s", out)
+	}
+}
+
+func TestWriteDOTTooLarge(t *testing.T) {
+	g := NewMemGraph()
+	for i := 1; i <= MaxDOTObjects+1; i++ {
+		g.AddObject(&Object{ID: ObjID(i), Type: "node"})
+	}
+
+	err := WriteDOT(&bytes.Buffer{}, g)
+	if err == nil || !strings.Contains(err.Error(), "Subgraph") {
+		t.Errorf("Expected size guard error suggesting Subgraph, got %v", err)
+	}
+}
+
+func TestSubgraph(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Size: 10, Ptrs: []ObjID{2, 3}})
+	g.AddObject(&Object{ID: 2, Type: "kept", Size: 20, Ptrs: []ObjID{3}})
+	g.AddObject(&Object{ID: 3, Type: "dropped", Size: 30})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	sub := Subgraph(g, []ObjID{1, 2, 404})
+
+	if sub.NumObjects() != 2 {
+		t.Fatalf("Expected 2 objects, got %d", sub.NumObjects())
+	}
+	if ptrs := sub.GetObject(1).Ptrs; len(ptrs) != 1 || ptrs[0] != 2 {
+		t.Errorf("Expected object 1 to keep only pointer to 2, got %v", ptrs)
+	}
+	if ptrs := sub.GetObject(2).Ptrs; len(ptrs) != 0 {
+		t.Errorf("Expected object 2 to lose pointer to 3, got %v", ptrs)
+	}
+	if roots := sub.GetRoots().IDs; len(roots) != 1 || roots[0] != 1 {
+		t.Errorf("Expected roots [1], got %v", roots)
+	}
+
+	// The source graph must be untouched
+	if len(g.GetObject(1).Ptrs) != 2 {
+		t.Error("Subgraph modified the source graph")
+	}
+}
+
+func TestLiveGraph(t *testing.T) {
+	// 1 (root) -> 2 -> 3, 4 -> 2 and 5 are garbage
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Size: 10, Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "mid", Size: 20, Ptrs: []ObjID{3}, HasFinalizer: true})
+	g.AddObject(&Object{ID: 3, Type: "leaf", Size: 30, Ptrs: []ObjID{}})
+	g.AddObject(&Object{ID: 4, Type: "garbage", Size: 40, Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 5, Type: "garbage", Size: 50})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	live := LiveGraph(g)
+
+	var ids []ObjID
+	for id := range RootDistance(g) {
+		ids = append(ids, id)
+	}
+	if live.NumObjects() != len(ids) {
+		t.Fatalf("Expected %d live objects, got %d", len(ids), live.NumObjects())
+	}
+	for _, id := range ids {
+		if got, want := live.GetObject(id), g.GetObject(id); !reflect.DeepEqual(got, want) {
+			t.Errorf("live object %d = %+v, want %+v", id, got, want)
+		}
+	}
+	if !reflect.DeepEqual(live.GetRoots(), g.GetRoots()) {
+		t.Errorf("roots = %v, want %v", live.GetRoots(), g.GetRoots())
+	}
+	if got, want := RetainedSize(live), RetainedSize(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("RetainedSize(live) = %v, want %v", got, want)
+	}
+}
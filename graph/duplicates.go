@@ -0,0 +1,58 @@
+// ABOUTME: Finds groups of byte-identical objects that could be interned
+// ABOUTME: Groups by type, size, and payload hash and totals the wasted bytes
+
+package graph
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MinDuplicateWaste is the fewest bytes a group of identical objects must
+// waste for FindDuplicates to report it. Smaller groups are rarely worth
+// deduplicating.
+const MinDuplicateWaste = 1024
+
+// FindDuplicates groups objects with the same type, size, and ContentHash,
+// such as thousands of copies of one string. Only groups with more than
+// one member that waste at least MinDuplicateWaste bytes are returned.
+// Keys are "type/size/hash" fingerprints and IDs are sorted. Objects with
+// no ContentHash are ignored, so a Go heap dump must be parsed with
+// GoHeapParser.HashPayloads for any to be found. When payloads were kept,
+// AsString on any member of a string group shows the duplicated text.
+func FindDuplicates(g Graph) map[string][]ObjID {
+	type fingerprint struct {
+		typ  string
+		size uint64
+		hash uint64
+	}
+	groups := make(map[fingerprint][]ObjID)
+	ForEachObjectWhere(g, func(obj *Object) bool {
+		return obj.ContentHash != 0 && obj.Size > 0
+	}, func(obj *Object) {
+		fp := fingerprint{obj.Type, obj.Size, obj.ContentHash}
+		groups[fp] = append(groups[fp], obj.ID)
+	})
+
+	result := make(map[string][]ObjID)
+	for fp, ids := range groups {
+		if len(ids) < 2 || uint64(len(ids)-1)*fp.size < MinDuplicateWaste {
+			continue
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		result[fmt.Sprintf("%s/%d/%016x", fp.typ, fp.size, fp.hash)] = ids
+	}
+	return result
+}
+
+// DuplicateWaste returns the bytes that would be reclaimed if every group
+// in dups were interned down to a single copy
+func DuplicateWaste(g Graph, dups map[string][]ObjID) uint64 {
+	var waste uint64
+	for _, ids := range dups {
+		if obj := g.GetObject(ids[0]); obj != nil {
+			waste += uint64(len(ids)-1) * obj.Size
+		}
+	}
+	return waste
+}
@@ -0,0 +1,34 @@
+// ABOUTME: Tests for duplicate object detection
+// ABOUTME: Checks grouping by type, size, and hash and the waste threshold
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	g := NewMemGraph()
+	// 3 copies of a 600-byte string waste 1200 bytes
+	g.AddObject(&Object{ID: 4, Type: "string", Size: 600, ContentHash: 0xabc})
+	g.AddObject(&Object{ID: 1, Type: "string", Size: 600, ContentHash: 0xabc})
+	g.AddObject(&Object{ID: 7, Type: "string", Size: 600, ContentHash: 0xabc})
+	// Same payload under another type is a separate group, and too small
+	g.AddObject(&Object{ID: 2, Type: "[]uint8", Size: 600, ContentHash: 0xabc})
+	g.AddObject(&Object{ID: 3, Type: "[]uint8", Size: 600, ContentHash: 0xabc})
+	// Different contents
+	g.AddObject(&Object{ID: 5, Type: "string", Size: 600, ContentHash: 0xdef})
+	// Unhashed objects are never grouped
+	g.AddObject(&Object{ID: 8, Type: "big", Size: 4096})
+	g.AddObject(&Object{ID: 9, Type: "big", Size: 4096})
+
+	dups := FindDuplicates(g)
+	want := map[string][]ObjID{"string/600/0000000000000abc": {1, 4, 7}}
+	if !reflect.DeepEqual(dups, want) {
+		t.Errorf("FindDuplicates() = %v, want %v", dups, want)
+	}
+	if waste := DuplicateWaste(g, dups); waste != 1200 {
+		t.Errorf("DuplicateWaste() = %d, want 1200", waste)
+	}
+}
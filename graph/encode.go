@@ -0,0 +1,393 @@
+// ABOUTME: Compact binary serialization of a parsed graph for caching
+// ABOUTME: Interns type names, writes edges as varints, and can gzip bulky sections
+
+package graph
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// encodingMagic starts every encoded graph and carries its format version
+const encodingMagic = "heaplens graph 4\n"
+
+// maxEncodedLen bounds length fields when decoding, so a corrupt length
+// cannot trigger a huge allocation
+const maxEncodedLen = 1 << 30
+
+// Header flags in the encoding
+const (
+	// headerCompressed marks the type table and payload sections as gzip
+	// streams
+	headerCompressed = 1 << iota
+)
+
+// Object flags in the encoding
+const (
+	flagFinalizer = 1 << iota
+	flagContentHash
+	flagData
+)
+
+// ErrInvalidEncoding is returned by DecodeGraph for input that is not a
+// graph written by Encode, or is corrupt
+var ErrInvalidEncoding = errors.New("invalid graph encoding")
+
+// EncodeOpts controls MemGraph.EncodeWith
+type EncodeOpts struct {
+	// Compress gzips the type table and the object payloads, the parts of
+	// a graph that compress best. The file shrinks, at the cost of slower
+	// encoding and decoding.
+	Compress bool
+}
+
+// Encode writes g in HeapLens's compact binary format, which DecodeGraph
+// reads back far faster than re-parsing the original dump. Type names are
+// written once in a string table; IDs, sizes, and pointers are varints,
+// with IDs and pointers stored as deltas so nearby objects cost a byte or
+// two each. Every Object field and the roots, with their kinds,
+// descriptions, and goroutines, survive the round trip. Nothing is
+// compressed; see EncodeWith.
+func (g *MemGraph) Encode(w io.Writer) error {
+	return encodeGraph(w, g, EncodeOpts{})
+}
+
+// EncodeWith is like Encode with options. DecodeGraph reads either form.
+func (g *MemGraph) EncodeWith(w io.Writer, opts EncodeOpts) error {
+	return encodeGraph(w, g, opts)
+}
+
+// encodeGraph writes a header byte and three sections: the type table,
+// the objects and roots, and the object payloads in object order. The
+// first and last are gzip streams when opts.Compress is set.
+func encodeGraph(w io.Writer, g Graph, opts EncodeOpts) error {
+	var objs []*Object
+	typeIndex := make(map[string]uint64)
+	var types []string
+	ForEachObjectSorted(g, func(obj *Object) {
+		objs = append(objs, obj)
+		if _, ok := typeIndex[obj.Type]; !ok {
+			typeIndex[obj.Type] = uint64(len(types))
+			types = append(types, obj.Type)
+		}
+	})
+
+	e := &encoder{w: bufio.NewWriter(w)}
+	e.w.WriteString(encodingMagic)
+	var header byte
+	if opts.Compress {
+		header |= headerCompressed
+	}
+	e.w.WriteByte(header)
+
+	err := e.section(opts.Compress, func(e *encoder) {
+		e.uvarint(uint64(len(types)))
+		for _, t := range types {
+			e.bytes([]byte(t))
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("encoding graph: %w", err)
+	}
+
+	e.uvarint(uint64(len(objs)))
+	var prev ObjID
+	for _, obj := range objs {
+		e.uvarint(uint64(obj.ID - prev))
+		prev = obj.ID
+		e.uvarint(typeIndex[obj.Type])
+		e.uvarint(obj.Size)
+		e.uvarint(obj.Addr)
+		e.uvarint(obj.AllocSize)
+		e.uvarint(obj.TypeSize)
+
+		var flags byte
+		if obj.HasFinalizer {
+			flags |= flagFinalizer
+		}
+		if obj.ContentHash != 0 {
+			flags |= flagContentHash
+		}
+		if obj.Data != nil {
+			flags |= flagData
+		}
+		e.w.WriteByte(flags)
+		if obj.ContentHash != 0 {
+			var b [8]byte
+			binary.LittleEndian.PutUint64(b[:], obj.ContentHash)
+			e.w.Write(b[:])
+		}
+		if obj.Data != nil {
+			// The bytes themselves follow in the payload section
+			e.uvarint(uint64(len(obj.Data)))
+		}
+
+		e.uvarint(uint64(len(obj.Ptrs)))
+		for _, ptr := range obj.Ptrs {
+			e.varint(int64(ptr - obj.ID))
+		}
+	}
+
+	roots := g.GetRoots()
+	e.uvarint(uint64(len(roots.IDs)))
+	for _, id := range roots.IDs {
+		e.uvarint(uint64(id))
+	}
+	e.uvarint(uint64(len(roots.Kinds)))
+	for _, kind := range roots.Kinds {
+		e.uvarint(uint64(kind))
+	}
+	e.uvarint(uint64(len(roots.Descs)))
+	for _, desc := range roots.Descs {
+		e.bytes([]byte(desc))
+	}
+	e.uvarint(uint64(len(roots.Goroutines)))
+	for _, gid := range roots.Goroutines {
+		e.uvarint(gid)
+	}
+
+	err = e.section(opts.Compress, func(e *encoder) {
+		for _, obj := range objs {
+			e.w.Write(obj.Data)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("encoding graph: %w", err)
+	}
+
+	if err := e.w.Flush(); err != nil {
+		return fmt.Errorf("encoding graph: %w", err)
+	}
+	return nil
+}
+
+// encoder writes varints to a buffered writer, which remembers the first
+// write error until Flush
+type encoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+// section calls write with an encoder for one section, gzipping what it
+// writes if compress is set
+func (e *encoder) section(compress bool, write func(*encoder)) error {
+	if !compress {
+		write(e)
+		return nil
+	}
+
+	gz := gzip.NewWriter(e.w)
+	sub := &encoder{w: bufio.NewWriter(gz)}
+	write(sub)
+	if err := sub.w.Flush(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (e *encoder) uvarint(v uint64) {
+	e.w.Write(e.buf[:binary.PutUvarint(e.buf[:], v)])
+}
+
+func (e *encoder) varint(v int64) {
+	e.w.Write(e.buf[:binary.PutVarint(e.buf[:], v)])
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.w.Write(b)
+}
+
+// DecodeGraph reads a graph written by Encode or EncodeWith into a new
+// MemGraph, decompressing it if needed
+func DecodeGraph(r io.Reader) (Graph, error) {
+	d := &decoder{r: bufio.NewReader(r)}
+	magic := make([]byte, len(encodingMagic))
+	if _, err := io.ReadFull(d.r, magic); err != nil || string(magic) != encodingMagic {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidEncoding)
+	}
+	header := d.byte()
+	if header&^headerCompressed != 0 {
+		d.fail(fmt.Errorf("unknown header flags %#x", header))
+	}
+	compressed := header&headerCompressed != 0
+
+	// Counts are not trusted to presize anything
+	var types []string
+	d.section(compressed, func(d *decoder) {
+		for i, n := 0, d.length(); i < n && d.err == nil; i++ {
+			types = append(types, string(d.bytes()))
+		}
+	})
+
+	g := NewMemGraph()
+	var dataLens []int
+	var withData []*Object
+	n := d.length()
+	var id ObjID
+	for i := 0; i < n && d.err == nil; i++ {
+		id += ObjID(d.uvarint())
+		obj := &Object{ID: id}
+		if t := d.uvarint(); t < uint64(len(types)) {
+			obj.Type = types[t]
+		} else {
+			d.fail(fmt.Errorf("object %d: type index %d out of range", id, t))
+		}
+		obj.Size = d.uvarint()
+		obj.Addr = d.uvarint()
+		obj.AllocSize = d.uvarint()
+		obj.TypeSize = d.uvarint()
+
+		flags := d.byte()
+		obj.HasFinalizer = flags&flagFinalizer != 0
+		if flags&flagContentHash != 0 {
+			if b := d.read(8); b != nil {
+				obj.ContentHash = binary.LittleEndian.Uint64(b)
+			}
+		}
+		if flags&flagData != 0 {
+			withData = append(withData, obj)
+			dataLens = append(dataLens, d.length())
+		}
+
+		for j, numPtrs := 0, d.length(); j < numPtrs && d.err == nil; j++ {
+			obj.Ptrs = append(obj.Ptrs, id+ObjID(d.varint()))
+		}
+		g.AddObject(obj)
+	}
+
+	var roots Roots
+	for i, n := 0, d.length(); i < n && d.err == nil; i++ {
+		roots.IDs = append(roots.IDs, ObjID(d.uvarint()))
+	}
+	for i, n := 0, d.length(); i < n && d.err == nil; i++ {
+		roots.Kinds = append(roots.Kinds, RootKind(d.uvarint()))
+	}
+	for i, n := 0, d.length(); i < n && d.err == nil; i++ {
+		roots.Descs = append(roots.Descs, string(d.bytes()))
+	}
+	for i, n := 0, d.length(); i < n && d.err == nil; i++ {
+		roots.Goroutines = append(roots.Goroutines, d.uvarint())
+	}
+	g.SetRoots(roots)
+
+	d.section(compressed, func(d *decoder) {
+		for i := 0; i < len(withData) && d.err == nil; i++ {
+			withData[i].Data = d.read(dataLens[i])
+		}
+	})
+
+	if d.err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEncoding, d.err)
+	}
+	return g, nil
+}
+
+// decoder reads varints from a buffered reader, remembering the first
+// error so callers can check once at the end. After an error every read
+// returns zero.
+type decoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// section calls read with a decoder for one section, decompressing it if
+// compressed is set. A gzip reader over a bufio.Reader reads byte by byte,
+// so it stops exactly at the end of the section's stream.
+func (d *decoder) section(compressed bool, read func(*decoder)) {
+	if d.err != nil {
+		return
+	}
+	if !compressed {
+		read(d)
+		return
+	}
+
+	gz, err := gzip.NewReader(d.r)
+	if err != nil {
+		d.fail(noEOF(err))
+		return
+	}
+	gz.Multistream(false)
+	sub := &decoder{r: bufio.NewReader(gz)}
+	read(sub)
+	d.fail(sub.err)
+	if d.err == nil {
+		// Drain the stream so its checksum is verified
+		_, err := io.Copy(io.Discard, sub.r)
+		d.fail(err)
+	}
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	d.fail(noEOF(err))
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(d.r)
+	d.fail(noEOF(err))
+	return v
+}
+
+// length reads a length field, rejecting implausibly large values
+func (d *decoder) length() int {
+	n := d.uvarint()
+	if n > maxEncodedLen {
+		d.fail(fmt.Errorf("length %d too large", n))
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	b, err := d.r.ReadByte()
+	d.fail(noEOF(err))
+	return b
+}
+
+// read reads n bytes, returning nil after an error
+func (d *decoder) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		d.fail(noEOF(err))
+		return nil
+	}
+	return b
+}
+
+func (d *decoder) bytes() []byte {
+	return d.read(d.length())
+}
+
+// noEOF turns io.EOF into io.ErrUnexpectedEOF, since the encoding never
+// ends in the middle of a field
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
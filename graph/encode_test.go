@@ -0,0 +1,164 @@
+// ABOUTME: Tests for the compact binary graph encoding
+// ABOUTME: Round-trips every object field, compressed or not, and rejects corrupt input
+
+package graph
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "*main.Server", Size: 64, Addr: 0xc000010000, AllocSize: 64, TypeSize: 64, Ptrs: []ObjID{5, 1 << 40}})
+	g.AddObject(&Object{ID: 5, Type: "[]byte", Size: 100, AllocSize: 112, ContentHash: 0xdeadbeefcafef00d, Data: []byte("payload")})
+	g.AddObject(&Object{ID: 1 << 40, Type: "*main.Server", Size: 64, HasFinalizer: true, Ptrs: []ObjID{1, 99}})
+	g.AddObject(&Object{ID: 7, Type: "", Size: 8, Data: []byte{}})
+	g.SetRoots(Roots{IDs: []ObjID{1, 7}, Kinds: []RootKind{RootGlobal, RootStack}, Descs: []string{"data", ""}, Goroutines: []uint64{0, 42}})
+
+	for _, opts := range []EncodeOpts{{}, {Compress: true}} {
+		var buf bytes.Buffer
+		if err := g.EncodeWith(&buf, opts); err != nil {
+			t.Fatalf("EncodeWith(%+v) error = %v", opts, err)
+		}
+		decoded, err := DecodeGraph(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("DecodeGraph(%+v) error = %v", opts, err)
+		}
+
+		if decoded.NumObjects() != g.NumObjects() {
+			t.Fatalf("%+v: NumObjects() = %d, want %d", opts, decoded.NumObjects(), g.NumObjects())
+		}
+		g.ForEachObject(func(want *Object) {
+			if got := decoded.GetObject(want.ID); !reflect.DeepEqual(got, want) {
+				t.Errorf("%+v: object %d = %+v, want %+v", opts, want.ID, got, want)
+			}
+		})
+		if !reflect.DeepEqual(decoded.GetRoots(), g.GetRoots()) {
+			t.Errorf("%+v: GetRoots() = %+v, want %+v", opts, decoded.GetRoots(), g.GetRoots())
+		}
+	}
+}
+
+func TestEncodeCompressedIsSmaller(t *testing.T) {
+	g := payloadGraph(1000)
+	var plain, compressed bytes.Buffer
+	if err := g.Encode(&plain); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if err := g.EncodeWith(&compressed, EncodeOpts{Compress: true}); err != nil {
+		t.Fatalf("EncodeWith() error = %v", err)
+	}
+	if compressed.Len() >= plain.Len()/2 {
+		t.Errorf("compressed encoding is %d bytes, want under half of %d", compressed.Len(), plain.Len())
+	}
+}
+
+func TestEncodeEmptyGraph(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewMemGraph().Encode(&buf); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	g, err := DecodeGraph(&buf)
+	if err != nil {
+		t.Fatalf("DecodeGraph() error = %v", err)
+	}
+	if g.NumObjects() != 0 || len(g.GetRoots().IDs) != 0 {
+		t.Errorf("decoded %d objects and %d roots, want none", g.NumObjects(), len(g.GetRoots().IDs))
+	}
+}
+
+func TestDecodeGraphRejectsCorruptInput(t *testing.T) {
+	for _, opts := range []EncodeOpts{{}, {Compress: true}} {
+		var buf bytes.Buffer
+		if err := payloadGraph(50).EncodeWith(&buf, opts); err != nil {
+			t.Fatalf("EncodeWith(%+v) error = %v", opts, err)
+		}
+		data := buf.Bytes()
+
+		// Every truncation fails rather than returning a partial graph
+		for n := 0; n < len(data); n++ {
+			if _, err := DecodeGraph(bytes.NewReader(data[:n])); !errors.Is(err, ErrInvalidEncoding) {
+				t.Fatalf("%+v: DecodeGraph(first %d bytes) error = %v, want ErrInvalidEncoding", opts, n, err)
+			}
+		}
+	}
+
+	if _, err := DecodeGraph(bytes.NewReader([]byte("go1.7 heap dump\n"))); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("DecodeGraph(heap dump) error = %v, want ErrInvalidEncoding", err)
+	}
+
+	// A type index past the string table
+	bad := append([]byte(encodingMagic), 0, 0, 1, 1, 5)
+	if _, err := DecodeGraph(bytes.NewReader(bad)); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("DecodeGraph(bad type index) error = %v, want ErrInvalidEncoding", err)
+	}
+
+	// A header flag this version does not know
+	bad = append([]byte(encodingMagic), 0x80, 0, 0, 0, 0, 0)
+	if _, err := DecodeGraph(bytes.NewReader(bad)); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("DecodeGraph(unknown header flag) error = %v, want ErrInvalidEncoding", err)
+	}
+}
+
+// payloadGraph returns benchmarkGraph(n) with a hashed, repetitive payload
+// on every object, as a dump parsed with payloads kept would have
+func payloadGraph(n int) *MemGraph {
+	g := benchmarkGraph(n)
+	g.ForEachObject(func(obj *Object) {
+		obj.Data = bytes.Repeat([]byte{byte(obj.ID), 0, 0, 0, 0, 0, 0, 0}, int(obj.Size)/8)
+		obj.ContentHash = uint64(obj.ID) * 0x9e3779b97f4a7c15
+	})
+	return g
+}
+
+// BenchmarkEncodeGraph and BenchmarkDecodeGraph report the encoded size
+// alongside the time, to weigh compression's cost against its savings
+func BenchmarkEncodeGraph(b *testing.B) {
+	g := payloadGraph(100000)
+	for _, bm := range []struct {
+		name string
+		opts EncodeOpts
+	}{
+		{"plain", EncodeOpts{}},
+		{"compressed", EncodeOpts{Compress: true}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			var buf bytes.Buffer
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				if err := g.EncodeWith(&buf, bm.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(buf.Len()), "encoded-bytes")
+		})
+	}
+}
+
+func BenchmarkDecodeGraph(b *testing.B) {
+	g := payloadGraph(100000)
+	for _, bm := range []struct {
+		name string
+		opts EncodeOpts
+	}{
+		{"plain", EncodeOpts{}},
+		{"compressed", EncodeOpts{Compress: true}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			var buf bytes.Buffer
+			if err := g.EncodeWith(&buf, bm.opts); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := DecodeGraph(bytes.NewReader(buf.Bytes())); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(buf.Len()), "encoded-bytes")
+		})
+	}
+}
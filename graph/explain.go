@@ -0,0 +1,101 @@
+// ABOUTME: Gathers everything about why one object is alive into one report
+// ABOUTME: Combines its shortest root path, dominator, cycle membership, and retained types
+
+package graph
+
+// explainTopTypes is how many types ExplainRetention lists
+const explainTopTypes = 5
+
+// RetentionExplanation describes why an object is alive, as
+// ExplainRetention returns
+type RetentionExplanation struct {
+	ID           ObjID
+	Type         string
+	Size         uint64
+	HasFinalizer bool
+
+	// Reachable is false if no root retains the object. Retained,
+	// PathToRoot, Dominator, InCycle, and TopTypes are then left empty.
+	Reachable bool
+
+	// Retained is the object's retained size, as RetainedSize gives it
+	Retained uint64
+
+	// PathToRoot is a shortest path from the object to a root, as
+	// ShortestPathToRoot finds it. A root's path is just itself.
+	PathToRoot Path
+
+	// Dominator is the object's immediate dominator, or 0 if no single
+	// object dominates it, as for roots and objects reachable from
+	// several roots
+	Dominator ObjID
+
+	// InCycle reports whether the object is part of a reference cycle
+	InCycle bool
+
+	// TopTypes groups the objects the object dominates, itself included,
+	// by type, largest first. At most five types are listed, with
+	// PercentOfTotal relative to Retained.
+	TopTypes []TypeStat
+}
+
+// ExplainRetention answers why id is alive in one pass over g's analyses,
+// sparing separate path, dominator, and cycle queries. An object not in g
+// gets an explanation with only ID set.
+func ExplainRetention(g Graph, id ObjID) RetentionExplanation {
+	e := RetentionExplanation{ID: id}
+	obj := g.GetObject(id)
+	if obj == nil {
+		return e
+	}
+	e.Type, e.Size, e.HasFinalizer = obj.Type, obj.Size, obj.HasFinalizer
+
+	di := ComputeDominators(g)
+	dom, ok := di.Idom[id]
+	if !ok {
+		return e
+	}
+	e.Reachable = true
+	e.Dominator = dom
+	e.PathToRoot, _ = ShortestPathToRoot(g, id)
+
+	set := di.DominatedSet(id)
+	for _, n := range set {
+		e.Retained += g.GetObject(n).Size
+	}
+	e.TopTypes = TypeHistogram(Subgraph(g, set))
+	if len(e.TopTypes) > explainTopTypes {
+		e.TopTypes = e.TopTypes[:explainTopTypes]
+	}
+
+	e.InCycle = di.d.inCycle(id)
+	return e
+}
+
+// inCycle reports whether the reachable object id shares a strongly
+// connected component with another object or points to itself
+func (d *denseGraph) inCycle(id ObjID) bool {
+	v := -1
+	for i := 1; i < len(d.ids); i++ {
+		if d.ids[i] == id {
+			v = i
+			break
+		}
+	}
+	if v < 0 {
+		return false
+	}
+
+	for _, w := range d.succ[d.start[v]:d.start[v+1]] {
+		if int(w) == v {
+			return true
+		}
+	}
+	comp, _ := d.components()
+	for w, c := range comp {
+		if w != v && c >= 0 && c == comp[v] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+// ABOUTME: Tests for explaining why an object is retained
+// ABOUTME: Covers dominated, shared, cyclic, rooted, unreachable, and missing objects
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExplainRetention(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "*main.Server", Size: 64, Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "*main.Cache", Size: 32, Ptrs: []ObjID{3, 4, 9}})
+	g.AddObject(&Object{ID: 3, Type: "map[string]*main.Entry", Size: 1000, Ptrs: []ObjID{5, 6}})
+	g.AddObject(&Object{ID: 4, Type: "[]uint8", Size: 500, HasFinalizer: true})
+	g.AddObject(&Object{ID: 5, Type: "main.Entry", Size: 100, Ptrs: []ObjID{6}})
+	g.AddObject(&Object{ID: 6, Type: "main.Entry", Size: 100, Ptrs: []ObjID{5}})
+	g.AddObject(&Object{ID: 7, Type: "main.globals", Size: 8, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 8, Type: "string", Size: 16})
+	g.AddObject(&Object{ID: 9, Type: "*main.Node", Size: 24, Ptrs: []ObjID{9}})
+	g.SetRoots(Roots{IDs: []ObjID{1, 7}})
+
+	want := RetentionExplanation{
+		ID:         3,
+		Type:       "map[string]*main.Entry",
+		Size:       1000,
+		Reachable:  true,
+		Retained:   1200,
+		PathToRoot: Path{IDs: []ObjID{3, 2, 1}},
+		Dominator:  2,
+		TopTypes: []TypeStat{
+			{Type: "map[string]*main.Entry", Count: 1, TotalSize: 1000, PercentOfTotal: percent(1000, 1200)},
+			{Type: "main.Entry", Count: 2, TotalSize: 200, PercentOfTotal: percent(200, 1200)},
+		},
+	}
+	if got := ExplainRetention(g, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExplainRetention(3) = %+v, want %+v", got, want)
+	}
+
+	tests := []struct {
+		id        ObjID
+		reachable bool
+		retained  uint64
+		path      []ObjID
+		dominator ObjID
+		inCycle   bool
+		finalizer bool
+	}{
+		{id: 1, reachable: true, retained: 1320, path: []ObjID{1}},
+		{id: 4, reachable: true, retained: 500, path: []ObjID{4, 7}, finalizer: true},
+		{id: 5, reachable: true, retained: 100, path: []ObjID{5, 3, 2, 1}, dominator: 3, inCycle: true},
+		{id: 9, reachable: true, retained: 24, path: []ObjID{9, 2, 1}, dominator: 2, inCycle: true},
+		{id: 8},
+	}
+	for _, tt := range tests {
+		e := ExplainRetention(g, tt.id)
+		if e.Reachable != tt.reachable || e.Retained != tt.retained || e.Dominator != tt.dominator || e.InCycle != tt.inCycle || e.HasFinalizer != tt.finalizer {
+			t.Errorf("ExplainRetention(%d) = %+v", tt.id, e)
+		}
+		if !reflect.DeepEqual(e.PathToRoot.IDs, tt.path) {
+			t.Errorf("ExplainRetention(%d) path = %v, want %v", tt.id, e.PathToRoot.IDs, tt.path)
+		}
+	}
+
+	if got := ExplainRetention(g, 99); !reflect.DeepEqual(got, RetentionExplanation{ID: 99}) {
+		t.Errorf("ExplainRetention(missing) = %+v, want only the ID", got)
+	}
+}
@@ -0,0 +1,51 @@
+// ABOUTME: Predicate-based object selection for building custom reports
+// ABOUTME: Combines size, kind, and type conditions over any Graph
+
+package graph
+
+import "sort"
+
+// Predicate reports whether an object should be selected
+type Predicate func(*Object) bool
+
+// ForEachObjectWhere calls fn for every object in g that pred accepts.
+// Iteration order is that of g.ForEachObject.
+func ForEachObjectWhere(g Graph, pred Predicate, fn func(*Object)) {
+	g.ForEachObject(func(obj *Object) {
+		if pred(obj) {
+			fn(obj)
+		}
+	})
+}
+
+// Filter returns the IDs of objects that pred accepts, in ascending order
+func Filter(g Graph, pred Predicate) []ObjID {
+	var ids []ObjID
+	ForEachObjectWhere(g, pred, func(obj *Object) {
+		ids = append(ids, obj.ID)
+	})
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// OfKind accepts objects that Classify puts in kind k
+func OfKind(k Kind) Predicate {
+	return func(obj *Object) bool { return Classify(obj) == k }
+}
+
+// LargerThan accepts objects whose Size exceeds n bytes
+func LargerThan(n uint64) Predicate {
+	return func(obj *Object) bool { return obj.Size > n }
+}
+
+// And accepts objects that every one of preds accepts
+func And(preds ...Predicate) Predicate {
+	return func(obj *Object) bool {
+		for _, pred := range preds {
+			if !pred(obj) {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,49 @@
+// ABOUTME: Tests for predicate-based object filtering
+// ABOUTME: Combines kind and size predicates as a custom report would
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	const mb = 1 << 20
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "[]uint8", Size: 4 * mb})
+	g.AddObject(&Object{ID: 2, Type: "[]string", Size: 64})
+	g.AddObject(&Object{ID: 3, Type: "main.Cache", Size: 2 * mb})
+	g.AddObject(&Object{ID: 4, Type: "[]*main.Entry", Size: mb + 1})
+	g.AddObject(&Object{ID: 5, Type: "[]uint8", Size: mb})
+
+	tests := []struct {
+		name string
+		pred Predicate
+		want []ObjID
+	}{
+		{name: "slices", pred: OfKind(KindSlice), want: []ObjID{1, 2, 4, 5}},
+		{name: "larger than 1MB", pred: LargerThan(mb), want: []ObjID{1, 3, 4}},
+		{name: "slices larger than 1MB", pred: And(OfKind(KindSlice), LargerThan(mb)), want: []ObjID{1, 4}},
+		{name: "no match", pred: OfKind(KindMap), want: nil},
+		{name: "empty And", pred: And(), want: []ObjID{1, 2, 3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Filter(g, tt.pred); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Filter() = %v, want %v", got, tt.want)
+			}
+
+			var size uint64
+			ForEachObjectWhere(g, tt.pred, func(obj *Object) { size += obj.Size })
+			var want uint64
+			for _, id := range tt.want {
+				want += g.GetObject(id).Size
+			}
+			if size != want {
+				t.Errorf("ForEachObjectWhere() visited %d bytes, want %d", size, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// ABOUTME: Finds objects kept alive only by pending finalizers
+// ABOUTME: Separates finalizer-retained objects from ones held by ordinary roots
+
+package graph
+
+import "sort"
+
+// FinalizerRetained returns the objects, sorted by ID, that only finalizer
+// roots (RootFinalizer) reach: no stack, global, or other root has a path
+// to them. They survive because a finalizer still has to run, which plain
+// reachability would otherwise count as garbage still in the dump.
+func FinalizerRetained(g Graph) []ObjID {
+	roots := g.GetRoots()
+	var finalizerRoots, otherRoots []ObjID
+	for i, id := range roots.IDs {
+		if roots.Kind(i) == RootFinalizer {
+			finalizerRoots = append(finalizerRoots, id)
+		} else {
+			otherRoots = append(otherRoots, id)
+		}
+	}
+	if len(finalizerRoots) == 0 {
+		return nil
+	}
+
+	reached := reachableFrom(g, otherRoots)
+	var result []ObjID
+	for id := range reachableFrom(g, finalizerRoots) {
+		if !reached[id] {
+			result = append(result, id)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// reachableFrom returns the set of objects reachable from ids, including
+// the ones in ids that are in g
+func reachableFrom(g Graph, ids []ObjID) map[ObjID]bool {
+	seen := make(map[ObjID]bool)
+	var stack []ObjID
+	for _, id := range ids {
+		if !seen[id] && g.GetObject(id) != nil {
+			seen[id] = true
+			stack = append(stack, id)
+		}
+	}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, ptr := range g.GetObject(id).Ptrs {
+			if !seen[ptr] && g.GetObject(ptr) != nil {
+				seen[ptr] = true
+				stack = append(stack, ptr)
+			}
+		}
+	}
+	return seen
+}
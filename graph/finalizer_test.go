@@ -0,0 +1,42 @@
+// ABOUTME: Tests for finding objects retained only by finalizers
+// ABOUTME: Mixes finalizer roots with ordinary roots that share objects
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFinalizerRetained(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "main.Global", Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "main.Shared"})
+	// 3 is reachable only from the finalizer queue, and keeps 4 alive
+	g.AddObject(&Object{ID: 3, Type: "*os.File", HasFinalizer: true, Ptrs: []ObjID{4, 2}})
+	g.AddObject(&Object{ID: 4, Type: "[]byte"})
+	// 5 is garbage
+	g.AddObject(&Object{ID: 5, Type: "main.Garbage"})
+	g.SetRoots(Roots{
+		IDs:   []ObjID{1, 3},
+		Kinds: []RootKind{RootGlobal, RootFinalizer},
+	})
+
+	if got, want := FinalizerRetained(g), []ObjID{3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FinalizerRetained() = %v, want %v", got, want)
+	}
+
+	// Once an ordinary root also reaches it, it is not finalizer-retained
+	g.SetRoots(Roots{
+		IDs:   []ObjID{1, 3, 3},
+		Kinds: []RootKind{RootGlobal, RootFinalizer, RootStack},
+	})
+	if got := FinalizerRetained(g); len(got) != 0 {
+		t.Errorf("FinalizerRetained() with a stack root = %v, want none", got)
+	}
+
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+	if got := FinalizerRetained(g); got != nil {
+		t.Errorf("FinalizerRetained() without finalizer roots = %v, want nil", got)
+	}
+}
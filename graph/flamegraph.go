@@ -0,0 +1,58 @@
+// ABOUTME: Exports the dominator tree as folded stacks for flamegraph tools
+// ABOUTME: Output works with flamegraph.pl, speedscope, and similar viewers
+
+package graph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteFlameGraph writes the dominator tree of g in collapsed-stack format.
+// Each reachable object becomes one line listing its dominator path from the
+// super-root as semicolon-separated type@ID frames, followed by the object's
+// own size. Summing a frame's subtree therefore yields its retained size.
+// Objects with zero size are omitted since they add no samples.
+func WriteFlameGraph(w io.Writer, g Graph) error {
+	tree := ComputeDominators(g).Tree
+
+	bw := bufio.NewWriter(w)
+
+	type frame struct {
+		id    ObjID
+		depth int
+	}
+	var path []string
+	stack := []frame{{id: 0}}
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if f.id != 0 {
+			obj := g.GetObject(f.id)
+			if obj == nil {
+				continue
+			}
+			path = append(path[:f.depth-1], flameFrame(obj))
+			if obj.Size > 0 {
+				fmt.Fprintf(bw, "%s %d\n", strings.Join(path, ";"), obj.Size)
+			}
+		}
+
+		// Push in reverse so children are emitted in ascending ID order
+		children := tree[f.id]
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, frame{id: children[i], depth: f.depth + 1})
+		}
+	}
+
+	return bw.Flush()
+}
+
+// flameFrame labels an object as type@ID. Semicolons separate frames in the
+// folded format, so any in the type name (e.g. struct literals) become commas.
+func flameFrame(obj *Object) string {
+	return fmt.Sprintf("%s@%d", strings.ReplaceAll(obj.Type, ";", ","), obj.ID)
+}
@@ -0,0 +1,53 @@
+// ABOUTME: Tests for collapsed-stack flamegraph export of the dominator tree
+// ABOUTME: Verifies stack paths follow dominators and sizes are self sizes
+
+package graph
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteFlameGraph(t *testing.T) {
+	// 1 -> 2 -> 4, 1 -> 3 -> 4: 4 is dominated by 1, not by 2 or 3
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Size: 100, Ptrs: []ObjID{2, 3}})
+	g.AddObject(&Object{ID: 2, Type: "left", Size: 30, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 3, Type: "struct { a int; b int }", Size: 40, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 4, Type: "merge", Size: 20})
+	g.AddObject(&Object{ID: 5, Type: "empty", Size: 0})
+	g.AddObject(&Object{ID: 6, Type: "garbage", Size: 999})
+	g.SetRoots(Roots{IDs: []ObjID{1, 5}})
+
+	var buf bytes.Buffer
+	if err := WriteFlameGraph(&buf, g); err != nil {
+		t.Fatalf("WriteFlameGraph() error = %v", err)
+	}
+
+	want := "root@1 100\n" +
+		"root@1;left@2 30\n" +
+		"root@1;struct { a int, b int }@3 40\n" +
+		"root@1;merge@4 20\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteFlameGraph() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteFlameGraphDeepChain(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "a", Size: 1, Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "b", Size: 2, Ptrs: []ObjID{3}})
+	g.AddObject(&Object{ID: 3, Type: "c", Size: 3})
+	g.AddObject(&Object{ID: 7, Type: "d", Size: 4})
+	g.SetRoots(Roots{IDs: []ObjID{1, 7}})
+
+	var buf bytes.Buffer
+	if err := WriteFlameGraph(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "a@1 1\na@1;b@2 2\na@1;b@2;c@3 3\nd@7 4\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteFlameGraph() =\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+// ABOUTME: Compares a heap against a baseline and flags growth over limits
+// ABOUTME: Turns retained size diffs into pass/fail checks for CI
+
+package graph
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GrowthLimit bounds how much a size may grow over its baseline. A zero
+// Bytes or Percent is no limit; exceeding either set one is a violation.
+type GrowthLimit struct {
+	Bytes   int64   // Maximum growth in bytes
+	Percent float64 // Maximum growth as a percentage of the baseline
+
+	// MinBytes is growth Percent ignores, so a small type that doubles by
+	// a few bytes is not a violation
+	MinBytes int64
+}
+
+// exceeded reports whether growing from base to cur breaks the limit.
+// Percentages don't apply when there is no baseline to compare to.
+func (l GrowthLimit) exceeded(base, cur uint64) bool {
+	growth := int64(cur) - int64(base)
+	if l.Bytes > 0 && growth > l.Bytes {
+		return true
+	}
+	return l.Percent > 0 && base > 0 && growth > l.MinBytes && float64(growth)*100 > l.Percent*float64(base)
+}
+
+// GateRules configures LeakGate
+type GateRules struct {
+	// Total limits growth of the whole reachable heap
+	Total GrowthLimit
+
+	// PerType limits growth of each type's retained size, unless Types
+	// has an entry for it
+	PerType GrowthLimit
+	Types   map[string]GrowthLimit
+}
+
+// Violation is a size that grew past its limit
+type Violation struct {
+	Type     string // Empty for the whole heap
+	Baseline uint64
+	Current  uint64
+	Limit    GrowthLimit
+}
+
+// Growth returns how many bytes the size grew
+func (v Violation) Growth() int64 {
+	return int64(v.Current) - int64(v.Baseline)
+}
+
+func (v Violation) String() string {
+	name := v.Type
+	if name == "" {
+		name = "total heap"
+	}
+	return fmt.Sprintf("%s grew by %d bytes (%d -> %d)", name, v.Growth(), v.Baseline, v.Current)
+}
+
+// LeakGate checks current against baseline and returns every limit in
+// rules that current breaks: total reachable bytes against rules.Total,
+// then each type's retained size (see RetainedSizeByType) against its
+// limit. Types new in current have a baseline of 0, so only byte limits
+// apply to them. The total violation comes first, then types by growth,
+// largest first. An empty result means the gate passes.
+func LeakGate(baseline, current Graph, rules GateRules) []Violation {
+	var violations []Violation
+	if base, cur := ReachableSize(baseline), ReachableSize(current); rules.Total.exceeded(base, cur) {
+		violations = append(violations, Violation{Baseline: base, Current: cur, Limit: rules.Total})
+	}
+
+	baseSizes := RetainedSizeByType(baseline)
+	var types []Violation
+	for typ, cur := range RetainedSizeByType(current) {
+		limit, ok := rules.Types[typ]
+		if !ok {
+			limit = rules.PerType
+		}
+		if base := baseSizes[typ]; limit.exceeded(base, cur) {
+			types = append(types, Violation{Type: typ, Baseline: base, Current: cur, Limit: limit})
+		}
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if gi, gj := types[i].Growth(), types[j].Growth(); gi != gj {
+			return gi > gj
+		}
+		return types[i].Type < types[j].Type
+	})
+	return append(violations, types...)
+}
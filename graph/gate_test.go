@@ -0,0 +1,76 @@
+// ABOUTME: Tests for the baseline comparison leak gate
+// ABOUTME: Checks byte and percent limits, per-type overrides, and ordering
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+// gateGraph builds a heap with one root per type, each retaining size bytes
+func gateGraph(sizes map[string]uint64) Graph {
+	g := NewMemGraph()
+	var roots []ObjID
+	for typ, size := range sizes {
+		id := ObjID(len(roots) + 1)
+		g.AddObject(&Object{ID: id, Type: typ, Size: size})
+		roots = append(roots, id)
+	}
+	g.SetRoots(Roots{IDs: roots})
+	return g
+}
+
+func TestLeakGate(t *testing.T) {
+	baseline := gateGraph(map[string]uint64{"cache": 1000, "conn": 500, "buf": 100})
+	current := gateGraph(map[string]uint64{"cache": 1500, "conn": 540, "buf": 400, "new": 50})
+
+	tests := []struct {
+		name  string
+		rules GateRules
+		want  []Violation
+	}{
+		{
+			name: "no limits",
+		},
+		{
+			name:  "total bytes",
+			rules: GateRules{Total: GrowthLimit{Bytes: 800}},
+			want:  []Violation{{Baseline: 1600, Current: 2490, Limit: GrowthLimit{Bytes: 800}}},
+		},
+		{
+			name:  "per-type percent skips new types",
+			rules: GateRules{PerType: GrowthLimit{Percent: 10}},
+			want: []Violation{
+				{Type: "cache", Baseline: 1000, Current: 1500, Limit: GrowthLimit{Percent: 10}},
+				{Type: "buf", Baseline: 100, Current: 400, Limit: GrowthLimit{Percent: 10}},
+			},
+		},
+		{
+			name:  "per-type percent ignores growth under MinBytes",
+			rules: GateRules{PerType: GrowthLimit{Percent: 10, MinBytes: 300}},
+			want: []Violation{
+				{Type: "cache", Baseline: 1000, Current: 1500, Limit: GrowthLimit{Percent: 10, MinBytes: 300}},
+			},
+		},
+		{
+			name: "override loosens one type",
+			rules: GateRules{
+				PerType: GrowthLimit{Bytes: 40},
+				Types:   map[string]GrowthLimit{"cache": {Percent: 60}},
+			},
+			want: []Violation{
+				{Type: "buf", Baseline: 100, Current: 400, Limit: GrowthLimit{Bytes: 40}},
+				{Type: "new", Baseline: 0, Current: 50, Limit: GrowthLimit{Bytes: 40}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LeakGate(baseline, current, tt.rules); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LeakGate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
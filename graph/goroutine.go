@@ -0,0 +1,104 @@
+// ABOUTME: Attributes retained memory to the goroutines whose stacks hold it
+// ABOUTME: Gives each goroutine a virtual node over its stack roots in the dominator tree
+
+package graph
+
+// GoroutineRetained returns, for each goroutine with a stack root, the
+// total size of the objects reachable only through that goroutine's
+// stack. Objects also reachable from another goroutine or from a global,
+// finalizer, or other root are not counted for any goroutine. Roots
+// without a goroutine (see Roots.Goroutines) are treated as shared, so a
+// graph without goroutine information yields an empty map.
+func GoroutineRetained(g Graph) map[uint64]uint64 {
+	d, goroutines := newGoroutineDenseGraph(g)
+	result := make(map[uint64]uint64, len(goroutines))
+	if len(goroutines) == 0 {
+		return result
+	}
+
+	idom, order := d.dominators()
+	retained := d.retainedSizes(idom, order, func(obj *Object) uint64 { return obj.Size })
+	for k, gid := range goroutines {
+		result[gid] = retained[k+1]
+	}
+	return result
+}
+
+// newGoroutineDenseGraph is like newDenseGraph, but inserts one virtual
+// node per goroutine right after the super-root. The super-root points at
+// the virtual nodes and the roots without a goroutine; each virtual node
+// points at its goroutine's stack roots, so it dominates exactly what that
+// goroutine alone keeps alive. It also returns the goroutine IDs, with
+// goroutines[k] at index k+1.
+func newGoroutineDenseGraph(g Graph) (*denseGraph, []uint64) {
+	roots := g.GetRoots()
+	var shared []ObjID
+	var goroutines []uint64
+	stacks := make(map[uint64][]ObjID)
+	for i, id := range roots.IDs {
+		gid := roots.Goroutine(i)
+		if gid == 0 {
+			shared = append(shared, id)
+			continue
+		}
+		if _, ok := stacks[gid]; !ok {
+			goroutines = append(goroutines, gid)
+		}
+		stacks[gid] = append(stacks[gid], id)
+	}
+
+	base := newDenseGraphFrom(g, Roots{IDs: shared})
+	if len(goroutines) == 0 {
+		return base, nil
+	}
+
+	// Dense indexes of the stack roots, shifted past the virtual nodes
+	shift := int32(len(goroutines))
+	index := make(map[ObjID]int32)
+	for _, ids := range stacks {
+		for _, id := range ids {
+			index[id] = -1
+		}
+	}
+	for v := 1; v < len(base.ids); v++ {
+		if _, ok := index[base.ids[v]]; ok {
+			index[base.ids[v]] = int32(v) + shift
+		}
+	}
+
+	n := len(base.ids) + len(goroutines)
+	d := &denseGraph{
+		ids:   make([]ObjID, n),
+		objs:  make([]*Object, n),
+		start: make([]int, n+1),
+		succ:  make([]int32, 0, len(base.succ)+len(goroutines)+len(roots.IDs)),
+	}
+	for _, w := range base.succ[base.start[0]:base.start[1]] {
+		d.succ = append(d.succ, w+shift)
+	}
+	for k := range goroutines {
+		d.succ = append(d.succ, int32(k+1))
+	}
+	d.start[1] = len(d.succ)
+
+	virtual := &Object{}
+	for k, gid := range goroutines {
+		d.objs[k+1] = virtual
+		for _, id := range stacks[gid] {
+			if w := index[id]; w >= 0 {
+				d.succ = append(d.succ, w)
+			}
+		}
+		d.start[k+2] = len(d.succ)
+	}
+
+	for v := 1; v < len(base.ids); v++ {
+		dv := v + int(shift)
+		d.ids[dv], d.objs[dv] = base.ids[v], base.objs[v]
+		for _, w := range base.succ[base.start[v]:base.start[v+1]] {
+			d.succ = append(d.succ, w+shift)
+		}
+		d.start[dv+1] = len(d.succ)
+	}
+	return d, goroutines
+}
@@ -0,0 +1,45 @@
+// ABOUTME: Tests for per-goroutine retained memory
+// ABOUTME: Checks objects shared between goroutines or with other roots go uncounted
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGoroutineRetained(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "*main.Request", Size: 100, Ptrs: []ObjID{2, 8}})
+	g.AddObject(&Object{ID: 2, Type: "[]byte", Size: 10})
+	g.AddObject(&Object{ID: 3, Type: "*main.Pool", Size: 1000})
+	g.AddObject(&Object{ID: 4, Type: "*main.Conn", Size: 50, Ptrs: []ObjID{5}})
+	g.AddObject(&Object{ID: 5, Type: "*main.Config", Size: 5})
+	g.AddObject(&Object{ID: 6, Type: "main.globals", Size: 1, Ptrs: []ObjID{5, 7}})
+	g.AddObject(&Object{ID: 7, Type: "*main.Logger", Size: 8})
+	g.AddObject(&Object{ID: 8, Type: "string", Size: 20})
+	g.SetRoots(Roots{
+		// Goroutine 7 holds 1 and 8 alone; 3 is on both 7's and 9's
+		// stacks; 5 and 7 are also reachable from a global
+		IDs:        []ObjID{1, 3, 8, 3, 4, 6, 7, 99},
+		Kinds:      []RootKind{RootStack, RootStack, RootStack, RootStack, RootStack, RootGlobal, RootStack, RootStack},
+		Goroutines: []uint64{7, 7, 7, 9, 9, 0, 11, 12},
+	})
+
+	want := map[uint64]uint64{7: 130, 9: 50, 11: 0, 12: 0}
+	for name, tg := range map[string]Graph{"MemGraph": g, "CompactGraph": Compact(g)} {
+		if got := GoroutineRetained(tg); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: GoroutineRetained() = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestGoroutineRetainedWithoutGoroutines(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "main.T", Size: 8})
+	g.SetRoots(Roots{IDs: []ObjID{1}, Kinds: []RootKind{RootStack}})
+
+	if got := GoroutineRetained(g); len(got) != 0 {
+		t.Errorf("GoroutineRetained() = %v, want an empty map", got)
+	}
+}
@@ -3,9 +3,17 @@
 
 package graph
 
-import "sync"
+import (
+	"slices"
+	"sort"
+	"sync"
+)
 
-// Graph represents a heap object graph
+// Graph represents a heap object graph.
+//
+// Implementations may guard individual calls, but algorithms make many
+// calls in sequence, so a Graph must not be mutated while an analysis runs
+// on it. Analyze a MemGraph.Snapshot when other goroutines may modify it.
 type Graph interface {
 	// AddObject adds an object to the graph
 	AddObject(obj *Object)
@@ -31,12 +39,20 @@ type MemGraph struct {
 	mu      sync.RWMutex
 	objects map[ObjID]*Object
 	roots   Roots
+	maxID   ObjID // largest ID added, for AddObjectAutoID
+
+	// reverse caches Referrers results; nil when stale
+	reverse ReverseEdges
+
+	// numEdges caches NumEdges; -1 when stale
+	numEdges int
 }
 
 // NewMemGraph creates a new in-memory graph
 func NewMemGraph() *MemGraph {
 	return &MemGraph{
-		objects: make(map[ObjID]*Object),
+		objects:  make(map[ObjID]*Object),
+		numEdges: -1,
 	}
 }
 
@@ -45,6 +61,50 @@ func (g *MemGraph) AddObject(obj *Object) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	g.objects[obj.ID] = obj
+	g.maxID = max(g.maxID, obj.ID)
+	g.reverse = nil
+	g.numEdges = -1
+}
+
+// AddObjectAutoID assigns obj the next free ID, one past the largest ID
+// added so far (starting at 1, since ID 0 is reserved), adds it to the
+// graph, and returns the ID
+func (g *MemGraph) AddObjectAutoID(obj *Object) ObjID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.maxID++
+	obj.ID = g.maxID
+	g.objects[obj.ID] = obj
+	g.reverse = nil
+	g.numEdges = -1
+	return obj.ID
+}
+
+// LinkByAddr resolves raw pointer addresses to object IDs, the way a
+// parser does once every object's address is known. For each object in
+// ptrs, every address found in addrToID is appended to its Ptrs. It
+// returns the number of addresses that matched no object.
+func LinkByAddr(g *MemGraph, addrToID map[uint64]ObjID, ptrs map[ObjID][]uint64) int {
+	unresolved := 0
+	for id, addrs := range ptrs {
+		obj := g.GetObject(id)
+		if obj == nil {
+			unresolved += len(addrs)
+			continue
+		}
+		for _, addr := range addrs {
+			if target, ok := addrToID[addr]; ok {
+				obj.Ptrs = append(obj.Ptrs, target)
+			} else {
+				unresolved++
+			}
+		}
+	}
+	g.mu.Lock()
+	g.reverse = nil
+	g.numEdges = -1
+	g.mu.Unlock()
+	return unresolved
 }
 
 // GetObject retrieves an object by ID
@@ -70,6 +130,93 @@ func (g *MemGraph) ForEachObject(fn func(*Object)) {
 	}
 }
 
+// Referrers implements ReferrerGraph, caching until the graph changes
+func (g *MemGraph) Referrers(id ObjID) []ObjID {
+	g.mu.RLock()
+	reverse := g.reverse
+	g.mu.RUnlock()
+	if reverse != nil {
+		return reverse[id]
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.reverse == nil {
+		g.reverse = make(ReverseEdges)
+		for _, obj := range g.objects {
+			for _, ptr := range obj.Ptrs {
+				g.reverse[ptr] = append(g.reverse[ptr], obj.ID)
+			}
+		}
+		for id, refs := range g.reverse {
+			sort.Slice(refs, func(i, j int) bool { return refs[i] < refs[j] })
+			g.reverse[id] = slices.Compact(refs)
+		}
+	}
+	return g.reverse[id]
+}
+
+// NumEdges returns the total number of pointers held by all objects. Like
+// Referrers, it is counted on the first call and cached until the next
+// AddObject, AddObjectAutoID, or LinkByAddr, so pointers changed in place
+// are not noticed.
+func (g *MemGraph) NumEdges() int {
+	g.mu.RLock()
+	n := g.numEdges
+	g.mu.RUnlock()
+	if n >= 0 {
+		return n
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.numEdges < 0 {
+		g.numEdges = 0
+		for _, obj := range g.objects {
+			g.numEdges += len(obj.Ptrs)
+		}
+	}
+	return g.numEdges
+}
+
+// ForEachObjectSorted is like ForEachObject but visits objects in
+// ascending ID order, for output that must be reproducible. It sorts on
+// every call, so internal passes that don't care about order should use
+// ForEachObject.
+func (g *MemGraph) ForEachObjectSorted(fn func(*Object)) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	objs := make([]*Object, 0, len(g.objects))
+	for _, obj := range g.objects {
+		objs = append(objs, obj)
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].ID < objs[j].ID })
+	for _, obj := range objs {
+		fn(obj)
+	}
+}
+
+// ForEachObjectSorted visits the objects of any graph in ascending ID
+// order. CompactGraph already iterates in that order, so it costs nothing
+// extra there.
+func ForEachObjectSorted(g Graph, fn func(*Object)) {
+	switch g := g.(type) {
+	case *CompactGraph:
+		g.ForEachObject(fn)
+	case *MemGraph:
+		g.ForEachObjectSorted(fn)
+	default:
+		ids := make([]ObjID, 0, g.NumObjects())
+		g.ForEachObject(func(obj *Object) {
+			ids = append(ids, obj.ID)
+		})
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			fn(g.GetObject(id))
+		}
+	}
+}
+
 // SetRoots sets the GC roots
 func (g *MemGraph) SetRoots(roots Roots) {
 	g.mu.Lock()
@@ -82,4 +229,22 @@ func (g *MemGraph) GetRoots() Roots {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 	return g.roots
+}
+
+// Snapshot returns a frozen copy of the graph taken under one read lock.
+// Later changes to g do not affect it, so long-running analyses see a
+// consistent graph, and it is safe for concurrent reads. Its AddObject
+// and SetRoots panic.
+func (g *MemGraph) Snapshot() Graph {
+	g.mu.RLock()
+	objs := make([]*Object, 0, len(g.objects))
+	for _, obj := range g.objects {
+		objs = append(objs, obj)
+	}
+	roots := g.roots
+	g.mu.RUnlock()
+
+	cg := compactObjects(objs, roots)
+	cg.frozen = true
+	return cg
 }
\ No newline at end of file
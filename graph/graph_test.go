@@ -4,6 +4,8 @@
 package graph
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -89,6 +91,125 @@ func TestIDUniqueness(t *testing.T) {
 	}
 }
 
+func TestAddObjectAutoID(t *testing.T) {
+	g := NewMemGraph()
+
+	first := &Object{Type: "first"}
+	if id := g.AddObjectAutoID(first); id != 1 || first.ID != 1 {
+		t.Errorf("first auto ID = %d (object has %d), want 1", id, first.ID)
+	}
+
+	g.AddObject(&Object{ID: 10, Type: "explicit"})
+	if id := g.AddObjectAutoID(&Object{Type: "after"}); id != 11 {
+		t.Errorf("auto ID after explicit 10 = %d, want 11", id)
+	}
+	if g.NumObjects() != 3 || g.GetObject(1) != first {
+		t.Errorf("expected 3 objects with the first at ID 1, got %d", g.NumObjects())
+	}
+}
+
+func TestLinkByAddr(t *testing.T) {
+	g := NewMemGraph()
+	addrs := map[uint64]ObjID{
+		0x1000: g.AddObjectAutoID(&Object{Type: "a"}),
+		0x2000: g.AddObjectAutoID(&Object{Type: "b"}),
+		0x3000: g.AddObjectAutoID(&Object{Type: "c"}),
+	}
+
+	unresolved := LinkByAddr(g, addrs, map[ObjID][]uint64{
+		addrs[0x1000]: {0x2000, 0x3000},
+		addrs[0x2000]: {0x3000, 0xdead},
+		99:            {0x1000},
+	})
+	if unresolved != 2 {
+		t.Errorf("unresolved = %d, want 2", unresolved)
+	}
+	if got := g.GetObject(1).Ptrs; !reflect.DeepEqual(got, []ObjID{2, 3}) {
+		t.Errorf("object 1 ptrs = %v, want [2 3]", got)
+	}
+	if got := g.GetObject(2).Ptrs; !reflect.DeepEqual(got, []ObjID{3}) {
+		t.Errorf("object 2 ptrs = %v, want [3]", got)
+	}
+}
+
+func TestForEachObjectSorted(t *testing.T) {
+	g := NewMemGraph()
+	for _, id := range []ObjID{42, 7, 1000, 3, 99} {
+		g.AddObject(&Object{ID: id, Type: "t"})
+	}
+
+	want := []ObjID{3, 7, 42, 99, 1000}
+	for name, graph := range map[string]Graph{"MemGraph": g, "CompactGraph": Compact(g), "other": scanGraph{g}} {
+		var got []ObjID
+		ForEachObjectSorted(graph, func(obj *Object) { got = append(got, obj.ID) })
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: ForEachObjectSorted() visited %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestObjectPayloadAccessors(t *testing.T) {
+	obj := &Object{Data: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}}
+
+	if v, ok := obj.ReadUint64(1, false); !ok || v != 0x0908070605040302 {
+		t.Errorf("ReadUint64(1, little) = %#x, %v", v, ok)
+	}
+	if v, ok := obj.ReadUint64(2, true); !ok || v != 0x030405060708090a {
+		t.Errorf("ReadUint64(2, big) = %#x, %v", v, ok)
+	}
+	if v, ok := obj.ReadWord(1, 4, false); !ok || v != 0x05040302 {
+		t.Errorf("ReadWord(1, 4, little) = %#x, %v", v, ok)
+	}
+	if v, ok := obj.ReadWord(6, 4, true); !ok || v != 0x0708090a {
+		t.Errorf("ReadWord(6, 4, big) = %#x, %v", v, ok)
+	}
+	if _, ok := obj.ReadWord(0, 2, false); ok {
+		t.Error("ReadWord with a 2-byte word should fail")
+	}
+	if b, ok := obj.Bytes(8, 2); !ok || !reflect.DeepEqual(b, []byte{9, 10}) {
+		t.Errorf("Bytes(8, 2) = %v, %v", b, ok)
+	}
+
+	for _, tt := range []struct{ offset, n int }{{3, 8}, {-1, 1}, {11, 0}, {0, -1}} {
+		if _, ok := obj.Bytes(tt.offset, tt.n); ok {
+			t.Errorf("Bytes(%d, %d) should be out of range", tt.offset, tt.n)
+		}
+	}
+	if _, ok := (&Object{}).ReadUint64(0, false); ok {
+		t.Error("ReadUint64 without a payload should fail")
+	}
+}
+
+func TestObjectAsString(t *testing.T) {
+	long := strings.Repeat("a", MaxStringPreview+10)
+	tests := []struct {
+		name   string
+		obj    *Object
+		want   string
+		wantOK bool
+	}{
+		{"string", &Object{Type: "string", Data: []byte("hello, 世界")}, "hello, 世界", true},
+		{"byte slice", &Object{Type: "[]byte", Data: []byte("a\tb\n")}, `a\tb\n`, true},
+		{"uint8 slice", &Object{Type: "[]uint8", Data: []byte{'x', 0}}, `x\x00`, true},
+		{"invalid utf8", &Object{Type: "string", Data: []byte{'a', 0xff, 'b'}}, `a\xffb`, true},
+		{"backslash", &Object{Type: "string", Data: []byte(`a\b`)}, `a\\b`, true},
+		{"empty", &Object{Type: "string", Data: []byte{}}, "", true},
+		{"truncated", &Object{Type: "string", Data: []byte(long)}, long[:MaxStringPreview] + "...", true},
+		{"no payload", &Object{Type: "string"}, "", false},
+		{"not a string", &Object{Type: "main.T", Data: []byte("abc")}, "", false},
+		{"int slice", &Object{Type: "[]int", Data: []byte("abc")}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.obj.AsString()
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("AsString() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
 func TestObjectRelationships(t *testing.T) {
 	g := NewMemGraph()
 	
@@ -129,4 +250,42 @@ func TestNilObjectHandling(t *testing.T) {
 	if g.NumObjects() != 0 {
 		t.Errorf("Expected 0 objects in empty graph, got %d", g.NumObjects())
 	}
-}
\ No newline at end of file
+}
+
+func TestMemGraphSnapshot(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Size: 10, Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "leaf", Size: 20})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	snap := g.Snapshot()
+
+	// Mutate the original while an analysis runs on the snapshot
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 3; i < 1000; i++ {
+			g.AddObject(&Object{ID: ObjID(i), Type: "new", Size: 1})
+		}
+		g.SetRoots(Roots{IDs: []ObjID{1, 3}})
+	}()
+	retained := RetainedSize(snap)
+	<-done
+
+	if snap.NumObjects() != 2 || snap.GetObject(3) != nil {
+		t.Errorf("snapshot has %d objects, want the original 2", snap.NumObjects())
+	}
+	if got := snap.GetRoots().IDs; len(got) != 1 || got[0] != 1 {
+		t.Errorf("snapshot roots = %v, want [1]", got)
+	}
+	if retained[1] != 30 {
+		t.Errorf("retained size of root = %d, want 30", retained[1])
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("AddObject on a snapshot should panic")
+		}
+	}()
+	snap.AddObject(&Object{ID: 5})
+}
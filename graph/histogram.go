@@ -0,0 +1,116 @@
+// ABOUTME: Per-type object counts and sizes for a heap graph
+// ABOUTME: Provides the histogram and its CSV export for spreadsheet analysis
+
+package graph
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// TypeStat summarizes all objects of one type
+type TypeStat struct {
+	Type      string // Type name
+	Count     int    // Number of objects of this type
+	TotalSize uint64 // Sum of object sizes in bytes
+
+	// PercentOfTotal is TotalSize as a percentage of TotalSize(g)
+	PercentOfTotal float64
+}
+
+// AvgSize returns the mean object size, or 0 for an empty stat
+func (s TypeStat) AvgSize() uint64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.TotalSize / uint64(s.Count)
+}
+
+// TotalSize returns the sum of all object sizes in g, reachable or not. It
+// is the denominator for type percentages; retained percentages use
+// ReachableSize instead, since no object can retain unreachable memory.
+func TotalSize(g Graph) uint64 {
+	var total uint64
+	g.ForEachObject(func(obj *Object) {
+		total += obj.Size
+	})
+	return total
+}
+
+// percent returns part as a percentage of total, or 0 if total is 0
+func percent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(total)
+}
+
+// TypeHistogram groups objects by type, ordered by total size descending
+// and then by type name
+func TypeHistogram(g Graph) []TypeStat {
+	return typeHistogram(g, func(name string) string { return name })
+}
+
+// TypeHistogramNormalized is like TypeHistogram but groups objects by
+// NormalizeTypeName of their type, folding "*T", "[]T", and "map[K]T" into
+// T. Objects keep their raw type names; only the grouping changes.
+func TypeHistogramNormalized(g Graph) []TypeStat {
+	return typeHistogram(g, NormalizeTypeName)
+}
+
+// typeHistogram groups objects by key(obj.Type)
+func typeHistogram(g Graph, key func(string) string) []TypeStat {
+	byType := make(map[string]*TypeStat)
+	var total uint64
+	g.ForEachObject(func(obj *Object) {
+		total += obj.Size
+		name := key(obj.Type)
+		stat, ok := byType[name]
+		if !ok {
+			stat = &TypeStat{Type: name}
+			byType[name] = stat
+		}
+		stat.Count++
+		stat.TotalSize += obj.Size
+	})
+
+	result := make([]TypeStat, 0, len(byType))
+	for _, stat := range byType {
+		stat.PercentOfTotal = percent(stat.TotalSize, total)
+		result = append(result, *stat)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalSize != result[j].TotalSize {
+			return result[i].TotalSize > result[j].TotalSize
+		}
+		return result[i].Type < result[j].Type
+	})
+	return result
+}
+
+// WriteTypeHistogramCSV writes the type histogram of g as CSV with a header
+// row and the columns type, count, total_size, and avg_size. Type names are
+// quoted as needed, since many contain commas.
+func WriteTypeHistogramCSV(w io.Writer, g Graph) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"type", "count", "total_size", "avg_size"}); err != nil {
+		return err
+	}
+
+	for _, stat := range TypeHistogram(g) {
+		record := []string{
+			stat.Type,
+			strconv.Itoa(stat.Count),
+			strconv.FormatUint(stat.TotalSize, 10),
+			strconv.FormatUint(stat.AvgSize(), 10),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,92 @@
+// ABOUTME: Tests for per-type histograms and their CSV export
+// ABOUTME: Verifies ordering, aggregation, and quoting of awkward type names
+
+package graph
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func histogramGraph() Graph {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "string", Size: 16})
+	g.AddObject(&Object{ID: 2, Type: "map[string,int]", Size: 200})
+	g.AddObject(&Object{ID: 3, Type: "string", Size: 32})
+	g.AddObject(&Object{ID: 4, Type: "[]byte", Size: 48})
+	return g
+}
+
+func TestTypeHistogram(t *testing.T) {
+	got := TypeHistogram(histogramGraph())
+	want := []TypeStat{
+		{Type: "map[string,int]", Count: 1, TotalSize: 200, PercentOfTotal: percent(200, 296)},
+		{Type: "[]byte", Count: 1, TotalSize: 48, PercentOfTotal: percent(48, 296)},
+		{Type: "string", Count: 2, TotalSize: 48, PercentOfTotal: percent(48, 296)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TypeHistogram() = %+v, want %+v", got, want)
+	}
+
+	if avg := got[2].AvgSize(); avg != 24 {
+		t.Errorf("AvgSize() = %d, want 24", avg)
+	}
+	if empty := TypeHistogram(NewMemGraph()); len(empty) != 0 {
+		t.Errorf("expected empty histogram, got %+v", empty)
+	}
+}
+
+func TestTypeHistogramNormalized(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "foo.Bar", Size: 16})
+	g.AddObject(&Object{ID: 2, Type: "*foo.Bar", Size: 8})
+	g.AddObject(&Object{ID: 3, Type: "[]foo.Bar", Size: 64})
+	g.AddObject(&Object{ID: 4, Type: "map[string]*foo.Bar", Size: 12})
+	g.AddObject(&Object{ID: 5, Type: "string", Size: 100})
+
+	got := TypeHistogramNormalized(g)
+	want := []TypeStat{
+		{Type: "foo.Bar", Count: 4, TotalSize: 100, PercentOfTotal: 50},
+		{Type: "string", Count: 1, TotalSize: 100, PercentOfTotal: 50},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TypeHistogramNormalized() = %+v, want %+v", got, want)
+	}
+	if raw := g.GetObject(2).Type; raw != "*foo.Bar" {
+		t.Errorf("object type changed to %q", raw)
+	}
+}
+
+func TestTotalSize(t *testing.T) {
+	g := histogramGraph()
+	if got := TotalSize(g); got != 296 {
+		t.Errorf("TotalSize() = %d, want 296", got)
+	}
+
+	var sum float64
+	for _, stat := range TypeHistogram(g) {
+		sum += stat.PercentOfTotal
+	}
+	if sum < 99.999 || sum > 100.001 {
+		t.Errorf("histogram percentages sum to %f, want 100", sum)
+	}
+	if got := percent(1, 0); got != 0 {
+		t.Errorf("percent(1, 0) = %f, want 0", got)
+	}
+}
+
+func TestWriteTypeHistogramCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteTypeHistogramCSV(&buf, histogramGraph()); err != nil {
+		t.Fatalf("WriteTypeHistogramCSV() error = %v", err)
+	}
+
+	want := "type,count,total_size,avg_size\n" +
+		"\"map[string,int]\",1,200,200\n" +
+		"[]byte,1,48,48\n" +
+		"string,2,48,24\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteTypeHistogramCSV() =\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,137 @@
+// ABOUTME: Coarse classification of objects by the syntax of their type names
+// ABOUTME: Buckets types into kinds such as slice, map, string, and struct
+
+package graph
+
+import "strings"
+
+// Kind is a coarse category of Go type
+type Kind int
+
+const (
+	KindUnknown Kind = iota
+	KindBasic
+	KindString
+	KindPointer
+	KindSlice
+	KindArray
+	KindMap
+	KindStruct
+	KindChannel
+	KindFunc
+	KindInterface
+)
+
+var kindNames = [...]string{
+	KindUnknown:   "unknown",
+	KindBasic:     "basic",
+	KindString:    "string",
+	KindPointer:   "pointer",
+	KindSlice:     "slice",
+	KindArray:     "array",
+	KindMap:       "map",
+	KindStruct:    "struct",
+	KindChannel:   "channel",
+	KindFunc:      "func",
+	KindInterface: "interface",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return kindNames[KindUnknown]
+	}
+	return kindNames[k]
+}
+
+var basicTypes = map[string]bool{
+	"bool": true, "byte": true, "rune": true, "uintptr": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true, "complex64": true, "complex128": true,
+}
+
+// Classify buckets obj by its type name. Only the outermost type
+// constructor counts, so "[]*T" is a slice and "*[]T" a pointer. Other
+// named types are assumed to be structs, which most named heap types are.
+// Empty names and the parser's "unknown" placeholder are KindUnknown.
+func Classify(obj *Object) Kind {
+	t := obj.Type
+	switch {
+	case t == "" || t == "unknown":
+		return KindUnknown
+	case t == "string":
+		return KindString
+	case basicTypes[t]:
+		return KindBasic
+	case t == "unsafe.Pointer" || strings.HasPrefix(t, "*"):
+		return KindPointer
+	case strings.HasPrefix(t, "[]"):
+		return KindSlice
+	case strings.HasPrefix(t, "["):
+		return KindArray
+	case strings.HasPrefix(t, "map["):
+		return KindMap
+	case strings.HasPrefix(t, "chan ") || strings.HasPrefix(t, "chan<-") || strings.HasPrefix(t, "<-chan "):
+		return KindChannel
+	case strings.HasPrefix(t, "func("):
+		return KindFunc
+	case t == "error" || t == "any" || strings.HasPrefix(t, "interface {") || strings.HasPrefix(t, "interface{"):
+		return KindInterface
+	default:
+		return KindStruct
+	}
+}
+
+// NormalizeTypeName strips pointer, slice, array, and map wrappers from a
+// type name down to its base, so "*foo.Bar", "[]foo.Bar", "[4]*foo.Bar",
+// and "map[string]foo.Bar" all become "foo.Bar". A map reduces to its
+// value type. Other names are returned unchanged.
+func NormalizeTypeName(name string) string {
+	for {
+		switch {
+		case strings.HasPrefix(name, "*"):
+			name = name[1:]
+		case strings.HasPrefix(name, "map["):
+			end := closingBracket(name, len("map"))
+			if end < 0 {
+				return name
+			}
+			name = name[end+1:]
+		case strings.HasPrefix(name, "["):
+			end := closingBracket(name, 0)
+			if end < 0 {
+				return name
+			}
+			name = name[end+1:]
+		default:
+			return name
+		}
+	}
+}
+
+// closingBracket returns the index of the ']' matching the '[' at open, or
+// -1 if there is none
+func closingBracket(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// SizeByKind sums object sizes per kind
+func SizeByKind(g Graph) map[Kind]uint64 {
+	sizes := make(map[Kind]uint64)
+	g.ForEachObject(func(obj *Object) {
+		sizes[Classify(obj)] += obj.Size
+	})
+	return sizes
+}
@@ -0,0 +1,89 @@
+// ABOUTME: Tests for classifying objects into kinds by type name
+// ABOUTME: Covers each kind, nested constructors, and per-kind size totals
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		typ  string
+		want Kind
+	}{
+		{"", KindUnknown},
+		{"unknown", KindUnknown},
+		{"string", KindString},
+		{"int64", KindBasic},
+		{"uint8", KindBasic},
+		{"float64", KindBasic},
+		{"*main.Node", KindPointer},
+		{"*[]byte", KindPointer},
+		{"unsafe.Pointer", KindPointer},
+		{"[]byte", KindSlice},
+		{"[]*main.Node", KindSlice},
+		{"[16]uint8", KindArray},
+		{"[2][]string", KindArray},
+		{"map[string]int", KindMap},
+		{"map[[4]byte][]string", KindMap},
+		{"chan int", KindChannel},
+		{"chan<- error", KindChannel},
+		{"<-chan struct {}", KindChannel},
+		{"func(int) error", KindFunc},
+		{"error", KindInterface},
+		{"interface {}", KindInterface},
+		{"interface { Read([]uint8) (int, error) }", KindInterface},
+		{"struct { a int }", KindStruct},
+		{"main.Session", KindStruct},
+		{"sync.Mutex", KindStruct},
+	}
+	for _, tt := range tests {
+		if got := Classify(&Object{Type: tt.typ}); got != tt.want {
+			t.Errorf("Classify(%q) = %v, want %v", tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestKindString(t *testing.T) {
+	if got := KindSlice.String(); got != "slice" {
+		t.Errorf("KindSlice.String() = %q, want slice", got)
+	}
+	if got := Kind(99).String(); got != "unknown" {
+		t.Errorf("Kind(99).String() = %q, want unknown", got)
+	}
+}
+
+func TestNormalizeTypeName(t *testing.T) {
+	tests := map[string]string{
+		"foo.Bar":                  "foo.Bar",
+		"*foo.Bar":                 "foo.Bar",
+		"[]foo.Bar":                "foo.Bar",
+		"[]*foo.Bar":               "foo.Bar",
+		"[16]**foo.Bar":            "foo.Bar",
+		"map[string]foo.Bar":       "foo.Bar",
+		"map[[2]int][]*foo.Bar":    "foo.Bar",
+		"*map[string]map[int]bool": "bool",
+		"github.com/x/y.Conn":      "github.com/x/y.Conn",
+		"func(int) error":          "func(int) error",
+		"map[string":               "map[string",
+	}
+	for name, want := range tests {
+		if got := NormalizeTypeName(name); got != want {
+			t.Errorf("NormalizeTypeName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSizeByKind(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "[]byte", Size: 600})
+	g.AddObject(&Object{ID: 2, Type: "[]int", Size: 100})
+	g.AddObject(&Object{ID: 3, Type: "main.T", Size: 300})
+
+	want := map[Kind]uint64{KindSlice: 700, KindStruct: 300}
+	if got := SizeByKind(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("SizeByKind() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+// ABOUTME: Finds the biggest single objects by shallow size
+// ABOUTME: Catches one giant allocation that retained size rankings can bury
+
+package graph
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// LargestObjects returns the n objects with the largest shallow Size,
+// largest first. Ties are ordered by ID. Unlike TopRetained it needs no
+// dominator analysis, and it ranks a huge []byte backing array on its own
+// size even when objects retaining it rank higher. It keeps only n
+// candidates while scanning, so it is cheap on large graphs.
+func LargestObjects(g Graph, n int) []ObjID {
+	if n <= 0 {
+		return nil
+	}
+
+	h := &smallestFirst{}
+	g.ForEachObject(func(obj *Object) {
+		if h.Len() < n {
+			heap.Push(h, obj)
+		} else if largerObject(obj, h.objs[0]) {
+			h.objs[0] = obj
+			heap.Fix(h, 0)
+		}
+	})
+
+	sort.Slice(h.objs, func(i, j int) bool { return largerObject(h.objs[i], h.objs[j]) })
+	ids := make([]ObjID, len(h.objs))
+	for i, obj := range h.objs {
+		ids[i] = obj.ID
+	}
+	return ids
+}
+
+// largerObject reports whether a ranks before b: larger, or the same size
+// with a lower ID
+func largerObject(a, b *Object) bool {
+	if a.Size != b.Size {
+		return a.Size > b.Size
+	}
+	return a.ID < b.ID
+}
+
+// smallestFirst is a heap whose root is the lowest ranked object, the one
+// to evict when a larger object turns up
+type smallestFirst struct {
+	objs []*Object
+}
+
+func (h *smallestFirst) Len() int           { return len(h.objs) }
+func (h *smallestFirst) Less(i, j int) bool { return largerObject(h.objs[j], h.objs[i]) }
+func (h *smallestFirst) Swap(i, j int)      { h.objs[i], h.objs[j] = h.objs[j], h.objs[i] }
+func (h *smallestFirst) Push(x any)         { h.objs = append(h.objs, x.(*Object)) }
+
+func (h *smallestFirst) Pop() any {
+	obj := h.objs[len(h.objs)-1]
+	h.objs = h.objs[:len(h.objs)-1]
+	return obj
+}
@@ -0,0 +1,50 @@
+// ABOUTME: Tests for finding the largest objects by shallow size
+// ABOUTME: Checks ordering, ties, and that retained size plays no part
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLargestObjects(t *testing.T) {
+	g := NewMemGraph()
+	// The holder retains the most, but the buffer is the biggest object
+	g.AddObject(&Object{ID: 1, Type: "*main.Holder", Size: 16, Ptrs: []ObjID{2, 3}})
+	g.AddObject(&Object{ID: 2, Type: "[]uint8", Size: 500 << 20})
+	g.AddObject(&Object{ID: 3, Type: "main.Entry", Size: 64})
+	g.AddObject(&Object{ID: 4, Type: "main.Entry", Size: 64})
+	g.AddObject(&Object{ID: 5, Type: "string", Size: 8})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	tests := []struct {
+		n    int
+		want []ObjID
+	}{
+		{n: 1, want: []ObjID{2}},
+		{n: 3, want: []ObjID{2, 3, 4}},
+		{n: 10, want: []ObjID{2, 3, 4, 1, 5}},
+		{n: 0, want: nil},
+	}
+	for _, tt := range tests {
+		if got := LargestObjects(g, tt.n); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("LargestObjects(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+
+	if top := TopRetained(RetainedSize(g), 1); top[0] != 1 {
+		t.Errorf("TopRetained() = %v, want the holder first", top)
+	}
+}
+
+func TestLargestObjectsMatchesFullSort(t *testing.T) {
+	g := benchmarkGraph(1000)
+	all := LargestObjects(g, g.NumObjects())
+	if len(all) != g.NumObjects() {
+		t.Fatalf("LargestObjects(all) returned %d objects, want %d", len(all), g.NumObjects())
+	}
+	if got := LargestObjects(g, 25); !reflect.DeepEqual(got, all[:25]) {
+		t.Errorf("LargestObjects(25) = %v, want the first 25 of a full sort %v", got, all[:25])
+	}
+}
@@ -0,0 +1,73 @@
+// ABOUTME: Finds maps retaining far more memory than their entries explain
+// ABOUTME: Flags maps that grew large, shrank, and kept their buckets
+
+package graph
+
+import "sort"
+
+const (
+	// MapBytesPerEntry is the generous per-entry estimate FindMapWaste
+	// allows for a key, a value, and their share of bucket overhead
+	MapBytesPerEntry = 256
+
+	// MinMapWasteRatio is how many times its expected size a map must retain
+	// for FindMapWaste to report it
+	MinMapWasteRatio = 4
+)
+
+// MapWaste is a map retaining much more than its live entries need, the
+// usual result of deleting most entries from a map that once grew large
+type MapWaste struct {
+	ID       ObjID  // Object holding the map header
+	Entries  uint64 // Live entries according to the header
+	Retained uint64 // Bytes the map header retains
+	Wasted   uint64 // Retained bytes beyond the per-entry estimate
+}
+
+// FindMapWaste reports maps whose retained size is at least
+// MinMapWasteRatio times Entries*MapBytesPerEntry, most wasted bytes first.
+// The entry count is the header's first word, pointerSize bytes (4 or 8, as
+// the dump's params say) in the given byte order, so only map-typed objects
+// with a kept payload (see Object.Data) are considered. Go never shrinks a map's buckets, so this is a coarse but
+// useful signal; a map whose values themselves hold large objects can also
+// trip it.
+func FindMapWaste(g Graph, pointerSize int, bigEndian bool) []MapWaste {
+	if pointerSize != 4 && pointerSize != 8 {
+		return nil
+	}
+
+	entries := make(map[ObjID]uint64)
+	var ids []ObjID
+	ForEachObjectWhere(g, func(obj *Object) bool {
+		return Classify(obj) == KindMap && len(obj.Data) >= pointerSize
+	}, func(obj *Object) {
+		entries[obj.ID], _ = obj.ReadWord(0, pointerSize, bigEndian)
+		ids = append(ids, obj.ID)
+	})
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var result []MapWaste
+	for id, retained := range RetainedSizeSubsets(g, ids) {
+		n := entries[id]
+		expected := max(n, 1) * MapBytesPerEntry
+		if retained/MinMapWasteRatio < expected {
+			continue
+		}
+		result = append(result, MapWaste{
+			ID:       id,
+			Entries:  n,
+			Retained: retained,
+			Wasted:   retained - expected,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Wasted != result[j].Wasted {
+			return result[i].Wasted > result[j].Wasted
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
@@ -0,0 +1,65 @@
+// ABOUTME: Tests for oversized map detection
+// ABOUTME: Builds map headers by hand and checks which maps are flagged
+
+package graph
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// mapHeader returns a little-endian map header payload holding count
+func mapHeader(count uint64) []byte {
+	data := make([]byte, 48)
+	binary.LittleEndian.PutUint64(data, count)
+	return data
+}
+
+func TestFindMapWaste(t *testing.T) {
+	g := NewMemGraph()
+	// 8 entries retaining 1MB of buckets
+	g.AddObject(&Object{ID: 1, Type: "map[string]int", Size: 48, Ptrs: []ObjID{2}, Data: mapHeader(8)})
+	g.AddObject(&Object{ID: 2, Type: "unknown", Size: 1 << 20})
+	// An empty map still allows one entry's worth
+	g.AddObject(&Object{ID: 3, Type: "map[int]bool", Size: 48, Ptrs: []ObjID{4}, Data: mapHeader(0)})
+	g.AddObject(&Object{ID: 4, Type: "unknown", Size: 4096})
+	// Well-sized map is not reported
+	g.AddObject(&Object{ID: 5, Type: "map[int]int", Size: 48, Ptrs: []ObjID{6}, Data: mapHeader(100)})
+	g.AddObject(&Object{ID: 6, Type: "unknown", Size: 8192})
+	// No payload kept
+	g.AddObject(&Object{ID: 7, Type: "map[int]int", Size: 48, Ptrs: []ObjID{8}})
+	g.AddObject(&Object{ID: 8, Type: "unknown", Size: 1 << 20})
+	g.SetRoots(Roots{IDs: []ObjID{1, 3, 5, 7}})
+
+	got := FindMapWaste(g, 8, false)
+	want := []MapWaste{
+		{ID: 1, Entries: 8, Retained: 48 + 1<<20, Wasted: 48 + 1<<20 - 8*MapBytesPerEntry},
+		{ID: 3, Entries: 0, Retained: 48 + 4096, Wasted: 48 + 4096 - MapBytesPerEntry},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindMapWaste() = %+v, want %+v", got, want)
+	}
+	if got := FindMapWaste(g, 0, false); got != nil {
+		t.Errorf("FindMapWaste(pointer size 0) = %+v, want nil", got)
+	}
+}
+
+func TestFindMapWaste32Bit(t *testing.T) {
+	// A big-endian 32-bit header: count 8, then the next word set so
+	// reading 8 bytes would give the wrong count
+	data := make([]byte, 28)
+	binary.BigEndian.PutUint32(data, 8)
+	binary.BigEndian.PutUint32(data[4:], 0xffffffff)
+
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "map[string]int", Size: 28, Ptrs: []ObjID{2}, Data: data})
+	g.AddObject(&Object{ID: 2, Type: "unknown", Size: 1 << 20})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	got := FindMapWaste(g, 4, true)
+	want := []MapWaste{{ID: 1, Entries: 8, Retained: 28 + 1<<20, Wasted: 28 + 1<<20 - 8*MapBytesPerEntry}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindMapWaste() = %+v, want %+v", got, want)
+	}
+}
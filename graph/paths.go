@@ -3,19 +3,48 @@
 
 package graph
 
+import (
+	"cmp"
+	"slices"
+	"sort"
+)
+
 // Path represents a path from an object to a root
 type Path struct {
 	IDs []ObjID // Sequence of object IDs from target to root
 }
 
-// PathsToRoots finds paths from an object to GC roots using BFS
+// PathsToRoots finds up to maxPaths paths from an object to GC roots using
+// BFS. Paths are ordered shortest first; paths of equal length are ordered by
+// the root they reach, then by the IDs along the way, so results are stable.
 func PathsToRoots(g Graph, from ObjID, maxPaths int) []Path {
+	return pathsToRoots(g, from, maxPaths, 0, nil)
+}
+
+// PathsToRootsBounded is like PathsToRoots but abandons any path longer
+// than maxDepth pointers, bounding time and memory on long chains. A depth
+// too small for the nearest root returns no paths even though the object
+// is reachable. maxDepth <= 0 means no bound.
+func PathsToRootsBounded(g Graph, from ObjID, maxPaths, maxDepth int) []Path {
+	return pathsToRoots(g, from, maxPaths, maxDepth, nil)
+}
+
+// PathsToRootsFiltered is like PathsToRoots but only returns paths that go
+// through at least one referrer accepted by through, such as an object of
+// type *Cache. The target itself does not count. Paths that reach a root
+// without passing the filter are dropped, so they never use up maxPaths.
+func PathsToRootsFiltered(g Graph, from ObjID, maxPaths int, through Predicate) []Path {
+	return pathsToRoots(g, from, maxPaths, 0, through)
+}
+
+// pathsToRoots implements PathsToRoots. maxDepth <= 0 means no bound, and
+// a nil through accepts every path.
+func pathsToRoots(g Graph, from ObjID, maxPaths, maxDepth int, through Predicate) []Path {
 	if maxPaths <= 0 {
 		return nil
 	}
 	
-	// Build reverse edges for traversal
-	reverse := BuildReverseEdges(g)
+	referrers := referrersOf(g)
 	
 	// Get roots
 	roots := g.GetRoots()
@@ -26,58 +55,130 @@ func PathsToRoots(g Graph, from ObjID, maxPaths int) []Path {
 	
 	// Check if starting object is itself a root
 	if rootSet[from] {
+		if through != nil {
+			return nil
+		}
 		return []Path{{IDs: []ObjID{from}}}
 	}
 	
-	// BFS state
-	type searchNode struct {
-		id   ObjID
-		path []ObjID
+	// partial is a path being extended and whether it has passed through
+	type partial struct {
+		ids     []ObjID
+		matched bool
 	}
 	
+	// BFS one depth at a time. Every path found at a depth is kept before
+	// stopping, so the cut at maxPaths never drops a shorter or tied path
+	// in favour of one that happened to be discovered first.
 	var result []Path
-	queue := []searchNode{{id: from, path: []ObjID{from}}}
-	
-	// BFS to find paths
-	for len(queue) > 0 && len(result) < maxPaths {
-		node := queue[0]
-		queue = queue[1:]
-		
-		// Get objects that point to current node
-		referrers := reverse[node.id]
-		
-		for _, referrerID := range referrers {
-			// Avoid cycles by checking if we've already visited this node in this path
-			inPath := false
-			for _, id := range node.path {
-				if id == referrerID {
-					inPath = true
-					break
+	level := []partial{{ids: []ObjID{from}, matched: through == nil}}
+	for depth := 1; len(level) > 0 && len(result) < maxPaths; depth++ {
+		if maxDepth > 0 && depth > maxDepth {
+			break
+		}
+		var next []partial
+		for _, path := range level {
+			// Get objects that point to current node
+			for _, referrerID := range referrers(path.ids[len(path.ids)-1]) {
+				// Avoid cycles by checking if we've already visited this node in this path
+				if containsID(path.ids, referrerID) {
+					continue
+				}
+				
+				newPath := make([]ObjID, len(path.ids)+1)
+				copy(newPath, path.ids)
+				newPath[len(path.ids)] = referrerID
+				matched := path.matched || through(g.GetObject(referrerID))
+				
+				// Check if we reached a root
+				if !rootSet[referrerID] {
+					// Continue searching
+					next = append(next, partial{ids: newPath, matched: matched})
+				} else if matched {
+					result = append(result, Path{IDs: newPath})
 				}
 			}
-			if inPath {
+		}
+		level = next
+	}
+	
+	sort.Slice(result, func(i, j int) bool {
+		return comparePaths(result[i].IDs, result[j].IDs) < 0
+	})
+	if len(result) > maxPaths {
+		result = result[:maxPaths]
+	}
+	return result
+}
+
+// containsID reports whether ids contains id
+func containsID(ids []ObjID, id ObjID) bool {
+	for _, x := range ids {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
+
+// comparePaths orders paths by length, then by the root reached, then by
+// their IDs from the target outwards
+func comparePaths(a, b []ObjID) int {
+	if len(a) != len(b) {
+		return cmp.Compare(len(a), len(b))
+	}
+	if c := cmp.Compare(a[len(a)-1], b[len(b)-1]); c != 0 {
+		return c
+	}
+	return slices.Compare(a, b)
+}
+
+// ShortestPathToRoot finds a single shortest path from an object to any GC
+// root. Unlike PathsToRoots it visits each object at most once, so it stays
+// linear in the size of the graph. Returns false if no root retains the object.
+func ShortestPathToRoot(g Graph, from ObjID) (Path, bool) {
+	if g.GetObject(from) == nil {
+		return Path{}, false
+	}
+
+	rootSet := make(map[ObjID]bool)
+	for _, id := range g.GetRoots().IDs {
+		rootSet[id] = true
+	}
+	if rootSet[from] {
+		return Path{IDs: []ObjID{from}}, true
+	}
+
+	referrers := referrersOf(g)
+
+	// next records, for each visited object, the referrer one step closer to a root
+	next := map[ObjID]ObjID{from: from}
+	queue := []ObjID{from}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		for _, referrerID := range referrers(id) {
+			if _, seen := next[referrerID]; seen {
 				continue
 			}
-			
-			newPath := make([]ObjID, len(node.path)+1)
-			copy(newPath, node.path)
-			newPath[len(node.path)] = referrerID
-			
-			// Check if we reached a root
+			next[referrerID] = id
+
 			if rootSet[referrerID] {
-				result = append(result, Path{IDs: newPath})
-				if len(result) >= maxPaths {
-					break
+				// Walk back from the root to the target, then reverse
+				ids := []ObjID{referrerID}
+				for cur := referrerID; cur != from; {
+					cur = next[cur]
+					ids = append(ids, cur)
 				}
-			} else {
-				// Continue searching
-				queue = append(queue, searchNode{
-					id:   referrerID,
-					path: newPath,
-				})
+				for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+					ids[i], ids[j] = ids[j], ids[i]
+				}
+				return Path{IDs: ids}, true
 			}
+			queue = append(queue, referrerID)
 		}
 	}
-	
-	return result
-}
\ No newline at end of file
+
+	return Path{}, false
+}
@@ -159,6 +159,112 @@ func TestMaxPaths(t *testing.T) {
 	}
 }
 
+func TestPathsToRootsShortestFirst(t *testing.T) {
+	// 5 has a 3-hop route to root 1 and 2-hop routes to roots 8 and 9:
+	// 1 (root) -> 2 -> 3 -> 5
+	// 9 (root) -> 6 -> 5
+	// 8 (root) -> 7 -> 5
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root1", Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "a", Ptrs: []ObjID{3}})
+	g.AddObject(&Object{ID: 3, Type: "b", Ptrs: []ObjID{5}})
+	g.AddObject(&Object{ID: 5, Type: "target"})
+	g.AddObject(&Object{ID: 6, Type: "c", Ptrs: []ObjID{5}})
+	g.AddObject(&Object{ID: 7, Type: "d", Ptrs: []ObjID{5}})
+	g.AddObject(&Object{ID: 8, Type: "root8", Ptrs: []ObjID{7}})
+	g.AddObject(&Object{ID: 9, Type: "root9", Ptrs: []ObjID{6}})
+	g.SetRoots(Roots{IDs: []ObjID{9, 1, 8}})
+
+	all := []Path{
+		{IDs: []ObjID{5, 7, 8}},
+		{IDs: []ObjID{5, 6, 9}},
+		{IDs: []ObjID{5, 3, 2, 1}},
+	}
+	for maxPaths := 1; maxPaths <= 4; maxPaths++ {
+		want := all[:min(maxPaths, len(all))]
+		if got := PathsToRoots(g, 5, maxPaths); !reflect.DeepEqual(got, want) {
+			t.Errorf("PathsToRoots(5, %d) = %v, want %v", maxPaths, got, want)
+		}
+	}
+}
+
+func TestPathsToRootsBounded(t *testing.T) {
+	// 1 (root) -> 2 -> 3 -> 4 -> 5, and 6 (root) -> 5
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "a", Ptrs: []ObjID{3}})
+	g.AddObject(&Object{ID: 3, Type: "b", Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 4, Type: "c", Ptrs: []ObjID{5}})
+	g.AddObject(&Object{ID: 5, Type: "target"})
+	g.AddObject(&Object{ID: 6, Type: "near root", Ptrs: []ObjID{5}})
+	g.SetRoots(Roots{IDs: []ObjID{1, 6}})
+
+	short := Path{IDs: []ObjID{5, 6}}
+	long := Path{IDs: []ObjID{5, 4, 3, 2, 1}}
+	tests := []struct {
+		maxDepth int
+		want     []Path
+	}{
+		{0, []Path{short, long}},
+		{1, []Path{short}},
+		{3, []Path{short}},
+		{4, []Path{short, long}},
+	}
+	for _, tt := range tests {
+		if got := PathsToRootsBounded(g, 5, 10, tt.maxDepth); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PathsToRootsBounded(5, 10, %d) = %v, want %v", tt.maxDepth, got, tt.want)
+		}
+	}
+
+	// Too small a bound finds nothing even for a reachable object
+	if got := PathsToRootsBounded(g, 2, 10, 0); len(got) != 1 {
+		t.Errorf("unbounded paths from 2 = %v, want one", got)
+	}
+	if got := PathsToRootsBounded(g, 4, 10, 2); got != nil {
+		t.Errorf("PathsToRootsBounded(4, 10, 2) = %v, want none", got)
+	}
+}
+
+func TestPathsToRootsFiltered(t *testing.T) {
+	// 4 is held by a session cache under root 1 and a request pool under
+	// root 2; the pool path is shorter
+	// 1 (root) -> 3 (*Cache) -> 5 -> 4
+	// 2 (root) -> 6 (*Pool) -> 4
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root1", Ptrs: []ObjID{3}})
+	g.AddObject(&Object{ID: 2, Type: "root2", Ptrs: []ObjID{6}})
+	g.AddObject(&Object{ID: 3, Type: "*Cache", Ptrs: []ObjID{5}})
+	g.AddObject(&Object{ID: 4, Type: "target"})
+	g.AddObject(&Object{ID: 5, Type: "entry", Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 6, Type: "*Pool", Ptrs: []ObjID{4}})
+	g.SetRoots(Roots{IDs: []ObjID{1, 2}})
+
+	ofType := func(typ string) Predicate {
+		return func(obj *Object) bool { return obj.Type == typ }
+	}
+
+	tests := []struct {
+		name    string
+		from    ObjID
+		through Predicate
+		want    []Path
+	}{
+		{name: "cache", from: 4, through: ofType("*Cache"), want: []Path{{IDs: []ObjID{4, 5, 3, 1}}}},
+		{name: "pool", from: 4, through: ofType("*Pool"), want: []Path{{IDs: []ObjID{4, 6, 2}}}},
+		{name: "root counts", from: 4, through: ofType("root1"), want: []Path{{IDs: []ObjID{4, 5, 3, 1}}}},
+		{name: "target does not count", from: 4, through: ofType("target"), want: nil},
+		{name: "root target", from: 1, through: ofType("root1"), want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// maxPaths of 1 must not be used up by the unfiltered shorter path
+			if got := PathsToRootsFiltered(g, tt.from, 1, tt.through); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PathsToRootsFiltered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestSelfReference(t *testing.T) {
 	// Object pointing to itself
 	g := NewMemGraph()
@@ -172,4 +278,44 @@ func TestSelfReference(t *testing.T) {
 	if !reflect.DeepEqual(paths, want) {
 		t.Errorf("PathsToRoots() with self-reference = %v, want %v", paths, want)
 	}
-}
\ No newline at end of file
+}
+
+func TestShortestPathToRoot(t *testing.T) {
+	// 1 (root) -> 2 -> 3 -> 4 -> 5
+	// 6 (root) -> 4
+	// 7 -> 7 (unreachable self-loop)
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "a", Ptrs: []ObjID{3}})
+	g.AddObject(&Object{ID: 3, Type: "b", Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 4, Type: "c", Ptrs: []ObjID{5, 2}})
+	g.AddObject(&Object{ID: 5, Type: "leaf"})
+	g.AddObject(&Object{ID: 6, Type: "root", Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 7, Type: "garbage", Ptrs: []ObjID{7}})
+	g.SetRoots(Roots{IDs: []ObjID{1, 6}})
+
+	tests := []struct {
+		name   string
+		from   ObjID
+		want   []ObjID
+		wantOK bool
+	}{
+		{name: "root itself", from: 1, want: []ObjID{1}, wantOK: true},
+		{name: "shorter of two roots", from: 5, want: []ObjID{5, 4, 6}, wantOK: true},
+		{name: "through a cycle", from: 3, want: []ObjID{3, 2, 1}, wantOK: true},
+		{name: "unreachable", from: 7, wantOK: false},
+		{name: "missing object", from: 99, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, ok := ShortestPathToRoot(g, tt.from)
+			if ok != tt.wantOK {
+				t.Fatalf("ShortestPathToRoot() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !reflect.DeepEqual(path.IDs, tt.want) {
+				t.Errorf("ShortestPathToRoot() = %v, want %v", path.IDs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// ABOUTME: Progress reporting and cancellation for long-running analyses
+// ABOUTME: Counts steps and checks a context every few thousand of them
+
+package graph
+
+import "context"
+
+// checkInterval is how many steps pass between progress reports and
+// cancellation checks. It must be a power of two.
+const checkInterval = 1 << 14
+
+// tracker counts the steps of an analysis towards total. A nil tracker
+// does nothing, so the plain entry points pay almost nothing for it.
+type tracker struct {
+	ctx      context.Context
+	progress func(done, total int) // may be nil
+	done     int
+	total    int
+}
+
+func newTracker(ctx context.Context, progress func(done, total int), total int) *tracker {
+	return &tracker{ctx: ctx, progress: progress, total: total}
+}
+
+// step records one step, reporting progress and returning the context's
+// error every checkInterval steps
+func (t *tracker) step() error {
+	if t == nil {
+		return nil
+	}
+	t.done++
+	if t.done&(checkInterval-1) != 0 {
+		return nil
+	}
+	return t.report()
+}
+
+// skipTo moves done forward to the end of a phase whose remaining steps
+// were not needed, such as objects a traversal never reached
+func (t *tracker) skipTo(done int) error {
+	if t == nil {
+		return nil
+	}
+	t.done = done
+	return t.report()
+}
+
+func (t *tracker) report() error {
+	if t.progress != nil {
+		t.progress(t.done, t.total)
+	}
+	return t.ctx.Err()
+}
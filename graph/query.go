@@ -0,0 +1,471 @@
+// ABOUTME: A small expression language for selecting objects
+// ABOUTME: Combines type, kind, size, retained size, and degree conditions with && and ||
+
+package graph
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Query returns the IDs of objects matching expr, in ascending order.
+//
+// An expression is a set of comparisons combined with && and ||, where &&
+// binds tighter; ! negates and parentheses group:
+//
+//	type =~ "Cache" && retained > 1MB && indegree > 100
+//	(kind == "map" || kind == "slice") && !(size < 4KB)
+//
+// Each comparison is a field, an operator, and a value:
+//
+//	type       the type name; == and != compare it with a string, =~ and
+//	           !~ match it against an unanchored regular expression
+//	kind       the Classify kind, such as "slice" or "map"; == and !=
+//	size       shallow size in bytes
+//	retained   retained size in bytes; an unreachable object retains itself
+//	indegree   number of pointers to the object
+//	outdegree  number of pointers the object holds
+//
+// Numeric fields take ==, !=, <, <=, >, and >= against a whole number,
+// optionally suffixed with a binary unit: B, KB, MB, GB, or TB (case
+// insensitive; K, M, G, and T also work). Strings are Go string literals,
+// double-quoted or, to spare regular expressions some escaping,
+// backquoted. Retained sizes and degrees are computed only when an
+// expression uses them.
+func Query(g Graph, expr string) ([]ObjID, error) {
+	return QueryContext(context.Background(), g, expr, nil)
+}
+
+// QueryContext is like Query, but reads retained sizes from retained, as
+// from DomInfo.RetainedSize, when it is not nil. Otherwise they are
+// computed if expr uses them, stopping early with ctx's error if ctx is
+// cancelled.
+func QueryContext(ctx context.Context, g Graph, expr string, retained map[ObjID]uint64) ([]ObjID, error) {
+	q, err := parseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	if q.needRetained {
+		if retained == nil {
+			if retained, err = RetainedSizeContext(ctx, g, nil); err != nil {
+				return nil, err
+			}
+		}
+		q.env.retained = retained
+	}
+	if q.needDegree {
+		q.env.degree = DegreeStats(g)
+	}
+	return Filter(g, q.pred), nil
+}
+
+// queryEnv holds the per-graph aggregates a compiled query reads. They
+// are filled in after parsing, once it is known which are needed.
+type queryEnv struct {
+	retained map[ObjID]uint64
+	degree   map[ObjID]Degree
+}
+
+// parsedQuery is a compiled expression and what it needs from the graph
+type parsedQuery struct {
+	pred         Predicate
+	env          *queryEnv
+	needRetained bool
+	needDegree   bool
+}
+
+// queryNumFields reads the numeric fields of an object
+var queryNumFields = map[string]func(env *queryEnv, obj *Object) uint64{
+	"size": func(_ *queryEnv, obj *Object) uint64 { return obj.Size },
+	"retained": func(env *queryEnv, obj *Object) uint64 {
+		if size, ok := env.retained[obj.ID]; ok {
+			return size
+		}
+		return obj.Size
+	},
+	"indegree":  func(env *queryEnv, obj *Object) uint64 { return uint64(env.degree[obj.ID].In) },
+	"outdegree": func(_ *queryEnv, obj *Object) uint64 { return uint64(len(obj.Ptrs)) },
+}
+
+// queryUnits maps size suffixes, lowercased, to their multipliers
+var queryUnits = map[string]uint64{
+	"b": 1,
+	"k": 1 << 10, "kb": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40,
+}
+
+// Token kinds produced by lexQuery
+const (
+	tokEOF = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+)
+
+type queryToken struct {
+	kind int
+	text string // the token as written
+	pos  int    // byte offset in the expression
+}
+
+// queryParser is a recursive descent parser over the tokens of one
+// expression, following this grammar:
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | "(" or ")" | compare
+//	compare = field op value
+type queryParser struct {
+	toks []queryToken
+	i    int
+	q    *parsedQuery
+}
+
+// parseQuery compiles expr into a predicate over a queryEnv
+func parseQuery(expr string) (*parsedQuery, error) {
+	toks, err := lexQuery(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %w", expr, err)
+	}
+	p := &queryParser{toks: toks, q: &parsedQuery{env: &queryEnv{}}}
+	pred, err := p.parseOr()
+	if err == nil && p.peek().kind != tokEOF {
+		err = p.errorf("unexpected %s", p.describe(p.peek()))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %w", expr, err)
+	}
+	p.q.pred = pred
+	return p.q, nil
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.toks[p.i]
+}
+
+func (p *queryParser) next() queryToken {
+	tok := p.toks[p.i]
+	if tok.kind != tokEOF {
+		p.i++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is the operator op
+func (p *queryParser) accept(op string) bool {
+	if tok := p.peek(); tok.kind == tokOp && tok.text == op {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// errorf reports an error at the next token
+func (p *queryParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at offset %d: %s", p.peek().pos, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser) describe(tok queryToken) string {
+	if tok.kind == tokEOF {
+		return "end of query"
+	}
+	return strconv.Quote(tok.text)
+}
+
+func (p *queryParser) parseOr() (Predicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(obj *Object) bool { return l(obj) || right(obj) }
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (Predicate, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(obj *Object) bool { return l(obj) && right(obj) }
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseUnary() (Predicate, error) {
+	switch {
+	case p.accept("!"):
+		pred, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(obj *Object) bool { return !pred(obj) }, nil
+	case p.accept("("):
+		pred, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("expected \")\", found %s", p.describe(p.peek()))
+		}
+		return pred, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *queryParser) parseCompare() (Predicate, error) {
+	field := p.peek()
+	if field.kind != tokIdent {
+		return nil, p.errorf("expected a field, found %s", p.describe(field))
+	}
+	p.next()
+	op := p.peek()
+	if op.kind != tokOp {
+		return nil, p.errorf("expected an operator after %s, found %s", field.text, p.describe(op))
+	}
+	p.next()
+	value := p.peek()
+
+	switch field.text {
+	case "type":
+		if op.text != "==" && op.text != "!=" && op.text != "=~" && op.text != "!~" {
+			return nil, fmt.Errorf("at offset %d: type does not support %q", op.pos, op.text)
+		}
+		s, err := p.stringValue(field.text)
+		if err != nil {
+			return nil, err
+		}
+		return compareType(op.text, s)
+	case "kind":
+		if op.text != "==" && op.text != "!=" {
+			return nil, fmt.Errorf("at offset %d: kind does not support %q", op.pos, op.text)
+		}
+		s, err := p.stringValue(field.text)
+		if err != nil {
+			return nil, err
+		}
+		kind, ok := parseKind(s)
+		if !ok {
+			return nil, fmt.Errorf("at offset %d: unknown kind %q", value.pos, s)
+		}
+		want := op.text == "=="
+		return func(obj *Object) bool { return (Classify(obj) == kind) == want }, nil
+	}
+
+	get, ok := queryNumFields[field.text]
+	if !ok {
+		return nil, fmt.Errorf("at offset %d: unknown field %q", field.pos, field.text)
+	}
+	if !numericOps[op.text] {
+		return nil, fmt.Errorf("at offset %d: %s does not support %q", op.pos, field.text, op.text)
+	}
+	n, err := p.numberValue(field.text)
+	if err != nil {
+		return nil, err
+	}
+	cmp := compareNumber(op.text, n)
+	switch field.text {
+	case "retained":
+		p.q.needRetained = true
+	case "indegree":
+		p.q.needDegree = true
+	}
+	env := p.q.env
+	return func(obj *Object) bool { return cmp(get(env, obj)) }, nil
+}
+
+// stringValue consumes a string literal compared with field
+func (p *queryParser) stringValue(field string) (string, error) {
+	tok := p.peek()
+	if tok.kind != tokString {
+		return "", p.errorf("%s needs a string, found %s", field, p.describe(tok))
+	}
+	p.next()
+	s, err := strconv.Unquote(tok.text)
+	if err != nil {
+		return "", fmt.Errorf("at offset %d: invalid string %s", tok.pos, tok.text)
+	}
+	return s, nil
+}
+
+// numberValue consumes a number, with an optional unit, compared with field
+func (p *queryParser) numberValue(field string) (uint64, error) {
+	tok := p.peek()
+	if tok.kind != tokNumber {
+		return 0, p.errorf("%s needs a number, found %s", field, p.describe(tok))
+	}
+	p.next()
+
+	digits := strings.TrimRightFunc(tok.text, isQueryLetter)
+	n, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("at offset %d: invalid number %s", tok.pos, tok.text)
+	}
+	if unit := tok.text[len(digits):]; unit != "" {
+		mult, ok := queryUnits[strings.ToLower(unit)]
+		if !ok {
+			return 0, fmt.Errorf("at offset %d: unknown unit %q", tok.pos+len(digits), unit)
+		}
+		hi, lo := bits.Mul64(n, mult)
+		if hi != 0 {
+			return 0, fmt.Errorf("at offset %d: %s is too large", tok.pos, tok.text)
+		}
+		n = lo
+	}
+	return n, nil
+}
+
+// compareType builds a type name comparison with op, one of ==, !=, =~,
+// and !~
+func compareType(op, s string) (Predicate, error) {
+	switch op {
+	case "==":
+		return func(obj *Object) bool { return obj.Type == s }, nil
+	case "!=":
+		return func(obj *Object) bool { return obj.Type != s }, nil
+	}
+
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid type pattern %q: %w", s, err)
+	}
+	// Many objects share a type, so match each distinct name only once
+	matches := make(map[string]bool)
+	want := op == "=~"
+	return func(obj *Object) bool {
+		match, ok := matches[obj.Type]
+		if !ok {
+			match = re.MatchString(obj.Type)
+			matches[obj.Type] = match
+		}
+		return match == want
+	}, nil
+}
+
+// numericOps are the operators numeric fields support
+var numericOps = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// compareNumber returns a test of a field's value against n with op, one
+// of numericOps
+func compareNumber(op string, n uint64) func(uint64) bool {
+	switch op {
+	case "==":
+		return func(v uint64) bool { return v == n }
+	case "!=":
+		return func(v uint64) bool { return v != n }
+	case "<":
+		return func(v uint64) bool { return v < n }
+	case "<=":
+		return func(v uint64) bool { return v <= n }
+	case ">":
+		return func(v uint64) bool { return v > n }
+	}
+	return func(v uint64) bool { return v >= n }
+}
+
+// parseKind returns the Kind whose String is name
+func parseKind(name string) (Kind, bool) {
+	for k, n := range kindNames {
+		if n == name {
+			return Kind(k), true
+		}
+	}
+	return KindUnknown, false
+}
+
+// queryOps lists the operators, longest first so "<=" is not read as "<"
+var queryOps = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")"}
+
+// lexQuery splits expr into tokens, ending with a tokEOF
+func lexQuery(expr string) ([]queryToken, error) {
+	var toks []queryToken
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isQueryLetter(rune(c)):
+			start := i
+			for i < len(expr) && (isQueryLetter(rune(expr[i])) || isQueryDigit(expr[i])) {
+				i++
+			}
+			toks = append(toks, queryToken{kind: tokIdent, text: expr[start:i], pos: start})
+		case isQueryDigit(c):
+			// A unit suffix is part of the number
+			start := i
+			for i < len(expr) && isQueryDigit(expr[i]) {
+				i++
+			}
+			for i < len(expr) && isQueryLetter(rune(expr[i])) {
+				i++
+			}
+			toks = append(toks, queryToken{kind: tokNumber, text: expr[start:i], pos: start})
+		case c == '"' || c == '`':
+			end, err := stringEnd(expr, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, queryToken{kind: tokString, text: expr[i:end], pos: i})
+			i = end
+		default:
+			op := ""
+			for _, o := range queryOps {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("at offset %d: unexpected character %q", i, rune(c))
+			}
+			toks = append(toks, queryToken{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, queryToken{kind: tokEOF, pos: len(expr)}), nil
+}
+
+// stringEnd returns the offset just past the string literal starting at
+// expr[start]. Double-quoted strings may escape their quote.
+func stringEnd(expr string, start int) (int, error) {
+	quote := expr[start]
+	for i := start + 1; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("at offset %d: unterminated string", start)
+}
+
+func isQueryLetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_'
+}
+
+func isQueryDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,184 @@
+// ABOUTME: Tests for the object query language
+// ABOUTME: Covers each field and operator, precedence, parse errors, and fuzzes the parser
+
+package graph
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	const mb = 1 << 20
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "*main.Cache", Size: 64, Ptrs: []ObjID{2, 3}})
+	g.AddObject(&Object{ID: 2, Type: "map[string]*main.Entry", Size: 2 * mb, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 3, Type: "[]uint8", Size: 4096, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 4, Type: "main.Entry", Size: 32})
+	g.AddObject(&Object{ID: 5, Type: "main.LRUCache", Size: 16})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	tests := []struct {
+		expr string
+		want []ObjID
+	}{
+		{expr: `type == "main.Entry"`, want: []ObjID{4}},
+		{expr: `type != "main.Entry"`, want: []ObjID{1, 2, 3, 5}},
+		{expr: `type =~ "Cache"`, want: []ObjID{1, 5}},
+		{expr: "type =~ `^main\\.`", want: []ObjID{4, 5}},
+		{expr: `type !~ "Cache|Entry"`, want: []ObjID{3}},
+		{expr: `kind == "slice"`, want: []ObjID{3}},
+		{expr: `kind != "struct"`, want: []ObjID{1, 2, 3}},
+		{expr: "size >= 4KB", want: []ObjID{2, 3}},
+		{expr: "size > 4096", want: []ObjID{2}},
+		{expr: "size < 32", want: []ObjID{5}},
+		{expr: "size <= 32b", want: []ObjID{4, 5}},
+		{expr: "size == 2MB", want: []ObjID{2}},
+		{expr: "size != 2m", want: []ObjID{1, 3, 4, 5}},
+		{expr: "retained > 2MB", want: []ObjID{1}},
+		{expr: "retained == 16", want: []ObjID{5}},
+		{expr: "indegree >= 2", want: []ObjID{4}},
+		{expr: "outdegree == 0", want: []ObjID{4, 5}},
+		{expr: `type =~ "Cache" && retained > 1MB`, want: []ObjID{1}},
+		{expr: `size < 64 || kind == "map"`, want: []ObjID{2, 4, 5}},
+		{expr: `size < 64 || size > 1MB && kind == "slice"`, want: []ObjID{4, 5}},
+		{expr: `(size < 64 || size > 1MB) && kind == "map"`, want: []ObjID{2}},
+		{expr: `!(size < 64) && !kind == "map"`, want: []ObjID{1, 3}},
+		{expr: "size>1k&&outdegree>0", want: []ObjID{2, 3}},
+		{expr: "size > 1TB", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := Query(g, tt.expr)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryComputesOnlyWhatItUses(t *testing.T) {
+	for _, tt := range []struct {
+		expr                     string
+		needRetained, needDegree bool
+	}{
+		{expr: `type =~ "x" && size > 1`},
+		{expr: "retained > 1", needRetained: true},
+		{expr: "indegree > 1 || outdegree > 1", needDegree: true},
+	} {
+		q, err := parseQuery(tt.expr)
+		if err != nil {
+			t.Fatalf("parseQuery(%q) error = %v", tt.expr, err)
+		}
+		if q.needRetained != tt.needRetained || q.needDegree != tt.needDegree {
+			t.Errorf("parseQuery(%q) needs retained %t, degree %t; want %t, %t", tt.expr, q.needRetained, q.needDegree, tt.needRetained, tt.needDegree)
+		}
+	}
+}
+
+func TestQueryContext(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "main.A", Size: 8, Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "main.B", Size: 8})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	// Supplied retained sizes are used as they are
+	got, err := QueryContext(context.Background(), g, "retained > 100", map[ObjID]uint64{2: 200})
+	if err != nil || !reflect.DeepEqual(got, []ObjID{2}) {
+		t.Errorf("QueryContext(supplied sizes) = %v, %v; want [2]", got, err)
+	}
+
+	// Computing them stops when the context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := QueryContext(ctx, g, "retained > 100", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("QueryContext(cancelled) error = %v, want context.Canceled", err)
+	}
+	if got, err := QueryContext(ctx, g, "size > 1", nil); err != nil || len(got) != 2 {
+		t.Errorf("QueryContext(cancelled, no retained) = %v, %v; want both objects", got, err)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: "", want: "expected a field, found end of query"},
+		{expr: "size >", want: "size needs a number, found end of query"},
+		{expr: "size > 1 &&", want: "at offset 11: expected a field"},
+		{expr: "size > 1 size > 2", want: `unexpected "size"`},
+		{expr: "(size > 1", want: `expected ")"`},
+		{expr: "color == 1", want: `unknown field "color"`},
+		{expr: `size > "big"`, want: "size needs a number"},
+		{expr: "type == main", want: "type needs a string"},
+		{expr: "type > 1", want: `type does not support ">"`},
+		{expr: `size =~ "1"`, want: `size does not support "=~"`},
+		{expr: `kind =~ "map"`, want: `kind does not support "=~"`},
+		{expr: `kind == "blob"`, want: `unknown kind "blob"`},
+		{expr: "size > 1XB", want: `unknown unit "XB"`},
+		{expr: "size > 99999999999999999999", want: "invalid number"},
+		{expr: "size > 20000000TB", want: "too large"},
+		{expr: `type =~ "("`, want: "invalid type pattern"},
+		{expr: `type == "abc`, want: "at offset 8: unterminated string"},
+		{expr: `type == "\q"`, want: "invalid string"},
+		{expr: "size > 1 & size < 2", want: `unexpected character '&'`},
+	}
+
+	g := NewMemGraph()
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Query(g, tt.expr)
+			if err == nil {
+				t.Fatal("Query() error = nil")
+			}
+			if !strings.Contains(err.Error(), tt.want) || !strings.HasPrefix(err.Error(), "invalid query") {
+				t.Errorf("Query() error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// FuzzQuery checks the parser never panics and that whatever it accepts
+// evaluates over a small graph
+func FuzzQuery(f *testing.F) {
+	for _, seed := range []string{
+		`type =~ "Cache" && retained > 1MB && indegree > 100`,
+		`(kind == "map" || kind == "slice") && !(size < 4KB)`,
+		"type == `a\\b` || outdegree != 0",
+		`type == "a\"b"`,
+		"((size > 1",
+		"size > 1 &&",
+		"!!!size<=1b",
+		`"`,
+	} {
+		f.Add(seed)
+	}
+
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "main.Cache", Size: 64, Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "[]uint8", Size: 1 << 20})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	f.Fuzz(func(t *testing.T, expr string) {
+		ids, err := Query(g, expr)
+		if err != nil {
+			if ids != nil {
+				t.Errorf("Query(%q) returned %v with error %v", expr, ids, err)
+			}
+			return
+		}
+		for _, id := range ids {
+			if g.GetObject(id) == nil {
+				t.Errorf("Query(%q) returned missing object %d", expr, id)
+			}
+		}
+	})
+}
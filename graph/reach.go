@@ -0,0 +1,36 @@
+// ABOUTME: Answers whether one object can reach another through pointers
+// ABOUTME: Depth-first search that stops as soon as the target is found
+
+package graph
+
+// IsReachableFrom reports whether to can be reached from from by following
+// pointers, which is cheaper than PathsToRoots when only a yes or no is
+// needed. Each object is visited at most once, so cycles are fine. An object
+// always reaches itself; otherwise both must be in the graph.
+func IsReachableFrom(g Graph, from, to ObjID) bool {
+	if from == to {
+		return true
+	}
+	if g.GetObject(from) == nil || g.GetObject(to) == nil {
+		return false
+	}
+
+	seen := map[ObjID]bool{from: true}
+	stack := []ObjID{from}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for _, ptr := range g.GetObject(id).Ptrs {
+			if ptr == to {
+				return true
+			}
+			if seen[ptr] || g.GetObject(ptr) == nil {
+				continue
+			}
+			seen[ptr] = true
+			stack = append(stack, ptr)
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+// ABOUTME: Tests for the is-reachable-from query
+// ABOUTME: Covers direct and indirect edges, cycles, and missing objects
+
+package graph
+
+import "testing"
+
+func TestIsReachableFrom(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "pool", Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "conn", Ptrs: []ObjID{3}})
+	g.AddObject(&Object{ID: 3, Type: "buffer", Ptrs: []ObjID{1}}) // cycle back to the pool
+	g.AddObject(&Object{ID: 4, Type: "other", Ptrs: []ObjID{3, 99}})
+	g.AddObject(&Object{ID: 5, Type: "island"})
+
+	tests := []struct {
+		name     string
+		from, to ObjID
+		want     bool
+	}{
+		{"direct", 1, 2, true},
+		{"indirect", 1, 3, true},
+		{"around a cycle", 2, 1, true},
+		{"into a cycle", 4, 2, true},
+		{"against edges", 3, 4, false},
+		{"disconnected", 1, 5, false},
+		{"itself", 5, 5, true},
+		{"dangling pointer", 4, 99, false},
+		{"missing source", 99, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsReachableFrom(g, tt.from, tt.to); got != tt.want {
+				t.Errorf("IsReachableFrom(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
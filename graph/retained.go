@@ -2,105 +2,247 @@
 // ABOUTME: Provides efficient computation of memory retained by each object
 package graph
 
+import (
+	"context"
+	"sort"
+)
+
 // RetainedSize computes the retained size for each reachable object in the graph.
 // The retained size of an object is the total size of all objects that would be
 // garbage collected if that object were removed. This is computed using the
 // dominator tree: an object retains all objects it dominates.
 // Returns a map from object ID to its retained size in bytes.
+//
+// With several roots, an object reachable from more than one of them is
+// dominated only by the super-root, so it counts towards no root's retained
+// size: each root retains just what it alone keeps alive. Use
+// RetainedSizeFromRoot to see everything a single root reaches.
 func RetainedSize(g Graph) map[ObjID]uint64 {
-	// First compute dominators and build the tree
-	dominators := Dominators(g)
-	tree := DominatorTree(dominators)
-	
-	// Create a map to store object sizes
-	objSizes := make(map[ObjID]uint64)
-	g.ForEachObject(func(obj *Object) {
-		objSizes[obj.ID] = obj.Size
-	})
-	
-	// Add super-root with size 0
-	objSizes[0] = 0
-	
-	// Compute retained sizes using post-order traversal of the dominator tree
-	retained := make(map[ObjID]uint64)
-	
-	var computeRetained func(ObjID) uint64
-	computeRetained = func(nodeID ObjID) uint64 {
-		if size, computed := retained[nodeID]; computed {
-			return size
-		}
-		
-		// Start with the object's own size
-		size := objSizes[nodeID]
-		
-		// Add retained sizes of all immediately dominated nodes
-		for _, child := range tree[nodeID] {
-			size += computeRetained(child)
+	return retainedSize(g, func(obj *Object) uint64 { return obj.Size })
+}
+
+// RetainedSizeFromRoot is like RetainedSize but analyzes the graph as if
+// root were its only root, so objects shared with other roots count
+// towards root and whatever dominates them under it. root's own retained
+// size is then everything reachable from it. Objects root cannot reach are
+// left out, as is everything if root is not in the graph.
+func RetainedSizeFromRoot(g Graph, root ObjID) map[ObjID]uint64 {
+	if root == 0 || g.GetObject(root) == nil {
+		return make(map[ObjID]uint64)
+	}
+	d := newDenseGraphFrom(g, Roots{IDs: []ObjID{root}})
+	return d.retainedSizeMap(func(obj *Object) uint64 { return obj.Size })
+}
+
+// RetainedSizeContext is like RetainedSize but stops early with ctx's
+// error if ctx is canceled. If progress is not nil, it is called
+// periodically with the steps done so far out of total; most of the work
+// is the dominator computation, as in DominatorsContext.
+func RetainedSizeContext(ctx context.Context, g Graph, progress func(done, total int)) (map[ObjID]uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d := newDenseGraph(g)
+	t := newTracker(ctx, progress, d.dominatorSteps())
+	idom, order, err := d.dominatorsTracked(t)
+	if err != nil {
+		return nil, err
+	}
+	result := d.retainedSizeResult(idom, order, func(obj *Object) uint64 { return obj.Size })
+	if err := t.skipTo(t.total); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RetainedAllocSize is like RetainedSize but counts each object's
+// AllocatedSize, the size-class rounded bytes the runtime reserved for it.
+// This is what matters when explaining memory use or OOMs.
+func RetainedAllocSize(g Graph) map[ObjID]uint64 {
+	return retainedSize(g, (*Object).AllocatedSize)
+}
+
+func retainedSize(g Graph, sizeOf func(*Object) uint64) map[ObjID]uint64 {
+	return newDenseGraph(g).retainedSizeMap(sizeOf)
+}
+
+// retainedSizeMap runs the dominator analysis of d and returns the
+// retained size of every reachable object
+func (d *denseGraph) retainedSizeMap(sizeOf func(*Object) uint64) map[ObjID]uint64 {
+	idom, order := d.dominators()
+	return d.retainedSizeResult(idom, order, sizeOf)
+}
+
+// retainedSizeResult maps the retained sizes from a dominator analysis of
+// d to object IDs
+func (d *denseGraph) retainedSizeResult(idom, order []int32, sizeOf func(*Object) uint64) map[ObjID]uint64 {
+	retained := d.retainedSizes(idom, order, sizeOf)
+
+	result := make(map[ObjID]uint64, len(order))
+	for _, v := range order[1:] {
+		if d.ids[v] != 0 {
+			result[d.ids[v]] = retained[v]
 		}
-		
-		retained[nodeID] = size
-		return size
 	}
-	
-	// Compute retained sizes for all nodes in the tree
-	for nodeID := range tree {
-		computeRetained(nodeID)
+	return result
+}
+
+// DominatedCounts gives, for each reachable object, how many objects its
+// retained size covers: itself and everything it dominates. A cache of
+// millions of tiny objects and one of a few huge ones can retain the same
+// bytes; the count tells them apart.
+func DominatedCounts(g Graph) map[ObjID]int {
+	d := newDenseGraph(g)
+	idom, order := d.dominators()
+	return d.dominatedCountResult(idom, order)
+}
+
+// dominatedCountResult is retainedSizeResult with every object counting
+// as one
+func (d *denseGraph) dominatedCountResult(idom, order []int32) map[ObjID]int {
+	counts := d.retainedSizes(idom, order, func(*Object) uint64 { return 1 })
+
+	result := make(map[ObjID]int, len(order))
+	for _, v := range order[1:] {
+		if d.ids[v] != 0 {
+			result[d.ids[v]] = int(counts[v])
+		}
 	}
-	
-	// Remove super-root from results
-	delete(retained, 0)
-	
-	return retained
+	return result
 }
 
 // RetainedSizeSubsets computes retained sizes for a specific subset of objects.
-// This is more efficient than computing all retained sizes when you only need
-// a few specific objects.
+// Objects that exist but are unreachable retain only themselves; IDs that are
+// not in the graph are left out of the result.
 func RetainedSizeSubsets(g Graph, targetIDs []ObjID) map[ObjID]uint64 {
 	if len(targetIDs) == 0 {
 		return make(map[ObjID]uint64)
 	}
-	
-	// First compute dominators and build the tree
-	dominators := Dominators(g)
-	tree := DominatorTree(dominators)
-	
-	// Create a map to store object sizes
-	objSizes := make(map[ObjID]uint64)
-	g.ForEachObject(func(obj *Object) {
-		objSizes[obj.ID] = obj.Size
-	})
-	
-	// Add super-root with size 0
-	objSizes[0] = 0
-	
+	return retainedSubset(g, RetainedSize(g), targetIDs)
+}
+
+// retainedSubset picks targetIDs out of retained, falling back to an
+// object's own size when it is unreachable
+func retainedSubset(g Graph, retained map[ObjID]uint64, targetIDs []ObjID) map[ObjID]uint64 {
 	result := make(map[ObjID]uint64)
-	computed := make(map[ObjID]uint64) // cache to avoid recomputation
-	
-	var computeRetained func(ObjID) uint64
-	computeRetained = func(nodeID ObjID) uint64 {
-		if size, exists := computed[nodeID]; exists {
-			return size
+	for _, targetID := range targetIDs {
+		if targetID == 0 {
+			continue
 		}
-		
-		// Start with the object's own size
-		size := objSizes[nodeID]
-		
-		// Add retained sizes of all immediately dominated nodes
-		for _, child := range tree[nodeID] {
-			size += computeRetained(child)
+		if size, ok := retained[targetID]; ok {
+			result[targetID] = size
+		} else if obj := g.GetObject(targetID); obj != nil {
+			result[targetID] = obj.Size
 		}
-		
-		computed[nodeID] = size
-		return size
 	}
-	
-	// Compute retained sizes only for target objects that exist
-	for _, targetID := range targetIDs {
-		if _, exists := objSizes[targetID]; exists && targetID != 0 {
-			result[targetID] = computeRetained(targetID)
+	return result
+}
+
+// RetainedSizeByType computes the total retained size attributed to each type.
+// An object's retained size counts towards its type only if no dominator of
+// that object has the same type, so nested objects of one type (e.g. the nodes
+// of a linked list) are not double counted.
+func RetainedSizeByType(g Graph) map[string]uint64 {
+	di := ComputeDominators(g)
+	retained, tree := di.RetainedSize(), di.Tree
+
+	result := make(map[string]uint64)
+	active := make(map[string]int) // types on the current dominator path
+
+	type frame struct {
+		id    ObjID
+		typ   string
+		leave bool
+	}
+	stack := []frame{{id: 0}}
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if f.leave {
+			active[f.typ]--
+			continue
+		}
+
+		if f.id != 0 {
+			obj := g.GetObject(f.id)
+			if obj == nil {
+				continue
+			}
+			if active[obj.Type] == 0 {
+				result[obj.Type] += retained[f.id]
+			}
+			active[obj.Type]++
+			stack = append(stack, frame{id: f.id, typ: obj.Type, leave: true})
+		}
+
+		for _, child := range tree[f.id] {
+			stack = append(stack, frame{id: child})
 		}
 	}
-	
+
 	return result
-}
\ No newline at end of file
+}
+
+// TopRetained returns the n objects with the largest retained sizes, largest
+// first, given the result of RetainedSize. Ties are ordered by ID.
+func TopRetained(retained map[ObjID]uint64, n int) []ObjID {
+	if n <= 0 {
+		return nil
+	}
+
+	ids := make([]ObjID, 0, len(retained))
+	for id := range retained {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if retained[ids[i]] != retained[ids[j]] {
+			return retained[ids[i]] > retained[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+	return ids
+}
+
+// RetainedStat is one row of a retained size report
+type RetainedStat struct {
+	ID       ObjID
+	Retained uint64 // Retained size in bytes
+
+	// PercentOfTotal is Retained as a percentage of ReachableSize(g)
+	PercentOfTotal float64
+}
+
+// ReachableSize returns the total size of all objects reachable from the
+// roots, which is the most any single object can retain
+func ReachableSize(g Graph) uint64 {
+	var total uint64
+	for id := range RootDistance(g) {
+		total += g.GetObject(id).Size
+	}
+	return total
+}
+
+// TopRetainedStats is TopRetained with each object's share of the
+// reachable heap. retained should come from RetainedSize(g).
+func TopRetainedStats(g Graph, retained map[ObjID]uint64, n int) []RetainedStat {
+	ids := TopRetained(retained, n)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	total := ReachableSize(g)
+	stats := make([]RetainedStat, len(ids))
+	for i, id := range ids {
+		stats[i] = RetainedStat{
+			ID:             id,
+			Retained:       retained[id],
+			PercentOfTotal: percent(retained[id], total),
+		}
+	}
+	return stats
+}
@@ -3,6 +3,8 @@
 package graph
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -223,6 +225,39 @@ func TestRetainedSizeWithDominators(t *testing.T) {
 	})
 }
 
+func TestRetainedSizeFromRoot(t *testing.T) {
+	// Roots 1 and 2 share 3, which points to 4; only 1 reaches 5
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Size: 100, Ptrs: []ObjID{3, 5}})
+	g.AddObject(&Object{ID: 2, Size: 200, Ptrs: []ObjID{3}})
+	g.AddObject(&Object{ID: 3, Size: 50, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 4, Size: 25})
+	g.AddObject(&Object{ID: 5, Size: 10})
+	g.SetRoots(Roots{IDs: []ObjID{1, 2}})
+
+	tests := []struct {
+		root ObjID
+		want map[ObjID]uint64
+	}{
+		{1, map[ObjID]uint64{1: 185, 3: 75, 4: 25, 5: 10}},
+		{2, map[ObjID]uint64{2: 275, 3: 75, 4: 25}},
+		{4, map[ObjID]uint64{4: 25}},
+		{99, map[ObjID]uint64{}},
+	}
+	for _, tt := range tests {
+		for name, graph := range map[string]Graph{"MemGraph": g, "CompactGraph": Compact(g)} {
+			if got := RetainedSizeFromRoot(graph, tt.root); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: RetainedSizeFromRoot(%d) = %v, want %v", name, tt.root, got, tt.want)
+			}
+		}
+	}
+
+	// The super-root model gives neither root the shared objects
+	if got := RetainedSize(g)[1]; got != 110 {
+		t.Errorf("RetainedSize()[1] = %d, want 110", got)
+	}
+}
+
 // TestRetainedSizeSubsets tests that RetainedSizeSubsets works correctly
 func TestRetainedSizeSubsets(t *testing.T) {
 	graph := func() Graph {
@@ -273,4 +308,140 @@ func TestRetainedSizeSubsets(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// TestRetainedSizeByType verifies nested objects of one type are not double counted
+func TestRetainedSizeByType(t *testing.T) {
+	// 1 (root, holder) -> 2 (node) -> 3 (node) -> 4 (payload)
+	//                  -> 5 (payload)
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "holder", Size: 10, Ptrs: []ObjID{2, 5}})
+	g.AddObject(&Object{ID: 2, Type: "node", Size: 20, Ptrs: []ObjID{3}})
+	g.AddObject(&Object{ID: 3, Type: "node", Size: 20, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 4, Type: "payload", Size: 100})
+	g.AddObject(&Object{ID: 5, Type: "payload", Size: 50})
+	g.AddObject(&Object{ID: 6, Type: "garbage", Size: 999})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	got := RetainedSizeByType(g)
+	want := map[string]uint64{
+		"holder":  200, // everything reachable
+		"node":    140, // outer node only: 20 + 20 + 100
+		"payload": 150, // 100 + 50
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RetainedSizeByType() = %v, want %v", got, want)
+	}
+}
+
+func TestTopRetained(t *testing.T) {
+	retained := map[ObjID]uint64{1: 100, 2: 500, 3: 100, 4: 50}
+
+	if got, want := TopRetained(retained, 3), []ObjID{2, 1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TopRetained(3) = %v, want %v", got, want)
+	}
+	if got := TopRetained(retained, 10); len(got) != 4 {
+		t.Errorf("TopRetained(10) returned %d objects, want 4", len(got))
+	}
+	if got := TopRetained(retained, 0); got != nil {
+		t.Errorf("TopRetained(0) = %v, want nil", got)
+	}
+}
+
+func TestTopRetainedStats(t *testing.T) {
+	// 1 -> 2 -> 3 from a single root; 4 is unreachable and not counted
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Size: 50, Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "mid", Size: 30, Ptrs: []ObjID{3}})
+	g.AddObject(&Object{ID: 3, Type: "leaf", Size: 20})
+	g.AddObject(&Object{ID: 4, Type: "garbage", Size: 1000})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	if got := ReachableSize(g); got != 100 {
+		t.Fatalf("ReachableSize() = %d, want 100", got)
+	}
+
+	got := TopRetainedStats(g, RetainedSize(g), 2)
+	want := []RetainedStat{
+		{ID: 1, Retained: 100, PercentOfTotal: 100},
+		{ID: 2, Retained: 50, PercentOfTotal: 50},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TopRetainedStats() = %+v, want %+v", got, want)
+	}
+	if got := TopRetainedStats(g, RetainedSize(g), 0); got != nil {
+		t.Errorf("TopRetainedStats(0) = %v, want nil", got)
+	}
+}
+
+func TestRetainedAllocSize(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Size: 17, AllocSize: 24, Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "leaf", Size: 10}) // unknown alloc size
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	want := map[ObjID]uint64{1: 34, 2: 10}
+	if got := RetainedAllocSize(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("RetainedAllocSize() = %v, want %v", got, want)
+	}
+	if got := RetainedSize(g)[1]; got != 27 {
+		t.Errorf("RetainedSize()[1] = %d, want 27", got)
+	}
+}
+
+func TestDominatedCounts(t *testing.T) {
+	// A map of many small entries and a buffer holding two large ones
+	// retain the same bytes but very different numbers of objects
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Size: 8, Ptrs: []ObjID{2, 3}})
+	g.AddObject(&Object{ID: 2, Type: "map", Size: 10, Ptrs: []ObjID{4, 5, 6, 7}})
+	g.AddObject(&Object{ID: 3, Type: "buffers", Size: 10, Ptrs: []ObjID{8, 9}})
+	for id := ObjID(4); id <= 7; id++ {
+		g.AddObject(&Object{ID: id, Type: "entry", Size: 50, Ptrs: []ObjID{10}})
+	}
+	g.AddObject(&Object{ID: 8, Type: "[]byte", Size: 100})
+	g.AddObject(&Object{ID: 9, Type: "[]byte", Size: 100})
+	g.AddObject(&Object{ID: 10, Type: "shared", Size: 1})
+	g.AddObject(&Object{ID: 11, Type: "garbage", Size: 1})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	want := map[ObjID]int{1: 10, 2: 6, 3: 3, 4: 1, 5: 1, 6: 1, 7: 1, 8: 1, 9: 1, 10: 1}
+	if got := DominatedCounts(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("DominatedCounts() = %v, want %v", got, want)
+	}
+	if retained := RetainedSize(g); retained[2] != 211 || retained[3] != 210 {
+		t.Errorf("RetainedSize() = %v, want 211 and 210 for 2 and 3", retained)
+	}
+}
+
+func TestRetainedSizeContext(t *testing.T) {
+	g := benchmarkGraph(3 * checkInterval)
+
+	var calls, last, total int
+	got, err := RetainedSizeContext(context.Background(), g, func(done, n int) {
+		if done < last {
+			t.Errorf("progress went backwards from %d to %d", last, done)
+		}
+		calls++
+		last, total = done, n
+	})
+	if err != nil {
+		t.Fatalf("RetainedSizeContext() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, RetainedSize(g)) {
+		t.Error("RetainedSizeContext() differs from RetainedSize()")
+	}
+	if calls < 2 || last != total {
+		t.Errorf("progress called %d times ending at %d/%d, want several ending at total", calls, last, total)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	got, err = RetainedSizeContext(ctx, g, func(done, total int) { cancel() })
+	if !errors.Is(err, context.Canceled) || got != nil {
+		t.Errorf("canceled mid-run = %d sizes, %v, want context.Canceled", len(got), err)
+	}
+	if _, err := RetainedSizeContext(ctx, g, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("already canceled error = %v, want context.Canceled", err)
+	}
+}
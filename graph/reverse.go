@@ -3,6 +3,11 @@
 
 package graph
 
+import (
+	"slices"
+	"sort"
+)
+
 // ReverseEdges maps each object to the objects that point to it
 type ReverseEdges map[ObjID][]ObjID
 
@@ -17,4 +22,83 @@ func BuildReverseEdges(g Graph) ReverseEdges {
 	})
 	
 	return reverse
-}
\ No newline at end of file
+}
+
+// ReferrerGraph is a Graph that can list an object's referrers without
+// scanning every object. MemGraph and CompactGraph implement it.
+type ReferrerGraph interface {
+	Graph
+
+	// Referrers returns what the package-level Referrers does for id
+	Referrers(id ObjID) []ObjID
+}
+
+// Referrers returns the IDs of objects that point to id, in ascending
+// order and without duplicates. The result may be shared and must not be
+// modified. It uses g's Referrers method when g is a ReferrerGraph, which
+// builds the reverse edges of the whole graph once and caches them until
+// the graph changes, and otherwise scans every object. Pointers changed by
+// editing an Object's Ptrs in place are not noticed by the cache.
+func Referrers(g Graph, id ObjID) []ObjID {
+	if rg, ok := g.(ReferrerGraph); ok {
+		return rg.Referrers(id)
+	}
+
+	var refs []ObjID
+	g.ForEachObject(func(obj *Object) {
+		for _, ptr := range obj.Ptrs {
+			if ptr == id {
+				refs = append(refs, obj.ID)
+				break
+			}
+		}
+	})
+	sort.Slice(refs, func(i, j int) bool { return refs[i] < refs[j] })
+	return refs
+}
+
+// referrersOf returns a function listing referrers as Referrers does, for
+// walks that visit many objects. Without a ReferrerGraph it scans g once
+// up front instead of once per visited object.
+func referrersOf(g Graph) func(ObjID) []ObjID {
+	if _, ok := g.(ReferrerGraph); ok {
+		return func(id ObjID) []ObjID { return Referrers(g, id) }
+	}
+	reverse := BuildReverseEdges(g)
+	for id, refs := range reverse {
+		sort.Slice(refs, func(i, j int) bool { return refs[i] < refs[j] })
+		reverse[id] = slices.Compact(refs)
+	}
+	return func(id ObjID) []ObjID { return reverse[id] }
+}
+
+// Ancestors returns every object that can reach id by following pointers,
+// not including id itself, sorted by ID. It is the blame set for a leak:
+// everything that could be keeping id alive, where DominatedSet is what id
+// keeps alive. Cycles are handled; nil is returned for objects not in the
+// graph.
+func Ancestors(g Graph, id ObjID) []ObjID {
+	if g.GetObject(id) == nil {
+		return nil
+	}
+
+	referrers := referrersOf(g)
+	seen := map[ObjID]bool{id: true}
+	var result []ObjID
+	queue := []ObjID{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, ref := range referrers(current) {
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			result = append(result, ref)
+			queue = append(queue, ref)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
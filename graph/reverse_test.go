@@ -0,0 +1,85 @@
+// ABOUTME: Tests for looking up an object's referrers
+// ABOUTME: Compares cached implementations with a scan and checks invalidation
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+// scanGraph hides the Referrers methods so the fallback scan is used
+type scanGraph struct{ Graph }
+
+func TestReferrers(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "a", Ptrs: []ObjID{3, 2}})
+	g.AddObject(&Object{ID: 2, Type: "b", Ptrs: []ObjID{3, 3}})
+	g.AddObject(&Object{ID: 3, Type: "c", Ptrs: []ObjID{99}})
+	g.AddObject(&Object{ID: 4, Type: "d", Ptrs: []ObjID{3}})
+
+	want := map[ObjID][]ObjID{1: nil, 2: {1}, 3: {1, 2, 4}, 4: nil, 404: nil}
+	graphs := map[string]Graph{
+		"MemGraph":     g,
+		"CompactGraph": Compact(g),
+		"Snapshot":     g.Snapshot(),
+		"scan":         scanGraph{g},
+	}
+	for name, graph := range graphs {
+		for id, refs := range want {
+			if got := Referrers(graph, id); len(got) != len(refs) || (len(refs) > 0 && !reflect.DeepEqual(got, refs)) {
+				t.Errorf("%s: Referrers(%d) = %v, want %v", name, id, got, refs)
+			}
+		}
+	}
+}
+
+func TestReferrersInvalidation(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "a", Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "b"})
+	cg := Compact(g)
+
+	if got := g.Referrers(2); !reflect.DeepEqual(got, []ObjID{1}) {
+		t.Fatalf("MemGraph Referrers(2) = %v, want [1]", got)
+	}
+	if got := cg.Referrers(2); !reflect.DeepEqual(got, []ObjID{1}) {
+		t.Fatalf("CompactGraph Referrers(2) = %v, want [1]", got)
+	}
+
+	for _, graph := range []ReferrerGraph{g, cg} {
+		graph.AddObject(&Object{ID: 3, Type: "c", Ptrs: []ObjID{2}})
+		if got := graph.Referrers(2); !reflect.DeepEqual(got, []ObjID{1, 3}) {
+			t.Errorf("%T Referrers(2) after AddObject = %v, want [1 3]", graph, got)
+		}
+	}
+
+	LinkByAddr(g, map[uint64]ObjID{0x10: 1}, map[ObjID][]uint64{2: {0x10}})
+	if got := g.Referrers(1); !reflect.DeepEqual(got, []ObjID{2}) {
+		t.Errorf("Referrers(1) after LinkByAddr = %v, want [2]", got)
+	}
+}
+
+func TestAncestors(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "pool", Ptrs: []ObjID{3, 4}})
+	g.AddObject(&Object{ID: 3, Type: "conn", Ptrs: []ObjID{4, 2}}) // cycle with the pool
+	g.AddObject(&Object{ID: 4, Type: "buffer"})
+	g.AddObject(&Object{ID: 5, Type: "cache", Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 6, Type: "unrelated", Ptrs: []ObjID{1}})
+
+	want := map[ObjID][]ObjID{
+		4:   {1, 2, 3, 5, 6},
+		2:   {1, 3, 6},
+		6:   nil,
+		404: nil,
+	}
+	for name, graph := range map[string]Graph{"MemGraph": g, "CompactGraph": Compact(g), "scan": scanGraph{g}} {
+		for id, ancestors := range want {
+			if got := Ancestors(graph, id); !reflect.DeepEqual(got, ancestors) {
+				t.Errorf("%s: Ancestors(%d) = %v, want %v", name, id, got, ancestors)
+			}
+		}
+	}
+}
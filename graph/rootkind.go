@@ -0,0 +1,77 @@
+// ABOUTME: Classifies GC roots by where they come from
+// ABOUTME: Groups roots into stacks, globals, finalizer queues, and others
+
+package graph
+
+// RootKind is the source of a GC root
+type RootKind int
+
+const (
+	RootOther     RootKind = iota // Runtime-internal roots and unknown sources
+	RootStack                     // A goroutine's stack frame
+	RootGlobal                    // A package-level variable (data or BSS)
+	RootFinalizer                 // An object or closure held for a finalizer
+)
+
+var rootKindNames = [...]string{
+	RootOther:     "other",
+	RootStack:     "stack",
+	RootGlobal:    "global",
+	RootFinalizer: "finalizer",
+}
+
+func (k RootKind) String() string {
+	if k < 0 || int(k) >= len(rootKindNames) {
+		return rootKindNames[RootOther]
+	}
+	return rootKindNames[k]
+}
+
+// Kind returns the kind of the i'th root in IDs, or RootOther if Kinds
+// doesn't cover it
+func (r Roots) Kind(i int) RootKind {
+	if i < len(r.Kinds) {
+		return r.Kinds[i]
+	}
+	return RootOther
+}
+
+// Desc returns the description of the i'th root in IDs, or "" if Descs
+// doesn't cover it
+func (r Roots) Desc(i int) string {
+	if i < len(r.Descs) {
+		return r.Descs[i]
+	}
+	return ""
+}
+
+// Goroutine returns the ID of the goroutine whose stack holds the i'th
+// root in IDs, or 0 if Goroutines doesn't cover it
+func (r Roots) Goroutine(i int) uint64 {
+	if i < len(r.Goroutines) {
+		return r.Goroutines[i]
+	}
+	return 0
+}
+
+// ParseRootKind returns the RootKind whose String is name
+func ParseRootKind(name string) (RootKind, bool) {
+	for k, n := range rootKindNames {
+		if n == name {
+			return RootKind(k), true
+		}
+	}
+	return RootOther, false
+}
+
+// RootsByKind groups g's roots by kind, keeping their order in GetRoots.
+// Only kinds with at least one root are present.
+func RootsByKind(g Graph) map[RootKind][]ObjID {
+	roots := g.GetRoots()
+	result := make(map[RootKind][]ObjID)
+	for i, id := range roots.IDs {
+		kind := roots.Kind(i)
+		result[kind] = append(result[kind], id)
+	}
+	return result
+}
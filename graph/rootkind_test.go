@@ -0,0 +1,54 @@
+// ABOUTME: Tests for GC root classification
+// ABOUTME: Checks grouping by kind and the default for unclassified roots
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRootsByKind(t *testing.T) {
+	g := NewMemGraph()
+	for id := ObjID(1); id <= 5; id++ {
+		g.AddObject(&Object{ID: id})
+	}
+	// Kinds is shorter than IDs, so root 5 defaults to RootOther
+	g.SetRoots(Roots{
+		IDs:   []ObjID{1, 2, 3, 4, 5},
+		Kinds: []RootKind{RootStack, RootGlobal, RootStack, RootFinalizer},
+	})
+
+	want := map[RootKind][]ObjID{
+		RootStack:     {1, 3},
+		RootGlobal:    {2},
+		RootFinalizer: {4},
+		RootOther:     {5},
+	}
+	if got := RootsByKind(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("RootsByKind() = %v, want %v", got, want)
+	}
+	if got := RootsByKind(Compact(g)); !reflect.DeepEqual(got, want) {
+		t.Errorf("RootsByKind(Compact) = %v, want %v", got, want)
+	}
+
+	if got := RootKind(99).String(); got != "other" {
+		t.Errorf("RootKind(99).String() = %q, want other", got)
+	}
+}
+
+func TestParseRootKind(t *testing.T) {
+	for _, kind := range []RootKind{RootOther, RootStack, RootGlobal, RootFinalizer} {
+		if got, ok := ParseRootKind(kind.String()); !ok || got != kind {
+			t.Errorf("ParseRootKind(%q) = %v, %v, want %v", kind.String(), got, ok, kind)
+		}
+	}
+	if _, ok := ParseRootKind("heap"); ok {
+		t.Error(`ParseRootKind("heap") should fail`)
+	}
+
+	roots := Roots{IDs: []ObjID{1, 2}, Descs: []string{"finalizer"}}
+	if roots.Desc(0) != "finalizer" || roots.Desc(1) != "" {
+		t.Errorf("Desc() = %q, %q, want finalizer and none", roots.Desc(0), roots.Desc(1))
+	}
+}
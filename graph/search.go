@@ -0,0 +1,44 @@
+// ABOUTME: Finds objects by type name for interactive exploration
+// ABOUTME: Supports regular expression and exact type matching
+
+package graph
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// FindObjectsByType returns the IDs of objects whose type matches the
+// regular expression pattern, in ascending ID order. The pattern is
+// unanchored, so a plain substring works too.
+func FindObjectsByType(g Graph, pattern string) ([]ObjID, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid type pattern %q: %w", pattern, err)
+	}
+
+	// Many objects share a type, so match each distinct name only once
+	matches := make(map[string]bool)
+	ids := findObjects(g, func(typeName string) bool {
+		match, ok := matches[typeName]
+		if !ok {
+			match = re.MatchString(typeName)
+			matches[typeName] = match
+		}
+		return match
+	})
+	return ids, nil
+}
+
+// FindObjectsByTypeExact returns the IDs of objects whose type is exactly
+// typeName, in ascending ID order
+func FindObjectsByTypeExact(g Graph, typeName string) []ObjID {
+	return findObjects(g, func(t string) bool {
+		return t == typeName
+	})
+}
+
+// findObjects collects and sorts the IDs of objects whose type passes match
+func findObjects(g Graph, match func(typeName string) bool) []ObjID {
+	return Filter(g, func(obj *Object) bool { return match(obj.Type) })
+}
@@ -0,0 +1,47 @@
+// ABOUTME: Tests for finding objects by type name
+// ABOUTME: Covers regexp, substring, exact, and invalid pattern cases
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindObjectsByType(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 3, Type: "*http.Request"})
+	g.AddObject(&Object{ID: 1, Type: "map[string]*http.Request"})
+	g.AddObject(&Object{ID: 2, Type: "[]byte"})
+	g.AddObject(&Object{ID: 4, Type: "*http.Request"})
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    []ObjID
+	}{
+		{name: "substring", pattern: "http.Request", want: []ObjID{1, 3, 4}},
+		{name: "anchored", pattern: `^\*http\.`, want: []ObjID{3, 4}},
+		{name: "no match", pattern: "sync.Mutex", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindObjectsByType(g, tt.pattern)
+			if err != nil {
+				t.Fatalf("FindObjectsByType() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindObjectsByType(%q) = %v, want %v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := FindObjectsByType(g, "[unclosed"); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+
+	if got, want := FindObjectsByTypeExact(g, "*http.Request"), []ObjID{3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindObjectsByTypeExact() = %v, want %v", got, want)
+	}
+}
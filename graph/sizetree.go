@@ -0,0 +1,93 @@
+// ABOUTME: Aggregates object sizes into a package path hierarchy
+// ABOUTME: Data model for treemaps and package-level memory breakdowns
+
+package graph
+
+import (
+	"sort"
+	"strings"
+)
+
+// SizeNode is one level of a SizeTree: a package path element, a package,
+// or a type
+type SizeNode struct {
+	Name     string      // Path element, package name, or type name
+	Self     uint64      // Bytes of objects whose type ends at this node
+	Total    uint64      // Self plus every descendant's Total
+	Count    int         // Objects in this node's subtree
+	Children []*SizeNode // Largest Total first, ties by name
+}
+
+// SizeTree groups object sizes by type name components, so that
+// "github.com/me/app/cache.Entry" is counted under github.com, me, app,
+// cache, and finally Entry. Pointer, slice, array, and map wrappers are
+// stripped first (see NormalizeTypeName), and names without a package,
+// such as "string" or "int", are children of the root. The root has an
+// empty name and covers the whole heap.
+//
+// Sizes are shallow object sizes rather than retained sizes, since those
+// overlap between types and would not add up level by level.
+func SizeTree(g Graph) *SizeNode {
+	root := &SizeNode{}
+	index := make(map[*SizeNode]map[string]*SizeNode)
+	g.ForEachObject(func(obj *Object) {
+		node := root
+		node.Total += obj.Size
+		node.Count++
+		for _, name := range typePath(obj.Type) {
+			children := index[node]
+			if children == nil {
+				children = make(map[string]*SizeNode)
+				index[node] = children
+			}
+			child, ok := children[name]
+			if !ok {
+				child = &SizeNode{Name: name}
+				children[name] = child
+				node.Children = append(node.Children, child)
+			}
+			node = child
+			node.Total += obj.Size
+			node.Count++
+		}
+		node.Self += obj.Size
+	})
+
+	for node := range index {
+		sort.Slice(node.Children, func(i, j int) bool {
+			a, b := node.Children[i], node.Children[j]
+			if a.Total != b.Total {
+				return a.Total > b.Total
+			}
+			return a.Name < b.Name
+		})
+	}
+	return root
+}
+
+// typePath splits a type name into its SizeTree path: the package path's
+// elements, the package name, then the type name with any type arguments.
+// Names that are not package-qualified are a single element.
+func typePath(name string) []string {
+	name = NormalizeTypeName(name)
+	if name == "" {
+		return []string{"unknown"}
+	}
+
+	// Slashes and dots inside type arguments don't split the path
+	base, args := name, ""
+	if i := strings.IndexByte(name, '['); i > 0 {
+		base, args = name[:i], name[i:]
+	}
+	if strings.ContainsAny(base, " {") {
+		return []string{name} // struct, interface, func, or chan literal
+	}
+
+	elems := strings.Split(base, "/")
+	last := elems[len(elems)-1]
+	pkg, typ, ok := strings.Cut(last, ".")
+	if !ok {
+		return []string{name}
+	}
+	return append(elems[:len(elems)-1], pkg, typ+args)
+}
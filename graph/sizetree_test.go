@@ -0,0 +1,51 @@
+// ABOUTME: Tests for the package hierarchy size aggregation
+// ABOUTME: Checks type name splitting and how sizes roll up the tree
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTypePath(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"github.com/me/app/cache.Entry", []string{"github.com", "me", "app", "cache", "Entry"}},
+		{"*main.Node", []string{"main", "Node"}},
+		{"[]net/http.Header", []string{"net", "http", "Header"}},
+		{"sync.Map[string,example.com/x.T]", []string{"sync", "Map[string,example.com/x.T]"}},
+		{"string", []string{"string"}},
+		{"struct { a.B }", []string{"struct { a.B }"}},
+		{"", []string{"unknown"}},
+	}
+	for _, tt := range tests {
+		if got := typePath(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("typePath(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSizeTree(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "example.com/app/cache.Entry", Size: 100})
+	g.AddObject(&Object{ID: 2, Type: "*example.com/app/cache.Entry", Size: 100})
+	g.AddObject(&Object{ID: 3, Type: "example.com/app/cache.Index", Size: 50})
+	g.AddObject(&Object{ID: 4, Type: "example.com/app.Server", Size: 300})
+	g.AddObject(&Object{ID: 5, Type: "string", Size: 16})
+
+	entry := &SizeNode{Name: "Entry", Self: 200, Total: 200, Count: 2}
+	index := &SizeNode{Name: "Index", Self: 50, Total: 50, Count: 1}
+	cache := &SizeNode{Name: "cache", Total: 250, Count: 3, Children: []*SizeNode{entry, index}}
+	server := &SizeNode{Name: "Server", Self: 300, Total: 300, Count: 1}
+	app := &SizeNode{Name: "app", Total: 550, Count: 4, Children: []*SizeNode{server, cache}}
+	example := &SizeNode{Name: "example.com", Total: 550, Count: 4, Children: []*SizeNode{app}}
+	str := &SizeNode{Name: "string", Self: 16, Total: 16, Count: 1}
+	want := &SizeNode{Total: 566, Count: 5, Children: []*SizeNode{example, str}}
+
+	if got := SizeTree(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("SizeTree() = %+v, want %+v", got, want)
+	}
+}
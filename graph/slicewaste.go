@@ -0,0 +1,76 @@
+// ABOUTME: Finds slices whose capacity far exceeds their length
+// ABOUTME: Estimates the bytes an oversized backing array keeps alive
+
+package graph
+
+import "sort"
+
+// MinSliceWasteRatio is how many times its length a slice's capacity must be
+// for FindSliceWaste to report it
+const MinSliceWasteRatio = 4
+
+// SliceWaste is a slice keeping a mostly unused backing array alive, the
+// usual result of appending to a slice that later shrank
+type SliceWaste struct {
+	ID      ObjID  // Object holding the slice header
+	Backing ObjID  // Backing array the header points to
+	Len     uint64 // Elements in use
+	Cap     uint64 // Elements the backing array holds
+	Wasted  uint64 // Backing array bytes beyond what Len elements need
+}
+
+// FindSliceWaste decodes the header of every slice-typed object with a
+// kept payload (see Object.Data) and reports those whose capacity is at
+// least MinSliceWasteRatio times their length, most wasted bytes first.
+// Headers are read as three words (pointer, len, cap) of pointerSize bytes,
+// 4 or 8 as the dump's params say, in the given byte order. Element size
+// is inferred from the backing array's size and capacity, and the bytes
+// Len elements need are rounded up by roundSize, such as to the runtime's
+// size classes, so Wasted is what a right-sized copy would actually free.
+// A nil roundSize leaves them as they are.
+func FindSliceWaste(g Graph, pointerSize int, bigEndian bool, roundSize func(uint64) uint64) []SliceWaste {
+	if pointerSize != 4 && pointerSize != 8 {
+		return nil
+	}
+
+	var result []SliceWaste
+	ForEachObjectWhere(g, func(obj *Object) bool {
+		return Classify(obj) == KindSlice && len(obj.Ptrs) > 0 && len(obj.Data) >= 3*pointerSize
+	}, func(obj *Object) {
+		length, _ := obj.ReadWord(pointerSize, pointerSize, bigEndian)
+		capacity, _ := obj.ReadWord(2*pointerSize, pointerSize, bigEndian)
+		if capacity == 0 || length > capacity || capacity/MinSliceWasteRatio < length {
+			return
+		}
+		backing := g.GetObject(obj.Ptrs[0])
+		if backing == nil {
+			return
+		}
+		elemSize := backing.Size / capacity
+		if elemSize == 0 {
+			return
+		}
+
+		needed := length * elemSize
+		if roundSize != nil {
+			needed = roundSize(needed)
+		}
+		if allocated := backing.AllocatedSize(); allocated > needed {
+			result = append(result, SliceWaste{
+				ID:      obj.ID,
+				Backing: backing.ID,
+				Len:     length,
+				Cap:     capacity,
+				Wasted:  allocated - needed,
+			})
+		}
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Wasted != result[j].Wasted {
+			return result[i].Wasted > result[j].Wasted
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
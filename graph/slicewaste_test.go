@@ -0,0 +1,76 @@
+// ABOUTME: Tests for oversized slice detection
+// ABOUTME: Builds slice headers by hand and checks the waste estimates
+
+package graph
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// roundTo16 stands in for size class rounding
+func roundTo16(n uint64) uint64 {
+	return (n + 15) &^ 15
+}
+
+// sliceHeader returns a little-endian slice header payload
+func sliceHeader(ptr, length, capacity uint64) []byte {
+	data := make([]byte, 24)
+	binary.LittleEndian.PutUint64(data, ptr)
+	binary.LittleEndian.PutUint64(data[8:], length)
+	binary.LittleEndian.PutUint64(data[16:], capacity)
+	return data
+}
+
+func TestFindSliceWaste(t *testing.T) {
+	g := NewMemGraph()
+	// 2 of 1024 8-byte elements in use: 16 bytes needed of 8192
+	g.AddObject(&Object{ID: 1, Type: "[]int", Size: 24, Ptrs: []ObjID{2}, Data: sliceHeader(0x2000, 2, 1024)})
+	g.AddObject(&Object{ID: 2, Type: "int", Size: 8192})
+	// Empty, with 100 16-byte elements of capacity in a 1792-byte class
+	g.AddObject(&Object{ID: 3, Type: "[]string", Size: 24, Ptrs: []ObjID{4}, Data: sliceHeader(0x3000, 0, 100)})
+	g.AddObject(&Object{ID: 4, Type: "string", Size: 1600, AllocSize: 1792})
+	// Half full is not reported
+	g.AddObject(&Object{ID: 5, Type: "[]int", Size: 24, Ptrs: []ObjID{6}, Data: sliceHeader(0x4000, 50, 100)})
+	g.AddObject(&Object{ID: 6, Type: "int", Size: 800})
+	// No payload kept, and a corrupt header with len > cap
+	g.AddObject(&Object{ID: 7, Type: "[]int", Size: 24, Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 8, Type: "[]int", Size: 24, Ptrs: []ObjID{2}, Data: sliceHeader(0x2000, 9, 1)})
+
+	got := FindSliceWaste(g, 8, false, roundTo16)
+	want := []SliceWaste{
+		{ID: 1, Backing: 2, Len: 2, Cap: 1024, Wasted: 8192 - 16},
+		{ID: 3, Backing: 4, Len: 0, Cap: 100, Wasted: 1792},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindSliceWaste() = %+v, want %+v", got, want)
+	}
+	if got := FindSliceWaste(g, 0, false, roundTo16); got != nil {
+		t.Errorf("FindSliceWaste(pointer size 0) = %+v, want nil", got)
+	}
+}
+
+func TestFindSliceWaste32Bit(t *testing.T) {
+	// A 12-byte header: 3 of 1024 4-byte elements in use
+	data := make([]byte, 12)
+	binary.BigEndian.PutUint32(data, 0x2000)
+	binary.BigEndian.PutUint32(data[4:], 3)
+	binary.BigEndian.PutUint32(data[8:], 1024)
+
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "[]int32", Size: 12, Ptrs: []ObjID{2}, Data: data})
+	g.AddObject(&Object{ID: 2, Type: "int32", Size: 4096})
+
+	got := FindSliceWaste(g, 4, true, roundTo16)
+	want := []SliceWaste{{ID: 1, Backing: 2, Len: 3, Cap: 1024, Wasted: 4096 - 16}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindSliceWaste() = %+v, want %+v", got, want)
+	}
+
+	// Without rounding, the 12 bytes in use are all that is needed
+	want[0].Wasted = 4096 - 12
+	if got := FindSliceWaste(g, 4, true, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindSliceWaste(no rounding) = %+v, want %+v", got, want)
+	}
+}
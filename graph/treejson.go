@@ -0,0 +1,77 @@
+// ABOUTME: Exports the dominator tree as nested JSON with retained sizes
+// ABOUTME: Machine-readable counterpart to the flamegraph export for custom UIs
+
+package graph
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// WriteDominatorTreeJSON writes the dominator tree of g as one nested JSON
+// object rooted at the super-root (ID 0, empty type, size 0). Each node is
+// {"id", "type", "size", "retained", "children"}, with children in
+// ascending ID order. The super-root's retained size is that of the whole
+// reachable heap. The tree is written iteratively, so deep dominator chains
+// cannot exhaust the stack.
+func WriteDominatorTreeJSON(w io.Writer, g Graph) error {
+	di := ComputeDominators(g)
+	tree, retained := di.Tree, di.RetainedSize()
+	for _, id := range tree[0] {
+		retained[0] += retained[id]
+	}
+
+	bw := bufio.NewWriter(w)
+
+	// Each frame is a node still to open, or a node to close once its
+	// children are written
+	type frame struct {
+		id    ObjID
+		first bool // first child of its parent, so no leading comma
+		close bool
+	}
+	stack := []frame{{id: 0, first: true}}
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if f.close {
+			bw.WriteString("]}")
+			continue
+		}
+
+		var obj Object
+		if o := g.GetObject(f.id); o != nil && f.id != 0 {
+			obj = *o
+		}
+		typ, err := json.Marshal(obj.Type)
+		if err != nil {
+			return err
+		}
+
+		if !f.first {
+			bw.WriteByte(',')
+		}
+		bw.WriteString(`{"id":`)
+		bw.WriteString(strconv.FormatUint(uint64(f.id), 10))
+		bw.WriteString(`,"type":`)
+		bw.Write(typ)
+		bw.WriteString(`,"size":`)
+		bw.WriteString(strconv.FormatUint(obj.Size, 10))
+		bw.WriteString(`,"retained":`)
+		bw.WriteString(strconv.FormatUint(retained[f.id], 10))
+		bw.WriteString(`,"children":[`)
+
+		// Push in reverse so children are written in ascending ID order
+		stack = append(stack, frame{id: f.id, close: true})
+		children := tree[f.id]
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, frame{id: children[i], first: i == 0})
+		}
+	}
+	bw.WriteByte('\n')
+
+	return bw.Flush()
+}
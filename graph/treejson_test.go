@@ -0,0 +1,84 @@
+// ABOUTME: Tests for the nested JSON export of the dominator tree
+// ABOUTME: Decodes the output and checks structure, retained sizes, and depth
+
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type treeNode struct {
+	ID       ObjID      `json:"id"`
+	Type     string     `json:"type"`
+	Size     uint64     `json:"size"`
+	Retained uint64     `json:"retained"`
+	Children []treeNode `json:"children"`
+}
+
+func TestWriteDominatorTreeJSON(t *testing.T) {
+	// 1 -> 2 -> 4, 1 -> 3 -> 4: 4 is dominated by 1, not by 2 or 3
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Size: 100, Ptrs: []ObjID{2, 3}})
+	g.AddObject(&Object{ID: 2, Type: "left", Size: 30, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 3, Type: `map["quoted"]int`, Size: 40, Ptrs: []ObjID{4}})
+	g.AddObject(&Object{ID: 4, Type: "merge", Size: 20})
+	g.AddObject(&Object{ID: 5, Type: "other", Size: 5})
+	g.AddObject(&Object{ID: 6, Type: "garbage", Size: 999})
+	g.SetRoots(Roots{IDs: []ObjID{5, 1}})
+
+	var buf bytes.Buffer
+	if err := WriteDominatorTreeJSON(&buf, g); err != nil {
+		t.Fatalf("WriteDominatorTreeJSON() error = %v", err)
+	}
+
+	var got treeNode
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+
+	leaf := func(id ObjID, typ string, size uint64) treeNode {
+		return treeNode{ID: id, Type: typ, Size: size, Retained: size, Children: []treeNode{}}
+	}
+	want := treeNode{Retained: 195, Children: []treeNode{
+		{ID: 1, Type: "root", Size: 100, Retained: 190, Children: []treeNode{
+			leaf(2, "left", 30),
+			leaf(3, `map["quoted"]int`, 40),
+			leaf(4, "merge", 20),
+		}},
+		leaf(5, "other", 5),
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WriteDominatorTreeJSON() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteDominatorTreeJSONDeepChain(t *testing.T) {
+	const n = 100000
+	g := NewMemGraph()
+	for i := 1; i <= n; i++ {
+		obj := &Object{ID: ObjID(i), Type: "node", Size: 1}
+		if i < n {
+			obj.Ptrs = []ObjID{ObjID(i + 1)}
+		}
+		g.AddObject(obj)
+	}
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	var buf bytes.Buffer
+	if err := WriteDominatorTreeJSON(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+
+	// Too deep for encoding/json to decode, so check the nesting balances
+	out := buf.String()
+	if opens, closes := strings.Count(out, "{"), strings.Count(out, "}"); opens != n+1 || closes != n+1 {
+		t.Errorf("got %d opening and %d closing braces, want %d each", opens, closes, n+1)
+	}
+	if !strings.HasPrefix(out, `{"id":0,"type":"","size":0,"retained":100000,`) {
+		t.Errorf("unexpected super-root: %.80s", out)
+	}
+}
@@ -0,0 +1,128 @@
+// ABOUTME: Time-series leak detection across ordered heap snapshots
+// ABOUTME: Scores each type by how consistently its total size grows
+
+package graph
+
+import "sort"
+
+// MinTrendSnapshots is the minimum number of snapshots needed to score trends.
+// Two snapshots are a plain diff; three or more make growth a stronger signal.
+const MinTrendSnapshots = 3
+
+// TrendStat describes how a type's total size evolved across snapshots
+type TrendStat struct {
+	Type      string   // Type name
+	Sizes     []uint64 // Total bytes of this type in each snapshot, in order
+	Growth    int64    // Size in the last snapshot minus size in the first
+	Score     float64  // Fraction of consecutive snapshot pairs where the type grew (0..1)
+	Monotonic bool     // True if the type grew between every consecutive pair
+}
+
+// TrendingTypes scores every type by how consistently its total size grows
+// across an ordered series of snapshots (oldest first). A type that grows
+// in every step is Monotonic and scores 1.0; noisy types that go up and down
+// score lower even if they end up larger. Only types with positive overall
+// growth are returned, ordered by score and then by growth.
+// Returns nil if fewer than MinTrendSnapshots snapshots are given.
+func TrendingTypes(snapshots []Graph, topN int) []TrendStat {
+	if len(snapshots) < MinTrendSnapshots || topN <= 0 {
+		return nil
+	}
+
+	series := typeSeries(snapshots)
+
+	var result []TrendStat
+	for typeName, sizes := range series {
+		if stat := newTrendStat(typeName, sizes); stat.Growth > 0 {
+			result = append(result, stat)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		if result[i].Growth != result[j].Growth {
+			return result[i].Growth > result[j].Growth
+		}
+		return result[i].Type < result[j].Type
+	})
+
+	if len(result) > topN {
+		result = result[:topN]
+	}
+	return result
+}
+
+// GrowthTrend reports how every type's total size moved across an ordered
+// series of snapshots (oldest first), including types that shrank or only
+// appear in some snapshots. Monotonic entries grew strictly at every step:
+// those are the leak suspects, and they come first, largest growth first.
+// The rest follow by growth, so steady-state churn sinks to the bottom.
+// Returns nil if fewer than MinTrendSnapshots snapshots are given.
+func GrowthTrend(snapshots []Graph) []TrendStat {
+	if len(snapshots) < MinTrendSnapshots {
+		return nil
+	}
+
+	var result []TrendStat
+	for typeName, sizes := range typeSeries(snapshots) {
+		result = append(result, newTrendStat(typeName, sizes))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Monotonic != result[j].Monotonic {
+			return result[i].Monotonic
+		}
+		if result[i].Growth != result[j].Growth {
+			return result[i].Growth > result[j].Growth
+		}
+		return result[i].Type < result[j].Type
+	})
+	return result
+}
+
+// newTrendStat scores one type's series of per-snapshot sizes
+func newTrendStat(typeName string, sizes []uint64) TrendStat {
+	increases := 0
+	for i := 1; i < len(sizes); i++ {
+		if sizes[i] > sizes[i-1] {
+			increases++
+		}
+	}
+	steps := len(sizes) - 1
+
+	return TrendStat{
+		Type:      typeName,
+		Sizes:     sizes,
+		Growth:    int64(sizes[len(sizes)-1]) - int64(sizes[0]),
+		Score:     float64(increases) / float64(steps),
+		Monotonic: increases == steps,
+	}
+}
+
+// typeSeries returns, for every type seen in any snapshot, its total size in
+// each snapshot. Types missing from a snapshot count as zero bytes there.
+func typeSeries(snapshots []Graph) map[string][]uint64 {
+	series := make(map[string][]uint64)
+	for i, g := range snapshots {
+		for typeName, size := range typeTotals(g) {
+			sizes, ok := series[typeName]
+			if !ok {
+				sizes = make([]uint64, len(snapshots))
+				series[typeName] = sizes
+			}
+			sizes[i] = size
+		}
+	}
+	return series
+}
+
+// typeTotals sums object sizes per type
+func typeTotals(g Graph) map[string]uint64 {
+	totals := make(map[string]uint64)
+	g.ForEachObject(func(obj *Object) {
+		totals[obj.Type] += obj.Size
+	})
+	return totals
+}
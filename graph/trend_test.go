@@ -0,0 +1,117 @@
+// ABOUTME: Tests for time-series trend detection across heap snapshots
+// ABOUTME: Verifies steady growers are flagged and noisy types are not
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+// snapshotWithSizes builds a graph with one object per entry, keyed by type
+func snapshotWithSizes(sizes map[string]uint64) Graph {
+	g := NewMemGraph()
+	id := ObjID(1)
+	for typeName, size := range sizes {
+		g.AddObject(&Object{ID: id, Type: typeName, Size: size})
+		id++
+	}
+	return g
+}
+
+func TestTrendingTypes(t *testing.T) {
+	snapshots := []Graph{
+		snapshotWithSizes(map[string]uint64{"*Session": 100, "[]byte": 500, "string": 50}),
+		snapshotWithSizes(map[string]uint64{"*Session": 200, "[]byte": 900, "string": 50}),
+		snapshotWithSizes(map[string]uint64{"*Session": 300, "[]byte": 400, "string": 50}),
+		snapshotWithSizes(map[string]uint64{"*Session": 400, "[]byte": 800, "string": 40}),
+	}
+
+	trends := TrendingTypes(snapshots, 10)
+	if len(trends) != 2 {
+		t.Fatalf("expected 2 growing types, got %d: %+v", len(trends), trends)
+	}
+
+	leak := trends[0]
+	if leak.Type != "*Session" {
+		t.Fatalf("expected *Session to rank first, got %s", leak.Type)
+	}
+	if !leak.Monotonic {
+		t.Error("expected *Session to be flagged as monotonic")
+	}
+	if leak.Score != 1.0 {
+		t.Errorf("expected *Session score 1.0, got %f", leak.Score)
+	}
+	if leak.Growth != 300 {
+		t.Errorf("expected *Session growth 300, got %d", leak.Growth)
+	}
+	if want := []uint64{100, 200, 300, 400}; !reflect.DeepEqual(leak.Sizes, want) {
+		t.Errorf("expected sizes %v, got %v", want, leak.Sizes)
+	}
+
+	noisy := trends[1]
+	if noisy.Type != "[]byte" {
+		t.Fatalf("expected []byte to rank second, got %s", noisy.Type)
+	}
+	if noisy.Monotonic {
+		t.Error("noisy type should not be flagged as monotonic")
+	}
+	if noisy.Score >= leak.Score {
+		t.Errorf("noisy score %f should be below steady grower %f", noisy.Score, leak.Score)
+	}
+}
+
+func TestTrendingTypesEdgeCases(t *testing.T) {
+	g := snapshotWithSizes(map[string]uint64{"T": 10})
+
+	if trends := TrendingTypes([]Graph{g, g}, 10); trends != nil {
+		t.Errorf("expected nil for two snapshots, got %v", trends)
+	}
+
+	// A type that appears only in later snapshots grows from zero
+	snapshots := []Graph{
+		snapshotWithSizes(map[string]uint64{"T": 10}),
+		snapshotWithSizes(map[string]uint64{"T": 10, "New": 5}),
+		snapshotWithSizes(map[string]uint64{"T": 10, "New": 15}),
+	}
+	trends := TrendingTypes(snapshots, 1)
+	if len(trends) != 1 || trends[0].Type != "New" {
+		t.Fatalf("expected only New, got %+v", trends)
+	}
+	if !trends[0].Monotonic || trends[0].Sizes[0] != 0 {
+		t.Errorf("expected New to grow monotonically from 0, got %+v", trends[0])
+	}
+}
+
+func TestGrowthTrend(t *testing.T) {
+	snapshots := []Graph{
+		snapshotWithSizes(map[string]uint64{"*Session": 100, "[]byte": 500, "string": 50}),
+		snapshotWithSizes(map[string]uint64{"*Session": 200, "[]byte": 900, "*Conn": 10}),
+		snapshotWithSizes(map[string]uint64{"*Session": 300, "[]byte": 400, "*Conn": 20, "string": 50}),
+	}
+
+	var got []string
+	for _, entry := range GrowthTrend(snapshots) {
+		got = append(got, entry.Type)
+	}
+	// *Conn is new in the second snapshot and still grows strictly from zero;
+	// string disappears and returns, so it is not a suspect
+	want := []string{"*Session", "*Conn", "string", "[]byte"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GrowthTrend() types = %v, want %v", got, want)
+	}
+
+	trend := GrowthTrend(snapshots)
+	if !trend[0].Monotonic || trend[0].Growth != 200 {
+		t.Errorf("*Session = %+v, want monotonic growth of 200", trend[0])
+	}
+	if !reflect.DeepEqual(trend[1].Sizes, []uint64{0, 10, 20}) {
+		t.Errorf("*Conn sizes = %v, want [0 10 20]", trend[1].Sizes)
+	}
+	if trend[3].Monotonic || trend[3].Growth != -100 {
+		t.Errorf("[]byte = %+v, want non-monotonic shrink of 100", trend[3])
+	}
+	if GrowthTrend(snapshots[:2]) != nil {
+		t.Error("GrowthTrend() with two snapshots should be nil")
+	}
+}
@@ -3,6 +3,15 @@
 
 package graph
 
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
 // ObjID is a unique identifier for a heap object
 type ObjID uint64
 
@@ -12,9 +21,141 @@ type Object struct {
 	Type string  // Type name (e.g. "string", "*MyStruct")
 	Size uint64  // Size in bytes
 	Ptrs []ObjID // IDs of objects this object points to
+
+	HasFinalizer bool // A finalizer is registered or queued for this object
+
+	// Addr is the object's address in the dumped process. Zero if unknown.
+	Addr uint64
+
+	// AllocSize is the size the allocator reserved for the object, after
+	// rounding up to its size class. Zero if unknown.
+	AllocSize uint64
+
+	// TypeSize is the declared size of the object's type. It differs from
+	// Size for variable-length objects such as slice backing arrays. Zero
+	// if the type is unknown.
+	TypeSize uint64
+
+	// ContentHash is a hash of the object's payload bytes, so identical
+	// objects can be found without keeping payloads. Zero if unknown.
+	ContentHash uint64
+
+	// Data is the object's raw payload. It is only kept when the parser
+	// is asked to, since payloads usually dominate a dump's size.
+	Data []byte
+}
+
+// AllocatedSize returns AllocSize if known, otherwise Size
+func (o *Object) AllocatedSize() uint64 {
+	if o.AllocSize != 0 {
+		return o.AllocSize
+	}
+	return o.Size
+}
+
+// Bytes returns the n payload bytes at offset, or false if the payload was
+// not kept or is too short. The result aliases Data.
+func (o *Object) Bytes(offset, n int) ([]byte, bool) {
+	if offset < 0 || n < 0 || offset > len(o.Data) || n > len(o.Data)-offset {
+		return nil, false
+	}
+	return o.Data[offset : offset+n], true
+}
+
+// ReadUint64 decodes the 8-byte word at offset in the payload, such as an
+// int field or a slice's length, in the dump's byte order
+func (o *Object) ReadUint64(offset int, bigEndian bool) (uint64, bool) {
+	b, ok := o.Bytes(offset, 8)
+	if !ok {
+		return 0, false
+	}
+	if bigEndian {
+		return binary.BigEndian.Uint64(b), true
+	}
+	return binary.LittleEndian.Uint64(b), true
+}
+
+// ReadWord decodes the size-byte word at offset in the payload, for
+// fields as wide as the dump's pointers. size must be 4 or 8.
+func (o *Object) ReadWord(offset, size int, bigEndian bool) (uint64, bool) {
+	if size == 8 {
+		return o.ReadUint64(offset, bigEndian)
+	}
+	b, ok := o.Bytes(offset, 4)
+	if !ok || size != 4 {
+		return 0, false
+	}
+	if bigEndian {
+		return uint64(binary.BigEndian.Uint32(b)), true
+	}
+	return uint64(binary.LittleEndian.Uint32(b)), true
+}
+
+// MaxStringPreview is the most payload bytes AsString decodes
+const MaxStringPreview = 256
+
+// AsString renders the payload of a string or []byte object as printable
+// text for display. Invalid UTF-8 and non-printable characters are escaped
+// Go-style, and payloads longer than MaxStringPreview are cut off and end in
+// "...". It returns false for other kinds of object or if the payload was
+// not kept.
+func (o *Object) AsString() (string, bool) {
+	if o.Data == nil || !isStringLike(o) {
+		return "", false
+	}
+
+	data, truncated := o.Data, false
+	if len(data) > MaxStringPreview {
+		data, truncated = data[:MaxStringPreview], true
+	}
+
+	var b strings.Builder
+	for len(data) > 0 {
+		r, n := utf8.DecodeRune(data)
+		switch {
+		case r == utf8.RuneError && n == 1:
+			// A multi-byte rune split by truncation is escaped like any
+			// other invalid byte
+			fmt.Fprintf(&b, `\x%02x`, data[0])
+		case r == '\\':
+			b.WriteString(`\\`)
+		case unicode.IsPrint(r):
+			b.WriteRune(r)
+		default:
+			q := strconv.QuoteRune(r)
+			b.WriteString(q[1 : len(q)-1])
+		}
+		data = data[n:]
+	}
+	if truncated {
+		b.WriteString("...")
+	}
+	return b.String(), true
+}
+
+// isStringLike reports whether obj holds string or byte slice contents
+func isStringLike(obj *Object) bool {
+	switch obj.Type {
+	case "[]byte", "[]uint8":
+		return true
+	}
+	return Classify(obj) == KindString
 }
 
 // Roots represents the set of GC root objects
 type Roots struct {
 	IDs []ObjID // Object IDs that are roots
+
+	// Kinds, if set, holds the kind of each root in IDs. Roots past its
+	// end are RootOther.
+	Kinds []RootKind
+
+	// Descs, if set, holds the dump's description of each root in IDs,
+	// such as "finalizer". Roots past its end have none.
+	Descs []string
+
+	// Goroutines, if set, holds the ID of the goroutine whose stack holds
+	// each root in IDs. It is 0 for roots not on a goroutine's stack and
+	// for roots past its end.
+	Goroutines []uint64
 }
\ No newline at end of file
@@ -0,0 +1,45 @@
+// ABOUTME: Compares each object's payload size with its type's declared size
+// ABOUTME: Surfaces oversized backing stores hiding behind small-looking types
+
+package graph
+
+import "sort"
+
+// SizeMismatch is an object whose Size differs from its TypeSize
+type SizeMismatch struct {
+	ID       ObjID
+	Type     string
+	Size     uint64
+	TypeSize uint64
+}
+
+// Excess returns how many bytes the object is larger than its type,
+// negative if it is smaller
+func (m SizeMismatch) Excess() int64 {
+	return int64(m.Size) - int64(m.TypeSize)
+}
+
+// TypeSizeMismatches returns every object of known type size whose Size
+// differs from it, largest excess first and then by ID. A payload several
+// times its type size is usually an array or slice backing store.
+func TypeSizeMismatches(g Graph) []SizeMismatch {
+	var result []SizeMismatch
+	ForEachObjectWhere(g, func(obj *Object) bool {
+		return obj.TypeSize != 0 && obj.Size != obj.TypeSize
+	}, func(obj *Object) {
+		result = append(result, SizeMismatch{
+			ID:       obj.ID,
+			Type:     obj.Type,
+			Size:     obj.Size,
+			TypeSize: obj.TypeSize,
+		})
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Excess() != result[j].Excess() {
+			return result[i].Excess() > result[j].Excess()
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
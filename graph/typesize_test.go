@@ -0,0 +1,31 @@
+// ABOUTME: Tests for the declared type size versus payload size report
+// ABOUTME: Checks filtering of unknown and matching sizes and the ordering
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTypeSizeMismatches(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "main.Header", Size: 32, TypeSize: 32})
+	g.AddObject(&Object{ID: 2, Type: "main.Entry", Size: 4096, TypeSize: 16})
+	g.AddObject(&Object{ID: 3, Type: "unknown", Size: 64})
+	g.AddObject(&Object{ID: 4, Type: "main.Small", Size: 8, TypeSize: 24})
+	g.AddObject(&Object{ID: 5, Type: "main.Entry", Size: 160, TypeSize: 16})
+
+	got := TypeSizeMismatches(g)
+	want := []SizeMismatch{
+		{ID: 2, Type: "main.Entry", Size: 4096, TypeSize: 16},
+		{ID: 5, Type: "main.Entry", Size: 160, TypeSize: 16},
+		{ID: 4, Type: "main.Small", Size: 8, TypeSize: 24},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TypeSizeMismatches() = %+v, want %+v", got, want)
+	}
+	if excess := got[2].Excess(); excess != -16 {
+		t.Errorf("Excess() = %d, want -16", excess)
+	}
+}
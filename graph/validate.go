@@ -0,0 +1,162 @@
+// ABOUTME: Checks a graph's structural invariants before analysis
+// ABOUTME: Reports dangling pointers and roots, zero sizes, duplicates, and self-loops; CheckInvariants is stricter
+
+package graph
+
+import (
+	"fmt"
+	"sort"
+)
+
+// IssueKind identifies which invariant an Issue breaks
+type IssueKind int
+
+const (
+	IssueDanglingPointer IssueKind = iota // Pointer to an object not in the graph
+	IssueDanglingRoot                     // Root naming an object not in the graph
+	IssueZeroSize                         // Object with size 0
+	IssueDuplicateID                      // Object ID visited more than once
+	IssueSelfLoop                         // Object pointing to itself
+	IssueReservedID                       // Object using ID 0, reserved for the super-root
+)
+
+var issueNames = [...]string{
+	IssueDanglingPointer: "dangling pointer",
+	IssueDanglingRoot:    "dangling root",
+	IssueZeroSize:        "zero size",
+	IssueDuplicateID:     "duplicate ID",
+	IssueSelfLoop:        "self-loop",
+	IssueReservedID:      "reserved ID",
+}
+
+func (k IssueKind) String() string {
+	if k < 0 || int(k) >= len(issueNames) {
+		return fmt.Sprintf("IssueKind(%d)", int(k))
+	}
+	return issueNames[k]
+}
+
+// Issue is one broken invariant
+type Issue struct {
+	Kind   IssueKind
+	ID     ObjID // Object the issue is about; the missing ID for dangling roots
+	Target ObjID // Missing pointer target, for dangling pointers
+}
+
+func (i Issue) String() string {
+	switch i.Kind {
+	case IssueDanglingPointer:
+		return fmt.Sprintf("object %d: %s to missing object %d", i.ID, i.Kind, i.Target)
+	case IssueDanglingRoot:
+		return fmt.Sprintf("root %d: object not in graph", i.ID)
+	default:
+		return fmt.Sprintf("object %d: %s", i.ID, i.Kind)
+	}
+}
+
+// Validate checks g's invariants and returns every issue found, ordered
+// by object ID and then kind. It never fails: callers decide which issues
+// matter. The algorithms tolerate all of these, but they usually point to
+// a parser bug or a broken fixture.
+func Validate(g Graph) []Issue {
+	var issues []Issue
+
+	seen := make(map[ObjID]bool, g.NumObjects())
+	g.ForEachObject(func(obj *Object) {
+		if seen[obj.ID] {
+			issues = append(issues, Issue{Kind: IssueDuplicateID, ID: obj.ID})
+		}
+		seen[obj.ID] = true
+	})
+
+	g.ForEachObject(func(obj *Object) {
+		if obj.ID == 0 {
+			issues = append(issues, Issue{Kind: IssueReservedID, ID: obj.ID})
+		}
+		if obj.Size == 0 {
+			issues = append(issues, Issue{Kind: IssueZeroSize, ID: obj.ID})
+		}
+		for _, ptr := range obj.Ptrs {
+			switch {
+			case ptr == obj.ID:
+				issues = append(issues, Issue{Kind: IssueSelfLoop, ID: obj.ID})
+			case !seen[ptr]:
+				issues = append(issues, Issue{Kind: IssueDanglingPointer, ID: obj.ID, Target: ptr})
+			}
+		}
+	})
+
+	for _, id := range g.GetRoots().IDs {
+		if !seen[id] {
+			issues = append(issues, Issue{Kind: IssueDanglingRoot, ID: id})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].ID != issues[j].ID {
+			return issues[i].ID < issues[j].ID
+		}
+		if issues[i].Kind != issues[j].Kind {
+			return issues[i].Kind < issues[j].Kind
+		}
+		return issues[i].Target < issues[j].Target
+	})
+	return issues
+}
+
+// CheckInvariants returns an error for the first invariant g breaks that a
+// parser or other graph builder must keep, or nil. Unlike Validate it
+// treats zero sizes and self-loops as fine. It checks that IDs are unique
+// and nonzero, every pointer and root names an object in g, the root
+// metadata is no longer than the root IDs, and the reachable and
+// unreachable objects partition g, with the breadth-first and dominator
+// analyses agreeing on which are which. Tests call it on every graph they
+// build, so a structurally broken one fails fast.
+func CheckInvariants(g Graph) error {
+	for _, issue := range Validate(g) {
+		switch issue.Kind {
+		case IssueDuplicateID, IssueReservedID, IssueDanglingPointer, IssueDanglingRoot:
+			return fmt.Errorf("%s", issue)
+		}
+	}
+
+	roots := g.GetRoots()
+	for _, meta := range []struct {
+		name string
+		n    int
+	}{
+		{"Kinds", len(roots.Kinds)},
+		{"Descs", len(roots.Descs)},
+		{"Goroutines", len(roots.Goroutines)},
+	} {
+		if meta.n > len(roots.IDs) {
+			return fmt.Errorf("roots have %d %s for %d IDs", meta.n, meta.name, len(roots.IDs))
+		}
+	}
+
+	reachable := RootDistance(g)
+	idom := ComputeDominators(g).Idom
+	var err error
+	var count, unreachable int
+	g.ForEachObject(func(obj *Object) {
+		count++
+		_, byDistance := reachable[obj.ID]
+		_, byDominators := idom[obj.ID]
+		if byDistance != byDominators && err == nil {
+			err = fmt.Errorf("object %d: reachable by distance is %t but by dominators is %t", obj.ID, byDistance, byDominators)
+		}
+		if !byDistance {
+			unreachable++
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if count != g.NumObjects() {
+		return fmt.Errorf("NumObjects() = %d but %d objects were visited", g.NumObjects(), count)
+	}
+	if len(reachable)+unreachable != count || len(idom) != len(reachable) {
+		return fmt.Errorf("%d reachable and %d unreachable objects don't partition %d objects", len(reachable), unreachable, count)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// ABOUTME: Tests for graph invariant validation
+// ABOUTME: Covers each issue kind, including duplicates from custom graphs
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+// sliceGraph is a Graph over a plain slice, which unlike MemGraph can
+// hold two objects with the same ID
+type sliceGraph struct {
+	objects []*Object
+	roots   Roots
+}
+
+func (g *sliceGraph) AddObject(obj *Object) { g.objects = append(g.objects, obj) }
+func (g *sliceGraph) NumObjects() int       { return len(g.objects) }
+func (g *sliceGraph) SetRoots(roots Roots)  { g.roots = roots }
+func (g *sliceGraph) GetRoots() Roots       { return g.roots }
+
+func (g *sliceGraph) GetObject(id ObjID) *Object {
+	for _, obj := range g.objects {
+		if obj.ID == id {
+			return obj
+		}
+	}
+	return nil
+}
+
+func (g *sliceGraph) ForEachObject(fn func(*Object)) {
+	for _, obj := range g.objects {
+		fn(obj)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	g := &sliceGraph{}
+	g.AddObject(&Object{ID: 1, Type: "root", Size: 10, Ptrs: []ObjID{2, 9}})
+	g.AddObject(&Object{ID: 2, Type: "node", Size: 10, Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 3, Type: "empty", Size: 0})
+	g.AddObject(&Object{ID: 3, Type: "again", Size: 5})
+	g.AddObject(&Object{ID: 0, Type: "reserved", Size: 5})
+	g.SetRoots(Roots{IDs: []ObjID{1, 7}})
+
+	want := []Issue{
+		{Kind: IssueReservedID, ID: 0},
+		{Kind: IssueDanglingPointer, ID: 1, Target: 9},
+		{Kind: IssueSelfLoop, ID: 2},
+		{Kind: IssueZeroSize, ID: 3},
+		{Kind: IssueDuplicateID, ID: 3},
+		{Kind: IssueDanglingRoot, ID: 7},
+	}
+	if got := Validate(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() = %v, want %v", got, want)
+	}
+}
+
+func TestValidateCleanGraph(t *testing.T) {
+	g := NewMemGraph()
+	g.AddObject(&Object{ID: 1, Type: "root", Size: 10, Ptrs: []ObjID{2}})
+	g.AddObject(&Object{ID: 2, Type: "leaf", Size: 10})
+	g.SetRoots(Roots{IDs: []ObjID{1}})
+
+	if issues := Validate(g); issues != nil {
+		t.Errorf("Validate() = %v, want no issues", issues)
+	}
+}
+
+func TestIssueString(t *testing.T) {
+	tests := []struct {
+		issue Issue
+		want  string
+	}{
+		{Issue{Kind: IssueDanglingPointer, ID: 1, Target: 9}, "object 1: dangling pointer to missing object 9"},
+		{Issue{Kind: IssueDanglingRoot, ID: 7}, "root 7: object not in graph"},
+		{Issue{Kind: IssueSelfLoop, ID: 2}, "object 2: self-loop"},
+	}
+	for _, tt := range tests {
+		if got := tt.issue.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestCheckInvariants(t *testing.T) {
+	g := benchmarkGraph(1000)
+	for name, tg := range map[string]Graph{"MemGraph": g, "CompactGraph": Compact(g)} {
+		if err := CheckInvariants(tg); err != nil {
+			t.Errorf("CheckInvariants(%s) error = %v", name, err)
+		}
+	}
+
+	// Zero sizes, self-loops, and unreachable objects are all allowed
+	build := func() *sliceGraph {
+		g := &sliceGraph{}
+		g.AddObject(&Object{ID: 1, Type: "root", Size: 10, Ptrs: []ObjID{2}})
+		g.AddObject(&Object{ID: 2, Type: "node", Size: 0, Ptrs: []ObjID{2}})
+		g.AddObject(&Object{ID: 3, Type: "garbage", Size: 5, Ptrs: []ObjID{1}})
+		g.SetRoots(Roots{IDs: []ObjID{1}, Kinds: []RootKind{RootStack}})
+		return g
+	}
+	if err := CheckInvariants(build()); err != nil {
+		t.Errorf("CheckInvariants(valid graph) error = %v", err)
+	}
+
+	breaks := map[string]func(g *sliceGraph){
+		"dangling pointer": func(g *sliceGraph) { g.objects[0].Ptrs = append(g.objects[0].Ptrs, 9) },
+		"dangling root":    func(g *sliceGraph) { g.roots.IDs = append(g.roots.IDs, 9) },
+		"duplicate ID":     func(g *sliceGraph) { g.AddObject(&Object{ID: 3, Type: "again", Size: 5}) },
+		"reserved ID":      func(g *sliceGraph) { g.AddObject(&Object{ID: 0, Type: "reserved", Size: 5}) },
+		"extra root kinds": func(g *sliceGraph) { g.roots.Kinds = append(g.roots.Kinds, RootOther) },
+		"extra root descs": func(g *sliceGraph) { g.roots.Descs = []string{"a", "b"} },
+	}
+	for name, brk := range breaks {
+		g := build()
+		brk(g)
+		if err := CheckInvariants(g); err == nil {
+			t.Errorf("CheckInvariants(%s) error = nil, want an error", name)
+		}
+	}
+
+	if err := CheckInvariants(miscountedGraph{build()}); err == nil {
+		t.Error("CheckInvariants(miscounted graph) error = nil, want an error")
+	}
+}
+
+// miscountedGraph reports one more object than it holds
+type miscountedGraph struct{ *sliceGraph }
+
+func (g miscountedGraph) NumObjects() int { return g.sliceGraph.NumObjects() + 1 }
@@ -0,0 +1,72 @@
+// ABOUTME: Opens every heap dump in a tar archive, such as a CI artifact
+// ABOUTME: Collects per-member parse errors instead of failing the archive
+
+package heapdump
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/prateek/heaplens/graph"
+)
+
+// OpenArchive parses each regular file in the .tar or .tar.gz archive at
+// path with Open and returns the graphs keyed by member name. Members are
+// decompressed like OpenFile does, and members no parser recognizes, such
+// as READMEs, are skipped. If some members fail to parse, the rest are
+// still returned, along with an error joining each failure prefixed by the
+// member name. Errors reading the archive itself are returned alone.
+func OpenArchive(path string) (map[string]graph.Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	gzipped := strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tgz")
+	r, err := MaybeDecompress(bufio.NewReader(f), gzipped)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+
+	graphs := make(map[string]graph.Graph)
+	var errs []error
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		g, err := openMember(tr, hdr.Name)
+		if errors.Is(err, ErrNoParser) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", hdr.Name, err))
+			continue
+		}
+		graphs[hdr.Name] = g
+	}
+	return graphs, errors.Join(errs...)
+}
+
+// openMember parses one archive member, decompressing it if needed
+func openMember(r io.Reader, name string) (graph.Graph, error) {
+	mr, err := MaybeDecompress(bufio.NewReader(r), strings.HasSuffix(name, ".gz"))
+	if err != nil {
+		return nil, err
+	}
+	return Open(mr)
+}
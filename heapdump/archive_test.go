@@ -0,0 +1,94 @@
+// ABOUTME: Tests for opening every dump in a tar archive
+// ABOUTME: Covers gzip archives, skipped members, and per-member errors
+
+package heapdump
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTar writes members to a tar archive at path, gzipped if compress
+func writeTar(t *testing.T, path string, compress bool, members map[string]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range members {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if compress {
+		writeGzip(t, path, buf.String())
+		return
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenArchive(t *testing.T) {
+	Reset()
+	Register(&JSONStub{})
+
+	var gzMember bytes.Buffer
+	zw := gzip.NewWriter(&gzMember)
+	zw.Write([]byte(fileTestJSON))
+	zw.Close()
+
+	members := map[string]string{
+		"pod-a/heap.json":    fileTestJSON,
+		"pod-b/heap.json.gz": gzMember.String(),
+		"README.txt":         "dumps from the nightly run",
+		"pod-c/heap.json":    `{"objects": [{"id": 1, "size": "big"}]}`,
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"dumps.tar", "dumps.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			writeTar(t, path, strings.HasSuffix(name, ".gz"), members)
+
+			graphs, err := OpenArchive(path)
+			if err == nil || !strings.Contains(err.Error(), "pod-c/heap.json") {
+				t.Errorf("Expected an error naming pod-c/heap.json, got %v", err)
+			}
+			if len(graphs) != 2 {
+				t.Fatalf("Expected 2 graphs, got %d: %v", len(graphs), graphs)
+			}
+			for _, member := range []string{"pod-a/heap.json", "pod-b/heap.json.gz"} {
+				if g := graphs[member]; g == nil || g.NumObjects() != 2 {
+					t.Errorf("graph for %s = %v, want 2 objects", member, g)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenArchiveErrors(t *testing.T) {
+	if _, err := OpenArchive(filepath.Join(t.TempDir(), "missing.tar")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected not-exist error, got %v", err)
+	}
+
+	notTar := filepath.Join(t.TempDir(), "dump.tar")
+	if err := os.WriteFile(notTar, []byte(strings.Repeat("x", 1024)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if graphs, err := OpenArchive(notTar); err == nil || graphs != nil {
+		t.Errorf("OpenArchive(not a tar) = %v, %v, want error", graphs, err)
+	}
+}
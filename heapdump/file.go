@@ -0,0 +1,59 @@
+// ABOUTME: Convenience helpers for opening heap dumps from the filesystem
+// ABOUTME: Handles file lifecycle and transparent gzip decompression
+
+package heapdump
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/prateek/heaplens/graph"
+)
+
+// gzipMagic is the two-byte header that starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// OpenFile opens the dump at path and parses it with Open.
+// Files ending in .gz or starting with the gzip magic bytes are
+// decompressed transparently. Errors are wrapped with the path.
+func OpenFile(path string) (graph.Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	r, err := MaybeDecompress(bufio.NewReader(f), strings.HasSuffix(path, ".gz"))
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+
+	g, err := Open(r)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	return g, nil
+}
+
+// MaybeDecompress wraps r in a gzip reader if forced, as for a path ending
+// in .gz, or if the stream starts with the gzip magic bytes. Otherwise it
+// returns r unchanged, with nothing consumed.
+func MaybeDecompress(r *bufio.Reader, force bool) (io.Reader, error) {
+	if !force {
+		magic, err := r.Peek(len(gzipMagic))
+		if err != nil || string(magic) != string(gzipMagic) {
+			// Too short to be gzip, or not gzip: let the parsers decide
+			return r, nil
+		}
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading gzip header: %w", err)
+	}
+	return zr, nil
+}
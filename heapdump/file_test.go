@@ -0,0 +1,88 @@
+// ABOUTME: Tests for opening heap dumps from files
+// ABOUTME: Validates plain and gzip-compressed dumps and error wrapping
+
+package heapdump
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const fileTestJSON = `{"objects": [{"id": 1, "type": "root", "size": 10, "ptrs": [2]}, {"id": 2, "type": "leaf", "size": 5}], "roots": [1]}`
+
+func writeGzip(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	if _, err := zw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	Reset()
+	Register(&JSONStub{})
+
+	dir := t.TempDir()
+
+	plain := filepath.Join(dir, "dump.json")
+	if err := os.WriteFile(plain, []byte(fileTestJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gzByExt := filepath.Join(dir, "dump.json.gz")
+	writeGzip(t, gzByExt, fileTestJSON)
+
+	gzByMagic := filepath.Join(dir, "dump.bin")
+	writeGzip(t, gzByMagic, fileTestJSON)
+
+	for _, path := range []string{plain, gzByExt, gzByMagic} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			g, err := OpenFile(path)
+			if err != nil {
+				t.Fatalf("OpenFile failed: %v", err)
+			}
+			if g.NumObjects() != 2 {
+				t.Errorf("Expected 2 objects, got %d", g.NumObjects())
+			}
+		})
+	}
+}
+
+func TestOpenFileErrors(t *testing.T) {
+	Reset()
+	Register(&JSONStub{})
+
+	dir := t.TempDir()
+
+	missing := filepath.Join(dir, "missing.json")
+	if _, err := OpenFile(missing); err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("Expected error mentioning %s, got %v", missing, err)
+	}
+
+	unknown := filepath.Join(dir, "unknown.dump")
+	if err := os.WriteFile(unknown, []byte("not a dump"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFile(unknown); err == nil || !strings.Contains(err.Error(), unknown) {
+		t.Errorf("Expected error mentioning %s, got %v", unknown, err)
+	}
+
+	badGzip := filepath.Join(dir, "bad.gz")
+	if err := os.WriteFile(badGzip, []byte("not gzip at all"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFile(badGzip); err == nil {
+		t.Error("Expected error for .gz file that is not gzip")
+	}
+}
@@ -0,0 +1,39 @@
+// ABOUTME: Approximate memory accounting for the buffered heap dump parser
+// ABOUTME: Lets servers stop parsing hostile or enormous dumps before an OOM
+
+package goheap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMemoryBudgetExceeded is returned when a parse would grow past
+// GoHeapParser.MaxMemory
+var ErrMemoryBudgetExceeded = errors.New("memory budget exceeded")
+
+// Approximate bytes kept per parsed record: the record's own struct plus
+// its share of the maps and slices indexing it. Only meant to be within a
+// small factor of the real heap growth.
+const (
+	objectCost    = 192 // graph.Object, its graph and address map entries, parser slices
+	pointerCost   = 16  // a raw address and its resolved ID
+	typeCost      = 96  // typeInfo and its map entry, plus the name
+	rootCost      = 8
+	goroutineCost = 160 // GoroutineFull, plus the wait reason
+	finalizerCost = 56
+	itabCost      = 24
+	deferCost     = 64
+	panicCost     = 56
+)
+
+// charge adds n bytes to the running memory estimate and fails once it
+// passes maxMemory. A zero maxMemory disables the budget.
+func (p *parser) charge(n uint64) error {
+	p.memEstimate += n
+	if p.maxMemory != 0 && p.memEstimate > p.maxMemory {
+		return fmt.Errorf("%w: estimated %d bytes after %d objects (limit %d)",
+			ErrMemoryBudgetExceeded, p.memEstimate, len(p.objects), p.maxMemory)
+	}
+	return nil
+}
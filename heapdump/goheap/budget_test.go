@@ -0,0 +1,34 @@
+// ABOUTME: Tests for the buffered parser's memory budget
+// ABOUTME: Checks the typed error and that partial results stay usable
+
+package goheap
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseMemoryBudget(t *testing.T) {
+	dump := chainDump(100)
+
+	if _, err := (&GoHeapParser{}).Parse(bytes.NewReader(dump)); err != nil {
+		t.Fatalf("Parse() without a budget error = %v", err)
+	}
+
+	// Each chain object holds one pointer
+	perObject := uint64(objectCost + pointerCost)
+	parser := &GoHeapParser{MaxMemory: 10 * perObject}
+
+	if _, err := parser.Parse(bytes.NewReader(dump)); !errors.Is(err, ErrMemoryBudgetExceeded) {
+		t.Fatalf("Parse() error = %v, want ErrMemoryBudgetExceeded", err)
+	}
+
+	g, err := parser.ParsePartial(bytes.NewReader(dump))
+	if !errors.Is(err, ErrMemoryBudgetExceeded) {
+		t.Fatalf("ParsePartial() error = %v, want ErrMemoryBudgetExceeded", err)
+	}
+	if g.NumObjects() != 11 {
+		t.Errorf("ParsePartial() kept %d objects, want the 11 read before the budget was crossed", g.NumObjects())
+	}
+}
@@ -0,0 +1,126 @@
+// ABOUTME: Checkpoints for resuming a streaming parse part way through a dump
+// ABOUTME: Tracks exact record offsets and saves the state needed to resume
+
+package goheap
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+
+	"github.com/prateek/heaplens/graph"
+)
+
+// DefaultCheckpointInterval is the number of records between checkpoints
+const DefaultCheckpointInterval = 100_000
+
+// Checkpoint marks a record boundary a parse can resume from. ByteOffset
+// is relative to the start of the dump.
+type Checkpoint struct {
+	ByteOffset  int64
+	RecordCount int64
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// offset returns the exact position of the next unread byte in the dump
+func (p *StreamingParser) offset() int64 {
+	return p.counter.n - int64(p.r.Buffered())
+}
+
+// maybeCheckpoint calls OnCheckpoint once every CheckpointInterval records
+func (p *StreamingParser) maybeCheckpoint() error {
+	if p.callbacks.OnCheckpoint == nil {
+		return nil
+	}
+
+	interval := p.CheckpointInterval
+	if interval <= 0 {
+		interval = DefaultCheckpointInterval
+	}
+	n := p.recordCount.Load()
+	if n == 0 || n%interval != 0 || n == p.lastCheckpoint {
+		return nil
+	}
+
+	p.lastCheckpoint = n
+	return p.callbacks.OnCheckpoint(Checkpoint{ByteOffset: p.offset(), RecordCount: n})
+}
+
+// parserState is the state SaveState writes and LoadState reads
+type parserState struct {
+	Params    DumpParams
+	AddrToID  map[uint64]graph.ObjID
+	LastID    graph.ObjID
+	TypeNames map[uint64]string
+
+	// Interface typing, so a resumed parse counts pointers the same way
+	PointerFree map[uint64]bool
+	Itabs       map[uint64]uint64
+}
+
+// SaveState writes the state needed to resume: the dump params, the type
+// and itab records read so far, and, in two-pass mode, the address to ID
+// map and the last ID delivered. Call it from OnCheckpoint.
+func (p *StreamingParser) SaveState(w io.Writer) error {
+	state := parserState{
+		Params:      p.params,
+		AddrToID:    p.addrToID,
+		LastID:      p.lastID,
+		TypeNames:   p.typeNames,
+		PointerFree: p.iface.pointerFree,
+		Itabs:       p.iface.itabs,
+	}
+	if err := gob.NewEncoder(w).Encode(state); err != nil {
+		return fmt.Errorf("saving parser state: %w", err)
+	}
+	return nil
+}
+
+// LoadState restores state written by SaveState before calling ResumeAt
+func (p *StreamingParser) LoadState(r io.Reader) error {
+	var state parserState
+	if err := gob.NewDecoder(r).Decode(&state); err != nil {
+		return fmt.Errorf("loading parser state: %w", err)
+	}
+	p.params = state.Params
+	p.addrToID = state.AddrToID
+	p.lastID = state.LastID
+	p.typeNames = state.TypeNames
+	p.iface = ifaceTypes{pointerFree: state.PointerFree, itabs: state.Itabs}
+	return nil
+}
+
+// ResumeAt seeks rs to the checkpoint and parses the rest of the dump.
+// rs must start at the beginning of the same dump, and LoadState must have
+// been called with the state saved at that checkpoint.
+func (p *StreamingParser) ResumeAt(rs io.ReadSeeker, cp Checkpoint) error {
+	if p.params.PointerSize == 0 {
+		return fmt.Errorf("resuming requires the dump params; call LoadState first")
+	}
+	if p.callbacks.OnResolvedObject != nil && p.addrToID == nil {
+		return fmt.Errorf("resuming with resolved pointers requires the saved address map; call LoadState first")
+	}
+
+	if _, err := rs.Seek(cp.ByteOffset, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking to checkpoint: %w", err)
+	}
+	p.rs = rs
+	p.counter = &countingReader{r: rs, n: cp.ByteOffset}
+	p.r.Reset(p.counter)
+	p.progress.Store(uint64(cp.ByteOffset))
+	p.recordCount.Store(cp.RecordCount)
+	p.lastCheckpoint = cp.RecordCount
+
+	return p.run()
+}
@@ -0,0 +1,105 @@
+// ABOUTME: Tests for streaming parser checkpoints and resume
+// ABOUTME: Resumes a two-pass parse mid-dump and compares with a full run
+
+package goheap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/prateek/heaplens/graph"
+)
+
+// chainDump builds a dump of n objects, each pointing to the next
+func chainDump(n int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x100000)
+	writeString(&buf, "amd64")
+	writeString(&buf, "go1.20.0")
+	writeVarint(&buf, 4)
+
+	for i := 0; i < n; i++ {
+		writeVarint(&buf, tagObject)
+		writeVarint(&buf, uint64(0x2000+i*0x100))
+		data := make([]byte, 16)
+		binary.LittleEndian.PutUint64(data[8:], uint64(0x2000+(i+1)*0x100))
+		writeBytes(&buf, data)
+		writeVarint(&buf, fieldKindPtr)
+		writeVarint(&buf, 8)
+		writeVarint(&buf, fieldKindEol)
+	}
+
+	writeVarint(&buf, tagEOF)
+	return buf.Bytes()
+}
+
+type resolvedObject struct {
+	ID   graph.ObjID
+	Ptrs []graph.ObjID
+}
+
+func TestStreamingResumeAtCheckpoint(t *testing.T) {
+	dump := chainDump(10)
+
+	var (
+		full      []resolvedObject
+		state     bytes.Buffer
+		cp        Checkpoint
+		atCP      int
+		parser    *StreamingParser
+		haveState bool
+	)
+	parser = NewSeekingStreamingParser(bytes.NewReader(dump), StreamCallbacks{
+		OnResolvedObject: func(id graph.ObjID, addr, typeAddr uint64, data []byte, ptrs []graph.ObjID) error {
+			full = append(full, resolvedObject{id, ptrs})
+			return nil
+		},
+		OnCheckpoint: func(c Checkpoint) error {
+			if haveState {
+				return nil
+			}
+			haveState, cp, atCP = true, c, len(full)
+			return parser.SaveState(&state)
+		},
+	})
+	parser.CheckpointInterval = 4
+	if err := parser.Parse(); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !haveState || cp.RecordCount != 4 {
+		t.Fatalf("first checkpoint = %+v, want one after 4 records", cp)
+	}
+
+	var resumed []resolvedObject
+	resume := NewStreamingParser(bytes.NewReader(nil), StreamCallbacks{
+		OnResolvedObject: func(id graph.ObjID, addr, typeAddr uint64, data []byte, ptrs []graph.ObjID) error {
+			resumed = append(resumed, resolvedObject{id, ptrs})
+			return nil
+		},
+	})
+	if err := resume.LoadState(&state); err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if err := resume.ResumeAt(bytes.NewReader(dump), cp); err != nil {
+		t.Fatalf("ResumeAt() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(resumed, full[atCP:]) {
+		t.Errorf("resumed objects = %v, want %v", resumed, full[atCP:])
+	}
+}
+
+func TestStreamingResumeRequiresState(t *testing.T) {
+	sp := NewStreamingParser(bytes.NewReader(nil), StreamCallbacks{})
+	if err := sp.ResumeAt(bytes.NewReader(chainDump(1)), Checkpoint{ByteOffset: 16}); err == nil {
+		t.Error("ResumeAt() without LoadState should fail")
+	}
+}
@@ -0,0 +1,215 @@
+// ABOUTME: Cross-checks the buffered and streaming parsers on the same dump
+// ABOUTME: Reports where their objects, types, pointers, or roots disagree
+
+package goheap
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/prateek/heaplens/graph"
+)
+
+// MaxDiscrepancies is how many differences a ComparisonReport lists
+const MaxDiscrepancies = 20
+
+// ComparisonReport describes where GoHeapParser and StreamingParser
+// disagree about a dump
+type ComparisonReport struct {
+	BufferedObjects  int
+	StreamingObjects int
+	BufferedRoots    int // Distinct root objects, stack roots left out
+	StreamingRoots   int
+
+	// Discrepancies holds the first MaxDiscrepancies differences: objects
+	// in record order, then roots in address order
+	Discrepancies []Discrepancy
+
+	// Total counts every difference, including those not listed
+	Total int
+}
+
+// Match reports whether the parsers agreed on everything compared
+func (r ComparisonReport) Match() bool {
+	return r.Total == 0
+}
+
+// Discrepancy is one difference between the parsers
+type Discrepancy struct {
+	ID   graph.ObjID // Object in record order, or 0 for a root
+	Addr uint64      // Address of the object or root
+
+	// What differs: "object", "address", "type", "size", "pointers", or
+	// "root". An "object" or "root" only one parser has is "present" in
+	// its column and "missing" in the other.
+	Field     string
+	Buffered  string
+	Streaming string
+}
+
+func (d Discrepancy) String() string {
+	if d.ID == 0 {
+		return fmt.Sprintf("root %#x: %s in buffered parser, %s in streaming parser", d.Addr, d.Buffered, d.Streaming)
+	}
+	return fmt.Sprintf("object %d at %#x: %s is %s in buffered parser, %s in streaming parser", d.ID, d.Addr, d.Field, d.Buffered, d.Streaming)
+}
+
+// streamedObject is what CompareParsers keeps of each streamed object
+type streamedObject struct {
+	addr     uint64
+	typeAddr uint64
+	size     uint64
+	ptrs     []uint64
+}
+
+// CompareParsers parses the same dump with GoHeapParser from r1 and with
+// StreamingParser from r2, and reports every object whose address, type,
+// size, or pointers differ, and every root only one of them finds. Objects
+// are matched by record order and pointers by target address. The
+// streaming parser reports no stack frames, so stack roots are left out;
+// finalizer roots are derived from its finalizer records the way Parse
+// derives them. An error means a parser failed, not that they disagree.
+func CompareParsers(r1, r2 io.Reader) (ComparisonReport, error) {
+	var report ComparisonReport
+
+	g, err := (&GoHeapParser{}).Parse(r1)
+	if err != nil {
+		return report, fmt.Errorf("buffered parser: %w", err)
+	}
+
+	var objects []streamedObject
+	var rootAddrs []uint64
+	var finalizers []*Finalizer
+	sp := NewStreamingParser(r2, StreamCallbacks{
+		OnObject: func(addr, typeAddr uint64, data []byte, ptrs []uint64) error {
+			objects = append(objects, streamedObject{addr: addr, typeAddr: typeAddr, size: uint64(len(data)), ptrs: ptrs})
+			return nil
+		},
+		OnRoot: func(desc string, ptr uint64) error {
+			rootAddrs = append(rootAddrs, ptr)
+			return nil
+		},
+		OnFinalizer: func(f *Finalizer) error {
+			finalizers = append(finalizers, f)
+			return nil
+		},
+	})
+	sp.SetErrorRecovery(0, false)
+	if err := sp.Parse(); err != nil {
+		return report, fmt.Errorf("streaming parser: %w", err)
+	}
+
+	report.BufferedObjects = g.NumObjects()
+	report.StreamingObjects = len(objects)
+	add := func(d Discrepancy) {
+		if report.Total < MaxDiscrepancies {
+			report.Discrepancies = append(report.Discrepancies, d)
+		}
+		report.Total++
+	}
+
+	// As in Parse, a repeated address names the last object recorded at it
+	byAddr := make(map[uint64]int, len(objects))
+	for i, obj := range objects {
+		byAddr[obj.addr] = i
+	}
+	resolve := func(ptrs []uint64) []uint64 {
+		var addrs []uint64
+		for _, ptr := range ptrs {
+			if _, ok := byAddr[ptr]; ok {
+				addrs = append(addrs, ptr)
+			}
+		}
+		slices.Sort(addrs)
+		return addrs
+	}
+
+	for i := 0; i < max(report.BufferedObjects, report.StreamingObjects); i++ {
+		id := graph.ObjID(i + 1)
+		obj := g.GetObject(id)
+		switch {
+		case obj == nil:
+			add(Discrepancy{ID: id, Addr: objects[i].addr, Field: "object", Buffered: "missing", Streaming: "present"})
+			continue
+		case i >= len(objects):
+			add(Discrepancy{ID: id, Addr: obj.Addr, Field: "object", Buffered: "present", Streaming: "missing"})
+			continue
+		}
+
+		s := objects[i]
+		if obj.Addr != s.addr {
+			add(Discrepancy{ID: id, Addr: obj.Addr, Field: "address", Buffered: fmt.Sprintf("%#x", obj.Addr), Streaming: fmt.Sprintf("%#x", s.addr)})
+			continue
+		}
+		if typ := sp.ResolveType(s.typeAddr); obj.Type != typ {
+			add(Discrepancy{ID: id, Addr: obj.Addr, Field: "type", Buffered: obj.Type, Streaming: typ})
+		}
+		if obj.Size != s.size {
+			add(Discrepancy{ID: id, Addr: obj.Addr, Field: "size", Buffered: fmt.Sprint(obj.Size), Streaming: fmt.Sprint(s.size)})
+		}
+		var ptrs []uint64
+		for _, ptr := range obj.Ptrs {
+			ptrs = append(ptrs, g.GetObject(ptr).Addr)
+		}
+		slices.Sort(ptrs)
+		if sptrs := resolve(s.ptrs); !slices.Equal(ptrs, sptrs) {
+			add(Discrepancy{ID: id, Addr: obj.Addr, Field: "pointers", Buffered: fmt.Sprintf("%#x", ptrs), Streaming: fmt.Sprintf("%#x", sptrs)})
+		}
+	}
+
+	buffered := make(map[uint64]bool)
+	roots := g.GetRoots()
+	for i, id := range roots.IDs {
+		if roots.Goroutine(i) == 0 {
+			buffered[g.GetObject(id).Addr] = true
+		}
+	}
+
+	streamed := make(map[uint64]bool)
+	for _, addr := range rootAddrs {
+		if _, ok := byAddr[addr]; ok {
+			streamed[addr] = true
+		}
+	}
+	for _, f := range finalizers {
+		i, ok := byAddr[f.Object]
+		if !ok {
+			continue
+		}
+		if _, ok := byAddr[f.Function]; ok {
+			streamed[f.Function] = true
+		}
+		if f.Queued {
+			streamed[f.Object] = true
+			continue
+		}
+		for _, ptr := range resolve(objects[i].ptrs) {
+			streamed[ptr] = true
+		}
+	}
+
+	report.BufferedRoots = len(buffered)
+	report.StreamingRoots = len(streamed)
+	var addrs []uint64
+	for addr := range buffered {
+		if !streamed[addr] {
+			addrs = append(addrs, addr)
+		}
+	}
+	for addr := range streamed {
+		if !buffered[addr] {
+			addrs = append(addrs, addr)
+		}
+	}
+	slices.Sort(addrs)
+	for _, addr := range addrs {
+		d := Discrepancy{Addr: addr, Field: "root", Buffered: "missing", Streaming: "present"}
+		if buffered[addr] {
+			d.Buffered, d.Streaming = "present", "missing"
+		}
+		add(d)
+	}
+
+	return report, nil
+}
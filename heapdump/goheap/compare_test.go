@@ -0,0 +1,110 @@
+// ABOUTME: Tests for cross-checking the buffered and streaming parsers
+// ABOUTME: Covers agreeing parsers, listed discrepancies, the listing cap, and parse failures
+
+package goheap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestCompareParsersMatch(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		dump := generateRandomValidDump(t, i)
+		report, err := CompareParsers(bytes.NewReader(dump), bytes.NewReader(dump))
+		if err != nil {
+			t.Fatalf("seed %d: CompareParsers() error = %v", i, err)
+		}
+		if !report.Match() || report.BufferedObjects != report.StreamingObjects || report.BufferedRoots != report.StreamingRoots {
+			t.Errorf("seed %d: CompareParsers() = %+v, want a match", i, report)
+		}
+	}
+}
+
+// typeAfterObjectsDump writes n objects whose type record comes after
+// them, which only the streaming parser can name, and one root
+func typeAfterObjectsDump(n int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x100000)
+	writeString(&buf, "amd64")
+	writeString(&buf, "go1.20.0")
+	writeVarint(&buf, 4)
+
+	for i := 0; i < n; i++ {
+		data := make([]byte, 16)
+		binary.LittleEndian.PutUint64(data, 0x100)
+		writeVarint(&buf, tagObject)
+		writeVarint(&buf, uint64(0x2000+i*0x100))
+		writeBytes(&buf, data)
+		writeVarint(&buf, fieldKindEol)
+	}
+
+	writeVarint(&buf, tagType)
+	writeVarint(&buf, 0x100)
+	writeVarint(&buf, 16)
+	writeString(&buf, "main.T")
+	writeVarint(&buf, 0)
+
+	writeVarint(&buf, tagOtherRoot)
+	writeString(&buf, "data")
+	writeVarint(&buf, 0x2000)
+	writeVarint(&buf, tagEOF)
+	return buf.Bytes()
+}
+
+func TestCompareParsersDiscrepancies(t *testing.T) {
+	dump := typeAfterObjectsDump(2)
+	report, err := CompareParsers(bytes.NewReader(dump), bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("CompareParsers() error = %v", err)
+	}
+
+	want := ComparisonReport{
+		BufferedObjects:  2,
+		StreamingObjects: 2,
+		BufferedRoots:    1,
+		StreamingRoots:   1,
+		Discrepancies: []Discrepancy{
+			{ID: 1, Addr: 0x2000, Field: "type", Buffered: "unknown", Streaming: "main.T"},
+			{ID: 2, Addr: 0x2100, Field: "type", Buffered: "unknown", Streaming: "main.T"},
+		},
+		Total: 2,
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("CompareParsers() = %+v, want %+v", report, want)
+	}
+	if got, want := report.Discrepancies[0].String(), "object 1 at 0x2000: type is unknown in buffered parser, main.T in streaming parser"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	root := Discrepancy{Addr: 0x2000, Field: "root", Buffered: "present", Streaming: "missing"}
+	if got, want := root.String(), "root 0x2000: present in buffered parser, missing in streaming parser"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	// Only the first MaxDiscrepancies are listed, but all are counted
+	dump = typeAfterObjectsDump(MaxDiscrepancies + 5)
+	report, err = CompareParsers(bytes.NewReader(dump), bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("CompareParsers() error = %v", err)
+	}
+	if len(report.Discrepancies) != MaxDiscrepancies || report.Total != MaxDiscrepancies+5 {
+		t.Errorf("CompareParsers() listed %d of %d discrepancies, want %d of %d", len(report.Discrepancies), report.Total, MaxDiscrepancies, MaxDiscrepancies+5)
+	}
+}
+
+func TestCompareParsersError(t *testing.T) {
+	dump := generateRandomValidDump(t, 1)
+	if _, err := CompareParsers(bytes.NewReader(dump[:20]), bytes.NewReader(dump)); err == nil {
+		t.Error("CompareParsers(truncated buffered input) error = nil, want an error")
+	}
+	if _, err := CompareParsers(bytes.NewReader(dump), bytes.NewReader([]byte("not a heap dump"))); err == nil {
+		t.Error("CompareParsers(invalid streaming input) error = nil, want an error")
+	}
+}
@@ -0,0 +1,74 @@
+// ABOUTME: Go version compatibility checks for heap dump record layouts
+// ABOUTME: Rejects unknown formats and warns about versions outside the tested range
+
+package goheap
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Tested Go versions. The parsers follow the record layouts in the
+// runtime's heapdump.go, and TestParseRuntimeDump checks them against a
+// dump written by the toolchain running the tests, go1.27. Other go1
+// versions are parsed too, with a warning that they are untested.
+const (
+	minTestedMinor = 27
+	maxTestedMinor = 27
+)
+
+var goVersionRe = regexp.MustCompile(`go(\d+)\.(\d+)`)
+
+// Compatibility reports whether a dump's record layout matches the parsers
+type Compatibility struct {
+	GoVersion string   // Version string from the params record
+	Tested    bool     // Version is in the tested range
+	Issues    []string // Known layout mismatches; non-empty means the dump would misparse
+}
+
+// Supported reports whether the dump can be parsed without known mismatches
+func (c Compatibility) Supported() bool {
+	return len(c.Issues) == 0
+}
+
+// Err returns a descriptive error naming the version if the dump is not supported
+func (c Compatibility) Err() error {
+	if c.Supported() {
+		return nil
+	}
+	return fmt.Errorf("heap dump from %s is not supported: %s", c.GoVersion, strings.Join(c.Issues, "; "))
+}
+
+// Warning describes why a supported dump's version is untested, or returns
+// "" if it is tested
+func (c Compatibility) Warning() string {
+	if c.Tested || !c.Supported() {
+		return ""
+	}
+	return fmt.Sprintf("heap dump from %q is outside the tested go1.%d-go1.%d range", c.GoVersion, minTestedMinor, maxTestedMinor)
+}
+
+// Compatibility checks the dump's Go version against the layouts the parsers
+// read. Only a version known to write a different layout is unsupported; a
+// version string the check can't read, as from some development builds, is
+// parsed as untested.
+func (p DumpParams) Compatibility() Compatibility {
+	c := Compatibility{GoVersion: p.GoVersion}
+
+	m := goVersionRe.FindStringSubmatch(p.GoVersion)
+	if m == nil {
+		return c
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+
+	switch {
+	case major != 1:
+		c.Issues = []string{fmt.Sprintf("unknown major version go%d", major)}
+	default:
+		c.Tested = minor >= minTestedMinor && minor <= maxTestedMinor
+	}
+	return c
+}
@@ -0,0 +1,137 @@
+// ABOUTME: Tests for Go version compatibility detection
+// ABOUTME: Checks version classification, rejection of unknown formats, and a real runtime dump
+
+package goheap
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestCompatibility(t *testing.T) {
+	tests := []struct {
+		version   string
+		tested    bool
+		supported bool
+	}{
+		{"go1.27.0", true, true},
+		{"go1.27.1", true, true},
+		{"go1.21.5", false, true},
+		{"go1.28", false, true},
+		{"devel go1.30-abcdef", false, true},
+		{"devel +abcdef", false, true},
+		{"go2.0", false, false},
+		{"", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			c := DumpParams{GoVersion: tt.version}.Compatibility()
+			if c.Tested != tt.tested {
+				t.Errorf("Tested = %v, want %v", c.Tested, tt.tested)
+			}
+			if c.Supported() != tt.supported {
+				t.Errorf("Supported() = %v, want %v (issues %v)", c.Supported(), tt.supported, c.Issues)
+			}
+			if err := c.Err(); (err != nil) == tt.supported {
+				t.Errorf("Err() = %v, want error %v", err, !tt.supported)
+			}
+			if warning := c.Warning(); (warning != "") != (tt.supported && !tt.tested) {
+				t.Errorf("Warning() = %q, want one only for supported untested versions", warning)
+			}
+		})
+	}
+}
+
+// paramsOnlyDump builds a dump holding only a params record for version
+func paramsOnlyDump(version string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x2000)
+	writeString(&buf, "amd64")
+	writeString(&buf, version)
+	writeVarint(&buf, 4)
+	writeVarint(&buf, tagEOF)
+	return buf.Bytes()
+}
+
+func TestParseRejectsIncompatibleVersion(t *testing.T) {
+	dump := paramsOnlyDump("go2.0")
+
+	_, err := (&GoHeapParser{}).Parse(bytes.NewReader(dump))
+	if err == nil || !strings.Contains(err.Error(), "go2.0") {
+		t.Errorf("Parse() error = %v, want error naming go2.0", err)
+	}
+
+	sp := NewStreamingParser(bytes.NewReader(dump), StreamCallbacks{})
+	if err := sp.Parse(); err == nil || !strings.Contains(err.Error(), "go2.0") {
+		t.Errorf("StreamingParser.Parse() error = %v, want error naming go2.0", err)
+	}
+
+	// An untested go1 version is parsed
+	dump = paramsOnlyDump("go1.24.2")
+	if _, err := (&GoHeapParser{}).Parse(bytes.NewReader(dump)); err != nil {
+		t.Errorf("Parse(go1.24.2) error = %v", err)
+	}
+	if err := NewStreamingParser(bytes.NewReader(dump), StreamCallbacks{}).Parse(); err != nil {
+		t.Errorf("StreamingParser.Parse(go1.24.2) error = %v", err)
+	}
+}
+
+// TestParseRuntimeDump parses a dump written by the running Go runtime, so
+// the record layouts are checked against the real format and not only
+// against this package's synthetic dumps
+func TestParseRuntimeDump(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "heapdump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime.GC() // so NumGC is set
+	debug.WriteHeapDump(f.Fd())
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dump, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := (&GoHeapParser{}).ParseFull(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("ParseFull() error = %v", err)
+	}
+	if result.Params.GoVersion != runtime.Version() {
+		t.Errorf("GoVersion = %q, want %q", result.Params.GoVersion, runtime.Version())
+	}
+	if result.Graph.NumObjects() == 0 {
+		t.Error("NumObjects() = 0")
+	}
+	if len(result.Goroutines) == 0 {
+		t.Error("no goroutines parsed")
+	}
+	if ms := result.MemStats; ms == nil || ms.HeapObjects == 0 || ms.NumGC == 0 {
+		t.Errorf("MemStats = %+v, want HeapObjects and NumGC set", ms)
+	}
+
+	goroutines := 0
+	sp := NewStreamingParser(bytes.NewReader(dump), StreamCallbacks{
+		OnGoroutine: func(id, status uint64, waitReason string) error {
+			goroutines++
+			return nil
+		},
+	})
+	if err := sp.Parse(); err != nil {
+		t.Fatalf("StreamingParser.Parse() error = %v", err)
+	}
+	if goroutines != len(result.Goroutines) {
+		t.Errorf("streamed %d goroutines, want %d", goroutines, len(result.Goroutines))
+	}
+}
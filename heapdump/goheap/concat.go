@@ -0,0 +1,70 @@
+// ABOUTME: Parses several Go heap dumps written one after another to a stream
+// ABOUTME: Skips padding between a dump's EOF record and the next header
+
+package goheap
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/prateek/heaplens/graph"
+)
+
+// ParseAll reads every dump in r, as from a file a tool appends periodic
+// dumps to, and returns their graphs in order. Parse stops at the first
+// dump's EOF record; ParseAll carries on, skipping any padding up to the
+// next dump's header, until the input ends. The input must start with a
+// dump. If a dump fails to parse, the graphs before it are returned with
+// the error.
+func (p *GoHeapParser) ParseAll(r io.Reader) ([]graph.Graph, error) {
+	// Each parser is handed the same reader, which newParser keeps rather
+	// than wrapping, so none reads ahead into the next dump
+	br := bufio.NewReaderSize(r, parserBufferSize)
+
+	var graphs []graph.Graph
+	for {
+		if len(graphs) > 0 {
+			found, err := skipToHeader(br)
+			if err != nil {
+				return graphs, fmt.Errorf("looking for heap dump %d: %w", len(graphs)+1, err)
+			}
+			if !found {
+				return graphs, nil
+			}
+		}
+
+		parser := p.newParser(br)
+		if err := parser.parse(); err != nil {
+			return graphs, fmt.Errorf("parsing heap dump %d: %w", len(graphs)+1, err)
+		}
+		graphs = append(graphs, parser.g)
+	}
+}
+
+// skipToHeader discards bytes from br up to the next dump header. It
+// reports false if the input ends first.
+func skipToHeader(br *bufio.Reader) (bool, error) {
+	for {
+		b, err := br.Peek(len(dumpHeader))
+		if string(b) == dumpHeader {
+			return true, nil
+		}
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		// Skip to the next byte that could start a header
+		n := len(b)
+		if i := bytes.IndexByte(b[1:], dumpHeader[0]); i >= 0 {
+			n = i + 1
+		}
+		if _, err := br.Discard(n); err != nil {
+			return false, err
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// ABOUTME: Tests for parsing Go heap dumps concatenated in one stream
+// ABOUTME: Covers padding between and after dumps, a single dump, and failures
+
+package goheap
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseAll(t *testing.T) {
+	first, second := generateDumpWithNObjects(t, 3), generateDumpWithNObjects(t, 5)
+	padding := make([]byte, 100)
+
+	tests := []struct {
+		name  string
+		input [][]byte
+		want  []int // object counts
+	}{
+		{"single dump", [][]byte{first}, []int{3}},
+		{"back to back", [][]byte{first, second}, []int{3, 5}},
+		{"padded", [][]byte{first, padding, second, padding}, []int{3, 5}},
+		{"junk between", [][]byte{first, []byte("gogo go1.7 heap"), second, []byte("go1.7")}, []int{3, 5}},
+	}
+	for _, tt := range tests {
+		graphs, err := (&GoHeapParser{}).ParseAll(bytes.NewReader(bytes.Join(tt.input, nil)))
+		if err != nil {
+			t.Fatalf("%s: ParseAll() error = %v", tt.name, err)
+		}
+		if len(graphs) != len(tt.want) {
+			t.Fatalf("%s: ParseAll() returned %d graphs, want %d", tt.name, len(graphs), len(tt.want))
+		}
+		for i, g := range graphs {
+			if g.NumObjects() != tt.want[i] {
+				t.Errorf("%s: graph %d has %d objects, want %d", tt.name, i, g.NumObjects(), tt.want[i])
+			}
+		}
+	}
+}
+
+func TestParseAllErrors(t *testing.T) {
+	if _, err := (&GoHeapParser{}).ParseAll(bytes.NewReader(nil)); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("ParseAll(empty) error = %v, want ErrInvalidHeader", err)
+	}
+
+	// The first dump must start the input
+	dump := generateDumpWithNObjects(t, 3)
+	if _, err := (&GoHeapParser{}).ParseAll(bytes.NewReader(append([]byte{0}, dump...))); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("ParseAll(leading padding) error = %v, want ErrInvalidHeader", err)
+	}
+
+	// A broken second dump keeps the first
+	input := append(append([]byte(nil), dump...), dump[:len(dump)/2]...)
+	graphs, err := (&GoHeapParser{}).ParseAll(bytes.NewReader(input))
+	if !errors.Is(err, ErrTruncated) || len(graphs) != 1 {
+		t.Errorf("ParseAll(truncated second dump) = %d graphs, %v, want 1 graph and ErrTruncated", len(graphs), err)
+	}
+}
@@ -0,0 +1,65 @@
+// ABOUTME: Error values the Go heap dump parsers wrap their failures in
+// ABOUTME: Lets callers tell a foreign format from corrupt or truncated data
+
+package goheap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrInvalidHeader means the input is not a Go heap dump at all
+	ErrInvalidHeader = errors.New("invalid header")
+
+	// ErrTruncated means the dump ended partway through a record, as when
+	// the writing process was killed. It wraps io.ErrUnexpectedEOF.
+	ErrTruncated = fmt.Errorf("truncated heap dump: %w", io.ErrUnexpectedEOF)
+
+	// ErrStringTooLong and ErrBytesTooLong mean a length prefix exceeded
+	// the parser's MaxStringLen or MaxBytesLen, usually from corruption
+	ErrStringTooLong = errors.New("string too long")
+	ErrBytesTooLong  = errors.New("byte slice too long")
+
+	// ErrPointerOutOfRange means an object's pointer field lies past the
+	// end of its data. Valid dumps never have one; the field is dropped
+	// and parsing continues.
+	ErrPointerOutOfRange = errors.New("pointer field out of range")
+)
+
+// pointerOutOfRange describes a pointer field at offset that doesn't fit
+// in an object of size bytes at addr
+func pointerOutOfRange(addr, offset uint64, size int) error {
+	return fmt.Errorf("%w: object %#x has a field at offset %d but only %d bytes", ErrPointerOutOfRange, addr, offset, size)
+}
+
+// ErrUnknownTag is returned for a record tag the parser does not know
+type ErrUnknownTag struct {
+	Tag uint64
+}
+
+func (e ErrUnknownTag) Error() string {
+	return fmt.Sprintf("unknown tag: %d", e.Tag)
+}
+
+// truncatedError marks an error caused by input ending mid-record. Its
+// message is the underlying error's; it only adds ErrTruncated to the chain.
+type truncatedError struct {
+	err error
+}
+
+func (e *truncatedError) Error() string   { return e.err.Error() }
+func (e *truncatedError) Unwrap() []error { return []error{ErrTruncated, e.err} }
+
+// markTruncated adds ErrTruncated to err if it came from running out of
+// input. A clean end of input between records never reaches here.
+func markTruncated(err error) error {
+	if err == nil || errors.Is(err, ErrTruncated) {
+		return err
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &truncatedError{err}
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+// ABOUTME: Tests for the parser error values
+// ABOUTME: Checks errors.Is and errors.As against both parsers' failures
+
+package goheap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestParseErrorValues(t *testing.T) {
+	header := []byte("go1.7 heap dump\n")
+	withRecord := func(record ...uint64) []byte {
+		var buf bytes.Buffer
+		buf.Write(header)
+		for _, v := range record {
+			writeVarint(&buf, v)
+		}
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"invalid header", []byte("not a heap dump!"), ErrInvalidHeader},
+		{"cut off mid-record", withRecord(tagObject, 0x2000, 64, 1, 2, 3), ErrTruncated},
+		{"cut off mid-varint", append(withRecord(tagObject), 0x80), ErrTruncated},
+		{"string too long", withRecord(tagOtherRoot, 1<<40), ErrStringTooLong},
+		{"byte slice too long", withRecord(tagObject, 0x2000, 1<<40), ErrBytesTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&GoHeapParser{}).Parse(bytes.NewReader(tt.data))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Parse() error = %v, want %v", err, tt.want)
+			}
+
+			sp := NewStreamingParser(bytes.NewReader(tt.data), StreamCallbacks{})
+			sp.SetErrorRecovery(0, false)
+			if err := sp.Parse(); !errors.Is(err, tt.want) {
+				t.Errorf("StreamingParser.Parse() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrTruncatedIsUnexpectedEOF(t *testing.T) {
+	_, err := (&GoHeapParser{}).Parse(bytes.NewReader(chainDump(3)[:60]))
+	if !errors.Is(err, ErrTruncated) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Parse() error = %v, want ErrTruncated wrapping io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestErrUnknownTag(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+	writeVarint(&buf, 99)
+
+	_, err := (&GoHeapParser{}).Parse(bytes.NewReader(buf.Bytes()))
+	var unknown ErrUnknownTag
+	if !errors.As(err, &unknown) || unknown.Tag != 99 {
+		t.Errorf("Parse() error = %v, want ErrUnknownTag{99}", err)
+	}
+}
+
+func TestPointerOutOfRangeIsReported(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x100000)
+	writeString(&buf, "amd64")
+	writeString(&buf, "go1.20.0")
+	writeVarint(&buf, 4)
+
+	// A 16-byte object with one valid pointer field and one far past its end
+	writeVarint(&buf, tagObject)
+	writeVarint(&buf, 0x2000)
+	data := make([]byte, 16)
+	binary.LittleEndian.PutUint64(data[8:], 0x2000)
+	writeBytes(&buf, data)
+	writeVarint(&buf, fieldKindPtr)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, fieldKindPtr)
+	writeVarint(&buf, 1<<20)
+	writeVarint(&buf, fieldKindEol)
+	writeVarint(&buf, tagEOF)
+	dump := buf.Bytes()
+
+	result, err := (&GoHeapParser{}).ParseFull(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("ParseFull() error = %v", err)
+	}
+	if result.BadPointers != 1 {
+		t.Errorf("BadPointers = %d, want 1", result.BadPointers)
+	}
+	if ptrs := result.Graph.GetObject(1).Ptrs; len(ptrs) != 1 {
+		t.Errorf("Ptrs = %v, want the one valid pointer", ptrs)
+	}
+
+	var reported []error
+	var objects int
+	sp := NewStreamingParser(bytes.NewReader(dump), StreamCallbacks{
+		OnObject: func(addr, typeAddr uint64, data []byte, ptrs []uint64) error {
+			objects++
+			return nil
+		},
+		OnError: func(err error, canRecover bool) error {
+			reported = append(reported, err)
+			return nil
+		},
+	})
+	if err := sp.Parse(); err != nil {
+		t.Fatalf("StreamingParser.Parse() error = %v", err)
+	}
+	if len(reported) != 1 || !errors.Is(reported[0], ErrPointerOutOfRange) {
+		t.Errorf("OnError got %v, want one ErrPointerOutOfRange", reported)
+	}
+	if objects != 1 {
+		t.Errorf("OnObject called %d times, want 1", objects)
+	}
+}
@@ -54,6 +54,12 @@ func FuzzParser(f *testing.F) {
 					t.Errorf("Negative object count: %d", numObjects)
 				}
 
+				// Every object record starts with a tagObject byte, so more
+				// objects than those bytes means records were misparsed
+				if tags := bytes.Count(data, []byte{tagObject}); numObjects > tags {
+					t.Errorf("Parsed %d objects from input with %d tagObject bytes", numObjects, tags)
+				}
+
 				// Ensure we can iterate without panic
 				g.ForEachObject(func(obj *graph.Object) {
 					if obj == nil {
@@ -66,6 +72,12 @@ func FuzzParser(f *testing.F) {
 				if roots.IDs == nil {
 					t.Error("Nil roots IDs")
 				}
+
+				// Pointers and roots must resolve, and reachability
+				// must be consistent
+				if err := graph.CheckInvariants(g); err != nil {
+					t.Errorf("Parsed graph is broken: %v", err)
+				}
 			}
 		}()
 	})
@@ -275,10 +287,13 @@ func createComplexDumpSeed() []byte {
 
 	// Goroutines
 	writeVarint(&buf, tagGoroutine)
-	for i := 0; i < 12; i++ {
+	for i := 0; i < 8; i++ {
 		writeVarint(&buf, uint64(i))
 	}
 	writeString(&buf, "waiting")
+	for i := 0; i < 4; i++ {
+		writeVarint(&buf, 0)
+	}
 
 	// Stack frames
 	writeVarint(&buf, tagStackFrame)
@@ -294,7 +309,7 @@ func createComplexDumpSeed() []byte {
 
 	// MemStats
 	writeVarint(&buf, tagMemStats)
-	for i := 0; i < 61; i++ {
+	for i := 0; i < 24+256+1; i++ {
 		writeVarint(&buf, uint64(i*1000))
 	}
 
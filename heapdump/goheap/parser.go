@@ -6,51 +6,214 @@ package goheap
 import (
 	"bufio"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"strings"
 	"sync"
 
 	"github.com/prateek/heaplens/graph"
 	"github.com/prateek/heaplens/heapdump"
+	"github.com/prateek/heaplens/heapdump/sizeclass"
 )
 
+// Default sanity limits on length-prefixed fields. They stop a corrupt
+// length from triggering a huge allocation.
+const (
+	DefaultMaxStringLen = 1 << 20 // 1MB
+	DefaultMaxBytesLen  = 1 << 30 // 1GB
+)
+
+// DefaultProgressInterval is the number of records between ProgressFunc calls
+const DefaultProgressInterval = 100_000
+
 // GoHeapParser implements the heapdump.Parser interface for Go heap dumps
-type GoHeapParser struct{}
+type GoHeapParser struct {
+	// MaxStringLen and MaxBytesLen cap string and byte slice lengths
+	// (names, object payloads). Zero means the package default.
+	MaxStringLen uint64
+	MaxBytesLen  uint64
+
+	// ProgressFunc, if set, is called with running totals every
+	// ProgressInterval records and once more when parsing finishes.
+	// ProgressInterval <= 0 means DefaultProgressInterval.
+	ProgressFunc     func(objects, types, roots int)
+	ProgressInterval int
+
+	// MaxMemory, if non-zero, caps the approximate memory the parse may
+	// hold. Once the running estimate passes it, parsing stops with
+	// ErrMemoryBudgetExceeded; ParsePartial still returns what was read.
+	// Single payloads are bounded separately by MaxBytesLen.
+	MaxMemory uint64
+
+	// RoundSizes sets each object's Size to its AllocSize, the bytes the
+	// allocator reserved after size class rounding, so total and retained
+	// sizes track HeapInuse instead of payload lengths
+	RoundSizes bool
+
+	// KeepPayloads keeps each object's raw bytes in Object.Data so fields
+	// can be decoded later. Payloads are usually most of a dump, so this
+	// roughly doubles memory use; MaxMemory counts them.
+	KeepPayloads bool
+
+	// HashPayloads sets each object's ContentHash, which FindDuplicates
+	// groups by. Hashing reads every payload byte a second time, so it is
+	// off unless asked for.
+	HashPayloads bool
+
+	// RecordMask, if non-zero, limits parsing to the kinds of record it
+	// holds; the rest are read past without being decoded. Leaving out
+	// goroutines, stack frames, and profiling records speeds up analyses
+	// that only need the object graph, such as type histograms. Leaving out
+	// RecordTypes leaves every object "unknown", and leaving out any of
+	// RecordGraph changes the graph.
+	RecordMask RecordMask
+}
 
 // Ensure GoHeapParser implements Parser interface
 var _ heapdump.Parser = (*GoHeapParser)(nil)
 
 // CanParse checks if the reader contains a Go heap dump
 func (p *GoHeapParser) CanParse(r io.Reader) bool {
-	// Read the header to check format
+	// A single Read may return fewer bytes than asked for, as network
+	// streams and decompressors often do
 	header := make([]byte, 16)
-	n, err := r.Read(header)
-	if err != nil || n < 16 {
+	if _, err := io.ReadFull(r, header); err != nil {
 		return false
 	}
-	return string(header) == "go1.7 heap dump\n"
+	return string(header) == dumpHeader
+}
+
+// dumpHeader starts every Go heap dump
+const dumpHeader = "go1.7 heap dump\n"
+
+// readHeader reads and verifies the dump header. Empty input is not a
+// dump at all, so it fails with ErrInvalidHeader rather than ErrTruncated.
+// A dump that ends right after its header is valid, if empty.
+func readHeader(r io.Reader) error {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF {
+			return fmt.Errorf("%w: empty input", ErrInvalidHeader)
+		}
+		return fmt.Errorf("reading header: %w", err)
+	}
+	if string(header) != dumpHeader {
+		return fmt.Errorf("%w: %q", ErrInvalidHeader, header)
+	}
+	return nil
+}
+
+// ParseResult is a parsed heap dump: the object graph plus the dump's
+// metadata records
+type ParseResult struct {
+	Graph      graph.Graph
+	Params     DumpParams
+	MemStats   *MemStatsFull // nil if the dump has no MemStats record
+	Goroutines []*GoroutineFull
+	Itabs      []*Itab
+	Defers     []*DeferRecord
+	Panics     []*PanicRecord
+
+	// BadPointers counts pointer fields past the end of their object's
+	// data (see ErrPointerOutOfRange). They are dropped, so a non-zero
+	// count means edges are missing from Graph.
+	BadPointers int
 }
 
 // Parse reads the heap dump and builds a graph
 func (p *GoHeapParser) Parse(r io.Reader) (graph.Graph, error) {
-	parser := &parser{
-		r:           bufio.NewReaderSize(r, 1024*1024), // 1MB buffer for performance
-		g:           graph.NewMemGraph(),
-		types:       make(map[uint64]*typeInfo),
-		addrToObjID: make(map[uint64]graph.ObjID),
-		roots:       make([]graph.ObjID, 0),
+	result, err := p.ParseFull(r)
+	if err != nil {
+		return nil, err
+	}
+	return result.Graph, nil
+}
+
+// ParsePartial is like Parse but keeps what was read before a failure,
+// such as a dump cut off mid-write when a process is killed. On error it
+// returns the graph of every complete object record before the failure,
+// with pointers and roots resolved among them, alongside the error.
+func (p *GoHeapParser) ParsePartial(r io.Reader) (graph.Graph, error) {
+	parser := p.newParser(r)
+	if err := parser.parse(); err != nil {
+		err = fmt.Errorf("parsing heap dump: %w", err)
+		if ferr := parser.finalize(); ferr != nil {
+			err = errors.Join(err, fmt.Errorf("resolving partial graph: %w", ferr))
+		}
+		return parser.g, err
 	}
+	return parser.g, nil
+}
 
+// ParseFull reads the heap dump and returns the graph with its metadata
+func (p *GoHeapParser) ParseFull(r io.Reader) (*ParseResult, error) {
+	parser := p.newParser(r)
 	if err := parser.parse(); err != nil {
 		return nil, fmt.Errorf("parsing heap dump: %w", err)
 	}
 
-	return parser.g, nil
+	return &ParseResult{
+		Graph: parser.g,
+		Params: DumpParams{
+			BigEndian:   parser.bigEndian,
+			PointerSize: parser.pointerSize,
+			HeapStart:   parser.heapStart,
+			HeapEnd:     parser.heapEnd,
+			Arch:        parser.arch,
+			GoVersion:   parser.goVersion,
+			NumCPUs:     parser.numCPUs,
+		},
+		MemStats:   parser.memStats,
+		Goroutines: parser.goroutines,
+		Itabs:      parser.itabs,
+		Defers:     parser.defers,
+		Panics:     parser.panics,
+
+		BadPointers: parser.badPointers,
+	}, nil
 }
 
-// Register registers the parser with the heapdump package
-func init() {
-	heapdump.Register(&GoHeapParser{})
+// parserBufferSize is the size of the buffered reader a parser wraps its
+// input in
+const parserBufferSize = 1024 * 1024
+
+// newParser returns parser state for reading r with p's settings
+func (p *GoHeapParser) newParser(r io.Reader) *parser {
+	return &parser{
+		r:           bufio.NewReaderSize(r, parserBufferSize),
+		g:           graph.NewMemGraph(),
+		types:       make(map[uint64]*typeInfo),
+		addrToObjID: make(map[uint64]graph.ObjID),
+		nextObjID:   1, // ID 0 is reserved for the dominator super-root
+
+		maxStringLen: p.MaxStringLen,
+		maxBytesLen:  p.MaxBytesLen,
+
+		progressFunc:     p.ProgressFunc,
+		progressInterval: p.ProgressInterval,
+
+		maxMemory:  p.MaxMemory,
+		roundSizes: p.RoundSizes,
+
+		keepPayloads: p.KeepPayloads,
+		hashPayloads: p.HashPayloads,
+		recordMask:   p.RecordMask,
+	}
+}
+
+var registerOnce sync.Once
+
+// RegisterParser adds a default GoHeapParser to the heapdump registry so
+// that heapdump.Open and OpenFile recognize Go heap dumps. Importing the
+// package no longer does this, leaving embedders that manage their own
+// registry in control; programs that want Go dumps detected call it once at
+// startup. Later calls do nothing.
+func RegisterParser() {
+	registerOnce.Do(func() {
+		heapdump.Register(&GoHeapParser{})
+	})
 }
 
 // Internal parser state
@@ -59,9 +222,65 @@ type parser struct {
 	g           graph.Graph
 	types       map[uint64]*typeInfo
 	addrToObjID map[uint64]graph.ObjID
-	roots       []graph.ObjID
 	nextObjID   graph.ObjID
 
+	// Length limits; zero means the package default
+	maxStringLen uint64
+	maxBytesLen  uint64
+
+	// Progress reporting; see GoHeapParser.ProgressFunc
+	progressFunc     func(objects, types, roots int)
+	progressInterval int
+	records          int
+
+	// Approximate memory held, checked against maxMemory; see charge
+	maxMemory   uint64
+	memEstimate uint64
+
+	// Replace payload sizes with allocation sizes in finalize
+	roundSizes bool
+
+	// Keep object payloads in Object.Data. When false, payloads are read
+	// into scratch, which is reused from object to object.
+	keepPayloads bool
+	scratch      []byte
+
+	// Set Object.ContentHash from each payload
+	hashPayloads bool
+
+	// Kinds of record to decode; the rest are skipped
+	recordMask RecordMask
+
+	// Objects and their raw pointer addresses, indexed by ID-1. Pointers
+	// can reference objects later in the dump, so they are resolved to
+	// IDs in finalize once every address is known. Roots likewise.
+	objects        []*graph.Object
+	rawPtrs        [][]uint64
+	rootAddrs      []uint64
+	rootKinds      []graph.RootKind // parallel to rootAddrs
+	rootDescs      []string         // parallel to rootAddrs
+	rootGoroutines []uint64         // parallel to rootAddrs
+
+	// What type and itab records say about interface fields' data words
+	iface ifaceTypes
+
+	// ID of the goroutine whose stack frames are being read. The runtime
+	// writes each goroutine's frames right after its record.
+	curGoroutine uint64
+
+	// Pointer fields dropped for lying outside their object
+	badPointers int
+
+	// Finalizer records; their objects are flagged in finalize
+	finalizers []*Finalizer
+
+	// Metadata records returned by ParseFull
+	memStats   *MemStatsFull
+	goroutines []*GoroutineFull
+	itabs      []*Itab
+	defers     []*DeferRecord
+	panics     []*PanicRecord
+
 	// Dump parameters
 	bigEndian   bool
 	pointerSize uint64
@@ -120,18 +339,16 @@ const (
 )
 
 // parse performs the main parsing
-func (p *parser) parse() error {
-	// Read and verify header
-	header := make([]byte, 16)
-	if _, err := io.ReadFull(p.r, header); err != nil {
-		return fmt.Errorf("reading header: %w", err)
-	}
-	if string(header) != "go1.7 heap dump\n" {
-		return fmt.Errorf("invalid header: %q", header)
+func (p *parser) parse() (err error) {
+	defer func() { err = markTruncated(err) }()
+
+	if err := readHeader(p.r); err != nil {
+		return err
 	}
 
 	// Read records
 	for {
+		p.maybeReportProgress()
 		tag, err := p.readVarint()
 		if err != nil {
 			if err == io.EOF {
@@ -140,6 +357,16 @@ func (p *parser) parse() error {
 			return fmt.Errorf("reading tag: %w", err)
 		}
 
+		if tag != tagEOF && tag != tagParams && !p.recordMask.wants(tag) {
+			if err := p.skipRecord(tag); err != nil {
+				return fmt.Errorf("skipping record %d: %w", tag, err)
+			}
+			if err := checkNextTag(p.r, tag); err != nil {
+				return err
+			}
+			continue
+		}
+
 		switch tag {
 		case tagEOF:
 			return p.finalize()
@@ -148,6 +375,9 @@ func (p *parser) parse() error {
 			if err := p.parseParams(); err != nil {
 				return fmt.Errorf("parsing params: %w", err)
 			}
+			if err := (DumpParams{GoVersion: p.goVersion}).Compatibility().Err(); err != nil {
+				return err
+			}
 
 		case tagType:
 			if err := p.parseType(); err != nil {
@@ -180,13 +410,19 @@ func (p *parser) parse() error {
 			}
 
 		case tagItab:
-			if err := p.skipItab(); err != nil {
-				return fmt.Errorf("skipping itab: %w", err)
+			if err := p.parseItab(); err != nil {
+				return fmt.Errorf("parsing itab: %w", err)
 			}
 
 		case tagFinalizer, tagQueuedFinalizer:
-			if err := p.skipFinalizer(); err != nil {
-				return fmt.Errorf("skipping finalizer: %w", err)
+			f, err := p.parseFinalizerFull()
+			if err != nil {
+				return fmt.Errorf("parsing finalizer: %w", err)
+			}
+			f.Queued = tag == tagQueuedFinalizer
+			p.finalizers = append(p.finalizers, f)
+			if err := p.charge(finalizerCost); err != nil {
+				return err
 			}
 
 		case tagData, tagBSS:
@@ -194,9 +430,24 @@ func (p *parser) parse() error {
 				return fmt.Errorf("skipping data segment: %w", err)
 			}
 
-		case tagDefer, tagPanic:
-			if err := p.skipDeferPanic(); err != nil {
-				return fmt.Errorf("skipping defer/panic: %w", err)
+		case tagDefer:
+			d, err := p.parseDeferFull()
+			if err != nil {
+				return fmt.Errorf("parsing defer: %w", err)
+			}
+			p.defers = append(p.defers, d)
+			if err := p.charge(deferCost); err != nil {
+				return err
+			}
+
+		case tagPanic:
+			pr, err := p.parsePanicFull()
+			if err != nil {
+				return fmt.Errorf("parsing panic: %w", err)
+			}
+			p.panics = append(p.panics, pr)
+			if err := p.charge(panicCost); err != nil {
+				return err
 			}
 
 		case tagOSThread:
@@ -204,22 +455,136 @@ func (p *parser) parse() error {
 				return fmt.Errorf("skipping OS thread: %w", err)
 			}
 
-		case tagMemProf, tagAllocSample:
+		case tagMemProf:
 			if err := p.skipMemProf(); err != nil {
 				return fmt.Errorf("skipping mem prof: %w", err)
 			}
 
+		case tagAllocSample:
+			if _, err := p.parseAllocSampleFull(); err != nil {
+				return fmt.Errorf("skipping alloc sample: %w", err)
+			}
+
 		default:
-			return fmt.Errorf("unknown tag: %d", tag)
+			return ErrUnknownTag{Tag: tag}
+		}
+
+		if err := checkNextTag(p.r, tag); err != nil {
+			return err
 		}
 	}
 
 	return p.finalize()
 }
 
-// finalize sets the roots and returns
+// ErrMisalignedRecord is returned when the byte after a record cannot start
+// another one, meaning the record was longer or shorter than its parser
+// expected. Everything before that record parsed cleanly, so ParsePartial
+// and the streaming parser's error recovery can still use it.
+var ErrMisalignedRecord = errors.New("misaligned record")
+
+// checkNextTag verifies that the byte after a record with tag prev could
+// start another record, so a malformed record fails where it is instead of
+// silently desyncing the records after it. Tags are varints below 128, so
+// they are always one byte.
+func checkNextTag(r *bufio.Reader, prev uint64) error {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil // the next read reports the end of input
+	}
+	if b[0] > tagAllocSample {
+		return fmt.Errorf("%w: byte 0x%02x after tag %d record is not a valid tag", ErrMisalignedRecord, b[0], prev)
+	}
+	return nil
+}
+
+// maybeReportProgress calls progressFunc once every progressInterval records
+func (p *parser) maybeReportProgress() {
+	if p.progressFunc == nil {
+		return
+	}
+
+	interval := p.progressInterval
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	if p.records > 0 && p.records%interval == 0 {
+		p.reportProgress()
+	}
+	p.records++
+}
+
+// reportProgress calls progressFunc with the current totals
+func (p *parser) reportProgress() {
+	if p.progressFunc == nil {
+		return
+	}
+
+	p.stats.mu.Lock()
+	objects, types, roots := p.stats.objects, p.stats.types, p.stats.roots
+	p.stats.mu.Unlock()
+	p.progressFunc(objects, types, roots)
+}
+
+// finalize resolves pointers and roots to object IDs
 func (p *parser) finalize() error {
-	p.g.SetRoots(graph.Roots{IDs: p.roots})
+	defer p.reportProgress()
+	p.resolvePointers()
+
+	roots := graph.Roots{
+		IDs:        make([]graph.ObjID, 0, len(p.rootAddrs)),
+		Kinds:      make([]graph.RootKind, 0, len(p.rootAddrs)),
+		Descs:      make([]string, 0, len(p.rootAddrs)),
+		Goroutines: make([]uint64, 0, len(p.rootAddrs)),
+	}
+	var onStacks bool
+	addRoot := func(id graph.ObjID, kind graph.RootKind, desc string, goroutine uint64) {
+		roots.IDs = append(roots.IDs, id)
+		roots.Kinds = append(roots.Kinds, kind)
+		roots.Descs = append(roots.Descs, desc)
+		roots.Goroutines = append(roots.Goroutines, goroutine)
+		onStacks = onStacks || goroutine != 0
+	}
+	for i, addr := range p.rootAddrs {
+		if objID, ok := p.addrToObjID[addr]; ok {
+			addRoot(objID, p.rootKinds[i], p.rootDescs[i], p.rootGoroutines[i])
+		}
+	}
+
+	// The runtime keeps a finalizer's closure alive, and everything its
+	// object references so the finalizer can use it. A queued finalizer
+	// holds the object itself.
+	for _, f := range p.finalizers {
+		objID, ok := p.addrToObjID[f.Object]
+		if !ok {
+			continue
+		}
+		obj := p.objects[objID-1]
+		obj.HasFinalizer = true
+		if fnID, ok := p.addrToObjID[f.Function]; ok {
+			addRoot(fnID, graph.RootFinalizer, "finalizer closure", 0)
+		}
+		if f.Queued {
+			addRoot(objID, graph.RootFinalizer, "queued finalizer", 0)
+			continue
+		}
+		for _, ptr := range obj.Ptrs {
+			addRoot(ptr, graph.RootFinalizer, "referenced by object with finalizer", 0)
+		}
+	}
+	if !onStacks {
+		roots.Goroutines = nil
+	}
+	p.g.SetRoots(roots)
+
+	classes := sizeClasses(p.memStats)
+	for _, obj := range p.objects {
+		obj.AllocSize = sizeclass.Round(obj.Size, classes)
+		if p.roundSizes {
+			obj.Size = obj.AllocSize
+		}
+	}
+
 	return nil
 }
 
@@ -234,11 +599,12 @@ func (p *parser) readString() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if length > 1<<20 { // Sanity check: 1MB max string
-		return "", fmt.Errorf("string too long: %d", length)
+	n, err := checkLength(length, limitOrDefault(p.maxStringLen, DefaultMaxStringLen), ErrStringTooLong)
+	if err != nil {
+		return "", err
 	}
 
-	data := make([]byte, length)
+	data := make([]byte, n)
 	if _, err := io.ReadFull(p.r, data); err != nil {
 		return "", err
 	}
@@ -251,17 +617,100 @@ func (p *parser) readBytes() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if length > 1<<30 { // Sanity check: 1GB max
-		return nil, fmt.Errorf("byte slice too long: %d", length)
+	n, err := checkLength(length, limitOrDefault(p.maxBytesLen, DefaultMaxBytesLen), ErrBytesTooLong)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readPayload reads an object's length-prefixed payload. Unless payloads are
+// kept, the result is only valid until the next call, which saves an
+// allocation per object.
+func (p *parser) readPayload() ([]byte, error) {
+	if p.keepPayloads {
+		return p.readBytes()
+	}
+
+	length, err := p.readVarint()
+	if err != nil {
+		return nil, err
+	}
+	n, err := checkLength(length, limitOrDefault(p.maxBytesLen, DefaultMaxBytesLen), ErrBytesTooLong)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxScratchLen {
+		// Don't hold on to a buffer for the occasional huge object
+		data := make([]byte, n)
+		_, err := io.ReadFull(p.r, data)
+		return data, err
 	}
 
-	data := make([]byte, length)
+	if cap(p.scratch) < n {
+		p.scratch = make([]byte, n, max(n, 2*cap(p.scratch)))
+	}
+	data := p.scratch[:n]
 	if _, err := io.ReadFull(p.r, data); err != nil {
 		return nil, err
 	}
 	return data, nil
 }
 
+// maxScratchLen is the largest payload readPayload reads into its reused
+// buffer
+const maxScratchLen = 1 << 20
+
+// skipBytes discards a length-prefixed byte slice without allocating
+func (p *parser) skipBytes() error {
+	length, err := p.readVarint()
+	if err != nil {
+		return err
+	}
+	n, err := checkLength(length, limitOrDefault(p.maxBytesLen, DefaultMaxBytesLen), ErrBytesTooLong)
+	if err != nil {
+		return err
+	}
+	if _, err := p.r.Discard(n); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+// maxHostLen is the longest field this host can hold in a slice. Lengths
+// are uint64 in the dump, so on a 32-bit host a field over 2GB would
+// otherwise wrap when converted to int. A variable so tests can stand in
+// for a 32-bit host.
+var maxHostLen uint64 = math.MaxInt
+
+// checkLength returns a length prefix as an int, or an error wrapping
+// errTooLong if it exceeds limit or maxHostLen
+func checkLength(length, limit uint64, errTooLong error) (int, error) {
+	if length > limit {
+		return 0, fmt.Errorf("%w: %d (limit %d)", errTooLong, length, limit)
+	}
+	if length > maxHostLen {
+		return 0, fmt.Errorf("%w: %d (this host's limit %d)", errTooLong, length, maxHostLen)
+	}
+	return int(length), nil
+}
+
+// limitOrDefault returns limit, or def if limit is zero
+func limitOrDefault(limit, def uint64) uint64 {
+	if limit == 0 {
+		return def
+	}
+	return limit
+}
+
 // parseParams parses a parameters record
 func (p *parser) parseParams() error {
 	bigEndian, err := p.readVarint()
@@ -274,6 +723,9 @@ func (p *parser) parseParams() error {
 	if err != nil {
 		return err
 	}
+	if err := checkPointerSize(p.pointerSize); err != nil {
+		return err
+	}
 
 	p.heapStart, err = p.readVarint()
 	if err != nil {
@@ -331,12 +783,13 @@ func (p *parser) parseType() error {
 		name:     name,
 		indirect: indirect != 0,
 	}
+	p.iface.addType(addr, indirect != 0)
 
 	p.stats.mu.Lock()
 	p.stats.types++
 	p.stats.mu.Unlock()
 
-	return nil
+	return p.charge(typeCost + uint64(len(name)))
 }
 
 // parseObject parses an object record
@@ -346,7 +799,7 @@ func (p *parser) parseObject() error {
 		return err
 	}
 
-	data, err := p.readBytes()
+	data, err := p.readPayload()
 	if err != nil {
 		return err
 	}
@@ -368,26 +821,11 @@ func (p *parser) parseObject() error {
 		}
 
 		// Extract pointer value from data if it's a pointer field
-		if kind == fieldKindPtr && int(offset+p.pointerSize) <= len(data) {
-			// Read pointer value from data at offset
-			ptrData := data[offset : offset+p.pointerSize]
-			var ptr uint64
-			if p.pointerSize == 8 {
-				if p.bigEndian {
-					ptr = binary.BigEndian.Uint64(ptrData)
-				} else {
-					ptr = binary.LittleEndian.Uint64(ptrData)
-				}
-			} else if p.pointerSize == 4 {
-				if p.bigEndian {
-					ptr = uint64(binary.BigEndian.Uint32(ptrData))
-				} else {
-					ptr = uint64(binary.LittleEndian.Uint32(ptrData))
-				}
-			}
-			if ptr != 0 {
-				pointers = append(pointers, ptr)
-			}
+		ptr, ok := p.iface.fieldPointer(data, kind, offset, p.pointerSize, p.bigEndian)
+		if !ok {
+			p.badPointers++
+		} else if ptr != 0 {
+			pointers = append(pointers, ptr)
 		}
 	}
 
@@ -398,129 +836,144 @@ func (p *parser) parseObject() error {
 
 	// Determine type name
 	typeName := "unknown"
+	var typeSize uint64
 	// Type address is usually stored at the beginning of the object
-	if len(data) >= int(p.pointerSize) {
-		typeAddrData := data[:p.pointerSize]
-		var typeAddr uint64
-		if p.pointerSize == 8 {
-			if p.bigEndian {
-				typeAddr = binary.BigEndian.Uint64(typeAddrData)
-			} else {
-				typeAddr = binary.LittleEndian.Uint64(typeAddrData)
-			}
-		} else if p.pointerSize == 4 {
-			if p.bigEndian {
-				typeAddr = uint64(binary.BigEndian.Uint32(typeAddrData))
-			} else {
-				typeAddr = uint64(binary.LittleEndian.Uint32(typeAddrData))
-			}
-		}
-
+	if typeAddr, ok := readPointer(data, 0, p.pointerSize, p.bigEndian); ok {
 		if t, ok := p.types[typeAddr]; ok {
 			typeName = t.name
+			typeSize = t.size
 		}
 	}
 
-	// Store raw pointers for now, will resolve to ObjIDs in second pass
+	// Store raw pointers for now, will resolve to ObjIDs in finalize
 	obj := &graph.Object{
 		ID:   objID,
 		Type: typeName,
 		Size: uint64(len(data)),
-		Ptrs: make([]graph.ObjID, 0, len(pointers)),
+		Addr: addr,
+
+		TypeSize: typeSize,
 	}
+	if p.hashPayloads {
+		obj.ContentHash = contentHash(data)
+	}
+	cost := objectCost + pointerCost*uint64(len(pointers))
+	if p.keepPayloads {
+		obj.Data = data
+		cost += uint64(len(data))
+	}
+	p.objects = append(p.objects, obj)
+	p.rawPtrs = append(p.rawPtrs, pointers)
 
-	// Store temporarily for second pass
 	p.g.AddObject(obj)
 
 	p.stats.mu.Lock()
 	p.stats.objects++
 	p.stats.mu.Unlock()
 
+	if err := p.charge(cost); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// contentHash returns the FNV-1a hash of an object's payload. It is written
+// out rather than using hash/fnv so hashing every object doesn't allocate.
+func contentHash(data []byte) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	for _, b := range data {
+		h ^= uint64(b)
+		h *= prime64
+	}
+	return h
+}
+
 // parseOtherRoot parses a root record
 func (p *parser) parseOtherRoot() error {
 	desc, err := p.readString()
 	if err != nil {
 		return err
 	}
-	_ = desc // We could store this for debugging
 
 	ptr, err := p.readVarint()
 	if err != nil {
 		return err
 	}
 
-	// Will resolve pointer to ObjID later
-	if objID, ok := p.addrToObjID[ptr]; ok {
-		p.roots = append(p.roots, objID)
-	}
+	// The object may not have been seen yet, so resolve in finalize
+	p.rootAddrs = append(p.rootAddrs, ptr)
+	p.rootKinds = append(p.rootKinds, otherRootKind(desc))
+	p.rootDescs = append(p.rootDescs, desc)
+	p.rootGoroutines = append(p.rootGoroutines, 0)
 
 	p.stats.mu.Lock()
 	p.stats.roots++
 	p.stats.mu.Unlock()
 
-	return nil
+	return p.charge(rootCost)
 }
 
-// parseGoroutine parses a goroutine record
-func (p *parser) parseGoroutine() error {
-	// Skip all goroutine fields for now
-	for i := 0; i < 12; i++ {
-		if _, err := p.readVarint(); err != nil {
-			return err
-		}
+// otherRootKind classifies a root record by its description. The runtime
+// only describes roots loosely, so anything unrecognized is RootOther.
+func otherRootKind(desc string) graph.RootKind {
+	switch {
+	case strings.Contains(desc, "finalizer"):
+		return graph.RootFinalizer
+	case strings.Contains(desc, "stack"):
+		return graph.RootStack
+	case strings.Contains(desc, "global") || strings.Contains(desc, "data") || strings.Contains(desc, "bss"):
+		return graph.RootGlobal
+	default:
+		return graph.RootOther
 	}
+}
 
-	// Skip wait reason string
-	if _, err := p.readString(); err != nil {
+// parseGoroutine parses a goroutine record
+func (p *parser) parseGoroutine() error {
+	g, err := p.parseGoroutineFull()
+	if err != nil {
 		return err
 	}
+	p.goroutines = append(p.goroutines, g)
+	p.curGoroutine = g.ID
 
 	p.stats.mu.Lock()
 	p.stats.goroutines++
 	p.stats.mu.Unlock()
 
-	return nil
+	return p.charge(goroutineCost + uint64(len(g.WaitReason)))
 }
 
-// parseStackFrame parses a stack frame record
+// parseStackFrame parses a stack frame record. Each pointer the frame
+// holds is a stack root of the goroutine whose record came before it.
 func (p *parser) parseStackFrame() error {
-	// Skip stack frame fields
-	for i := 0; i < 3; i++ {
-		if _, err := p.readVarint(); err != nil {
-			return err
-		}
-	}
-
-	// Skip data
-	if _, err := p.readBytes(); err != nil {
+	sf, err := p.parseStackFrameFull()
+	if err != nil {
 		return err
 	}
 
-	// Skip more fields
-	for i := 0; i < 3; i++ {
-		if _, err := p.readVarint(); err != nil {
-			return err
+	for _, field := range sf.Pointers {
+		ptr, ok := p.iface.fieldPointer(sf.Data, field.Kind, field.Offset, p.pointerSize, p.bigEndian)
+		if !ok || ptr == 0 {
+			continue
 		}
-	}
 
-	// Skip name
-	if _, err := p.readString(); err != nil {
-		return err
-	}
+		// As with other roots, resolved in finalize
+		p.rootAddrs = append(p.rootAddrs, ptr)
+		p.rootKinds = append(p.rootKinds, graph.RootStack)
+		p.rootDescs = append(p.rootDescs, sf.Name)
+		p.rootGoroutines = append(p.rootGoroutines, p.curGoroutine)
 
-	// Skip fields
-	for {
-		kind, err := p.readVarint()
-		if err != nil {
-			return err
-		}
-		if kind == fieldKindEol {
-			break
-		}
-		if _, err := p.readVarint(); err != nil {
+		p.stats.mu.Lock()
+		p.stats.roots++
+		p.stats.mu.Unlock()
+
+		if err := p.charge(rootCost); err != nil {
 			return err
 		}
 	}
@@ -530,45 +983,33 @@ func (p *parser) parseStackFrame() error {
 
 // parseMemStats parses memory statistics
 func (p *parser) parseMemStats() error {
-	// Skip all memstats fields (there are many)
-	// In production, we might want to store some of these
-	for i := 0; i < 8; i++ {
-		if _, err := p.readVarint(); err != nil {
-			return err
-		}
+	stats, err := p.parseMemStatsFull()
+	if err != nil {
+		return err
 	}
+	p.memStats = stats
 	return nil
 }
 
-// Skip functions for unimplemented record types
-
-func (p *parser) skipItab() error {
-	// tagItab format: address, type_address
-	if _, err := p.readVarint(); err != nil {
-		return err
-	}
-	if _, err := p.readVarint(); err != nil {
+// parseItab parses an interface table record
+func (p *parser) parseItab() error {
+	itab, err := p.parseItabFull()
+	if err != nil {
 		return err
 	}
-	return nil
+	p.itabs = append(p.itabs, itab)
+	p.iface.addItab(itab.Interface, itab.Type)
+	return p.charge(itabCost)
 }
 
-func (p *parser) skipFinalizer() error {
-	// Finalizer: obj, fn, fn.fn, fint, ot
-	for i := 0; i < 5; i++ {
-		if _, err := p.readVarint(); err != nil {
-			return err
-		}
-	}
-	return nil
-}
+// Skip functions for unimplemented record types
 
 func (p *parser) skipDataSegment() error {
 	// Data/BSS segment: address, data, fields
 	if _, err := p.readVarint(); err != nil {
 		return err
 	}
-	if _, err := p.readBytes(); err != nil {
+	if err := p.skipBytes(); err != nil {
 		return err
 	}
 	// Skip fields
@@ -587,17 +1028,6 @@ func (p *parser) skipDataSegment() error {
 	return nil
 }
 
-func (p *parser) skipDeferPanic() error {
-	// Defer/Panic records have variable format
-	// For now, try to skip 5 varints (rough estimate)
-	for i := 0; i < 5; i++ {
-		if _, err := p.readVarint(); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 func (p *parser) skipOSThread() error {
 	// OS Thread: id, os_id, go_id
 	for i := 0; i < 3; i++ {
@@ -6,13 +6,20 @@ package goheap
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"io"
+	"math"
 	"os"
+	"reflect"
 	"runtime/debug"
 	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/prateek/heaplens/graph"
+	"github.com/prateek/heaplens/heapdump"
 )
 
 // TestCanParse tests format detection
@@ -52,10 +59,35 @@ func TestCanParse(t *testing.T) {
 			if got != tt.expected {
 				t.Errorf("CanParse() = %v, want %v", got, tt.expected)
 			}
+
+			// A reader returning one byte per Read gives the same answer
+			got = parser.CanParse(iotest.OneByteReader(bytes.NewReader(tt.data)))
+			if got != tt.expected {
+				t.Errorf("CanParse(OneByteReader) = %v, want %v", got, tt.expected)
+			}
 		})
 	}
 }
 
+func TestRegisterParser(t *testing.T) {
+	dump := chainDump(3)
+	if _, err := heapdump.OpenBytes(dump); !errors.Is(err, heapdump.ErrNoParser) {
+		t.Fatalf("OpenBytes() before RegisterParser error = %v, want ErrNoParser", err)
+	}
+
+	RegisterParser()
+	RegisterParser() // Later calls do nothing
+	t.Cleanup(heapdump.Reset)
+
+	g, err := heapdump.OpenBytes(dump)
+	if err != nil {
+		t.Fatalf("OpenBytes() error = %v", err)
+	}
+	if g.NumObjects() != 3 {
+		t.Errorf("NumObjects() = %d, want 3", g.NumObjects())
+	}
+}
+
 // TestParseMinimalDump tests parsing a minimal valid dump
 func TestParseMinimalDump(t *testing.T) {
 	// Build a minimal valid heap dump
@@ -164,11 +196,192 @@ func TestParseWithObjects(t *testing.T) {
 		t.Errorf("Expected size 16, got %d", obj.Size)
 	}
 
+	if obj.TypeSize != 16 {
+		t.Errorf("Expected type size 16, got %d", obj.TypeSize)
+	}
+
 	// Check roots
 	roots := g.GetRoots()
 	if len(roots.IDs) != 1 {
 		t.Errorf("Expected 1 root, got %d", len(roots.IDs))
 	}
+	if kind := roots.Kind(0); kind != graph.RootOther {
+		t.Errorf("root kind = %v, want other", kind)
+	}
+}
+
+func TestOtherRootKind(t *testing.T) {
+	tests := []struct {
+		desc string
+		want graph.RootKind
+	}{
+		{"finalizer", graph.RootFinalizer},
+		{"queued finalizer closure", graph.RootFinalizer},
+		{"goroutine stack", graph.RootStack},
+		{"global", graph.RootGlobal},
+		{"bss segment", graph.RootGlobal},
+		{"test root", graph.RootOther},
+		{"", graph.RootOther},
+	}
+	for _, tt := range tests {
+		if got := otherRootKind(tt.desc); got != tt.want {
+			t.Errorf("otherRootKind(%q) = %v, want %v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+// archDump builds the same three-object heap as a dump from arch, whose
+// words are ptrSize bytes in the given byte order. Object 1 points to 2 and
+// 3, and every object starts with its type pointer.
+func archDump(arch string, ptrSize uint64, bigEndian bool) []byte {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	putWord := func(b []byte, v uint64) {
+		if ptrSize == 4 {
+			order.PutUint32(b, uint32(v))
+		} else {
+			order.PutUint64(b, v)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+	writeVarint(&buf, tagParams)
+	if bigEndian {
+		writeVarint(&buf, 1)
+	} else {
+		writeVarint(&buf, 0)
+	}
+	writeVarint(&buf, ptrSize)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x9000)
+	writeString(&buf, arch)
+	writeString(&buf, "go1.21.0")
+	writeVarint(&buf, 4)
+
+	writeVarint(&buf, tagType)
+	writeVarint(&buf, 0x500)
+	writeVarint(&buf, 3*ptrSize)
+	writeString(&buf, "main.Node")
+	writeVarint(&buf, 0)
+
+	addrs := []uint64{0x2000, 0x3000, 0x4000}
+	for i, addr := range addrs {
+		data := make([]byte, 3*ptrSize)
+		putWord(data, 0x500)
+		writeVarint(&buf, tagObject)
+		writeVarint(&buf, addr)
+		if i == 0 {
+			putWord(data[ptrSize:], addrs[1])
+			putWord(data[2*ptrSize:], addrs[2])
+		}
+		writeBytes(&buf, data)
+		if i == 0 {
+			writeVarint(&buf, fieldKindPtr)
+			writeVarint(&buf, ptrSize)
+			writeVarint(&buf, fieldKindPtr)
+			writeVarint(&buf, 2*ptrSize)
+		}
+		writeVarint(&buf, fieldKindEol)
+	}
+
+	writeVarint(&buf, tagOtherRoot)
+	writeString(&buf, "global")
+	writeVarint(&buf, addrs[0])
+	writeVarint(&buf, tagEOF)
+	return buf.Bytes()
+}
+
+// TestParseIsArchIndependent checks that word size and byte order come
+// from the dump's params rather than the host running the parser
+func TestParseIsArchIndependent(t *testing.T) {
+	parse := func(dump []byte) graph.Graph {
+		t.Helper()
+		g, err := (&GoHeapParser{}).Parse(bytes.NewReader(dump))
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		return g
+	}
+	objects := func(g graph.Graph) []graph.Object {
+		var objs []graph.Object
+		graph.ForEachObjectSorted(g, func(obj *graph.Object) { objs = append(objs, *obj) })
+		return objs
+	}
+
+	amd64 := parse(archDump("amd64", 8, false))
+	if got := amd64.GetObject(1).Ptrs; !reflect.DeepEqual(got, []graph.ObjID{2, 3}) {
+		t.Fatalf("amd64 object 1 pointers = %v, want [2 3]", got)
+	}
+
+	arm64 := parse(archDump("arm64", 8, false))
+	if !reflect.DeepEqual(objects(arm64), objects(amd64)) {
+		t.Errorf("arm64 objects = %+v, want %+v", objects(arm64), objects(amd64))
+	}
+
+	// A 32-bit big-endian dump has smaller objects with different bytes,
+	// but the same types and edges
+	mips := objects(parse(archDump("mips", 4, true)))
+	for i, want := range objects(amd64) {
+		got := mips[i]
+		if got.ID != want.ID || got.Type != want.Type || !reflect.DeepEqual(got.Ptrs, want.Ptrs) || got.Size != want.Size/2 {
+			t.Errorf("mips object %d = %+v, want the amd64 object %+v at half the size", got.ID, got, want)
+		}
+	}
+
+	result, err := (&GoHeapParser{}).ParseFull(bytes.NewReader(archDump("arm64", 8, false)))
+	if err != nil || result.Params.Arch != "arm64" {
+		t.Errorf("ParseFull() Params.Arch = %q, %v, want arm64", result.Params.Arch, err)
+	}
+}
+
+func TestParseKeepPayloads(t *testing.T) {
+	dump := archDump("amd64", 8, false)
+
+	g, err := (&GoHeapParser{}).Parse(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if data := g.GetObject(1).Data; data != nil {
+		t.Errorf("payload kept without KeepPayloads: %x", data)
+	}
+
+	g, err = (&GoHeapParser{KeepPayloads: true}).Parse(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("Parse() with KeepPayloads error = %v", err)
+	}
+	obj := g.GetObject(1)
+	if len(obj.Data) != 24 {
+		t.Fatalf("len(Data) = %d, want 24", len(obj.Data))
+	}
+	if typ, ok := obj.ReadUint64(0, false); !ok || typ != 0x500 {
+		t.Errorf("ReadUint64(0) = %#x, %v, want the type pointer 0x500", typ, ok)
+	}
+	if ptr, ok := obj.ReadUint64(16, false); !ok || ptr != 0x4000 {
+		t.Errorf("ReadUint64(16) = %#x, %v, want 0x4000", ptr, ok)
+	}
+}
+
+func TestParseRejectsOddPointerSize(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+	writeVarint(&buf, tagParams)
+	for _, v := range []uint64{0, 6, 0x1000, 0x2000} {
+		writeVarint(&buf, v)
+	}
+	writeString(&buf, "weird")
+	writeString(&buf, "go1.21.0")
+	writeVarint(&buf, 4)
+	writeVarint(&buf, tagEOF)
+	dump := buf.Bytes()
+	if _, err := (&GoHeapParser{}).Parse(bytes.NewReader(dump)); err == nil || !strings.Contains(err.Error(), "pointer size 6") {
+		t.Errorf("Parse() error = %v, want unsupported pointer size", err)
+	}
+	if err := NewStreamingParser(bytes.NewReader(dump), StreamCallbacks{}).Parse(); err == nil || !strings.Contains(err.Error(), "pointer size 6") {
+		t.Errorf("StreamingParser.Parse() error = %v, want unsupported pointer size", err)
+	}
 }
 
 // TestParseRealDump tests parsing a real heap dump if available
@@ -223,6 +436,96 @@ func TestParseRealDump(t *testing.T) {
 	}
 }
 
+// TestParseEmptyDumps checks that a dump with no records parses to an
+// empty graph through every entry point, while empty input is rejected
+func TestParseEmptyDumps(t *testing.T) {
+	var withEOF bytes.Buffer
+	withEOF.WriteString("go1.7 heap dump\n")
+	writeVarint(&withEOF, tagEOF)
+
+	heapdump.Register(&GoHeapParser{})
+	t.Cleanup(heapdump.Reset)
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"header only", []byte("go1.7 heap dump\n")},
+		{"header and EOF record", withEOF.Bytes()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			parsers := map[string]func() (graph.Graph, error){
+				"Parse": func() (graph.Graph, error) {
+					return (&GoHeapParser{}).Parse(bytes.NewReader(tt.data))
+				},
+				"ParseFull": func() (graph.Graph, error) {
+					res, err := (&GoHeapParser{}).ParseFull(bytes.NewReader(tt.data))
+					if err != nil {
+						return nil, err
+					}
+					if res.Params != (DumpParams{}) || len(res.Goroutines) != 0 || res.MemStats != nil {
+						t.Errorf("ParseFull() = %+v, want no records", res)
+					}
+					return res.Graph, nil
+				},
+				"Open": func() (graph.Graph, error) {
+					return heapdump.Open(iotest.OneByteReader(bytes.NewReader(tt.data)))
+				},
+				"OpenBytes": func() (graph.Graph, error) {
+					return heapdump.OpenBytes(tt.data)
+				},
+			}
+			for name, parse := range parsers {
+				g, err := parse()
+				if err != nil {
+					t.Fatalf("%s() error = %v", name, err)
+				}
+				if g.NumObjects() != 0 || len(g.GetRoots().IDs) != 0 {
+					t.Errorf("%s() = %d objects and %d roots, want an empty graph", name, g.NumObjects(), len(g.GetRoots().IDs))
+				}
+			}
+
+			records := 0
+			count := func() error { records++; return nil }
+			callbacks := StreamCallbacks{
+				OnParams: func(DumpParams) error { return count() },
+				OnObject: func(addr, typeAddr uint64, data []byte, ptrs []uint64) error { return count() },
+				OnResolvedObject: func(id graph.ObjID, addr, typeAddr uint64, data []byte, ptrs []graph.ObjID) error {
+					return count()
+				},
+				OnRoot: func(string, uint64) error { return count() },
+			}
+			if err := NewSeekingStreamingParser(bytes.NewReader(tt.data), callbacks).Parse(); err != nil {
+				t.Errorf("StreamingParser.Parse() error = %v", err)
+			}
+			callbacks.OnResolvedObject = nil
+			if err := NewStreamingParser(bytes.NewReader(tt.data), callbacks).Parse(); err != nil {
+				t.Errorf("StreamingParser.Parse() without resolving error = %v", err)
+			}
+			if records != 0 {
+				t.Errorf("streaming parsers delivered %d records, want none", records)
+			}
+		})
+	}
+
+	t.Run("empty input", func(t *testing.T) {
+		if _, err := (&GoHeapParser{}).Parse(bytes.NewReader(nil)); !errors.Is(err, ErrInvalidHeader) || errors.Is(err, ErrTruncated) {
+			t.Errorf("Parse() error = %v, want ErrInvalidHeader", err)
+		}
+		if err := NewStreamingParser(bytes.NewReader(nil), StreamCallbacks{}).Parse(); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("StreamingParser.Parse() error = %v, want ErrInvalidHeader", err)
+		}
+		if _, err := heapdump.Open(bytes.NewReader(nil)); !errors.Is(err, heapdump.ErrNoParser) {
+			t.Errorf("Open() error = %v, want ErrNoParser", err)
+		}
+
+		// A partial header is a truncated dump, not a foreign format
+		if _, err := (&GoHeapParser{}).Parse(strings.NewReader("go1.7 he")); !errors.Is(err, ErrTruncated) {
+			t.Errorf("Parse(partial header) error = %v, want ErrTruncated", err)
+		}
+	})
+}
+
 // TestParseErrors tests error handling
 func TestParseErrors(t *testing.T) {
 	tests := []struct {
@@ -250,6 +553,25 @@ func TestParseErrors(t *testing.T) {
 			}(),
 			wantErr: "unknown tag",
 		},
+		{
+			name: "goroutine record with an extra field",
+			data: func() []byte {
+				var buf bytes.Buffer
+				buf.WriteString("go1.7 heap dump\n")
+				writeVarint(&buf, tagGoroutine)
+				for i := 0; i < 8; i++ {
+					writeVarint(&buf, 0)
+				}
+				writeString(&buf, "running")
+				for i := 0; i < 4; i++ {
+					writeVarint(&buf, 0)
+				}
+				writeVarint(&buf, 0x4000) // not part of the record
+				writeVarint(&buf, tagEOF)
+				return buf.Bytes()
+			}(),
+			wantErr: "misaligned record: byte 0x80 after tag 4 record",
+		},
 	}
 
 	parser := &GoHeapParser{}
@@ -339,23 +661,639 @@ func TestParseWithPointers(t *testing.T) {
 		t.Errorf("Expected 2 objects, got %d", g.NumObjects())
 	}
 
-	// Verify objects were created
-	obj1Count := 0
-	obj2Count := 0
-	g.ForEachObject(func(o *graph.Object) {
-		if o.ID == 0 {
-			obj1Count++
+	// IDs start at 1 since 0 is reserved for the dominator super-root
+	obj1 := g.GetObject(1)
+	obj2 := g.GetObject(2)
+	if obj1 == nil || obj2 == nil {
+		t.Fatalf("Expected objects with IDs 1 and 2")
+	}
+
+	// Object 1 points to object 2; object 2's null pointer is dropped
+	if len(obj1.Ptrs) != 1 || obj1.Ptrs[0] != 2 {
+		t.Errorf("Expected object 1 to point to object 2, got %v", obj1.Ptrs)
+	}
+	if len(obj2.Ptrs) != 0 {
+		t.Errorf("Expected object 2 to have no pointers, got %v", obj2.Ptrs)
+	}
+}
+
+// TestParseResolvesForwardRoots tests that roots recorded before their
+// object still resolve
+func TestParseResolvesForwardRoots(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x3000)
+	writeString(&buf, "amd64")
+	writeString(&buf, "go1.20.0")
+	writeVarint(&buf, 4)
+
+	writeVarint(&buf, tagOtherRoot)
+	writeString(&buf, "global")
+	writeVarint(&buf, 0x2000)
+
+	writeVarint(&buf, tagObject)
+	writeVarint(&buf, 0x2000)
+	writeBytes(&buf, make([]byte, 16))
+	writeVarint(&buf, fieldKindEol)
+
+	writeVarint(&buf, tagEOF)
+
+	g, err := (&GoHeapParser{}).Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	roots := g.GetRoots().IDs
+	if len(roots) != 1 || roots[0] != 1 {
+		t.Errorf("Expected root [1], got %v", roots)
+	}
+}
+
+func TestParseStackRoots(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x4000)
+	writeString(&buf, "amd64")
+	writeString(&buf, "go1.20.0")
+	writeVarint(&buf, 4)
+
+	for _, addr := range []uint64{0x2000, 0x3000} {
+		writeVarint(&buf, tagObject)
+		writeVarint(&buf, addr)
+		writeBytes(&buf, make([]byte, 16))
+		writeVarint(&buf, fieldKindEol)
+	}
+
+	// Goroutine 7's frame points at the first object and holds a nil
+	// pointer; goroutine 9's points at the second
+	for _, gr := range []struct {
+		id    uint64
+		frame []byte
+	}{
+		{7, []byte{0, 0x20, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{9, []byte{0, 0x30, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+	} {
+		writeVarint(&buf, tagGoroutine)
+		for _, v := range []uint64{0xa000, 0xa100, gr.id, 0x400f00, 4, 0, 0, 0} {
+			writeVarint(&buf, v)
+		}
+		writeString(&buf, "select")
+		for j := 0; j < 4; j++ {
+			writeVarint(&buf, 0)
 		}
-		if o.ID == 1 {
-			obj2Count++
+
+		writeVarint(&buf, tagStackFrame)
+		for _, v := range []uint64{0xb000, 0, 0} {
+			writeVarint(&buf, v)
 		}
+		writeBytes(&buf, gr.frame)
+		for _, v := range []uint64{0x401000, 0x401010, 0x401020} {
+			writeVarint(&buf, v)
+		}
+		writeString(&buf, "main.worker")
+		writeVarint(&buf, fieldKindPtr)
+		writeVarint(&buf, 0)
+		writeVarint(&buf, fieldKindPtr)
+		writeVarint(&buf, 8)
+		writeVarint(&buf, fieldKindEol)
+	}
+
+	writeVarint(&buf, tagEOF)
+
+	g, err := (&GoHeapParser{RecordMask: RecordGraph}).Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := graph.Roots{
+		IDs:        []graph.ObjID{1, 2},
+		Kinds:      []graph.RootKind{graph.RootStack, graph.RootStack},
+		Descs:      []string{"main.worker", "main.worker"},
+		Goroutines: []uint64{7, 9},
+	}
+	if roots := g.GetRoots(); !reflect.DeepEqual(roots, want) {
+		t.Errorf("GetRoots() = %+v, want %+v", roots, want)
+	}
+	if got := graph.GoroutineRetained(g); !reflect.DeepEqual(got, map[uint64]uint64{7: 16, 9: 16}) {
+		t.Errorf("GoroutineRetained() = %v, want 16 bytes each for goroutines 7 and 9", got)
+	}
+}
+
+func TestParseInterfaceFields(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x4000)
+	writeString(&buf, "amd64")
+	writeString(&buf, "go1.20.0")
+	writeVarint(&buf, 4)
+
+	// An interface's data word holds a *main.T; main.Handle is marked as
+	// holding no pointer there
+	for _, typ := range []struct {
+		addr     uint64
+		name     string
+		indirect uint64
+	}{
+		{0x100, "*main.T", 1},
+		{0x200, "main.Handle", 0},
+	} {
+		writeVarint(&buf, tagType)
+		writeVarint(&buf, typ.addr)
+		writeVarint(&buf, 8)
+		writeString(&buf, typ.name)
+		writeVarint(&buf, typ.indirect)
+	}
+
+	// The runtime writes itabs before objects
+	writeVarint(&buf, tagItab)
+	writeVarint(&buf, 0x300)
+	writeVarint(&buf, 0x100)
+
+	// An interface{} holding the second object, an io.Reader holding the
+	// third through itab 0x300, and an interface{} holding a main.Handle
+	// whose word happens to equal the fourth object's address
+	words := []uint64{0x100, 0x3000, 0x300, 0x3100, 0x200, 0x3200}
+	data := make([]byte, 8*len(words))
+	for i, w := range words {
+		binary.LittleEndian.PutUint64(data[8*i:], w)
+	}
+	writeVarint(&buf, tagObject)
+	writeVarint(&buf, 0x2000)
+	writeBytes(&buf, data)
+	writeVarint(&buf, fieldKindEface)
+	writeVarint(&buf, 0)
+	cut := buf.Len()
+	for _, field := range []uint64{fieldKindIface, 16, fieldKindEface, 32, fieldKindEol} {
+		writeVarint(&buf, field)
+	}
+	for _, addr := range []uint64{0x3000, 0x3100, 0x3200} {
+		writeVarint(&buf, tagObject)
+		writeVarint(&buf, addr)
+		writeBytes(&buf, make([]byte, 16))
+		writeVarint(&buf, fieldKindEol)
+	}
+	writeVarint(&buf, tagEOF)
+	dump := buf.Bytes()
+
+	g, err := (&GoHeapParser{RecordMask: RecordGraph}).Parse(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if ptrs := g.GetObject(1).Ptrs; !reflect.DeepEqual(ptrs, []graph.ObjID{2, 3}) {
+		t.Errorf("object 1 Ptrs = %v, want [2 3]", ptrs)
+	}
+
+	// The streaming parser skips the same data word
+	var streamed []uint64
+	sp := NewStreamingParser(bytes.NewReader(dump), StreamCallbacks{
+		OnObject: func(addr, typeAddr uint64, data []byte, ptrs []uint64) error {
+			if addr == 0x2000 {
+				streamed = ptrs
+			}
+			return nil
+		},
 	})
+	if err := sp.Parse(); err != nil {
+		t.Fatalf("streaming Parse() error = %v", err)
+	}
+	if !reflect.DeepEqual(streamed, []uint64{0x3000, 0x3100}) {
+		t.Errorf("streamed ptrs = %#x, want [0x3000 0x3100]", streamed)
+	}
+	report, err := CompareParsers(bytes.NewReader(dump), bytes.NewReader(dump))
+	if err != nil || !report.Match() {
+		t.Errorf("CompareParsers() = %+v, %v, want a match", report, err)
+	}
+
+	// A dump cut off inside the first object's fields is not an object
+	g, err = (&GoHeapParser{}).ParsePartial(bytes.NewReader(dump[:cut]))
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("ParsePartial(truncated) error = %v, want ErrTruncated", err)
+	}
+	if g == nil || g.NumObjects() != 0 {
+		t.Errorf("ParsePartial(truncated) graph = %v, want no objects", g)
+	}
+
+	// Without type information, every data word is followed
+	fields := []PointerField{{Kind: fieldKindEface, Offset: 0}, {Kind: fieldKindIface, Offset: 16}, {Kind: fieldKindEface, Offset: 32}, {Kind: fieldKindIface, Offset: 40}}
+	if got := ExtractPointers(data, fields, 8, false); !reflect.DeepEqual(got, []uint64{0x3000, 0x3100, 0x3200}) {
+		t.Errorf("ExtractPointers() = %#x, want the three data words", got)
+	}
+}
+
+func TestParseFinalizers(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x4000)
+	writeString(&buf, "amd64")
+	writeString(&buf, "go1.20.0")
+	writeVarint(&buf, 4)
+
+	for _, addr := range []uint64{0x2000, 0x3000} {
+		writeVarint(&buf, tagObject)
+		writeVarint(&buf, addr)
+		writeBytes(&buf, make([]byte, 16))
+		writeVarint(&buf, fieldKindEol)
+	}
+
+	// obj, fn, fn.fn, fint, ot
+	writeVarint(&buf, tagQueuedFinalizer)
+	for _, v := range []uint64{0x3000, 0x500, 0x510, 0x600, 0x700} {
+		writeVarint(&buf, v)
+	}
+
+	writeVarint(&buf, tagEOF)
+	dump := buf.Bytes()
+
+	g, err := (&GoHeapParser{}).Parse(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if g.GetObject(1).HasFinalizer {
+		t.Error("object 1 should not have a finalizer")
+	}
+	if !g.GetObject(2).HasFinalizer {
+		t.Error("object 2 should have a finalizer")
+	}
+	if addr := g.GetObject(2).Addr; addr != 0x3000 {
+		t.Errorf("object 2 Addr = %#x, want 0x3000", addr)
+	}
+
+	// The queued finalizer's object is a finalizer root and nothing else
+	// holds it
+	wantRoots := graph.Roots{
+		IDs:   []graph.ObjID{2},
+		Kinds: []graph.RootKind{graph.RootFinalizer},
+		Descs: []string{"queued finalizer"},
+	}
+	if roots := g.GetRoots(); !reflect.DeepEqual(roots, wantRoots) {
+		t.Errorf("GetRoots() = %+v, want %+v", roots, wantRoots)
+	}
+	if got := graph.FinalizerRetained(g); !reflect.DeepEqual(got, []graph.ObjID{2}) {
+		t.Errorf("FinalizerRetained() = %v, want [2]", got)
+	}
+
+	// A second finalizer record for the same object is kept, not merged
+	more := bytes.NewBuffer(append([]byte(nil), dump[:len(dump)-1]...))
+	writeVarint(more, tagFinalizer)
+	for _, v := range []uint64{0x3000, 0x520, 0x530, 0x600, 0x700} {
+		writeVarint(more, v)
+	}
+	writeVarint(more, tagEOF)
+
+	finalizers, err := ReadFinalizers(more)
+	if err != nil {
+		t.Fatalf("ReadFinalizers() error = %v", err)
+	}
+	want := []*Finalizer{
+		{Object: 0x3000, Function: 0x500, FuncVal: 0x510, FuncType: 0x600, ObjType: 0x700, Queued: true},
+		{Object: 0x3000, Function: 0x520, FuncVal: 0x530, FuncType: 0x600, ObjType: 0x700},
+	}
+	if !reflect.DeepEqual(finalizers, want) {
+		t.Errorf("ReadFinalizers() = %+v, want %+v", finalizers, want)
+	}
+}
+
+func TestParseContentHash(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x5000)
+	writeString(&buf, "amd64")
+	writeString(&buf, "go1.20.0")
+	writeVarint(&buf, 4)
+
+	for i, payload := range []string{"hello, world!!!!", "hello, world!!!!", "goodbye, world!!"} {
+		writeVarint(&buf, tagObject)
+		writeVarint(&buf, uint64(0x2000+i*0x1000))
+		writeBytes(&buf, []byte(payload))
+		writeVarint(&buf, fieldKindEol)
+	}
+	writeVarint(&buf, tagEOF)
+	dump := buf.Bytes()
+
+	g, err := (&GoHeapParser{}).Parse(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if h := g.GetObject(1).ContentHash; h != 0 {
+		t.Errorf("ContentHash = %x without HashPayloads, want 0", h)
+	}
+
+	g, err = (&GoHeapParser{HashPayloads: true}).Parse(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("Parse() with HashPayloads error = %v", err)
+	}
+	h1, h2, h3 := g.GetObject(1).ContentHash, g.GetObject(2).ContentHash, g.GetObject(3).ContentHash
+	if h1 == 0 || h1 != h2 {
+		t.Errorf("identical payloads hashed to %x and %x", h1, h2)
+	}
+	if h1 == h3 {
+		t.Errorf("different payloads both hashed to %x", h1)
+	}
+
+	want := fnv.New64a()
+	want.Write([]byte("hello, world!!!!"))
+	if h1 != want.Sum64() {
+		t.Errorf("ContentHash = %x, want FNV-1a %x", h1, want.Sum64())
+	}
+}
+
+func TestParseFull(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x3000)
+	writeString(&buf, "arm64")
+	writeString(&buf, "go1.21.3")
+	writeVarint(&buf, 8)
+
+	writeVarint(&buf, tagItab)
+	writeVarint(&buf, 0x900)
+	writeVarint(&buf, 0x910)
+
+	writeVarint(&buf, tagObject)
+	writeVarint(&buf, 0x2000)
+	writeBytes(&buf, make([]byte, 16))
+	writeVarint(&buf, fieldKindEol)
+
+	// addr, sp, id, go pc, status, system, background, wait since,
+	// reason, ctxt, m, defer, panic
+	writeVarint(&buf, tagGoroutine)
+	for _, v := range []uint64{0xa000, 0xa100, 7, 0x400f00, 4, 0, 0, 12} {
+		writeVarint(&buf, v)
+	}
+	writeString(&buf, "chan receive")
+	for i := 0; i < 4; i++ {
+		writeVarint(&buf, 0)
+	}
+
+	writeVarint(&buf, tagMemStats)
+	writeMemStats(&buf)
+
+	// addr, gp, sp, pc, fn, fn entry, link
+	writeVarint(&buf, tagDefer)
+	for _, v := range []uint64{0xd000, 0xa000, 0xa0f0, 0x401000, 0xf000, 0x402000, 0} {
+		writeVarint(&buf, v)
+	}
+
+	// addr, gp, type, data, defer, link
+	writeVarint(&buf, tagPanic)
+	for _, v := range []uint64{0xe000, 0xa000, 0x5000, 0x5100, 0, 0} {
+		writeVarint(&buf, v)
+	}
+
+	writeVarint(&buf, tagEOF)
+
+	result, err := (&GoHeapParser{}).ParseFull(&buf)
+	if err != nil {
+		t.Fatalf("ParseFull() error = %v", err)
+	}
+
+	if result.Graph.NumObjects() != 1 {
+		t.Errorf("Expected 1 object, got %d", result.Graph.NumObjects())
+	}
+	wantParams := DumpParams{PointerSize: 8, HeapStart: 0x1000, HeapEnd: 0x3000, Arch: "arm64", GoVersion: "go1.21.3", NumCPUs: 8}
+	if result.Params != wantParams {
+		t.Errorf("Params = %+v, want %+v", result.Params, wantParams)
+	}
+	if want := []*Itab{{Interface: 0x900, Type: 0x910}}; !reflect.DeepEqual(result.Itabs, want) {
+		t.Errorf("Itabs = %+v, want %+v", result.Itabs, want)
+	}
+	if len(result.Goroutines) != 1 || result.Goroutines[0].ID != 7 || result.Goroutines[0].GoPC != 0x400f00 || result.Goroutines[0].WaitReason != "chan receive" {
+		t.Errorf("Goroutines = %+v, want goroutine 7 from 0x400f00 in chan receive", result.Goroutines)
+	}
+	if result.MemStats == nil || result.MemStats.HeapObjects != 12 {
+		t.Errorf("MemStats = %+v, want HeapObjects 12", result.MemStats)
+	}
+	if len(result.Defers) != 1 || result.Defers[0].Gp != 0xa000 || result.Defers[0].FnEntry != 0x402000 {
+		t.Errorf("Defers = %+v, want one defer of 0x402000 on goroutine 0xa000", result.Defers)
+	}
+	if len(result.Panics) != 1 || result.Panics[0].Gp != 0xa000 || result.Panics[0].Typ != 0x5000 {
+		t.Errorf("Panics = %+v, want one panic of type 0x5000 on goroutine 0xa000", result.Panics)
+	}
+}
+
+func TestParsePartial(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x4000)
+	writeString(&buf, "amd64")
+	writeString(&buf, "go1.20.0")
+	writeVarint(&buf, 4)
+
+	writeVarint(&buf, tagOtherRoot)
+	writeString(&buf, "global")
+	writeVarint(&buf, 0x2000)
+
+	// Object 1 points to object 2, which follows it
+	writeVarint(&buf, tagObject)
+	writeVarint(&buf, 0x2000)
+	objData := make([]byte, 16)
+	binary.LittleEndian.PutUint64(objData[8:], 0x3000)
+	writeBytes(&buf, objData)
+	writeVarint(&buf, fieldKindPtr)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, fieldKindEol)
+
+	writeVarint(&buf, tagObject)
+	writeVarint(&buf, 0x3000)
+	writeBytes(&buf, make([]byte, 16))
+	writeVarint(&buf, fieldKindEol)
+
+	// The third object is cut off part way through its payload
+	writeVarint(&buf, tagObject)
+	writeVarint(&buf, 0x3100)
+	writeVarint(&buf, 64)
+	buf.Write(make([]byte, 10))
+	truncated := buf.Bytes()
+
+	if g, err := (&GoHeapParser{}).Parse(bytes.NewReader(truncated)); err == nil || g != nil {
+		t.Errorf("Parse() = %v, %v, want nil graph and error", g, err)
+	}
 
-	if obj1Count != 1 {
-		t.Errorf("Expected 1 object with ID 0, got %d", obj1Count)
+	g, err := (&GoHeapParser{}).ParsePartial(bytes.NewReader(truncated))
+	if err == nil {
+		t.Error("ParsePartial() should report the truncation")
+	}
+	if g == nil {
+		t.Fatal("ParsePartial() returned nil graph")
+	}
+	if g.NumObjects() != 2 {
+		t.Errorf("Expected 2 complete objects, got %d", g.NumObjects())
+	}
+	if obj := g.GetObject(1); obj == nil || !reflect.DeepEqual(obj.Ptrs, []graph.ObjID{2}) {
+		t.Errorf("object 1 = %+v, want pointer to 2", obj)
 	}
-	if obj2Count != 1 {
-		t.Errorf("Expected 1 object with ID 1, got %d", obj2Count)
+	if roots := g.GetRoots().IDs; !reflect.DeepEqual(roots, []graph.ObjID{1}) {
+		t.Errorf("roots = %v, want [1]", roots)
+	}
+}
+
+func TestParseProgress(t *testing.T) {
+	var calls [][3]int
+	parser := &GoHeapParser{
+		ProgressFunc: func(objects, types, roots int) {
+			calls = append(calls, [3]int{objects, types, roots})
+		},
+		ProgressInterval: 4,
+	}
+	if _, err := parser.Parse(bytes.NewReader(chainDump(10))); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	// Params plus 10 objects: reports after 4 and 8 records, then at the end
+	want := [][3]int{{3, 0, 0}, {7, 0, 0}, {10, 0, 0}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("progress calls = %v, want %v", calls, want)
+	}
+}
+
+// zeroReader is an endless stream of zero bytes
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	clear(b)
+	return len(b), nil
+}
+
+// objectDump returns a dump holding one object whose payload is size zero
+// bytes, generated lazily so huge objects need no backing buffer
+func objectDump(size uint64) io.Reader {
+	var head bytes.Buffer
+	head.WriteString("go1.7 heap dump\n")
+	writeVarint(&head, tagParams)
+	writeVarint(&head, 0)
+	writeVarint(&head, 8)
+	writeVarint(&head, 0x1000)
+	writeVarint(&head, 0x2000)
+	writeString(&head, "amd64")
+	writeString(&head, "go1.20.0")
+	writeVarint(&head, 4)
+	writeVarint(&head, tagObject)
+	writeVarint(&head, 0x2000)
+	writeVarint(&head, size)
+
+	var tail bytes.Buffer
+	writeVarint(&tail, fieldKindEol)
+	writeVarint(&tail, tagEOF)
+
+	return io.MultiReader(&head, io.LimitReader(zeroReader{}, int64(size)), &tail)
+}
+
+func TestParseLengthLimits(t *testing.T) {
+	// Lengths past the default limit are rejected
+	_, err := (&GoHeapParser{}).Parse(objectDump(DefaultMaxBytesLen + 1))
+	if err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Errorf("Parse() error = %v, want length limit error", err)
+	}
+
+	// A corrupt length is rejected before allocating, even with a raised limit
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+	writeVarint(&buf, tagObject)
+	writeVarint(&buf, 0x2000)
+	writeVarint(&buf, 1<<60)
+	_, err = (&GoHeapParser{MaxBytesLen: 4 << 30}).Parse(&buf)
+	if err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Errorf("Parse() error = %v, want length limit error", err)
+	}
+
+	// Strings have their own limit
+	buf.Reset()
+	buf.WriteString("go1.7 heap dump\n")
+	writeVarint(&buf, tagType)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 16)
+	writeString(&buf, strings.Repeat("x", 64))
+	writeVarint(&buf, 0)
+	writeVarint(&buf, tagEOF)
+	sp := NewStreamingParser(&buf, StreamCallbacks{})
+	sp.MaxStringLen = 32
+	sp.SetErrorRecovery(0, false)
+	if err := sp.Parse(); err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Errorf("StreamingParser.Parse() error = %v, want length limit error", err)
+	}
+}
+
+func TestParseLengthExceedsHost(t *testing.T) {
+	// Stand in for a 32-bit host, where a 2GB payload can't be held in a
+	// slice even with MaxBytesLen raised past it
+	defer func(n uint64) { maxHostLen = n }(maxHostLen)
+	maxHostLen = math.MaxInt32
+	size := uint64(math.MaxInt32) + 1
+
+	parsers := map[string]func(r io.Reader) error{
+		"Parse": func(r io.Reader) error {
+			_, err := (&GoHeapParser{MaxBytesLen: 4 << 30}).Parse(r)
+			return err
+		},
+		"Parse with payloads": func(r io.Reader) error {
+			_, err := (&GoHeapParser{MaxBytesLen: 4 << 30, KeepPayloads: true}).Parse(r)
+			return err
+		},
+		"Parse skipping objects": func(r io.Reader) error {
+			_, err := (&GoHeapParser{MaxBytesLen: 4 << 30, RecordMask: RecordRoots}).Parse(r)
+			return err
+		},
+		"StreamingParser": func(r io.Reader) error {
+			sp := NewStreamingParser(r, StreamCallbacks{})
+			sp.MaxBytesLen = 4 << 30
+			sp.SetErrorRecovery(0, false)
+			return sp.Parse()
+		},
+	}
+	for name, parse := range parsers {
+		if err := parse(objectDump(size)); !errors.Is(err, ErrBytesTooLong) {
+			t.Errorf("%s error = %v, want ErrBytesTooLong", name, err)
+		}
+	}
+}
+
+func TestParseObjectLargerThan1GB(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 1GB allocation in short mode")
+	}
+
+	size := uint64(DefaultMaxBytesLen + 16)
+	g, err := (&GoHeapParser{MaxBytesLen: 2 << 30}).Parse(objectDump(size))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if obj := g.GetObject(1); obj == nil || obj.Size != size {
+		t.Errorf("GetObject(1) = %+v, want object of size %d", obj, size)
 	}
 }
 
@@ -436,3 +1374,45 @@ func BenchmarkParse(b *testing.B) {
 
 	b.SetBytes(int64(len(data)))
 }
+
+// BenchmarkParsePayloads benchmarks a payload-heavy dump of 4KB objects with
+// one pointer each, with and without KeepPayloads
+func BenchmarkParsePayloads(b *testing.B) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x10000000)
+	writeString(&buf, "amd64")
+	writeString(&buf, "go1.20.0")
+	writeVarint(&buf, 4)
+
+	for i := 0; i < 1000; i++ {
+		writeVarint(&buf, tagObject)
+		writeVarint(&buf, uint64(0x2000+i*0x1000))
+		objData := make([]byte, 4096)
+		binary.LittleEndian.PutUint64(objData[8:], uint64(0x2000+(i+1)*0x1000))
+		writeBytes(&buf, objData)
+		writeVarint(&buf, fieldKindPtr)
+		writeVarint(&buf, 8)
+		writeVarint(&buf, fieldKindEol)
+	}
+	writeVarint(&buf, tagEOF)
+	data := buf.Bytes()
+
+	for _, keep := range []bool{false, true} {
+		b.Run(fmt.Sprintf("keep=%v", keep), func(b *testing.B) {
+			parser := &GoHeapParser{KeepPayloads: keep}
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := parser.Parse(bytes.NewReader(data)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// ABOUTME: Selects which kinds of record the parser decodes
+// ABOUTME: Skips unwanted records by reading past them without building anything
+
+package goheap
+
+// RecordMask is a set of record kinds, one bit per record tag. The
+// parameters and EOF records are always read.
+type RecordMask uint64
+
+// Record kinds for GoHeapParser.RecordMask
+const (
+	RecordObjects      RecordMask = 1 << tagObject
+	RecordRoots        RecordMask = 1 << tagOtherRoot
+	RecordTypes        RecordMask = 1 << tagType
+	RecordGoroutines   RecordMask = 1 << tagGoroutine
+	RecordStackFrames  RecordMask = 1 << tagStackFrame
+	RecordFinalizers   RecordMask = 1<<tagFinalizer | 1<<tagQueuedFinalizer
+	RecordItabs        RecordMask = 1 << tagItab
+	RecordOSThreads    RecordMask = 1 << tagOSThread
+	RecordMemStats     RecordMask = 1 << tagMemStats
+	RecordDataSegments RecordMask = 1<<tagData | 1<<tagBSS
+	RecordDefers       RecordMask = 1 << tagDefer
+	RecordPanics       RecordMask = 1 << tagPanic
+	RecordMemProf      RecordMask = 1<<tagMemProf | 1<<tagAllocSample
+
+	// RecordGraph is everything the object graph is built from: objects,
+	// their types, and the roots and finalizers that keep them alive.
+	// Goroutines and their stack frames supply the stack roots, and itabs
+	// tell which interface fields hold pointers.
+	RecordGraph = RecordObjects | RecordTypes | RecordRoots | RecordFinalizers | RecordGoroutines | RecordStackFrames | RecordItabs
+)
+
+// wants reports whether records with tag should be decoded. The zero mask
+// wants everything.
+func (m RecordMask) wants(tag uint64) bool {
+	return m == 0 || tag >= 64 || m&(1<<tag) != 0
+}
+
+// skipRecord reads past the body of a record with tag, decoding as little
+// as its layout allows
+func (p *parser) skipRecord(tag uint64) error {
+	switch tag {
+	case tagObject:
+		// address, payload, field list
+		if err := p.skipVarints(1); err != nil {
+			return err
+		}
+		if err := p.skipBytes(); err != nil {
+			return err
+		}
+		return p.skipFields()
+	case tagOtherRoot:
+		// description, pointer
+		if err := p.skipBytes(); err != nil {
+			return err
+		}
+		return p.skipVarints(1)
+	case tagType:
+		// address, size, name, indirect
+		if err := p.skipVarints(2); err != nil {
+			return err
+		}
+		if err := p.skipBytes(); err != nil {
+			return err
+		}
+		return p.skipVarints(1)
+	case tagGoroutine:
+		_, err := p.parseGoroutineFull()
+		return err
+	case tagStackFrame:
+		// three varints, frame bytes, three varints, name, field list
+		if err := p.skipVarints(3); err != nil {
+			return err
+		}
+		if err := p.skipBytes(); err != nil {
+			return err
+		}
+		if err := p.skipVarints(3); err != nil {
+			return err
+		}
+		if err := p.skipBytes(); err != nil {
+			return err
+		}
+		return p.skipFields()
+	case tagFinalizer, tagQueuedFinalizer:
+		return p.skipVarints(5)
+	case tagItab:
+		return p.skipVarints(2)
+	case tagOSThread:
+		return p.skipOSThread()
+	case tagMemStats:
+		_, err := p.parseMemStatsFull()
+		return err
+	case tagData, tagBSS:
+		return p.skipDataSegment()
+	case tagDefer:
+		_, err := p.parseDeferFull()
+		return err
+	case tagPanic:
+		_, err := p.parsePanicFull()
+		return err
+	case tagMemProf:
+		return p.skipMemProf()
+	case tagAllocSample:
+		_, err := p.parseAllocSampleFull()
+		return err
+	default:
+		return ErrUnknownTag{Tag: tag}
+	}
+}
+
+// skipVarints reads past n varints
+func (p *parser) skipVarints(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := p.readVarint(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipFields reads past a field list up to its end marker
+func (p *parser) skipFields() error {
+	for {
+		kind, err := p.readVarint()
+		if err != nil {
+			return err
+		}
+		if kind == fieldKindEol {
+			return nil
+		}
+		if _, err := p.readVarint(); err != nil {
+			return err
+		}
+	}
+}
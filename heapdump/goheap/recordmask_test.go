@@ -0,0 +1,161 @@
+// ABOUTME: Tests for parsing a subset of record kinds
+// ABOUTME: Checks masked records are skipped cleanly and benchmarks the savings
+
+package goheap
+
+import (
+	"bytes"
+	"testing"
+)
+
+// goroutineDump returns a dump of one typed object and n goroutines, each
+// with a stack frame, plus one record of every other metadata kind
+func goroutineDump(n int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x100000)
+	writeString(&buf, "amd64")
+	writeString(&buf, "go1.20.0")
+	writeVarint(&buf, 4)
+
+	writeVarint(&buf, tagType)
+	writeVarint(&buf, 0x500)
+	writeVarint(&buf, 16)
+	writeString(&buf, "main.T")
+	writeVarint(&buf, 0)
+
+	writeVarint(&buf, tagObject)
+	writeVarint(&buf, 0x2000)
+	writeBytes(&buf, []byte{0x00, 0x05, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	writeVarint(&buf, fieldKindEol)
+
+	writeVarint(&buf, tagOtherRoot)
+	writeString(&buf, "data")
+	writeVarint(&buf, 0x2000)
+
+	for i := 0; i < n; i++ {
+		// addr, sp, id, go pc, status, system, background, wait since,
+		// reason, ctxt, m, defer, panic
+		writeVarint(&buf, tagGoroutine)
+		for _, v := range []uint64{uint64(0xa000 + i*0x100), 0xa100, uint64(i + 1), 0x400f00, 4, 0, 0, 12} {
+			writeVarint(&buf, v)
+		}
+		writeString(&buf, "chan receive")
+		for j := 0; j < 4; j++ {
+			writeVarint(&buf, 0)
+		}
+
+		// sp, depth, child sp, frame, entry, pc, continuation, name, fields
+		writeVarint(&buf, tagStackFrame)
+		writeVarint(&buf, 0xb000)
+		writeVarint(&buf, 0)
+		writeVarint(&buf, 0)
+		writeBytes(&buf, make([]byte, 64))
+		writeVarint(&buf, 0x401000)
+		writeVarint(&buf, 0x401010)
+		writeVarint(&buf, 0x401020)
+		writeString(&buf, "main.worker")
+		writeVarint(&buf, fieldKindPtr)
+		writeVarint(&buf, 8)
+		writeVarint(&buf, fieldKindEol)
+	}
+
+	writeVarint(&buf, tagItab)
+	writeVarint(&buf, 0x900)
+	writeVarint(&buf, 0x910)
+
+	writeVarint(&buf, tagOSThread)
+	for _, v := range []uint64{0xc000, 1, 1} {
+		writeVarint(&buf, v)
+	}
+
+	writeVarint(&buf, tagMemStats)
+	writeMemStats(&buf)
+
+	writeVarint(&buf, tagDefer)
+	for _, v := range []uint64{0xd000, 0xa000, 0xa0f0, 0x401000, 0xf000, 0x402000, 0} {
+		writeVarint(&buf, v)
+	}
+
+	writeVarint(&buf, tagPanic)
+	for _, v := range []uint64{0xe000, 0xa000, 0x5000, 0x5100, 0, 0} {
+		writeVarint(&buf, v)
+	}
+
+	writeVarint(&buf, tagEOF)
+	return buf.Bytes()
+}
+
+func TestParseRecordMask(t *testing.T) {
+	dump := goroutineDump(3)
+
+	all, err := (&GoHeapParser{}).ParseFull(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("ParseFull() error = %v", err)
+	}
+	if len(all.Goroutines) != 3 || len(all.Itabs) != 1 || len(all.Defers) != 1 || len(all.Panics) != 1 || all.MemStats == nil {
+		t.Fatalf("unmasked parse missed records: %+v", all)
+	}
+
+	graphOnly, err := (&GoHeapParser{RecordMask: RecordGraph}).ParseFull(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("ParseFull(RecordGraph) error = %v", err)
+	}
+	if len(graphOnly.Defers) != 0 || len(graphOnly.Panics) != 0 || graphOnly.MemStats != nil {
+		t.Errorf("masked records were decoded: %+v", graphOnly)
+	}
+	if len(graphOnly.Goroutines) != 3 {
+		t.Errorf("RecordGraph parse has %d goroutines, want 3; their stacks hold roots", len(graphOnly.Goroutines))
+	}
+	if len(graphOnly.Itabs) != 1 {
+		t.Errorf("RecordGraph parse has %d itabs, want 1; they type interface fields", len(graphOnly.Itabs))
+	}
+	if graphOnly.Params != all.Params {
+		t.Errorf("Params = %+v, want %+v; parameters are always read", graphOnly.Params, all.Params)
+	}
+	obj := graphOnly.Graph.GetObject(1)
+	if obj == nil || obj.Type != "main.T" {
+		t.Fatalf("object 1 = %+v, want a main.T", obj)
+	}
+	if roots := graphOnly.Graph.GetRoots().IDs; len(roots) != 1 || roots[0] != 1 {
+		t.Errorf("roots = %v, want [1]", roots)
+	}
+
+	// Without types, objects keep their payload but not their names
+	untyped, err := (&GoHeapParser{RecordMask: RecordObjects}).Parse(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("Parse(RecordObjects) error = %v", err)
+	}
+	if obj := untyped.GetObject(1); obj == nil || obj.Type != "unknown" || len(untyped.GetRoots().IDs) != 0 {
+		t.Errorf("RecordObjects parse = %+v with roots %v, want an unknown object and no roots", obj, untyped.GetRoots().IDs)
+	}
+}
+
+// BenchmarkParseRecordMask compares a full parse of a goroutine-heavy dump
+// with one that skips everything but the object graph
+func BenchmarkParseRecordMask(b *testing.B) {
+	dump := goroutineDump(10000)
+	for _, bm := range []struct {
+		name string
+		mask RecordMask
+	}{
+		{"all", 0},
+		{"graph", RecordGraph},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			p := &GoHeapParser{RecordMask: bm.mask}
+			b.SetBytes(int64(len(dump)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.ParseFull(bytes.NewReader(dump)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ package goheap
 import (
 	"bufio"
 	"encoding/binary"
+	"fmt"
 )
 
 // Record types for full heap dump support
@@ -17,6 +18,7 @@ type (
 		FuncVal  uint64
 		FuncType uint64
 		ObjType  uint64
+		Queued   bool // From a queued finalizer record: ready to run
 	}
 
 	// Itab represents an interface table record
@@ -74,25 +76,27 @@ type (
 		Pointers []PointerField
 	}
 
-	// DeferRecord represents a deferred function call
+	// DeferRecord represents a deferred function call, in the order the
+	// runtime's dumpdefer writes it
 	DeferRecord struct {
 		Address uint64
-		Gp      uint64
-		Argp    uint64
+		Gp      uint64 // goroutine the defer belongs to
+		Argp    uint64 // stack pointer of the deferring frame
 		PC      uint64
-		Fn      uint64
-		FnEntry uint64
-		Link    uint64
+		Fn      uint64 // funcval pointer
+		FnEntry uint64 // entry PC of the deferred function, 0 if Fn is nil
+		Link    uint64 // next defer on the goroutine
 	}
 
-	// PanicRecord represents a panic
+	// PanicRecord represents an active panic, in the order the runtime's
+	// dumppanic writes it
 	PanicRecord struct {
 		Address uint64
-		Gp      uint64
-		Typ     uint64
-		Data    uint64
-		Defer   uint64
-		Link    uint64
+		Gp      uint64 // goroutine that is panicking
+		Typ     uint64 // type of the panic value
+		Data    uint64 // data word of the panic value
+		Defer   uint64 // always 0 in current runtimes
+		Link    uint64 // earlier panic on the goroutine
 	}
 
 	// GoroutineFull represents complete goroutine information
@@ -100,6 +104,7 @@ type (
 		Address      uint64
 		StackTop     uint64
 		ID           uint64
+		GoPC         uint64 // PC of the go statement that created it
 		Status       uint64
 		IsSystem     bool
 		IsBackground bool
@@ -113,45 +118,50 @@ type (
 
 	// MemStatsFull represents complete memory statistics
 	MemStatsFull struct {
-		Alloc         uint64
-		TotalAlloc    uint64
-		Sys           uint64
-		Lookups       uint64
-		Mallocs       uint64
-		Frees         uint64
-		HeapAlloc     uint64
-		HeapSys       uint64
-		HeapIdle      uint64
-		HeapInuse     uint64
-		HeapReleased  uint64
-		HeapObjects   uint64
-		StackInuse    uint64
-		StackSys      uint64
-		MSpanInuse    uint64
-		MSpanSys      uint64
-		MCacheInuse   uint64
-		MCacheSys     uint64
-		BuckHashSys   uint64
-		GCSys         uint64
-		OtherSys      uint64
-		NextGC        uint64
-		LastGC        uint64
-		PauseTotalNs  uint64
-		NumGC         uint32
-		NumForcedGC   uint32
-		GCCPUFraction float64
-		EnableGC      bool
-		DebugGC       bool
-		// BySize stats would go here
+		Alloc        uint64
+		TotalAlloc   uint64
+		Sys          uint64
+		Lookups      uint64
+		Mallocs      uint64
+		Frees        uint64
+		HeapAlloc    uint64
+		HeapSys      uint64
+		HeapIdle     uint64
+		HeapInuse    uint64
+		HeapReleased uint64
+		HeapObjects  uint64
+		StackInuse   uint64
+		StackSys     uint64
+		MSpanInuse   uint64
+		MSpanSys     uint64
+		MCacheInuse  uint64
+		MCacheSys    uint64
+		BuckHashSys  uint64
+		GCSys        uint64
+		OtherSys     uint64
+		NextGC       uint64
+		LastGC       uint64
+		PauseTotalNs uint64
+		PauseNs      [256]uint64 // ring buffer of recent pause times, as in runtime.MemStats
+		NumGC        uint32
+
+		// BySize is always empty for a parsed dump: the runtime's MemStats
+		// record ends with PauseNs and NumGC and carries no per-size-class
+		// counts, so sizeClasses falls back to the runtime's default classes
+		BySize []SizeClassStat
+	}
+
+	// SizeClassStat counts allocations and frees for one heap size class
+	SizeClassStat struct {
+		Size    uint64
+		Mallocs uint64
+		Frees   uint64
 	}
 
 	// AllocSample represents an allocation sample
 	AllocSample struct {
-		Address  uint64
-		Profile  uint64
-		Size     uint64
-		NumAlloc uint64
-		NumFree  uint64
+		Address uint64 // sampled object
+		Profile uint64 // memory profile bucket it was sampled into
 	}
 )
 
@@ -339,6 +349,10 @@ func (p *parser) parseMemProfFull() (*MemProfRecord, error) {
 		return nil, err
 	}
 
+	if nstk > maxMemProfDepth {
+		return nil, fmt.Errorf("memory profile stack too deep: %d", nstk)
+	}
+
 	// Read stack frames
 	mp.Stack = make([]MemProfFrame, nstk)
 	for i := uint64(0); i < nstk; i++ {
@@ -511,6 +525,11 @@ func (p *parser) parseGoroutineFull() (*GoroutineFull, error) {
 		return nil, err
 	}
 
+	g.GoPC, err = p.readVarint()
+	if err != nil {
+		return nil, err
+	}
+
 	g.Status, err = p.readVarint()
 	if err != nil {
 		return nil, err
@@ -561,84 +580,47 @@ func (p *parser) parseGoroutineFull() (*GoroutineFull, error) {
 	return g, nil
 }
 
-// parseMemStatsFull parses complete memory statistics
+// parseMemStatsFull parses complete memory statistics. The runtime writes
+// the uint64 fields of runtime.MemStats from Alloc through PauseTotalNs,
+// then all of PauseNs, then NumGC.
 func (p *parser) parseMemStatsFull() (*MemStatsFull, error) {
 	ms := &MemStatsFull{}
 	var err error
 
-	// Parse all 61+ fields of MemStats
-	// This is simplified - the real format has 61 fields
-	ms.Alloc, err = p.readVarint()
-	if err != nil {
-		return nil, err
+	fields := []*uint64{
+		&ms.Alloc, &ms.TotalAlloc, &ms.Sys, &ms.Lookups, &ms.Mallocs, &ms.Frees,
+		&ms.HeapAlloc, &ms.HeapSys, &ms.HeapIdle, &ms.HeapInuse, &ms.HeapReleased, &ms.HeapObjects,
+		&ms.StackInuse, &ms.StackSys, &ms.MSpanInuse, &ms.MSpanSys, &ms.MCacheInuse, &ms.MCacheSys,
+		&ms.BuckHashSys, &ms.GCSys, &ms.OtherSys, &ms.NextGC, &ms.LastGC, &ms.PauseTotalNs,
 	}
-
-	ms.TotalAlloc, err = p.readVarint()
-	if err != nil {
-		return nil, err
-	}
-
-	ms.Sys, err = p.readVarint()
-	if err != nil {
-		return nil, err
-	}
-
-	ms.Lookups, err = p.readVarint()
-	if err != nil {
-		return nil, err
-	}
-
-	ms.Mallocs, err = p.readVarint()
-	if err != nil {
-		return nil, err
-	}
-
-	ms.Frees, err = p.readVarint()
-	if err != nil {
-		return nil, err
-	}
-
-	ms.HeapAlloc, err = p.readVarint()
-	if err != nil {
-		return nil, err
-	}
-
-	ms.HeapSys, err = p.readVarint()
-	if err != nil {
-		return nil, err
-	}
-
-	ms.HeapIdle, err = p.readVarint()
-	if err != nil {
-		return nil, err
-	}
-
-	ms.HeapInuse, err = p.readVarint()
-	if err != nil {
-		return nil, err
+	for _, field := range fields {
+		*field, err = p.readVarint()
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	ms.HeapReleased, err = p.readVarint()
-	if err != nil {
-		return nil, err
+	for i := range ms.PauseNs {
+		ms.PauseNs[i], err = p.readVarint()
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	ms.HeapObjects, err = p.readVarint()
+	numGC, err := p.readVarint()
 	if err != nil {
 		return nil, err
 	}
-
-	// Skip remaining fields for brevity
-	// In production, all 61 fields should be parsed
-	for i := 0; i < 49; i++ {
-		if _, err := p.readVarint(); err != nil {
-			return nil, err
-		}
-	}
+	ms.NumGC = uint32(numGC)
 
 	return ms, nil
 }
 
+// maxMemProfDepth bounds a memory profile record's stack depth so corrupt
+// input cannot force a huge allocation. The runtime records at most a few
+// dozen frames.
+const maxMemProfDepth = 1024
+
 // parseAllocSampleFull parses a complete allocation sample
 func (p *parser) parseAllocSampleFull() (*AllocSample, error) {
 	as := &AllocSample{}
@@ -654,58 +636,129 @@ func (p *parser) parseAllocSampleFull() (*AllocSample, error) {
 		return nil, err
 	}
 
-	as.Size, err = p.readVarint()
-	if err != nil {
-		return nil, err
-	}
-
-	as.NumAlloc, err = p.readVarint()
-	if err != nil {
-		return nil, err
-	}
-
-	as.NumFree, err = p.readVarint()
-	if err != nil {
-		return nil, err
-	}
-
 	return as, nil
 }
 
-// ExtractPointers extracts pointer values from data given pointer fields
+// ExtractPointers extracts pointer values from data given pointer fields.
+// For interface fields it extracts the data word without the type check
+// the parsers make, so a word the dump's types mark as holding no pointer
+// may be returned; it will rarely resolve to an object.
 func ExtractPointers(data []byte, fields []PointerField, pointerSize uint64, bigEndian bool) []uint64 {
 	var pointers []uint64
 
 	for _, field := range fields {
-		if field.Kind != fieldKindPtr {
-			continue
+		var ptr uint64
+		var ok bool
+		switch field.Kind {
+		case fieldKindPtr:
+			ptr, ok = readPointer(data, field.Offset, pointerSize, bigEndian)
+		case fieldKindIface, fieldKindEface:
+			_, ptr, ok = readInterface(data, field.Offset, pointerSize, bigEndian)
 		}
-
-		if int(field.Offset+pointerSize) > len(data) {
-			continue
+		if ok && ptr != 0 {
+			pointers = append(pointers, ptr)
 		}
+	}
 
-		ptrData := data[field.Offset : field.Offset+pointerSize]
-		var ptr uint64
+	return pointers
+}
 
-		if pointerSize == 8 {
-			if bigEndian {
-				ptr = binary.BigEndian.Uint64(ptrData)
-			} else {
-				ptr = binary.LittleEndian.Uint64(ptrData)
-			}
-		} else if pointerSize == 4 {
-			if bigEndian {
-				ptr = uint64(binary.BigEndian.Uint32(ptrData))
-			} else {
-				ptr = uint64(binary.LittleEndian.Uint32(ptrData))
-			}
-		}
+// readPointer decodes the pointer at offset in data using the dump's word
+// size and byte order, never the host's. It reports false if the pointer
+// does not fit in data. Bounds are checked in uint64 so a corrupt offset
+// cannot wrap around on a 32-bit host.
+func readPointer(data []byte, offset, pointerSize uint64, bigEndian bool) (uint64, bool) {
+	n := uint64(len(data))
+	if offset > n || pointerSize > n-offset {
+		return 0, false
+	}
+
+	b := data[offset : offset+pointerSize]
+	switch {
+	case pointerSize == 8 && bigEndian:
+		return binary.BigEndian.Uint64(b), true
+	case pointerSize == 8:
+		return binary.LittleEndian.Uint64(b), true
+	case pointerSize == 4 && bigEndian:
+		return uint64(binary.BigEndian.Uint32(b)), true
+	case pointerSize == 4:
+		return uint64(binary.LittleEndian.Uint32(b)), true
+	}
+	return 0, false
+}
 
-		if ptr != 0 {
-			pointers = append(pointers, ptr)
+// readInterface decodes the two words of the interface at offset in data:
+// the itab, or the type for an empty interface, then the data word. It
+// reports false if the interface does not fit in data.
+func readInterface(data []byte, offset, pointerSize uint64, bigEndian bool) (tab, word uint64, ok bool) {
+	n := uint64(len(data))
+	if offset > n || 2*pointerSize > n-offset {
+		return 0, 0, false
+	}
+	tab, _ = readPointer(data, offset, pointerSize, bigEndian)
+	word, ok = readPointer(data, offset+pointerSize, pointerSize, bigEndian)
+	return tab, word, ok
+}
+
+// ifaceTypes is what a parser has read from type and itab records about
+// interface data words. The runtime writes the itabs, and their types,
+// before any object, so the parsers decide each interface field as they
+// read it and agree with each other.
+type ifaceTypes struct {
+	pointerFree map[uint64]bool   // Types whose data word holds no pointer
+	itabs       map[uint64]uint64 // Itab address to its concrete type
+}
+
+func (t *ifaceTypes) addType(addr uint64, indirect bool) {
+	if indirect {
+		delete(t.pointerFree, addr)
+		return
+	}
+	if t.pointerFree == nil {
+		t.pointerFree = make(map[uint64]bool)
+	}
+	t.pointerFree[addr] = true
+}
+
+func (t *ifaceTypes) addItab(itab, typ uint64) {
+	if t.itabs == nil {
+		t.itabs = make(map[uint64]uint64)
+	}
+	t.itabs[itab] = typ
+}
+
+// fieldPointer returns the pointer held by a field of kind at offset in
+// data, or 0 if it holds none. An interface's data word counts unless its
+// type, found through the itab for a non-empty interface, is known to hold
+// no pointer there; a word that doesn't land on an object is dropped when
+// pointers are resolved. It reports false if the field does not fit in
+// data.
+func (t *ifaceTypes) fieldPointer(data []byte, kind, offset, pointerSize uint64, bigEndian bool) (uint64, bool) {
+	switch kind {
+	case fieldKindPtr:
+		return readPointer(data, offset, pointerSize, bigEndian)
+	case fieldKindIface, fieldKindEface:
+		tab, word, ok := readInterface(data, offset, pointerSize, bigEndian)
+		if !ok {
+			return 0, false
+		}
+		typ := tab
+		if kind == fieldKindIface {
+			typ = t.itabs[tab]
+		}
+		if t.pointerFree[typ] {
+			return 0, true
 		}
+		return word, true
 	}
+	return 0, true
+}
 
-	return pointers
+// checkPointerSize rejects word sizes no Go port uses, which would
+// otherwise leave every pointer unread
+func checkPointerSize(size uint64) error {
+	if size != 4 && size != 8 {
+		return fmt.Errorf("unsupported pointer size %d", size)
+	}
+	return nil
 }
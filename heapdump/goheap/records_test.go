@@ -0,0 +1,88 @@
+// ABOUTME: Tests for the full record parsers
+// ABOUTME: Feeds synthetic records directly to the record-level functions
+
+package goheap
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// recordParser returns a parser reading the given record body
+func recordParser(body []byte) *parser {
+	return &parser{r: bufio.NewReader(bytes.NewReader(body))}
+}
+
+// writeMemStats writes a MemStats record body whose values count up from 1:
+// 24 named fields, 256 PauseNs entries, and NumGC
+func writeMemStats(buf *bytes.Buffer) {
+	for i := 0; i < 24+256+1; i++ {
+		writeVarint(buf, uint64(i+1))
+	}
+}
+
+func TestParseMemStatsFull(t *testing.T) {
+	var buf bytes.Buffer
+	writeMemStats(&buf)
+	writeVarint(&buf, tagEOF) // must be left unread
+
+	p := recordParser(buf.Bytes())
+	ms, err := p.parseMemStatsFull()
+	if err != nil {
+		t.Fatalf("parseMemStatsFull() error = %v", err)
+	}
+
+	if ms.Alloc != 1 || ms.HeapObjects != 12 || ms.PauseTotalNs != 24 || ms.BySize != nil {
+		t.Errorf("parseMemStatsFull() = Alloc %d, HeapObjects %d, PauseTotalNs %d, BySize %v; want 1, 12, 24, nil",
+			ms.Alloc, ms.HeapObjects, ms.PauseTotalNs, ms.BySize)
+	}
+	if ms.PauseNs[0] != 25 || ms.PauseNs[255] != 280 || ms.NumGC != 281 {
+		t.Errorf("PauseNs[0], PauseNs[255], NumGC = %d, %d, %d; want 25, 280, 281", ms.PauseNs[0], ms.PauseNs[255], ms.NumGC)
+	}
+	if tag, err := p.readVarint(); err != nil || tag != tagEOF {
+		t.Errorf("record not consumed exactly: next = %d, %v", tag, err)
+	}
+
+	truncated := buf.Bytes()[:buf.Len()-2]
+	if _, err := recordParser(truncated).parseMemStatsFull(); err == nil {
+		t.Error("parseMemStatsFull(truncated) error = nil")
+	}
+}
+
+func TestParseDeferPanicFull(t *testing.T) {
+	var buf bytes.Buffer
+	// addr, gp, sp, pc, fn, fn entry, link
+	for _, v := range []uint64{0xd000, 0xa000, 0xc0ffee, 0x401000, 0xf000, 0x402000, 0xd100} {
+		writeVarint(&buf, v)
+	}
+	// addr, gp, type, data, defer, link
+	for _, v := range []uint64{0xe000, 0xa000, 0x5000, 0x5100, 0, 0xe100} {
+		writeVarint(&buf, v)
+	}
+	writeVarint(&buf, tagEOF) // must be left unread
+
+	p := recordParser(buf.Bytes())
+	d, err := p.parseDeferFull()
+	if err != nil {
+		t.Fatalf("parseDeferFull() error = %v", err)
+	}
+	wantDefer := &DeferRecord{Address: 0xd000, Gp: 0xa000, Argp: 0xc0ffee, PC: 0x401000, Fn: 0xf000, FnEntry: 0x402000, Link: 0xd100}
+	if !reflect.DeepEqual(d, wantDefer) {
+		t.Errorf("parseDeferFull() = %+v, want %+v", d, wantDefer)
+	}
+
+	pr, err := p.parsePanicFull()
+	if err != nil {
+		t.Fatalf("parsePanicFull() error = %v", err)
+	}
+	wantPanic := &PanicRecord{Address: 0xe000, Gp: 0xa000, Typ: 0x5000, Data: 0x5100, Link: 0xe100}
+	if !reflect.DeepEqual(pr, wantPanic) {
+		t.Errorf("parsePanicFull() = %+v, want %+v", pr, wantPanic)
+	}
+
+	if tag, err := p.readVarint(); err != nil || tag != tagEOF {
+		t.Errorf("records not consumed exactly: next = %d, %v", tag, err)
+	}
+}
@@ -0,0 +1,64 @@
+// ABOUTME: Resolves raw pointer addresses in parsed objects to object IDs
+// ABOUTME: Shards the work across GOMAXPROCS workers for large dumps
+
+package goheap
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/prateek/heaplens/graph"
+)
+
+// parallelResolveThreshold is the object count above which pointer
+// resolution is spread across workers. Below it, goroutine startup costs
+// more than the lookups themselves.
+const parallelResolveThreshold = 100_000
+
+// resolvePointers fills in every object's Ptrs from its raw addresses.
+// Pointers that do not land on the start of a known object are dropped.
+func (p *parser) resolvePointers() {
+	workers := runtime.GOMAXPROCS(0)
+	if len(p.objects) < parallelResolveThreshold || workers < 2 {
+		p.resolveRange(0, len(p.objects))
+	} else {
+		p.resolveParallel(workers)
+	}
+	p.rawPtrs = nil
+}
+
+// resolveParallel shards objects into contiguous ranges, one per worker.
+// addrToObjID is only read here and each worker writes only its own
+// objects, so no locking is needed.
+func (p *parser) resolveParallel(workers int) {
+	chunk := (len(p.objects) + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for start := 0; start < len(p.objects); start += chunk {
+		end := min(start+chunk, len(p.objects))
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			p.resolveRange(start, end)
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+// resolveRange resolves the pointers of objects[start:end]
+func (p *parser) resolveRange(start, end int) {
+	for i := start; i < end; i++ {
+		raw := p.rawPtrs[i]
+		if len(raw) == 0 {
+			continue
+		}
+
+		ptrs := make([]graph.ObjID, 0, len(raw))
+		for _, addr := range raw {
+			if objID, ok := p.addrToObjID[addr]; ok {
+				ptrs = append(ptrs, objID)
+			}
+		}
+		p.objects[i].Ptrs = ptrs
+	}
+}
@@ -0,0 +1,92 @@
+// ABOUTME: Tests and benchmarks for pointer resolution after parsing
+// ABOUTME: Checks the parallel resolver matches the serial one
+
+package goheap
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/prateek/heaplens/graph"
+)
+
+// newResolveState builds parser state as parseObject leaves it: n objects
+// at 16-byte aligned addresses, each with a few raw pointers. Some
+// pointers miss every object and must be dropped.
+func newResolveState(n int) *parser {
+	rng := rand.New(rand.NewSource(int64(n)))
+	p := &parser{addrToObjID: make(map[uint64]graph.ObjID, n)}
+	for i := 0; i < n; i++ {
+		id := graph.ObjID(i + 1)
+		p.addrToObjID[0x10000+uint64(i)*16] = id
+		p.objects = append(p.objects, &graph.Object{ID: id})
+
+		raw := make([]uint64, rng.Intn(5))
+		for j := range raw {
+			raw[j] = 0x10000 + uint64(rng.Intn(n+n/10))*16
+		}
+		p.rawPtrs = append(p.rawPtrs, raw)
+	}
+	return p
+}
+
+func resolvedPtrs(p *parser) [][]graph.ObjID {
+	ptrs := make([][]graph.ObjID, len(p.objects))
+	for i, obj := range p.objects {
+		ptrs[i] = obj.Ptrs
+	}
+	return ptrs
+}
+
+func TestResolveParallelMatchesSerial(t *testing.T) {
+	const n = 10000
+
+	serial := newResolveState(n)
+	serial.resolveRange(0, n)
+
+	for _, workers := range []int{2, 3, 8} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			parallel := newResolveState(n)
+			parallel.resolveParallel(workers)
+
+			if !reflect.DeepEqual(resolvedPtrs(parallel), resolvedPtrs(serial)) {
+				t.Error("parallel resolution differs from serial")
+			}
+		})
+	}
+
+	// Every resolved pointer must name an existing object
+	for _, obj := range serial.objects {
+		for _, ptr := range obj.Ptrs {
+			if ptr < 1 || ptr > n {
+				t.Fatalf("object %d has unresolved pointer %d", obj.ID, ptr)
+			}
+		}
+	}
+}
+
+// BenchmarkResolvePointers compares serial and parallel resolution on a
+// 1M-object dump. The speedup scales with available cores.
+func BenchmarkResolvePointers(b *testing.B) {
+	const n = 1_000_000
+
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			p := newResolveState(n)
+			b.StartTimer()
+			p.resolveRange(0, n)
+		}
+	})
+
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			p := newResolveState(n)
+			b.StartTimer()
+			p.resolvePointers()
+		}
+	})
+}
@@ -0,0 +1,30 @@
+// ABOUTME: Rounds object payload sizes up to the runtime's allocation size classes
+// ABOUTME: Uses the dump's MemStats size classes when present, else the Go defaults
+
+package goheap
+
+import (
+	"sort"
+
+	"github.com/prateek/heaplens/heapdump/sizeclass"
+)
+
+// sizeClasses returns the sorted, non-zero class sizes from stats, or the
+// runtime defaults if stats has none
+func sizeClasses(stats *MemStatsFull) []uint64 {
+	if stats == nil || len(stats.BySize) == 0 {
+		return sizeclass.DefaultClasses
+	}
+
+	classes := make([]uint64, 0, len(stats.BySize))
+	for _, sc := range stats.BySize {
+		if sc.Size > 0 {
+			classes = append(classes, sc.Size)
+		}
+	}
+	if len(classes) == 0 {
+		return sizeclass.DefaultClasses
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i] < classes[j] })
+	return classes
+}
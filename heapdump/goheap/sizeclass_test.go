@@ -0,0 +1,79 @@
+// ABOUTME: Tests for rounding object sizes up to allocation size classes
+// ABOUTME: Covers default classes, dump-provided classes, and large objects
+
+package goheap
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/prateek/heaplens/heapdump/sizeclass"
+)
+
+func TestAllocSize(t *testing.T) {
+	defaultSizeClasses := sizeclass.DefaultClasses
+	custom := sizeClasses(&MemStatsFull{BySize: []SizeClassStat{{Size: 0}, {Size: 64}, {Size: 32}}})
+
+	tests := []struct {
+		name    string
+		size    uint64
+		classes []uint64
+		want    uint64
+	}{
+		{"zero", 0, defaultSizeClasses, 0},
+		{"exact class", 16, defaultSizeClasses, 16},
+		{"rounded up", 17, defaultSizeClasses, 24},
+		{"largest class", 32768, defaultSizeClasses, 32768},
+		{"large object", 32769, defaultSizeClasses, 40960},
+		{"dump classes", 17, custom, 32},
+		{"past dump classes", 65, custom, 8192},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sizeclass.Round(tt.size, tt.classes); got != tt.want {
+				t.Errorf("Round(%d) = %d, want %d", tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSetsAllocSize(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x3000)
+	writeString(&buf, "amd64")
+	writeString(&buf, "go1.20.0")
+	writeVarint(&buf, 4)
+
+	writeVarint(&buf, tagObject)
+	writeVarint(&buf, 0x2000)
+	writeBytes(&buf, make([]byte, 17))
+	writeVarint(&buf, fieldKindEol)
+
+	writeVarint(&buf, tagEOF)
+
+	dump := buf.Bytes()
+
+	g, err := (&GoHeapParser{}).Parse(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	obj := g.GetObject(1)
+	if obj.Size != 17 || obj.AllocSize != 24 {
+		t.Errorf("Size, AllocSize = %d, %d, want 17, 24", obj.Size, obj.AllocSize)
+	}
+
+	g, err = (&GoHeapParser{RoundSizes: true}).Parse(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("Parse() with RoundSizes error = %v", err)
+	}
+	obj = g.GetObject(1)
+	if obj.Size != 24 || obj.AllocSize != 24 {
+		t.Errorf("with RoundSizes, Size, AllocSize = %d, %d, want 24, 24", obj.Size, obj.AllocSize)
+	}
+}
@@ -10,13 +10,24 @@ import (
 	"io"
 	"sync/atomic"
 	"time"
+
+	"github.com/prateek/heaplens/graph"
 )
 
 // StreamingParser provides a memory-efficient streaming API for parsing large dumps
 type StreamingParser struct {
+	// MaxStringLen and MaxBytesLen cap string and byte slice lengths.
+	// Zero means the package default.
+	MaxStringLen uint64
+	MaxBytesLen  uint64
+
+	// CheckpointInterval is the number of records between OnCheckpoint
+	// calls. Zero means DefaultCheckpointInterval.
+	CheckpointInterval int64
+
 	r           *bufio.Reader
+	counter     *countingReader
 	callbacks   StreamCallbacks
-	progress    atomic.Uint64
 	recordCount atomic.Int64
 	startTime   time.Time
 
@@ -27,8 +38,41 @@ type StreamingParser struct {
 
 	// Dump parameters
 	params DumpParams
+
+	// Type names by address, for ResolveType
+	typeNames map[uint64]string
+
+	// What type and itab records say about interface fields' data words
+	iface ifaceTypes
+
+	// Two-pass pointer resolution; rs is nil unless the reader can seek.
+	// As in GoHeapParser, every object record gets the next ID, and a
+	// repeated address maps to the last object recorded at it. lastID is
+	// the ID of the latest object record in the current pass.
+	rs       io.ReadSeeker
+	indexing bool
+	addrToID map[uint64]graph.ObjID
+	lastID   graph.ObjID
+
+	lastCheckpoint int64
+
+	// sawEOF is set once the EOF record is read, telling a complete dump
+	// from one that stops cleanly between records
+	sawEOF bool
+
+	// Time between OnProgress calls; zero means DefaultStreamProgressInterval
+	progressInterval time.Duration
+
+	// Byte offset for OnProgress, which runs on another goroutine. The
+	// parsing goroutine stores it between records rather than paying for
+	// an atomic on every read.
+	progress atomic.Uint64
 }
 
+// DefaultStreamProgressInterval is how often a StreamingParser calls
+// OnProgress unless SetProgressInterval says otherwise
+const DefaultStreamProgressInterval = 500 * time.Millisecond
+
 // DumpParams contains heap dump parameters
 type DumpParams struct {
 	BigEndian   bool
@@ -48,26 +92,52 @@ type StreamCallbacks struct {
 	// OnType is called for each type record
 	OnType func(addr uint64, size uint64, name string, indirect bool) error
 
-	// OnObject is called for each object
+	// OnObject is called for each object. The dump may describe typeAddr
+	// in a type record that comes later, so the name is not always known
+	// yet; count by typeAddr and call ResolveType once Parse returns.
+	// ptrs includes interface data words as GoHeapParser counts them.
 	OnObject func(addr uint64, typeAddr uint64, data []byte, ptrs []uint64) error
 
+	// OnResolvedObject is called for each object with pointers resolved to
+	// object IDs. IDs start at 1 in record order, matching GoHeapParser.
+	// Setting it requires a parser from NewSeekingStreamingParser.
+	OnResolvedObject func(id graph.ObjID, addr uint64, typeAddr uint64, data []byte, ptrs []graph.ObjID) error
+
 	// OnRoot is called for each GC root
 	OnRoot func(desc string, ptr uint64) error
 
 	// OnGoroutine is called for each goroutine
 	OnGoroutine func(id uint64, status uint64, waitReason string) error
 
-	// OnProgress is called periodically with progress updates
+	// OnFinalizer is called for each finalizer and queued finalizer record
+	OnFinalizer func(f *Finalizer) error
+
+	// OnMemStats is called with the dump's memory statistics
+	OnMemStats func(stats *MemStatsFull) error
+
+	// OnCheckpoint is called at record boundaries every CheckpointInterval
+	// records. Pass the checkpoint to ResumeAt to continue from there.
+	OnCheckpoint func(cp Checkpoint) error
+
+	// OnProgress is called once when parsing starts, every progress
+	// interval while it runs, and once when it returns. Calls come from a
+	// background goroutine but never overlap, and none happen after Parse
+	// or ResumeAt returns.
 	OnProgress func(bytesRead int64, recordsProcessed int64, elapsed time.Duration)
 
-	// OnError is called on recoverable errors
+	// OnError is called on recoverable errors. Problems that don't stop
+	// the record from being read, such as ErrPointerOutOfRange, are
+	// reported with canRecover true and parsing continues unless OnError
+	// returns an error.
 	OnError func(err error, canRecover bool) error
 }
 
 // NewStreamingParser creates a new streaming parser
 func NewStreamingParser(r io.Reader, callbacks StreamCallbacks) *StreamingParser {
+	counter := &countingReader{r: r}
 	return &StreamingParser{
-		r:           bufio.NewReaderSize(r, 4*1024*1024), // 4MB buffer
+		r:           bufio.NewReaderSize(counter, 4*1024*1024), // 4MB buffer
+		counter:     counter,
 		callbacks:   callbacks,
 		maxErrors:   100,
 		skipOnError: true,
@@ -75,59 +145,144 @@ func NewStreamingParser(r io.Reader, callbacks StreamCallbacks) *StreamingParser
 	}
 }
 
+// NewSeekingStreamingParser creates a streaming parser that can make two
+// passes over rs: the first builds the address to ID map, the second
+// delivers resolved pointers to OnResolvedObject.
+func NewSeekingStreamingParser(rs io.ReadSeeker, callbacks StreamCallbacks) *StreamingParser {
+	p := NewStreamingParser(rs, callbacks)
+	p.rs = rs
+	return p
+}
+
 // SetErrorRecovery configures error recovery behavior
 func (p *StreamingParser) SetErrorRecovery(maxErrors int, skipOnError bool) {
 	p.maxErrors = maxErrors
 	p.skipOnError = skipOnError
 }
 
+// SetProgressInterval sets how often OnProgress is called while parsing.
+// d <= 0 restores DefaultStreamProgressInterval.
+func (p *StreamingParser) SetProgressInterval(d time.Duration) {
+	p.progressInterval = d
+}
+
 // Parse performs streaming parse with callbacks
 func (p *StreamingParser) Parse() error {
-	// Read and verify header
-	header := make([]byte, 16)
-	if _, err := io.ReadFull(p.r, header); err != nil {
-		return fmt.Errorf("reading header: %w", err)
+	if p.callbacks.OnResolvedObject != nil {
+		if p.rs == nil {
+			return fmt.Errorf("resolving pointers requires a seekable reader; use NewSeekingStreamingParser")
+		}
+		if err := p.indexObjects(); err != nil {
+			return fmt.Errorf("indexing objects: %w", markTruncated(err))
+		}
 	}
-	if string(header) != "go1.7 heap dump\n" {
-		return fmt.Errorf("invalid header: %q", header)
+
+	if err := p.readHeader(); err != nil {
+		return markTruncated(err)
 	}
+	return p.run()
+}
 
-	p.progress.Add(16)
-	progressTicker := time.NewTicker(10 * time.Millisecond) // More frequent updates for testing
-	defer progressTicker.Stop()
+// run reads records to the end of the dump, reporting progress
+func (p *StreamingParser) run() error {
+	if p.callbacks.OnProgress == nil {
+		return markTruncated(p.readRecords())
+	}
 
-	// Start progress reporting goroutine
-	done := make(chan struct{})
-	defer close(done)
-	
-	// Send an initial progress update immediately
-	if p.callbacks.OnProgress != nil {
-		p.callbacks.OnProgress(
-			int64(p.progress.Load()),
-			p.recordCount.Load(),
-			time.Since(p.startTime),
-		)
+	p.reportProgress()
+	stop := p.startProgress()
+	err := p.readRecords()
+	stop()
+	p.syncProgress()
+	p.reportProgress()
+	return markTruncated(err)
+}
+
+// startProgress calls OnProgress every progress interval from a background
+// goroutine. The returned stop function waits for that goroutine to exit,
+// so no progress callback runs once it returns.
+func (p *StreamingParser) startProgress() (stop func()) {
+	interval := p.progressInterval
+	if interval <= 0 {
+		interval = DefaultStreamProgressInterval
 	}
-	
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+
 	go func() {
+		defer close(exited)
 		for {
 			select {
-			case <-progressTicker.C:
-				if p.callbacks.OnProgress != nil {
-					p.callbacks.OnProgress(
-						int64(p.progress.Load()),
-						p.recordCount.Load(),
-						time.Since(p.startTime),
-					)
-				}
+			case <-ticker.C:
+				p.reportProgress()
 			case <-done:
 				return
 			}
 		}
 	}()
 
-	// Read records
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-exited
+	}
+}
+
+// readHeader reads and verifies the dump header
+func (p *StreamingParser) readHeader() error {
+	if err := readHeader(p.r); err != nil {
+		return err
+	}
+	p.syncProgress()
+	return nil
+}
+
+// indexObjects makes the first of two passes: it assigns object IDs by
+// address, seeking past object payloads, then rewinds for the second pass.
+// No callbacks fire during this pass.
+func (p *StreamingParser) indexObjects() error {
+	start, err := p.rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	callbacks := p.callbacks
+	p.callbacks = StreamCallbacks{}
+	p.indexing = true
+	p.addrToID = make(map[uint64]graph.ObjID)
+
+	err = p.readHeader()
+	if err == nil {
+		err = p.readRecords()
+	}
+
+	p.callbacks = callbacks
+	p.indexing = false
+	p.lastID = 0
+	p.progress.Store(0)
+	p.recordCount.Store(0)
+	p.errorCount = 0
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.rs.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	p.counter.n = 0
+	p.r.Reset(p.counter)
+	return nil
+}
+
+// readRecords reads records until EOF
+func (p *StreamingParser) readRecords() error {
 	for {
+		if err := p.maybeCheckpoint(); err != nil {
+			return err
+		}
+		p.syncProgress()
+
 		tag, err := p.readVarint()
 		if err != nil {
 			if err == io.EOF {
@@ -143,6 +298,7 @@ func (p *StreamingParser) Parse() error {
 
 		switch tag {
 		case tagEOF:
+			p.sawEOF = true
 			return nil
 
 		case tagParams:
@@ -150,6 +306,9 @@ func (p *StreamingParser) Parse() error {
 				// Params are critical - can't skip them
 				return fmt.Errorf("parsing params: %w", err)
 			}
+			if err := p.params.Compatibility().Err(); err != nil {
+				return err
+			}
 
 		case tagType:
 			if err := p.parseType(); err != nil {
@@ -183,6 +342,34 @@ func (p *StreamingParser) Parse() error {
 				}
 			}
 
+		case tagMemStats:
+			if err := p.parseMemStats(); err != nil {
+				if !p.handleError(fmt.Errorf("parsing memstats: %w", err)) {
+					return err
+				}
+			}
+
+		case tagFinalizer, tagQueuedFinalizer:
+			if err := p.parseFinalizer(tag == tagQueuedFinalizer); err != nil {
+				if !p.handleError(fmt.Errorf("parsing finalizer: %w", err)) {
+					return err
+				}
+			}
+
+		case tagItab:
+			if err := p.parseItab(); err != nil {
+				if !p.handleError(fmt.Errorf("parsing itab: %w", err)) {
+					return err
+				}
+			}
+
+		case tagStackFrame, tagData, tagBSS, tagOSThread, tagDefer, tagPanic, tagMemProf, tagAllocSample:
+			if err := p.skipRecord(tag); err != nil {
+				if !p.handleError(fmt.Errorf("skipping record %d: %w", tag, err)) {
+					return err
+				}
+			}
+
 		default:
 			// Try to skip unknown records
 			if err := p.skipUnknown(tag); err != nil {
@@ -191,9 +378,25 @@ func (p *StreamingParser) Parse() error {
 				}
 			}
 		}
+
+		if err := checkNextTag(p.r, tag); err != nil {
+			if !p.handleError(err) {
+				return err
+			}
+		}
 	}
 
-	// Final progress update
+	return nil
+}
+
+// syncProgress publishes the parse position to reportProgress. Only the
+// parsing goroutine may call it.
+func (p *StreamingParser) syncProgress() {
+	p.progress.Store(uint64(p.offset()))
+}
+
+// reportProgress sends a progress update
+func (p *StreamingParser) reportProgress() {
 	if p.callbacks.OnProgress != nil {
 		p.callbacks.OnProgress(
 			int64(p.progress.Load()),
@@ -201,8 +404,15 @@ func (p *StreamingParser) Parse() error {
 			time.Since(p.startTime),
 		)
 	}
+}
 
-	return nil
+// warn reports a problem that doesn't stop the current record from being
+// read, returning OnError's result
+func (p *StreamingParser) warn(err error) error {
+	if p.callbacks.OnError == nil {
+		return nil
+	}
+	return p.callbacks.OnError(err, true)
 }
 
 // handleError handles recoverable errors
@@ -250,6 +460,12 @@ func (p *StreamingParser) seekToNextRecord() {
 	}
 }
 
+// skipRecord reads past a known record that has no callback, using the
+// buffered parser's layout for it so the stream stays aligned
+func (p *StreamingParser) skipRecord(tag uint64) error {
+	return p.recordParser().skipRecord(tag)
+}
+
 // skipUnknown attempts to skip an unknown record type
 func (p *StreamingParser) skipUnknown(tag uint64) error {
 	// For unknown tags, try to skip a reasonable amount of data
@@ -265,7 +481,7 @@ func (p *StreamingParser) skipUnknown(tag uint64) error {
 		}
 	default:
 		// Completely unknown tag - this is an error
-		return fmt.Errorf("unknown tag: %d", tag)
+		return ErrUnknownTag{Tag: tag}
 	}
 	return nil
 }
@@ -284,6 +500,9 @@ func (p *StreamingParser) parseParams() error {
 	if err != nil {
 		return err
 	}
+	if err := checkPointerSize(p.params.PointerSize); err != nil {
+		return err
+	}
 
 	p.params.HeapStart, err = p.readVarint()
 	if err != nil {
@@ -340,6 +559,12 @@ func (p *StreamingParser) parseType() error {
 		return err
 	}
 
+	if p.typeNames == nil {
+		p.typeNames = make(map[uint64]string)
+	}
+	p.typeNames[addr] = name
+	p.iface.addType(addr, indirect != 0)
+
 	if p.callbacks.OnType != nil {
 		return p.callbacks.OnType(addr, size, name, indirect != 0)
 	}
@@ -347,6 +572,32 @@ func (p *StreamingParser) parseType() error {
 	return nil
 }
 
+// ResolveType returns the name of the type at typeAddr, or "unknown", as
+// GoHeapParser names objects, if no type record for it has been read.
+// During parsing only earlier records are known; once Parse or ResumeAt
+// returns every type in the dump is.
+func (p *StreamingParser) ResolveType(typeAddr uint64) string {
+	if name, ok := p.typeNames[typeAddr]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// parseItab records which concrete type an itab holds, to tell which
+// interface fields hold pointers
+func (p *StreamingParser) parseItab() error {
+	itab, err := p.readVarint()
+	if err != nil {
+		return err
+	}
+	typ, err := p.readVarint()
+	if err != nil {
+		return err
+	}
+	p.iface.addItab(itab, typ)
+	return nil
+}
+
 // parseObject parses an object record and calls callback
 func (p *StreamingParser) parseObject() error {
 	addr, err := p.readVarint()
@@ -354,32 +605,20 @@ func (p *StreamingParser) parseObject() error {
 		return err
 	}
 
+	if p.indexing {
+		return p.indexObject(addr)
+	}
+
 	data, err := p.readBytes()
 	if err != nil {
 		return err
 	}
 
 	// Extract type address from data
-	var typeAddr uint64
-	if len(data) >= int(p.params.PointerSize) {
-		typeAddrData := data[:p.params.PointerSize]
-		if p.params.PointerSize == 8 {
-			if p.params.BigEndian {
-				typeAddr = binary.BigEndian.Uint64(typeAddrData)
-			} else {
-				typeAddr = binary.LittleEndian.Uint64(typeAddrData)
-			}
-		} else if p.params.PointerSize == 4 {
-			if p.params.BigEndian {
-				typeAddr = uint64(binary.BigEndian.Uint32(typeAddrData))
-			} else {
-				typeAddr = uint64(binary.LittleEndian.Uint32(typeAddrData))
-			}
-		}
-	}
+	typeAddr, _ := readPointer(data, 0, p.params.PointerSize, p.params.BigEndian)
 
 	// Parse fields to extract pointers
-	var pointers []uint64
+	var pointers, badOffsets []uint64
 	for {
 		kind, err := p.readVarint()
 		if err != nil {
@@ -395,117 +634,191 @@ func (p *StreamingParser) parseObject() error {
 		}
 
 		// Extract pointer value from data if it's a pointer field
-		if kind == fieldKindPtr && int(offset+p.params.PointerSize) <= len(data) {
-			ptrData := data[offset : offset+p.params.PointerSize]
-			var ptr uint64
-			if p.params.PointerSize == 8 {
-				if p.params.BigEndian {
-					ptr = binary.BigEndian.Uint64(ptrData)
-				} else {
-					ptr = binary.LittleEndian.Uint64(ptrData)
-				}
-			} else if p.params.PointerSize == 4 {
-				if p.params.BigEndian {
-					ptr = uint64(binary.BigEndian.Uint32(ptrData))
-				} else {
-					ptr = uint64(binary.LittleEndian.Uint32(ptrData))
-				}
-			}
-			if ptr != 0 {
-				pointers = append(pointers, ptr)
-			}
+		ptr, ok := p.iface.fieldPointer(data, kind, offset, p.params.PointerSize, p.params.BigEndian)
+		if !ok {
+			badOffsets = append(badOffsets, offset)
+		} else if ptr != 0 {
+			pointers = append(pointers, ptr)
+		}
+	}
+
+	// Reported once the whole record is read, so stopping leaves the
+	// reader at the next record
+	for _, offset := range badOffsets {
+		if err := p.warn(pointerOutOfRange(addr, offset, len(data))); err != nil {
+			return err
 		}
 	}
 
 	if p.callbacks.OnObject != nil {
-		return p.callbacks.OnObject(addr, typeAddr, data, pointers)
+		if err := p.callbacks.OnObject(addr, typeAddr, data, pointers); err != nil {
+			return err
+		}
+	}
+
+	if p.callbacks.OnResolvedObject != nil {
+		p.lastID++
+		resolved := make([]graph.ObjID, 0, len(pointers))
+		for _, ptr := range pointers {
+			if id, ok := p.addrToID[ptr]; ok {
+				resolved = append(resolved, id)
+			}
+		}
+		return p.callbacks.OnResolvedObject(p.lastID, addr, typeAddr, data, resolved)
 	}
 
 	return nil
 }
 
-// parseRoot parses a root record and calls callback
-func (p *StreamingParser) parseRoot() error {
-	desc, err := p.readString()
+// indexObject assigns the next ID to the object at addr and skips the rest
+// of the record
+func (p *StreamingParser) indexObject(addr uint64) error {
+	length, err := p.readVarint()
 	if err != nil {
 		return err
 	}
-
-	ptr, err := p.readVarint()
+	n, err := checkLength(length, limitOrDefault(p.MaxBytesLen, DefaultMaxBytesLen), ErrBytesTooLong)
 	if err != nil {
 		return err
 	}
+	if err := p.skipBytes(n); err != nil {
+		return err
+	}
 
-	if p.callbacks.OnRoot != nil {
-		return p.callbacks.OnRoot(desc, ptr)
+	for {
+		kind, err := p.readVarint()
+		if err != nil {
+			return err
+		}
+		if kind == fieldKindEol {
+			break
+		}
+		if _, err := p.readVarint(); err != nil {
+			return err
+		}
 	}
 
+	p.lastID++
+	p.addrToID[addr] = p.lastID
 	return nil
 }
 
-// parseGoroutine parses a goroutine record and calls callback
-func (p *StreamingParser) parseGoroutine() error {
-	// Skip address
-	if _, err := p.readVarint(); err != nil {
+// skipBytes advances past n bytes, seeking the underlying reader when
+// they are not already buffered
+func (p *StreamingParser) skipBytes(n int) error {
+	if n <= p.r.Buffered() || p.rs == nil {
+		_, err := p.r.Discard(n)
 		return err
 	}
 
-	// Skip stack pointer
-	if _, err := p.readVarint(); err != nil {
+	ahead := int64(n) - int64(p.r.Buffered())
+	if _, err := p.rs.Seek(ahead, io.SeekCurrent); err != nil {
 		return err
 	}
+	p.counter.n += ahead
+	p.r.Reset(p.counter)
+	return nil
+}
 
-	id, err := p.readVarint()
+// parseRoot parses a root record and calls callback
+func (p *StreamingParser) parseRoot() error {
+	desc, err := p.readString()
 	if err != nil {
 		return err
 	}
 
-	status, err := p.readVarint()
+	ptr, err := p.readVarint()
 	if err != nil {
 		return err
 	}
 
-	// Skip is_system
-	if _, err := p.readVarint(); err != nil {
-		return err
+	if p.callbacks.OnRoot != nil {
+		return p.callbacks.OnRoot(desc, ptr)
 	}
 
-	// Skip is_background
-	if _, err := p.readVarint(); err != nil {
+	return nil
+}
+
+// parseGoroutine parses a goroutine record and calls callback
+func (p *StreamingParser) parseGoroutine() error {
+	// Share the record layout with the buffered parser
+	g, err := p.recordParser().parseGoroutineFull()
+	if err != nil {
 		return err
 	}
 
-	// Skip wait_since
-	if _, err := p.readVarint(); err != nil {
-		return err
+	if p.callbacks.OnGoroutine != nil {
+		return p.callbacks.OnGoroutine(g.ID, g.Status, g.WaitReason)
 	}
 
-	waitReason, err := p.readString()
+	return nil
+}
+
+// parseMemStats parses memory statistics and calls callback
+func (p *StreamingParser) parseMemStats() error {
+	// Share the record layout with the buffered parser
+	stats, err := p.recordParser().parseMemStatsFull()
 	if err != nil {
 		return err
 	}
 
-	// Skip remaining fields
-	for i := 0; i < 4; i++ {
-		if _, err := p.readVarint(); err != nil {
-			return err
-		}
+	if p.callbacks.OnMemStats != nil {
+		return p.callbacks.OnMemStats(stats)
 	}
 
-	if p.callbacks.OnGoroutine != nil {
-		return p.callbacks.OnGoroutine(id, status, waitReason)
+	return nil
+}
+
+// parseFinalizer parses a finalizer record and calls callback
+func (p *StreamingParser) parseFinalizer(queued bool) error {
+	f, err := p.recordParser().parseFinalizerFull()
+	if err != nil {
+		return err
+	}
+	f.Queued = queued
+
+	if p.callbacks.OnFinalizer != nil {
+		return p.callbacks.OnFinalizer(f)
 	}
 
 	return nil
 }
 
-// readVarint reads a variable-length integer
+// recordParser returns a buffered-parser view of the stream for reading
+// records whose layout is shared between the two parsers
+func (p *StreamingParser) recordParser() *parser {
+	return &parser{r: p.r, maxStringLen: p.MaxStringLen, maxBytesLen: p.MaxBytesLen}
+}
+
+// ReadFinalizers streams a dump and returns its finalizer records in dump
+// order. An object can appear in more than one, as when it has cleanups as
+// well as a finalizer.
+func ReadFinalizers(r io.Reader) ([]*Finalizer, error) {
+	var finalizers []*Finalizer
+	sp := NewStreamingParser(r, StreamCallbacks{
+		OnFinalizer: func(f *Finalizer) error {
+			finalizers = append(finalizers, f)
+			return nil
+		},
+	})
+	if err := sp.Parse(); err != nil {
+		return nil, err
+	}
+	return finalizers, nil
+}
+
+// readVarint reads a variable-length integer. It decodes straight from
+// the read buffer when the whole varint is there, which is almost always,
+// instead of going byte by byte.
 func (p *StreamingParser) readVarint() (uint64, error) {
-	v, err := binary.ReadUvarint(p.r)
-	if err == nil {
-		p.progress.Add(1) // Approximate
+	if n := p.r.Buffered(); n > 0 {
+		buf, _ := p.r.Peek(min(n, binary.MaxVarintLen64))
+		if v, k := binary.Uvarint(buf); k > 0 {
+			p.r.Discard(k)
+			return v, nil
+		}
 	}
-	return v, err
+	return binary.ReadUvarint(p.r)
 }
 
 // readString reads a length-prefixed string
@@ -514,14 +827,13 @@ func (p *StreamingParser) readString() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if length > 1<<20 { // Sanity check: 1MB max string
-		return "", fmt.Errorf("string too long: %d", length)
+	n, err := checkLength(length, limitOrDefault(p.MaxStringLen, DefaultMaxStringLen), ErrStringTooLong)
+	if err != nil {
+		return "", err
 	}
 
-	data := make([]byte, length)
-	n, err := io.ReadFull(p.r, data)
-	p.progress.Add(uint64(n))
-	if err != nil {
+	data := make([]byte, n)
+	if _, err := io.ReadFull(p.r, data); err != nil {
 		return "", err
 	}
 	return string(data), nil
@@ -533,14 +845,13 @@ func (p *StreamingParser) readBytes() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if length > 1<<30 { // Sanity check: 1GB max
-		return nil, fmt.Errorf("byte slice too long: %d", length)
+	n, err := checkLength(length, limitOrDefault(p.MaxBytesLen, DefaultMaxBytesLen), ErrBytesTooLong)
+	if err != nil {
+		return nil, err
 	}
 
-	data := make([]byte, length)
-	n, err := io.ReadFull(p.r, data)
-	p.progress.Add(uint64(n))
-	if err != nil {
+	data := make([]byte, n)
+	if _, err := io.ReadFull(p.r, data); err != nil {
 		return nil, err
 	}
 	return data, nil
@@ -7,10 +7,15 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"io"
+	"reflect"
+	"runtime"
 	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/prateek/heaplens/graph"
 )
 
 // TestStreamingParseBasic tests basic streaming parse functionality
@@ -151,11 +156,16 @@ func TestStreamingProgress(t *testing.T) {
 		},
 	}
 
+	size := int64(buf.Len())
 	parser := NewStreamingParser(&buf, callbacks)
+	parser.SetProgressInterval(time.Millisecond)
 	err := parser.Parse()
 	if err != nil {
 		t.Fatalf("Parse() error = %v", err)
 	}
+	if lastBytesRead != size {
+		t.Errorf("final progress = %d bytes, want the whole %d byte dump", lastBytesRead, size)
+	}
 
 	// The final progress update should always be called
 	// so we should have at least one update 
@@ -166,6 +176,41 @@ func TestStreamingProgress(t *testing.T) {
 	t.Logf("Received %d progress updates", progressCalls.Load())
 }
 
+// TestStreamingProgressStopsWithParse checks that the progress goroutine
+// is gone once Parse returns, including when it returns early
+func TestStreamingProgressStopsWithParse(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	var calls atomic.Int32
+	objects := 0
+	parser := NewStreamingParser(bytes.NewReader(chainDump(10000)), StreamCallbacks{
+		OnObject: func(addr, typeAddr uint64, data []byte, ptrs []uint64) error {
+			objects++
+			if objects == 5000 {
+				return errors.New("stop")
+			}
+			return nil
+		},
+		OnProgress: func(bytesRead, records int64, elapsed time.Duration) {
+			calls.Add(1)
+		},
+	})
+	parser.SetProgressInterval(time.Microsecond)
+	parser.SetErrorRecovery(0, false)
+	if err := parser.Parse(); err == nil {
+		t.Fatal("Parse() should fail when OnObject does")
+	}
+
+	after := calls.Load()
+	time.Sleep(10 * time.Millisecond)
+	if got := calls.Load(); got != after {
+		t.Errorf("OnProgress called %d times after Parse returned", got-after)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines running after Parse, want %d", n, before)
+	}
+}
+
 // TestStreamingErrorRecovery tests error recovery mechanisms
 func TestStreamingErrorRecovery(t *testing.T) {
 	var buf bytes.Buffer
@@ -488,6 +533,7 @@ func TestStreamingGoroutines(t *testing.T) {
 	writeVarint(&buf, 0x5000) // address
 	writeVarint(&buf, 0x5100) // stack pointer
 	writeVarint(&buf, 1)      // ID
+	writeVarint(&buf, 0x4010) // creator PC
 	writeVarint(&buf, 2)      // status (running)
 	writeVarint(&buf, 0)      // not system
 	writeVarint(&buf, 0)      // not background
@@ -525,6 +571,42 @@ func TestStreamingGoroutines(t *testing.T) {
 	}
 }
 
+// TestStreamingMemStats tests that MemStats records reach the callback
+func TestStreamingMemStats(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+
+	writeVarint(&buf, tagMemStats)
+	writeVarint(&buf, 1000) // Alloc
+	writeVarint(&buf, 5000) // TotalAlloc
+	writeVarint(&buf, 8000) // Sys
+	for i := 3; i < 24+256+1; i++ {
+		writeVarint(&buf, uint64(i))
+	}
+	writeVarint(&buf, tagEOF)
+
+	var stats *MemStatsFull
+	callbacks := StreamCallbacks{
+		OnMemStats: func(ms *MemStatsFull) error {
+			stats = ms
+			return nil
+		},
+	}
+
+	if err := NewStreamingParser(&buf, callbacks).Parse(); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if stats == nil {
+		t.Fatal("OnMemStats was not called")
+	}
+	if stats.Alloc != 1000 || stats.TotalAlloc != 5000 || stats.Sys != 8000 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if stats.HeapObjects != 11 {
+		t.Errorf("HeapObjects = %d, want 11", stats.HeapObjects)
+	}
+}
+
 // TestStreamingParsePerformance validates performance characteristics
 func TestStreamingParsePerformance(t *testing.T) {
 	// Create a 10MB dump
@@ -598,3 +680,167 @@ func TestStreamingParsePerformance(t *testing.T) {
 		t.Errorf("Expected %d objects, got %d", numObjects, objectCount)
 	}
 }
+
+// TestStreamingResolvedPointers tests the two-pass mode against the buffered parser
+func TestStreamingResolvedPointers(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0) // little endian
+	writeVarint(&buf, 8) // pointer size
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x4000)
+	writeString(&buf, "amd64")
+	writeString(&buf, "go1.20.0")
+	writeVarint(&buf, 4)
+
+	// Object 1 points forward to object 3, past a payload larger than the
+	// read buffer so the first pass has to seek
+	writeVarint(&buf, tagObject)
+	writeVarint(&buf, 0x2000)
+	obj1Data := make([]byte, 16)
+	binary.LittleEndian.PutUint64(obj1Data[8:], 0x3000)
+	writeBytes(&buf, obj1Data)
+	writeVarint(&buf, fieldKindPtr)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, fieldKindEol)
+
+	writeVarint(&buf, tagObject)
+	writeVarint(&buf, 0x2800)
+	writeBytes(&buf, make([]byte, 5<<20))
+	writeVarint(&buf, fieldKindEol)
+
+	writeVarint(&buf, tagObject)
+	writeVarint(&buf, 0x3000)
+	obj3Data := make([]byte, 16)
+	binary.LittleEndian.PutUint64(obj3Data[8:], 0x2000)
+	writeBytes(&buf, obj3Data)
+	writeVarint(&buf, fieldKindPtr)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, fieldKindEol)
+
+	// A second record at object 1's address gets its own ID, and pointers
+	// to the address resolve to it, as in GoHeapParser
+	writeVarint(&buf, tagObject)
+	writeVarint(&buf, 0x2000)
+	writeBytes(&buf, make([]byte, 16))
+	writeVarint(&buf, fieldKindEol)
+
+	writeVarint(&buf, tagEOF)
+	dump := buf.Bytes()
+
+	got := make(map[graph.ObjID][]graph.ObjID)
+	var rawCalls int
+	callbacks := StreamCallbacks{
+		OnObject: func(addr, typeAddr uint64, data []byte, ptrs []uint64) error {
+			rawCalls++
+			return nil
+		},
+		OnResolvedObject: func(id graph.ObjID, addr, typeAddr uint64, data []byte, ptrs []graph.ObjID) error {
+			got[id] = ptrs
+			return nil
+		},
+	}
+
+	if err := NewSeekingStreamingParser(bytes.NewReader(dump), callbacks).Parse(); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if rawCalls != 4 {
+		t.Errorf("OnObject called %d times, want 4", rawCalls)
+	}
+
+	g, err := (&GoHeapParser{}).Parse(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("GoHeapParser.Parse() error = %v", err)
+	}
+	want := make(map[graph.ObjID][]graph.ObjID)
+	g.ForEachObject(func(obj *graph.Object) {
+		want[obj.ID] = append([]graph.ObjID{}, obj.Ptrs...)
+	})
+	if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(got[3], []graph.ObjID{4}) {
+		t.Errorf("resolved pointers = %v, want %v", got, want)
+	}
+
+	// Resolution without a seekable reader is an error
+	unseekable := struct{ io.Reader }{bytes.NewReader(dump)}
+	err = NewStreamingParser(unseekable, callbacks).Parse()
+	if err == nil || !strings.Contains(err.Error(), "seekable") {
+		t.Errorf("Parse() on unseekable reader error = %v, want seekable error", err)
+	}
+}
+
+// TestStreamingResolveType checks that objects typed by a later type
+// record can be named once the parse finishes, and after a resume
+func TestStreamingResolveType(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go1.7 heap dump\n")
+
+	writeVarint(&buf, tagParams)
+	writeVarint(&buf, 0)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, 0x1000)
+	writeVarint(&buf, 0x100000)
+	writeString(&buf, "amd64")
+	writeString(&buf, "go1.20.0")
+	writeVarint(&buf, 4)
+
+	writeType := func(addr uint64, name string) {
+		writeVarint(&buf, tagType)
+		writeVarint(&buf, addr)
+		writeVarint(&buf, 16)
+		writeString(&buf, name)
+		writeVarint(&buf, 0)
+	}
+	writeObject := func(addr, typeAddr uint64) {
+		writeVarint(&buf, tagObject)
+		writeVarint(&buf, addr)
+		data := make([]byte, 16)
+		binary.LittleEndian.PutUint64(data, typeAddr)
+		writeBytes(&buf, data)
+		writeVarint(&buf, fieldKindEol)
+	}
+	writeObject(0x2000, 0x600)
+	writeType(0x500, "main.Early")
+	writeObject(0x2100, 0x500)
+	writeObject(0x2200, 0x600)
+	writeType(0x600, "main.Late")
+	writeVarint(&buf, tagEOF)
+
+	var parser *StreamingParser
+	var during []string
+	counts := make(map[uint64]int)
+	parser = NewStreamingParser(&buf, StreamCallbacks{
+		OnObject: func(addr, typeAddr uint64, data []byte, ptrs []uint64) error {
+			during = append(during, parser.ResolveType(typeAddr))
+			counts[typeAddr]++
+			return nil
+		},
+	})
+	if err := parser.Parse(); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if want := []string{"unknown", "main.Early", "unknown"}; !reflect.DeepEqual(during, want) {
+		t.Errorf("ResolveType() while parsing = %v, want %v", during, want)
+	}
+	byName := make(map[string]int)
+	for typeAddr, n := range counts {
+		byName[parser.ResolveType(typeAddr)] += n
+	}
+	if want := map[string]int{"main.Early": 1, "main.Late": 2}; !reflect.DeepEqual(byName, want) {
+		t.Errorf("counts by ResolveType() after Parse = %v, want %v", byName, want)
+	}
+
+	var state bytes.Buffer
+	if err := parser.SaveState(&state); err != nil {
+		t.Fatalf("SaveState() error = %v", err)
+	}
+	resume := NewStreamingParser(bytes.NewReader(nil), StreamCallbacks{})
+	if err := resume.LoadState(&state); err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if got := resume.ResolveType(0x600); got != "main.Late" {
+		t.Errorf("ResolveType() after LoadState = %q, want main.Late", got)
+	}
+}
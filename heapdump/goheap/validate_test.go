@@ -0,0 +1,83 @@
+// ABOUTME: Tests for structural validation of Go heap dumps
+// ABOUTME: Checks counts on good dumps and where broken ones fail
+
+package goheap
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dump := chainDump(5)
+	report, err := (&GoHeapParser{}).Validate(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	want := ValidateReport{Records: 7, Objects: 5, Offset: int64(len(dump))}
+	if report != want {
+		t.Errorf("Validate() = %+v, want %+v", report, want)
+	}
+}
+
+func TestValidateSkipsUncalledRecords(t *testing.T) {
+	var buf bytes.Buffer
+	params := paramsOnlyDump("go1.20.0")
+	buf.Write(params[:len(params)-1]) // drop EOF
+	writeVarint(&buf, tagStackFrame)
+	for i := 0; i < 3; i++ {
+		writeVarint(&buf, 0x100)
+	}
+	writeBytes(&buf, make([]byte, 24))
+	for i := 0; i < 3; i++ {
+		writeVarint(&buf, 0x200)
+	}
+	writeString(&buf, "main.main")
+	writeVarint(&buf, fieldKindPtr)
+	writeVarint(&buf, 8)
+	writeVarint(&buf, fieldKindEol)
+	writeVarint(&buf, tagBSS)
+	writeVarint(&buf, 0x5000)
+	writeBytes(&buf, make([]byte, 16))
+	writeVarint(&buf, fieldKindEol)
+	writeVarint(&buf, tagEOF)
+
+	if _, err := (&GoHeapParser{}).Validate(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestValidateReportsWhereItBroke(t *testing.T) {
+	dump := chainDump(3)
+	noEOF := dump[:len(dump)-1]
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"bad header", []byte("not a heap dump!"), ErrInvalidHeader},
+		{"cut off mid-record", dump[:60], ErrTruncated},
+		{"no EOF record", noEOF, ErrTruncated},
+		{"garbage after a record", append(append([]byte{}, noEOF...), 99), ErrMisalignedRecord},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := (&GoHeapParser{}).Validate(bytes.NewReader(tt.data))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Validate() error = %v, want %v", err, tt.want)
+			}
+			if report.Offset > int64(len(tt.data)) {
+				t.Errorf("Offset = %d, past the end of %d bytes", report.Offset, len(tt.data))
+			}
+		})
+	}
+
+	// Garbage after the last object makes that object the broken record,
+	// so the offset points inside the dump at its start
+	report, _ := (&GoHeapParser{}).Validate(bytes.NewReader(append(noEOF[:len(noEOF):len(noEOF)], 99)))
+	if report.Objects != 3 || report.Offset <= 16 || report.Offset >= int64(len(noEOF)) {
+		t.Errorf("report = %+v, want 3 objects and an offset within the last record", report)
+	}
+}
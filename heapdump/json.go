@@ -1,26 +1,36 @@
-// ABOUTME: JSON stub parser for testing heap analysis algorithms
-// ABOUTME: Reads a simple JSON format with objects and roots
+// ABOUTME: JSON parser and writer for heap graphs
+// ABOUTME: Reads v1 and v2 dumps and writes v2, with a type table and root kinds
 
 package heapdump
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 
 	"github.com/prateek/heaplens/graph"
 )
 
-// JSONStub is a parser for JSON test dumps
+// JSONStub is a parser for JSON dumps. It reads both versions of the
+// format: v1, a bare list of objects and root IDs, and v2, which WriteJSON
+// writes.
 type JSONStub struct{}
 
-// jsonDump represents the JSON dump format
+// JSONVersion is the version of the JSON format WriteJSON writes. Version 2
+// adds a shared type table, object addresses and allocation details, and
+// roots with their kinds and descriptions.
+const JSONVersion = 2
+
+// jsonDump represents the v1 JSON dump format
 type jsonDump struct {
 	Objects []jsonObject   `json:"objects"`
 	Roots   []graph.ObjID  `json:"roots"`
 }
 
-// jsonObject represents an object in the JSON format
+// jsonObject represents an object in the v1 JSON format
 type jsonObject struct {
 	ID   graph.ObjID   `json:"id"`
 	Type string        `json:"type"`
@@ -28,6 +38,36 @@ type jsonObject struct {
 	Ptrs []graph.ObjID `json:"ptrs"`
 }
 
+// jsonDumpV2 represents the v2 JSON dump format. Version comes first so
+// CanParse can recognize a dump from its first bytes.
+type jsonDumpV2 struct {
+	Version int            `json:"version"`
+	Types   []string       `json:"types"`
+	Objects []jsonObjectV2 `json:"objects"`
+	Roots   []jsonRoot     `json:"roots"`
+}
+
+// jsonObjectV2 represents an object in the v2 JSON format. Type indexes
+// the dump's type table.
+type jsonObjectV2 struct {
+	ID        graph.ObjID   `json:"id"`
+	Type      int           `json:"type"`
+	Size      uint64        `json:"size"`
+	Addr      uint64        `json:"addr,omitempty"`
+	AllocSize uint64        `json:"alloc_size,omitempty"`
+	TypeSize  uint64        `json:"type_size,omitempty"`
+	Finalizer bool          `json:"finalizer,omitempty"`
+	Ptrs      []graph.ObjID `json:"ptrs"`
+}
+
+// jsonRoot represents a root in the v2 JSON format
+type jsonRoot struct {
+	ID        graph.ObjID `json:"id"`
+	Kind      string      `json:"kind"`
+	Desc      string      `json:"desc,omitempty"`
+	Goroutine uint64      `json:"goroutine,omitempty"`
+}
+
 // CanParse checks if the input looks like our JSON format
 func (p *JSONStub) CanParse(r io.Reader) bool {
 	// Read a small amount to check format
@@ -40,6 +80,12 @@ func (p *JSONStub) CanParse(r io.Reader) bool {
 	if n == 0 {
 		return false
 	}
+
+	// A v2 dump starts with its version, so it is recognized even when
+	// its type table runs past buf
+	if version, ok := leadingVersion(buf[:n]); ok {
+		return version >= 1 && version <= JSONVersion
+	}
 	
 	// Check if it has the expected structure
 	// We check for the presence of "objects" key in the JSON
@@ -56,15 +102,71 @@ func (p *JSONStub) CanParse(r io.Reader) bool {
 	return test.Objects != nil
 }
 
+// leadingVersion returns the value of "version" if it is the first key of
+// the JSON object in data
+func leadingVersion(data []byte) (int, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return 0, false
+	}
+	if key, err := dec.Token(); err != nil || key != "version" {
+		return 0, false
+	}
+	var version int
+	if err := dec.Decode(&version); err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
 // Parse reads the JSON dump and builds a graph
 func (p *JSONStub) Parse(r io.Reader) (graph.Graph, error) {
-	var dump jsonDump
-	
-	decoder := json.NewDecoder(r)
-	if err := decoder.Decode(&dump); err != nil {
+	var raw struct {
+		Version int             `json:"version"`
+		Types   []string        `json:"types"`
+		Objects json.RawMessage `json:"objects"`
+		Roots   json.RawMessage `json:"roots"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
 		return nil, fmt.Errorf("failed to decode JSON: %w", err)
 	}
-	
+
+	switch raw.Version {
+	case 0, 1:
+		var dump jsonDump
+		if err := unmarshalFields(&dump.Objects, raw.Objects, &dump.Roots, raw.Roots); err != nil {
+			return nil, err
+		}
+		return buildV1(dump)
+	case 2:
+		dump := jsonDumpV2{Version: raw.Version, Types: raw.Types}
+		if err := unmarshalFields(&dump.Objects, raw.Objects, &dump.Roots, raw.Roots); err != nil {
+			return nil, err
+		}
+		return buildV2(dump)
+	default:
+		return nil, fmt.Errorf("unsupported JSON dump version %d", raw.Version)
+	}
+}
+
+// unmarshalFields decodes the objects and roots of a dump, leaving missing
+// ones empty
+func unmarshalFields(objects any, rawObjects json.RawMessage, roots any, rawRoots json.RawMessage) error {
+	if rawObjects != nil {
+		if err := json.Unmarshal(rawObjects, objects); err != nil {
+			return fmt.Errorf("failed to decode JSON objects: %w", err)
+		}
+	}
+	if rawRoots != nil {
+		if err := json.Unmarshal(rawRoots, roots); err != nil {
+			return fmt.Errorf("failed to decode JSON roots: %w", err)
+		}
+	}
+	return nil
+}
+
+// buildV1 builds a graph from a v1 dump
+func buildV1(dump jsonDump) (graph.Graph, error) {
 	// Validate required fields
 	for i, obj := range dump.Objects {
 		if obj.ID == 0 {
@@ -98,6 +200,183 @@ func (p *JSONStub) Parse(r io.Reader) (graph.Graph, error) {
 	return g, nil
 }
 
+// buildV2 builds a graph from a v2 dump. Root kinds, descriptions, and
+// goroutines are only kept if some root has one.
+func buildV2(dump jsonDumpV2) (graph.Graph, error) {
+	g := graph.NewMemGraph()
+	for i, obj := range dump.Objects {
+		if obj.ID == 0 {
+			return nil, fmt.Errorf("object at index %d missing ID", i)
+		}
+		if obj.Type < 0 || obj.Type >= len(dump.Types) {
+			return nil, fmt.Errorf("object %d has type index %d outside the %d types", obj.ID, obj.Type, len(dump.Types))
+		}
+		ptrs := obj.Ptrs
+		if ptrs == nil {
+			ptrs = []graph.ObjID{}
+		}
+		g.AddObject(&graph.Object{
+			ID:   obj.ID,
+			Type: dump.Types[obj.Type],
+			Size: obj.Size,
+			Ptrs: ptrs,
+
+			HasFinalizer: obj.Finalizer,
+			Addr:         obj.Addr,
+			AllocSize:    obj.AllocSize,
+			TypeSize:     obj.TypeSize,
+		})
+	}
+
+	roots := graph.Roots{IDs: make([]graph.ObjID, len(dump.Roots))}
+	kinds := make([]graph.RootKind, len(dump.Roots))
+	descs := make([]string, len(dump.Roots))
+	goroutines := make([]uint64, len(dump.Roots))
+	var hasKinds, hasDescs, hasGoroutines bool
+	for i, root := range dump.Roots {
+		roots.IDs[i] = root.ID
+		kind, ok := graph.ParseRootKind(root.Kind)
+		if !ok && root.Kind != "" {
+			return nil, fmt.Errorf("root %d has unknown kind %q", root.ID, root.Kind)
+		}
+		kinds[i], descs[i], goroutines[i] = kind, root.Desc, root.Goroutine
+		hasKinds = hasKinds || kind != graph.RootOther
+		hasDescs = hasDescs || root.Desc != ""
+		hasGoroutines = hasGoroutines || root.Goroutine != 0
+	}
+	if hasKinds {
+		roots.Kinds = kinds
+	}
+	if hasDescs {
+		roots.Descs = descs
+	}
+	if hasGoroutines {
+		roots.Goroutines = goroutines
+	}
+	g.SetRoots(roots)
+
+	return g, nil
+}
+
+// jsonTypes returns the sorted type table of g and each type's index in it
+func jsonTypes(g graph.Graph) ([]string, map[string]int) {
+	index := make(map[string]int)
+	g.ForEachObject(func(obj *graph.Object) {
+		index[obj.Type] = 0
+	})
+	types := make([]string, 0, len(index))
+	for t := range index {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for i, t := range types {
+		index[t] = i
+	}
+	return types, index
+}
+
+// toJSONObject converts obj to its v2 form
+func toJSONObject(obj *graph.Object, typeIndex map[string]int) jsonObjectV2 {
+	ptrs := obj.Ptrs
+	if ptrs == nil {
+		ptrs = []graph.ObjID{}
+	}
+	return jsonObjectV2{
+		ID:        obj.ID,
+		Type:      typeIndex[obj.Type],
+		Size:      obj.Size,
+		Addr:      obj.Addr,
+		AllocSize: obj.AllocSize,
+		TypeSize:  obj.TypeSize,
+		Finalizer: obj.HasFinalizer,
+		Ptrs:      ptrs,
+	}
+}
+
+// jsonRoots converts g's roots to their v2 form
+func jsonRoots(g graph.Graph) []jsonRoot {
+	roots := g.GetRoots()
+	result := make([]jsonRoot, len(roots.IDs))
+	for i, id := range roots.IDs {
+		result[i] = jsonRoot{ID: id, Kind: roots.Kind(i).String(), Desc: roots.Desc(i), Goroutine: roots.Goroutine(i)}
+	}
+	return result
+}
+
+// WriteJSON writes g in the v2 JSON dump format read by JSONStub.
+// Objects are written in ascending ID order and types in name order so
+// output is reproducible.
+func WriteJSON(w io.Writer, g graph.Graph) error {
+	types, typeIndex := jsonTypes(g)
+	dump := jsonDumpV2{
+		Version: JSONVersion,
+		Types:   types,
+		Objects: make([]jsonObjectV2, 0, g.NumObjects()),
+		Roots:   jsonRoots(g),
+	}
+
+	graph.ForEachObjectSorted(g, func(obj *graph.Object) {
+		dump.Objects = append(dump.Objects, toJSONObject(obj, typeIndex))
+	})
+
+	if err := json.NewEncoder(w).Encode(dump); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return nil
+}
+
+// WriteJSONStream writes the same output as WriteJSON without building the
+// whole document in memory: objects are encoded one at a time, in the order
+// of graph.ForEachObjectSorted. Only the type table and the sorted object
+// list are held, or just the type table for a *graph.CompactGraph.
+func WriteJSONStream(w io.Writer, g graph.Graph) error {
+	types, typeIndex := jsonTypes(g)
+	header, err := json.Marshal(types)
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `{"version":%d,"types":`, JSONVersion)
+	bw.Write(header)
+	bw.WriteString(`,"objects":[`)
+
+	first := true
+	writeObject := func(obj *graph.Object) {
+		if err != nil {
+			return
+		}
+		var data []byte
+		data, err = json.Marshal(toJSONObject(obj, typeIndex))
+		if err != nil {
+			return
+		}
+		if !first {
+			bw.WriteByte(',')
+		}
+		first = false
+		bw.Write(data)
+	}
+
+	graph.ForEachObjectSorted(g, writeObject)
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	data, err := json.Marshal(jsonRoots(g))
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	bw.WriteString(`],"roots":`)
+	bw.Write(data)
+	bw.WriteString("}\n")
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+	return nil
+}
+
 // init registers the JSON parser
 func init() {
 	Register(&JSONStub{})
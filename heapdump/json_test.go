@@ -4,8 +4,15 @@
 package heapdump
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/prateek/heaplens/graph"
 )
 
 func TestJSONParse(t *testing.T) {
@@ -125,6 +132,25 @@ func TestMalformedJSON(t *testing.T) {
 	}
 }
 
+func TestJSONEmptyDumps(t *testing.T) {
+	parser := &JSONStub{}
+	for _, content := range []string{`{}`, `{"objects":[],"roots":[]}`, `{"version":2,"types":[],"objects":[],"roots":[]}`} {
+		g, err := parser.Parse(strings.NewReader(content))
+		if err != nil {
+			t.Errorf("Parse(%s) error = %v", content, err)
+			continue
+		}
+		if g.NumObjects() != 0 || len(g.GetRoots().IDs) != 0 {
+			t.Errorf("Parse(%s) = %d objects and %d roots, want an empty graph", content, g.NumObjects(), len(g.GetRoots().IDs))
+		}
+	}
+
+	// Empty input is not a JSON dump at all
+	if _, err := parser.Parse(strings.NewReader("")); err == nil {
+		t.Error("Parse(empty input) error = nil")
+	}
+}
+
 func TestJSONWithComplexGraph(t *testing.T) {
 	// Test with cycles and multiple roots
 	jsonData := `{
@@ -153,4 +179,182 @@ func TestJSONWithComplexGraph(t *testing.T) {
 	if len(roots.IDs) != 2 {
 		t.Errorf("Expected 2 roots, got %d", len(roots.IDs))
 	}
-}
\ No newline at end of file
+}
+
+func TestWriteJSONRoundTrip(t *testing.T) {
+	file, err := os.Open("../testdata/simple.json")
+	if err != nil {
+		t.Fatalf("Failed to open test file: %v", err)
+	}
+	defer file.Close()
+
+	parser := &JSONStub{}
+	original, err := parser.Parse(file)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, original); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	if !parser.CanParse(bytes.NewReader(buf.Bytes())) {
+		t.Fatalf("JSONStub cannot detect its own output:\n%s", buf.String())
+	}
+
+	reparsed, err := parser.Parse(&buf)
+	if err != nil {
+		t.Fatalf("Re-parse failed: %v", err)
+	}
+
+	if reparsed.NumObjects() != original.NumObjects() {
+		t.Fatalf("Object count = %d, want %d", reparsed.NumObjects(), original.NumObjects())
+	}
+	original.ForEachObject(func(want *graph.Object) {
+		got := reparsed.GetObject(want.ID)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Object %d = %+v, want %+v", want.ID, got, want)
+		}
+	})
+
+	if !reflect.DeepEqual(reparsed.GetRoots(), original.GetRoots()) {
+		t.Errorf("Roots = %v, want %v", reparsed.GetRoots(), original.GetRoots())
+	}
+}
+
+func TestWriteJSONEmptyGraph(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, graph.NewMemGraph()); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	want := `{"version":2,"types":[],"objects":[],"roots":[]}` + "\n"
+	if buf.String() != want {
+		t.Errorf("WriteJSON() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteJSONStreamMatchesWriteJSON(t *testing.T) {
+	g := graph.NewMemGraph()
+	g.AddObject(&graph.Object{ID: 3, Type: "<html>", Size: 8})
+	g.AddObject(&graph.Object{ID: 1, Type: "*Node", Size: 32, Ptrs: []graph.ObjID{2, 3}})
+	g.AddObject(&graph.Object{ID: 2, Type: "[]byte", Size: 100})
+	g.SetRoots(graph.Roots{IDs: []graph.ObjID{1}})
+
+	for name, dump := range map[string]graph.Graph{
+		"MemGraph":     g,
+		"CompactGraph": graph.Compact(g),
+		"empty":        graph.NewMemGraph(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var want, got bytes.Buffer
+			if err := WriteJSON(&want, dump); err != nil {
+				t.Fatalf("WriteJSON failed: %v", err)
+			}
+			if err := WriteJSONStream(&got, dump); err != nil {
+				t.Fatalf("WriteJSONStream failed: %v", err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("WriteJSONStream() = %q, want %q", got.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestExportsAreByteIdentical(t *testing.T) {
+	// Enough objects that map iteration order is certain to vary
+	g := graph.NewMemGraph()
+	for i := 1; i <= 500; i++ {
+		g.AddObject(&graph.Object{ID: graph.ObjID(i), Type: "node", Size: uint64(i), Ptrs: []graph.ObjID{graph.ObjID(i%500 + 1)}})
+	}
+	g.SetRoots(graph.Roots{IDs: []graph.ObjID{1}})
+
+	exports := map[string]func(io.Writer, graph.Graph) error{
+		"WriteJSON":             WriteJSON,
+		"WriteJSONStream":       WriteJSONStream,
+		"WriteDOT":              graph.WriteDOT,
+		"WriteTypeHistogramCSV": graph.WriteTypeHistogramCSV,
+	}
+	for name, export := range exports {
+		var first, second bytes.Buffer
+		if err := export(&first, g); err != nil {
+			t.Fatalf("%s error = %v", name, err)
+		}
+		if err := export(&second, g); err != nil {
+			t.Fatalf("%s error = %v", name, err)
+		}
+		if !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Errorf("%s output differs between runs", name)
+		}
+	}
+}
+
+func TestJSONV2RoundTrip(t *testing.T) {
+	g := graph.NewMemGraph()
+	g.AddObject(&graph.Object{ID: 1, Type: "*main.Server", Size: 64, Addr: 0xc000010000, AllocSize: 64, TypeSize: 64, Ptrs: []graph.ObjID{2}})
+	g.AddObject(&graph.Object{ID: 2, Type: "[]byte", Size: 100, AllocSize: 112, HasFinalizer: true, Ptrs: []graph.ObjID{}})
+	// Enough objects that the type table alone doesn't fit in CanParse's peek
+	for i := 3; i < 100; i++ {
+		g.AddObject(&graph.Object{ID: graph.ObjID(i), Type: fmt.Sprintf("main.Type%03d", i), Size: 8, Ptrs: []graph.ObjID{}})
+	}
+	g.SetRoots(graph.Roots{
+		IDs:        []graph.ObjID{1, 2, 3},
+		Kinds:      []graph.RootKind{graph.RootGlobal, graph.RootFinalizer, graph.RootStack},
+		Descs:      []string{"data", "", "main.worker"},
+		Goroutines: []uint64{0, 0, 7},
+	})
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, g); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), `{"version":2,"types":["*main.Server","[]byte",`) {
+		t.Errorf("WriteJSON() starts %.60q, want the version and sorted type table", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"roots":[{"id":1,"kind":"global","desc":"data"},{"id":2,"kind":"finalizer"},{"id":3,"kind":"stack","desc":"main.worker","goroutine":7}]`) {
+		t.Errorf("WriteJSON() roots missing kinds, descriptions, or goroutines")
+	}
+
+	parser := &JSONStub{}
+	if !parser.CanParse(bytes.NewReader(buf.Bytes())) {
+		t.Fatal("JSONStub cannot detect a v2 dump larger than its peek")
+	}
+	reparsed, err := parser.Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	g.ForEachObject(func(want *graph.Object) {
+		if got := reparsed.GetObject(want.ID); !reflect.DeepEqual(got, want) {
+			t.Errorf("Object %d = %+v, want %+v", want.ID, got, want)
+		}
+	})
+	if !reflect.DeepEqual(reparsed.GetRoots(), g.GetRoots()) {
+		t.Errorf("Roots = %+v, want %+v", reparsed.GetRoots(), g.GetRoots())
+	}
+}
+
+func TestJSONV2Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"type index out of range", `{"version":2,"types":["a"],"objects":[{"id":1,"type":1}]}`, "type index 1"},
+		{"unknown root kind", `{"version":2,"types":[],"objects":[],"roots":[{"id":1,"kind":"heap"}]}`, `unknown kind "heap"`},
+		{"missing ID", `{"version":2,"types":["a"],"objects":[{"type":0}]}`, "missing ID"},
+		{"future version", `{"version":3,"objects":[]}`, "unsupported JSON dump version 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&JSONStub{}).Parse(strings.NewReader(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+
+	if (&JSONStub{}).CanParse(strings.NewReader(`{"version":3,"objects":[]}`)) {
+		t.Error("CanParse() should reject a future version")
+	}
+}
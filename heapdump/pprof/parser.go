@@ -0,0 +1,453 @@
+// ABOUTME: Parser for runtime/pprof heap profiles implementing the heapdump interface
+// ABOUTME: Builds an edge-less graph with one object per allocation stack
+
+package pprof
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prateek/heaplens/graph"
+	"github.com/prateek/heaplens/heapdump"
+)
+
+// gzipMagic is how pprof profiles start when written by the runtime
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ProfileParser implements the heapdump.Parser interface for pprof heap profiles.
+// Profiles record allocation sites rather than objects, so every distinct
+// allocation stack becomes one object whose Type is the top frame's function
+// and whose Size is the live (inuse_space) bytes allocated there. Profiles
+// carry no pointers, so the graph has no edges and every site is a root.
+type ProfileParser struct{}
+
+// Ensure ProfileParser implements Parser interface
+var _ heapdump.Parser = (*ProfileParser)(nil)
+
+// Register registers the parser with the heapdump package
+func init() {
+	heapdump.Register(&ProfileParser{})
+}
+
+// CanParse checks if the reader contains a gzipped or raw pprof profile
+func (p *ProfileParser) CanParse(r io.Reader) bool {
+	header := make([]byte, 512)
+	n, _ := io.ReadFull(r, header)
+	header = header[:n]
+
+	if bytes.HasPrefix(header, gzipMagic) {
+		zr, err := gzip.NewReader(bytes.NewReader(header))
+		if err != nil {
+			return false
+		}
+		// The preview is truncated, so a partial read is expected
+		header, _ = io.ReadAll(zr)
+	}
+	return looksLikeProfile(header)
+}
+
+// looksLikeProfile walks the top-level fields of a (possibly truncated)
+// preview and checks each is a Profile field with the expected wire type.
+// At least one sample_type or period_type must decode as a ValueType.
+func looksLikeProfile(data []byte) bool {
+	b := &protoBuffer{data: data}
+	sawValueType := false
+	for !b.done() {
+		num, wireType, err := b.field()
+		if err != nil || num > 14 {
+			return sawValueType && err == errTruncated
+		}
+
+		switch num {
+		case 1, 11: // sample_type, period_type
+			if wireType != wireBytes {
+				return false
+			}
+			msg, err := b.bytes()
+			if err != nil {
+				return sawValueType
+			}
+			if !isValueType(msg) {
+				return false
+			}
+			sawValueType = true
+		case 2, 3, 4, 5, 6, 13: // messages, strings, or packed comments
+			if wireType != wireBytes {
+				return false
+			}
+			if _, err := b.bytes(); err != nil {
+				return sawValueType
+			}
+		default: // scalar fields
+			if wireType != wireVarint {
+				return false
+			}
+			if _, err := b.varint(); err != nil {
+				return sawValueType
+			}
+		}
+	}
+	return sawValueType
+}
+
+// isValueType checks that msg holds only the varint type and unit fields
+func isValueType(msg []byte) bool {
+	vt := &protoBuffer{data: msg}
+	for !vt.done() {
+		num, wireType, err := vt.field()
+		if err != nil || (num != 1 && num != 2) || wireType != wireVarint {
+			return false
+		}
+		if _, err := vt.varint(); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse reads the profile and builds a graph of allocation sites
+func (p *ProfileParser) Parse(r io.Reader) (graph.Graph, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading profile: %w", err)
+	}
+
+	if bytes.HasPrefix(data, gzipMagic) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decompressing profile: %w", err)
+		}
+		data, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing profile: %w", err)
+		}
+	}
+
+	prof, err := decodeProfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing profile: %w", err)
+	}
+	return prof.buildGraph()
+}
+
+// profile holds the subset of profile.proto needed to build a graph
+type profile struct {
+	sampleTypes []string // Value type names, indexed like sample values
+	samples     []sample
+	locations   map[uint64]uint64 // location ID -> function ID of its innermost line
+	functions   map[uint64]int64  // function ID -> name string index
+	strings     []string
+}
+
+type sample struct {
+	locations []uint64 // Leaf first
+	values    []int64
+}
+
+func decodeProfile(data []byte) (*profile, error) {
+	prof := &profile{
+		locations: make(map[uint64]uint64),
+		functions: make(map[uint64]int64),
+	}
+	var sampleTypeIdx []int64
+
+	b := &protoBuffer{data: data}
+	for !b.done() {
+		num, wireType, err := b.field()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case num == 1 && wireType == wireBytes:
+			msg, err := b.bytes()
+			if err != nil {
+				return nil, err
+			}
+			idx, err := decodeValueType(msg)
+			if err != nil {
+				return nil, fmt.Errorf("sample type: %w", err)
+			}
+			sampleTypeIdx = append(sampleTypeIdx, idx)
+		case num == 2 && wireType == wireBytes:
+			msg, err := b.bytes()
+			if err != nil {
+				return nil, err
+			}
+			s, err := decodeSample(msg)
+			if err != nil {
+				return nil, fmt.Errorf("sample: %w", err)
+			}
+			prof.samples = append(prof.samples, s)
+		case num == 4 && wireType == wireBytes:
+			msg, err := b.bytes()
+			if err != nil {
+				return nil, err
+			}
+			if err := prof.decodeLocation(msg); err != nil {
+				return nil, fmt.Errorf("location: %w", err)
+			}
+		case num == 5 && wireType == wireBytes:
+			msg, err := b.bytes()
+			if err != nil {
+				return nil, err
+			}
+			if err := prof.decodeFunction(msg); err != nil {
+				return nil, fmt.Errorf("function: %w", err)
+			}
+		case num == 6 && wireType == wireBytes:
+			s, err := b.bytes()
+			if err != nil {
+				return nil, err
+			}
+			prof.strings = append(prof.strings, string(s))
+		default:
+			if err := b.skip(wireType); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, idx := range sampleTypeIdx {
+		prof.sampleTypes = append(prof.sampleTypes, prof.str(idx))
+	}
+	return prof, nil
+}
+
+// decodeValueType returns the string index of a ValueType's type name
+func decodeValueType(data []byte) (int64, error) {
+	var typeIdx int64
+	b := &protoBuffer{data: data}
+	for !b.done() {
+		num, wireType, err := b.field()
+		if err != nil {
+			return 0, err
+		}
+		if num == 1 && wireType == wireVarint {
+			v, err := b.varint()
+			if err != nil {
+				return 0, err
+			}
+			typeIdx = int64(v)
+			continue
+		}
+		if err := b.skip(wireType); err != nil {
+			return 0, err
+		}
+	}
+	return typeIdx, nil
+}
+
+func decodeSample(data []byte) (sample, error) {
+	var s sample
+	var values []uint64
+	b := &protoBuffer{data: data}
+	for !b.done() {
+		num, wireType, err := b.field()
+		if err != nil {
+			return s, err
+		}
+		switch num {
+		case 1:
+			s.locations, err = b.uint64s(wireType, s.locations)
+		case 2:
+			values, err = b.uint64s(wireType, values)
+		default:
+			err = b.skip(wireType)
+		}
+		if err != nil {
+			return s, err
+		}
+	}
+	for _, v := range values {
+		s.values = append(s.values, int64(v))
+	}
+	return s, nil
+}
+
+func (prof *profile) decodeLocation(data []byte) error {
+	var id, funcID uint64
+	haveLine := false
+	b := &protoBuffer{data: data}
+	for !b.done() {
+		num, wireType, err := b.field()
+		if err != nil {
+			return err
+		}
+		switch {
+		case num == 1 && wireType == wireVarint:
+			id, err = b.varint()
+		case num == 4 && wireType == wireBytes && !haveLine:
+			// With inlining a location has several lines; the first is
+			// the innermost frame
+			var msg []byte
+			msg, err = b.bytes()
+			if err == nil {
+				funcID, err = decodeLineFunction(msg)
+				haveLine = true
+			}
+		default:
+			err = b.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	prof.locations[id] = funcID
+	return nil
+}
+
+// decodeLineFunction returns the function ID of a Line message
+func decodeLineFunction(data []byte) (uint64, error) {
+	var funcID uint64
+	b := &protoBuffer{data: data}
+	for !b.done() {
+		num, wireType, err := b.field()
+		if err != nil {
+			return 0, err
+		}
+		if num == 1 && wireType == wireVarint {
+			funcID, err = b.varint()
+		} else {
+			err = b.skip(wireType)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return funcID, nil
+}
+
+func (prof *profile) decodeFunction(data []byte) error {
+	var id uint64
+	var nameIdx int64
+	b := &protoBuffer{data: data}
+	for !b.done() {
+		num, wireType, err := b.field()
+		if err != nil {
+			return err
+		}
+		switch {
+		case num == 1 && wireType == wireVarint:
+			id, err = b.varint()
+		case num == 2 && wireType == wireVarint:
+			var v uint64
+			v, err = b.varint()
+			nameIdx = int64(v)
+		default:
+			err = b.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	prof.functions[id] = nameIdx
+	return nil
+}
+
+// str looks up an entry in the string table, tolerating bad indexes
+func (prof *profile) str(idx int64) string {
+	if idx < 0 || idx >= int64(len(prof.strings)) {
+		return ""
+	}
+	return prof.strings[idx]
+}
+
+// frameName returns the innermost function name at a location
+func (prof *profile) frameName(locID uint64) string {
+	funcID, ok := prof.locations[locID]
+	if !ok {
+		return ""
+	}
+	nameIdx, ok := prof.functions[funcID]
+	if !ok {
+		return ""
+	}
+	return prof.str(nameIdx)
+}
+
+// inuseIndex returns which sample value holds inuse_space. Profiles without
+// that type fall back to their last value, which the runtime uses for sizes.
+func (prof *profile) inuseIndex() (int, error) {
+	if len(prof.sampleTypes) == 0 {
+		return 0, errors.New("profile has no sample types")
+	}
+	for i, name := range prof.sampleTypes {
+		if name == "inuse_space" {
+			return i, nil
+		}
+	}
+	return len(prof.sampleTypes) - 1, nil
+}
+
+// buildGraph aggregates samples by stack into objects. Sites with no live
+// bytes are dropped since they no longer contribute to the heap.
+func (prof *profile) buildGraph() (graph.Graph, error) {
+	valueIdx, err := prof.inuseIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	type site struct {
+		typeName string
+		size     uint64
+	}
+	sites := make(map[string]*site)
+	for _, s := range prof.samples {
+		if len(s.locations) == 0 || valueIdx >= len(s.values) || s.values[valueIdx] <= 0 {
+			continue
+		}
+
+		key := stackKey(s.locations)
+		st, ok := sites[key]
+		if !ok {
+			typeName := prof.frameName(s.locations[0])
+			if typeName == "" {
+				typeName = "<unknown>"
+			}
+			st = &site{typeName: typeName}
+			sites[key] = st
+		}
+		st.size += uint64(s.values[valueIdx])
+	}
+
+	// Sort stacks so object IDs are stable across runs
+	keys := make([]string, 0, len(sites))
+	for key := range sites {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	g := graph.NewMemGraph()
+	roots := make([]graph.ObjID, 0, len(keys))
+	for i, key := range keys {
+		id := graph.ObjID(i + 1) // ID 0 is reserved for the dominator super-root
+		g.AddObject(&graph.Object{
+			ID:   id,
+			Type: sites[key].typeName,
+			Size: sites[key].size,
+		})
+		roots = append(roots, id)
+	}
+	g.SetRoots(graph.Roots{IDs: roots})
+
+	return g, nil
+}
+
+// stackKey builds a map key identifying a stack of location IDs
+func stackKey(locations []uint64) string {
+	var sb strings.Builder
+	for i, loc := range locations {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatUint(loc, 10))
+	}
+	return sb.String()
+}
@@ -0,0 +1,220 @@
+// ABOUTME: Tests for the pprof heap profile parser
+// ABOUTME: Uses hand-encoded profiles plus real profiles from runtime/pprof
+
+package pprof
+
+import (
+	"bytes"
+	"compress/gzip"
+	"runtime"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/prateek/heaplens/graph"
+	"github.com/prateek/heaplens/heapdump"
+)
+
+// protoWriter encodes just enough of the protobuf wire format for tests
+type protoWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *protoWriter) varint(v uint64) {
+	for v >= 0x80 {
+		w.buf.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	w.buf.WriteByte(byte(v))
+}
+
+func (w *protoWriter) uint(num int, v uint64) {
+	w.varint(uint64(num)<<3 | wireVarint)
+	w.varint(v)
+}
+
+func (w *protoWriter) bytes(num int, data []byte) {
+	w.varint(uint64(num)<<3 | wireBytes)
+	w.varint(uint64(len(data)))
+	w.buf.Write(data)
+}
+
+func (w *protoWriter) packed(num int, vs ...uint64) {
+	var inner protoWriter
+	for _, v := range vs {
+		inner.varint(v)
+	}
+	w.bytes(num, inner.buf.Bytes())
+}
+
+// testProfile encodes a heap profile with three samples. Two share a stack
+// (main.alloc <- main.main) and one allocates from an inlined frame.
+func testProfile() []byte {
+	strs := []string{"", "alloc_space", "bytes", "inuse_space", "main.alloc", "main.main", "bytes.growSlice", "main.inlined"}
+
+	var p protoWriter
+	for _, typeIdx := range []uint64{1, 3} {
+		var vt protoWriter
+		vt.uint(1, typeIdx)
+		vt.uint(2, 2)
+		p.bytes(1, vt.buf.Bytes())
+	}
+
+	samples := []struct {
+		locs   []uint64
+		values []uint64
+	}{
+		{[]uint64{1, 2}, []uint64{4096, 1024}},
+		{[]uint64{1, 2}, []uint64{2048, 512}},
+		{[]uint64{3, 2}, []uint64{8192, 8192}},
+		{[]uint64{1, 3}, []uint64{100, 0}}, // freed, dropped
+	}
+	for _, s := range samples {
+		var sw protoWriter
+		sw.packed(1, s.locs...)
+		sw.packed(2, s.values...)
+		p.bytes(2, sw.buf.Bytes())
+	}
+
+	locations := [][]uint64{{1, 1}, {2, 2}, {3, 3, 4}} // id, then function of each line
+	for _, l := range locations {
+		var lw protoWriter
+		lw.uint(1, l[0])
+		for _, fn := range l[1:] {
+			var line protoWriter
+			line.uint(1, fn)
+			lw.bytes(4, line.buf.Bytes())
+		}
+		p.bytes(4, lw.buf.Bytes())
+	}
+
+	for id, name := range map[uint64]uint64{1: 4, 2: 5, 3: 6, 4: 7} {
+		var fw protoWriter
+		fw.uint(1, id)
+		fw.uint(2, name)
+		p.bytes(5, fw.buf.Bytes())
+	}
+
+	for _, s := range strs {
+		p.bytes(6, []byte(s))
+	}
+	return p.buf.Bytes()
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCanParse(t *testing.T) {
+	raw := testProfile()
+	tests := []struct {
+		name     string
+		data     []byte
+		expected bool
+	}{
+		{name: "raw profile", data: raw, expected: true},
+		{name: "gzipped profile", data: gzipBytes(t, raw), expected: true},
+		{name: "json dump", data: []byte(`{"objects":[]}`), expected: false},
+		{name: "go heap dump", data: []byte("go1.7 heap dump\n"), expected: false},
+		{name: "gzipped json", data: gzipBytes(t, []byte(`{"objects":[]}`)), expected: false},
+		{name: "empty", data: nil, expected: false},
+	}
+
+	p := &ProfileParser{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.CanParse(bytes.NewReader(tt.data)); got != tt.expected {
+				t.Errorf("CanParse() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	g, err := (&ProfileParser{}).Parse(bytes.NewReader(gzipBytes(t, testProfile())))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	sizes := make(map[string]uint64)
+	edges := 0
+	g.ForEachObject(func(obj *graph.Object) {
+		if obj.ID == 0 {
+			t.Error("object uses reserved ID 0")
+		}
+		sizes[obj.Type] += obj.Size
+		edges += len(obj.Ptrs)
+	})
+
+	if len(sizes) != 2 {
+		t.Fatalf("expected 2 allocation sites, got %v", sizes)
+	}
+	if sizes["main.alloc"] != 1536 {
+		t.Errorf("main.alloc inuse = %d, want 1536", sizes["main.alloc"])
+	}
+	if sizes["bytes.growSlice"] != 8192 {
+		t.Errorf("inlined site inuse = %d, want 8192", sizes["bytes.growSlice"])
+	}
+	if edges != 0 {
+		t.Errorf("expected no edges, got %d", edges)
+	}
+	if got := len(g.GetRoots().IDs); got != 2 {
+		t.Errorf("expected every site to be a root, got %d roots", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	raw := testProfile()
+	inputs := map[string][]byte{
+		"truncated":    raw[:len(raw)-3],
+		"no samples":   {},
+		"corrupt gzip": append([]byte{0x1f, 0x8b}, 0xff, 0xff),
+	}
+	for name, data := range inputs {
+		t.Run(name, func(t *testing.T) {
+			if _, err := (&ProfileParser{}).Parse(bytes.NewReader(data)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+var sink [][]byte
+
+// TestRuntimeProfile parses a real profile through the registry
+func TestRuntimeProfile(t *testing.T) {
+	runtime.MemProfileRate = 1
+	defer func() { runtime.MemProfileRate = 512 * 1024 }()
+
+	for i := 0; i < 100; i++ {
+		sink = append(sink, make([]byte, 1024))
+	}
+	runtime.GC()
+
+	var buf bytes.Buffer
+	if err := pprof.WriteHeapProfile(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := heapdump.Open(&buf)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	var total uint64
+	g.ForEachObject(func(obj *graph.Object) {
+		total += obj.Size
+	})
+	if total < 100*1024 {
+		t.Errorf("expected at least %d live bytes, got %d", 100*1024, total)
+	}
+	sink = nil
+}
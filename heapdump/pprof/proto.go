@@ -0,0 +1,133 @@
+// ABOUTME: Minimal protobuf wire-format decoder for pprof profiles
+// ABOUTME: Decodes only what the profile parser needs, without external deps
+
+package pprof
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Protobuf wire types
+const (
+	wireVarint = 0
+	wire64Bit  = 1
+	wireBytes  = 2
+	wire32Bit  = 5
+)
+
+// maxFieldNumber is the largest field number protobuf allows. Checking it
+// keeps the number from wrapping when converted to a 32-bit int.
+const maxFieldNumber = 1<<29 - 1
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// protoBuffer walks the fields of a single protobuf message
+type protoBuffer struct {
+	data []byte
+	pos  int
+}
+
+// done reports whether every field has been consumed
+func (b *protoBuffer) done() bool {
+	return b.pos >= len(b.data)
+}
+
+// varint reads a base-128 varint
+func (b *protoBuffer) varint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if b.pos >= len(b.data) {
+			return 0, errTruncated
+		}
+		c := b.data[b.pos]
+		b.pos++
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("varint overflows 64 bits")
+}
+
+// field reads the next field key
+func (b *protoBuffer) field() (num int, wireType int, err error) {
+	key, err := b.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	if key>>3 == 0 || key>>3 > maxFieldNumber {
+		return 0, 0, fmt.Errorf("invalid field number %d", key>>3)
+	}
+	num = int(key >> 3)
+	return num, int(key & 7), nil
+}
+
+// bytes reads a length-delimited payload
+func (b *protoBuffer) bytes() ([]byte, error) {
+	n, err := b.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(b.data)-b.pos) {
+		return nil, errTruncated
+	}
+	data := b.data[b.pos : b.pos+int(n)]
+	b.pos += int(n)
+	return data, nil
+}
+
+// skip discards a field value of the given wire type
+func (b *protoBuffer) skip(wireType int) error {
+	switch wireType {
+	case wireVarint:
+		_, err := b.varint()
+		return err
+	case wire64Bit:
+		return b.advance(8)
+	case wireBytes:
+		_, err := b.bytes()
+		return err
+	case wire32Bit:
+		return b.advance(4)
+	default:
+		return fmt.Errorf("unsupported wire type %d", wireType)
+	}
+}
+
+func (b *protoBuffer) advance(n int) error {
+	if n > len(b.data)-b.pos {
+		return errTruncated
+	}
+	b.pos += n
+	return nil
+}
+
+// uint64s decodes a repeated integer field that may be packed or unpacked,
+// appending the values to dst
+func (b *protoBuffer) uint64s(wireType int, dst []uint64) ([]uint64, error) {
+	if wireType == wireVarint {
+		v, err := b.varint()
+		if err != nil {
+			return nil, err
+		}
+		return append(dst, v), nil
+	}
+	if wireType != wireBytes {
+		return nil, fmt.Errorf("unexpected wire type %d for repeated integer", wireType)
+	}
+
+	packed, err := b.bytes()
+	if err != nil {
+		return nil, err
+	}
+	pb := &protoBuffer{data: packed}
+	for !pb.done() {
+		v, err := pb.varint()
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, v)
+	}
+	return dst, nil
+}
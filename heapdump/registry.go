@@ -17,10 +17,11 @@ var (
 	ErrNoParser = errors.New("no parser found for dump format")
 )
 
-// parserRegistry holds registered parsers
+// parserRegistry holds registered parsers, ordered by descending priority
 type parserRegistry struct {
-	mu      sync.RWMutex
-	parsers []Parser
+	mu         sync.RWMutex
+	parsers    []Parser
+	priorities []int // priorities[i] is the priority of parsers[i]
 }
 
 // Global registry instance
@@ -28,41 +29,112 @@ var registry = &parserRegistry{
 	parsers: make([]Parser, 0),
 }
 
-// Register adds a parser to the registry
+// Register adds a parser to the registry with priority 0
 func Register(p Parser) {
+	RegisterWithPriority(p, 0)
+}
+
+// RegisterWithPriority adds a parser to the registry with an explicit priority.
+// Open tries parsers with higher priorities first; parsers with equal
+// priority are tried in registration order.
+func RegisterWithPriority(p Parser, priority int) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	// Insert after every parser with priority >= this one
+	i := 0
+	for i < len(registry.priorities) && registry.priorities[i] >= priority {
+		i++
+	}
+	registry.parsers = append(registry.parsers, nil)
+	copy(registry.parsers[i+1:], registry.parsers[i:])
+	registry.parsers[i] = p
+	registry.priorities = append(registry.priorities, 0)
+	copy(registry.priorities[i+1:], registry.priorities[i:])
+	registry.priorities[i] = priority
+}
+
+// Unregister removes a previously registered parser from the registry.
+// It returns false if the parser was not registered.
+func Unregister(p Parser) bool {
 	registry.mu.Lock()
 	defer registry.mu.Unlock()
-	registry.parsers = append(registry.parsers, p)
+
+	for i, registered := range registry.parsers {
+		if registered == p {
+			registry.parsers = append(registry.parsers[:i], registry.parsers[i+1:]...)
+			registry.priorities = append(registry.priorities[:i], registry.priorities[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Reset removes all registered parsers, including the ones registered by
+// package init functions. Embedders can call it and then Register only the
+// parsers they want Open to consider.
+func Reset() {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.parsers = make([]Parser, 0)
+	registry.priorities = nil
 }
 
 // Open reads a heap dump and returns a graph
-// It tries each registered parser to find one that can handle the format
+// It tries each registered parser, highest priority first, to find one that
+// can handle the format
 func Open(r io.Reader) (graph.Graph, error) {
 	// Read some bytes for format detection
 	// We need to buffer since we'll try multiple parsers
 	buf := new(bytes.Buffer)
 	tee := io.TeeReader(r, buf)
 	
-	// Try to read enough for format detection
-	detectBuf := make([]byte, 4096)
-	n, err := tee.Read(detectBuf)
-	if err != nil && err != io.EOF {
+	// Try to read enough for format detection. A single Read may return
+	// fewer bytes, or none, without the stream having ended.
+	detectBuf := make([]byte, detectSize)
+	n, err := io.ReadFull(tee, detectBuf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return nil, err
 	}
 	
 	registry.mu.RLock()
 	defer registry.mu.RUnlock()
 	
-	// Try each parser
+	parser := detectParser(detectBuf[:n])
+	if parser == nil {
+		return nil, ErrNoParser
+	}
+	
+	// Create fresh reader for actual parsing
+	parseReader := io.MultiReader(bytes.NewReader(detectBuf[:n]), r)
+	return parser.Parse(parseReader)
+}
+
+// OpenBytes parses a heap dump that is already in memory, such as one
+// fetched over HTTP. Parsers read data directly, so unlike Open nothing is
+// copied for format detection.
+func OpenBytes(data []byte) (graph.Graph, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	parser := detectParser(data[:min(len(data), detectSize)])
+	if parser == nil {
+		return nil, ErrNoParser
+	}
+	return parser.Parse(bytes.NewReader(data))
+}
+
+// detectSize is how much of a dump format detection looks at
+const detectSize = 4096
+
+// detectParser returns the first registered parser that accepts header,
+// or nil. The caller must hold registry.mu.
+func detectParser(header []byte) Parser {
 	for _, parser := range registry.parsers {
-		// Create a fresh reader for CanParse check
-		checkReader := bytes.NewReader(detectBuf[:n])
-		if parser.CanParse(checkReader) {
-			// Create fresh reader for actual parsing
-			parseReader := io.MultiReader(bytes.NewReader(detectBuf[:n]), r)
-			return parser.Parse(parseReader)
+		// Create a fresh reader for each CanParse check
+		if parser.CanParse(bytes.NewReader(header)) {
+			return parser
 		}
 	}
-	
-	return nil, ErrNoParser
+	return nil
 }
\ No newline at end of file
@@ -4,9 +4,13 @@
 package heapdump
 
 import (
+	"bytes"
+	"errors"
 	"io"
+	"os"
 	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/prateek/heaplens/graph"
 )
@@ -29,9 +33,7 @@ func (p *mockParser) Parse(r io.Reader) (graph.Graph, error) {
 
 func TestRegister(t *testing.T) {
 	// Clear registry for test
-	registry = &parserRegistry{
-		parsers: make([]Parser, 0),
-	}
+	Reset()
 	
 	parser1 := &mockParser{name: "parser1"}
 	parser2 := &mockParser{name: "parser2"}
@@ -46,9 +48,7 @@ func TestRegister(t *testing.T) {
 
 func TestOpen(t *testing.T) {
 	// Clear and setup registry
-	registry = &parserRegistry{
-		parsers: make([]Parser, 0),
-	}
+	Reset()
 	
 	jsonParser := &mockParser{name: "json"}
 	goParser := &mockParser{name: "goheap"}
@@ -95,9 +95,7 @@ func TestOpen(t *testing.T) {
 
 func TestMultipleParserRegistration(t *testing.T) {
 	// Clear registry
-	registry = &parserRegistry{
-		parsers: make([]Parser, 0),
-	}
+	Reset()
 	
 	// Register multiple parsers that can handle same format
 	// Last registered should take precedence
@@ -115,9 +113,7 @@ func TestMultipleParserRegistration(t *testing.T) {
 
 func TestParserSelection(t *testing.T) {
 	// Clear registry
-	registry = &parserRegistry{
-		parsers: make([]Parser, 0),
-	}
+	Reset()
 	
 	// Register parsers in specific order
 	fallbackParser := &mockParser{name: "fallback"}
@@ -140,9 +136,7 @@ func TestParserSelection(t *testing.T) {
 
 func TestThreadSafeRegistry(t *testing.T) {
 	// Clear registry
-	registry = &parserRegistry{
-		parsers: make([]Parser, 0),
-	}
+	Reset()
 	
 	// Concurrent registration should be safe
 	done := make(chan bool)
@@ -162,4 +156,145 @@ func TestThreadSafeRegistry(t *testing.T) {
 	if len(registry.parsers) != 10 {
 		t.Errorf("Expected 10 parsers after concurrent registration, got %d", len(registry.parsers))
 	}
-}
\ No newline at end of file
+}
+
+// claimingParser accepts any input and tags the graph it returns with its name
+type claimingParser struct {
+	name string
+}
+
+func (p *claimingParser) CanParse(r io.Reader) bool {
+	return true
+}
+
+func (p *claimingParser) Parse(r io.Reader) (graph.Graph, error) {
+	g := graph.NewMemGraph()
+	g.AddObject(&graph.Object{ID: 1, Type: p.name})
+	return g, nil
+}
+
+func TestRegisterWithPriority(t *testing.T) {
+	// Clear registry
+	Reset()
+
+	// The loose parser registers first but must lose to the higher priority one
+	RegisterWithPriority(&claimingParser{name: "loose"}, -1)
+	Register(&claimingParser{name: "default"})
+	RegisterWithPriority(&claimingParser{name: "specific"}, 10)
+
+	g, err := Open(strings.NewReader("ambiguous data"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := g.GetObject(1).Type; got != "specific" {
+		t.Errorf("Expected high priority parser to win, got %q", got)
+	}
+
+	want := []int{10, 0, -1}
+	for i, p := range want {
+		if registry.priorities[i] != p {
+			t.Errorf("priority[%d] = %d, want %d", i, registry.priorities[i], p)
+		}
+	}
+}
+
+func TestEqualPriorityKeepsRegistrationOrder(t *testing.T) {
+	// Clear registry
+	Reset()
+
+	Register(&claimingParser{name: "first"})
+	Register(&claimingParser{name: "second"})
+
+	g, err := Open(strings.NewReader("ambiguous data"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := g.GetObject(1).Type; got != "first" {
+		t.Errorf("Expected first registered parser to win, got %q", got)
+	}
+}
+
+func TestUnregister(t *testing.T) {
+	Reset()
+
+	jsonParser := &mockParser{name: "json"}
+	goParser := &mockParser{name: "goheap"}
+	Register(jsonParser)
+	RegisterWithPriority(goParser, 5)
+
+	if !Unregister(goParser) {
+		t.Fatal("Expected Unregister to report the parser was removed")
+	}
+	if Unregister(goParser) {
+		t.Error("Expected second Unregister of the same parser to return false")
+	}
+	if len(registry.parsers) != 1 || len(registry.priorities) != 1 {
+		t.Fatalf("Expected 1 parser left, got %d", len(registry.parsers))
+	}
+
+	if _, err := Open(strings.NewReader("goheap dump data")); err != ErrNoParser {
+		t.Errorf("Expected ErrNoParser after unregistering, got %v", err)
+	}
+	if _, err := Open(strings.NewReader("json dump data")); err != nil {
+		t.Errorf("Remaining parser should still work, got %v", err)
+	}
+}
+
+func TestReset(t *testing.T) {
+	Register(&mockParser{name: "json"})
+	Reset()
+
+	if len(registry.parsers) != 0 {
+		t.Errorf("Expected empty registry after Reset, got %d parsers", len(registry.parsers))
+	}
+	if _, err := Open(strings.NewReader("json dump data")); err != ErrNoParser {
+		t.Errorf("Expected ErrNoParser after Reset, got %v", err)
+	}
+}
+
+func TestOpenBytes(t *testing.T) {
+	Reset()
+	Register(&JSONStub{})
+
+	data, err := os.ReadFile("../testdata/simple.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := OpenBytes(data)
+	if err != nil {
+		t.Fatalf("OpenBytes() error = %v", err)
+	}
+	if g.NumObjects() != 5 {
+		t.Errorf("Expected 5 objects, got %d", g.NumObjects())
+	}
+
+	for _, data := range [][]byte{nil, []byte("unknown format")} {
+		if _, err := OpenBytes(data); !errors.Is(err, ErrNoParser) {
+			t.Errorf("OpenBytes(%q) error = %v, want ErrNoParser", data, err)
+		}
+	}
+}
+
+func TestOpenShortReads(t *testing.T) {
+	Reset()
+	Register(&JSONStub{})
+	defer Reset()
+
+	data, err := os.ReadFile("../testdata/simple.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Detection must not give up after a Read that returns little or nothing
+	g, err := Open(iotest.OneByteReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatalf("Open(OneByteReader) error = %v", err)
+	}
+	if g.NumObjects() != 5 {
+		t.Errorf("Expected 5 objects, got %d", g.NumObjects())
+	}
+
+	if _, err := Open(bytes.NewReader(nil)); !errors.Is(err, ErrNoParser) {
+		t.Errorf("Open(empty) error = %v, want ErrNoParser", err)
+	}
+}
@@ -0,0 +1,39 @@
+// ABOUTME: The Go runtime's small object size classes and rounding to them
+// ABOUTME: Turns payload lengths into the bytes the allocator actually reserves
+
+package sizeclass
+
+import "sort"
+
+// DefaultClasses are the runtime's 67 small object size classes
+// (runtime/sizeclasses.go), smallest first
+var DefaultClasses = []uint64{
+	8, 16, 24, 32, 48, 64, 80, 96, 112, 128, 144, 160, 176, 192, 208, 224,
+	240, 256, 288, 320, 352, 384, 416, 448, 480, 512, 576, 640, 704, 768,
+	896, 1024, 1152, 1280, 1408, 1536, 1792, 2048, 2304, 2688, 3072, 3200,
+	3456, 4096, 4864, 5376, 6144, 6528, 6784, 6912, 8192, 9472, 9728, 10240,
+	10880, 12288, 13568, 14336, 16384, 18432, 19072, 20480, 21760, 24576,
+	27264, 28672, 32768,
+}
+
+// PageSize is the granularity of objects too big for a size class
+const PageSize = 8192
+
+// RoundToSizeClass rounds n up using DefaultClasses
+func RoundToSizeClass(n uint64) uint64 {
+	return Round(n, DefaultClasses)
+}
+
+// Round rounds n up to the smallest of the sorted classes that holds it.
+// Sizes past the largest class are rounded up to whole pages, and zero
+// stays zero.
+func Round(n uint64, classes []uint64) uint64 {
+	if n == 0 {
+		return 0
+	}
+	i := sort.Search(len(classes), func(i int) bool { return classes[i] >= n })
+	if i < len(classes) {
+		return classes[i]
+	}
+	return (n + PageSize - 1) / PageSize * PageSize
+}
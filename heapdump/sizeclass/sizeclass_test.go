@@ -0,0 +1,41 @@
+// ABOUTME: Tests for rounding sizes to the runtime's size classes
+// ABOUTME: Covers exact classes, rounding up, and page-rounded large objects
+
+package sizeclass
+
+import "testing"
+
+func TestRoundToSizeClass(t *testing.T) {
+	tests := []struct {
+		name string
+		n    uint64
+		want uint64
+	}{
+		{"zero", 0, 0},
+		{"smallest", 1, 8},
+		{"exact class", 16, 16},
+		{"rounded up", 17, 24},
+		{"between classes", 1025, 1152},
+		{"largest class", 32768, 32768},
+		{"large object", 32769, 40960},
+		{"whole pages", 65536, 65536},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RoundToSizeClass(tt.n); got != tt.want {
+				t.Errorf("RoundToSizeClass(%d) = %d, want %d", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultClasses(t *testing.T) {
+	if len(DefaultClasses) != 67 {
+		t.Errorf("len(DefaultClasses) = %d, want 67", len(DefaultClasses))
+	}
+	for i := 1; i < len(DefaultClasses); i++ {
+		if DefaultClasses[i] <= DefaultClasses[i-1] {
+			t.Errorf("DefaultClasses not ascending at %d: %d after %d", i, DefaultClasses[i], DefaultClasses[i-1])
+		}
+	}
+}
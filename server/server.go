@@ -0,0 +1,372 @@
+// ABOUTME: HTTP analysis server rendering heap graphs with embedded templates
+// ABOUTME: Holds a parsed graph in memory and serves server-side rendered pages
+
+package server
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prateek/heaplens/graph"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+//go:embed static/style.css
+var styleCSS []byte
+
+// Limits on how many paths the paths page searches for, and how long they
+// may be
+const (
+	defaultMaxPaths = 5
+	maxMaxPaths     = 50
+	maxPathDepth    = 100
+)
+
+// maxQueryResults caps how many matches the query page lists
+const maxQueryResults = 500
+
+var templateFuncs = template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}
+
+// Server serves the web UI for a single parsed heap graph
+type Server struct {
+	g         graph.Graph
+	dumpFile  string
+	histogram []graph.TypeStat
+	fanout    map[string]float64
+	roots     map[graph.ObjID]bool
+	totalSize uint64
+	retained  map[graph.ObjID]uint64 // nil unless dominators were supplied
+	pages     map[string]*template.Template
+	mux       *http.ServeMux
+}
+
+// Ensure Server implements http.Handler
+var _ http.Handler = (*Server)(nil)
+
+// New creates a server for g. dumpFile is only used for display.
+// Aggregates are computed up front so requests only sort and render.
+// A *graph.MemGraph is snapshotted so concurrent requests see a stable graph.
+func New(g graph.Graph, dumpFile string) (*Server, error) {
+	return NewWithDominators(g, dumpFile, nil)
+}
+
+// NewWithDominators is like New, but takes the dominator analysis of g,
+// such as one loaded from a cache, so object pages don't each compute
+// retained sizes. di may be nil.
+func NewWithDominators(g graph.Graph, dumpFile string, di *graph.DomInfo) (*Server, error) {
+	if mg, ok := g.(*graph.MemGraph); ok {
+		g = mg.Snapshot()
+	}
+	s := &Server{
+		g:         g,
+		dumpFile:  dumpFile,
+		histogram: graph.TypeHistogram(g),
+		fanout:    graph.TypeFanout(g),
+		totalSize: graph.TotalSize(g),
+		roots:     make(map[graph.ObjID]bool),
+		pages:     make(map[string]*template.Template),
+		mux:       http.NewServeMux(),
+	}
+	for _, id := range g.GetRoots().IDs {
+		s.roots[id] = true
+	}
+	if di != nil {
+		s.retained = di.RetainedSize()
+	}
+
+	// Each page defines its own "content" block, so each gets its own set
+	for _, page := range []string{"types", "object", "paths", "query"} {
+		tmpl, err := template.New(page).Funcs(templateFuncs).ParseFS(templateFS, "templates/layout.html", "templates/"+page+".html")
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s template: %w", page, err)
+		}
+		s.pages[page] = tmpl
+	}
+
+	s.mux.HandleFunc("/", s.handleTypes)
+	s.mux.HandleFunc("/object", s.handleObject)
+	s.mux.HandleFunc("/paths", s.handlePaths)
+	s.mux.HandleFunc("/query", s.handleQuery)
+	s.mux.HandleFunc("/static/css", s.handleCSS)
+	return s, nil
+}
+
+// ServeHTTP dispatches to the page handlers
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// pageData holds the fields shared by every page's layout
+type pageData struct {
+	Title       string
+	DumpFile    string
+	CurrentTime string
+}
+
+func (s *Server) newPageData(title string) pageData {
+	return pageData{
+		Title:       title,
+		DumpFile:    s.dumpFile,
+		CurrentTime: time.Now().Format(time.RFC3339),
+	}
+}
+
+// render executes a page template, reporting failures as a 500
+func (s *Server) render(w http.ResponseWriter, page string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.pages[page].ExecuteTemplate(w, "layout", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleCSS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/css")
+	w.Write(styleCSS)
+}
+
+// objectParam looks up the object named by the id query parameter,
+// writing an error response and returning nil if it is invalid or missing
+func (s *Server) objectParam(w http.ResponseWriter, r *http.Request) *graph.Object {
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid object id", http.StatusBadRequest)
+		return nil
+	}
+	obj := s.g.GetObject(graph.ObjID(id))
+	if obj == nil {
+		http.Error(w, fmt.Sprintf("object %d not found", id), http.StatusNotFound)
+		return nil
+	}
+	return obj
+}
+
+// objectRef is a link to another object, resolved for display
+type objectRef struct {
+	ID   graph.ObjID
+	Type string
+	Size uint64
+}
+
+func (s *Server) refs(ids []graph.ObjID) []objectRef {
+	refs := make([]objectRef, 0, len(ids))
+	for _, id := range ids {
+		ref := objectRef{ID: id, Type: "(not in dump)"}
+		if obj := s.g.GetObject(id); obj != nil {
+			ref.Type, ref.Size = obj.Type, obj.Size
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+type objectPage struct {
+	pageData
+	Object    *graph.Object
+	Retained  uint64
+	IsRoot    bool
+	Pointers  []objectRef
+	Referrers []objectRef
+	RootPath  []objectRef
+
+	// Contents previews the payload of string and []byte objects, when the
+	// dump was parsed with payloads kept
+	Contents    string
+	HasContents bool
+}
+
+// handleObject renders the detail page for a single object
+func (s *Server) handleObject(w http.ResponseWriter, r *http.Request) {
+	obj := s.objectParam(w, r)
+	if obj == nil {
+		return
+	}
+
+	retained := s.retained
+	if retained == nil {
+		// Stop the dominator computation if the client goes away
+		var err error
+		retained, err = graph.RetainedSizeContext(r.Context(), s.g, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	size, ok := retained[obj.ID]
+	if !ok {
+		size = obj.Size // unreachable objects retain only themselves
+	}
+
+	data := objectPage{
+		pageData:  s.newPageData(fmt.Sprintf("HeapLens - Object #%d", obj.ID)),
+		Object:    obj,
+		Retained:  size,
+		IsRoot:    s.roots[obj.ID],
+		Pointers:  s.refs(obj.Ptrs),
+		Referrers: s.refs(graph.Referrers(s.g, obj.ID)),
+	}
+	if path, ok := graph.ShortestPathToRoot(s.g, obj.ID); ok {
+		data.RootPath = s.refs(path.IDs)
+	}
+	data.Contents, data.HasContents = obj.AsString()
+
+	s.render(w, "object", data)
+}
+
+type pathsPage struct {
+	pageData
+	Object *graph.Object
+	Max    int
+	Depth  int
+	Paths  [][]objectRef
+
+	// Reachable is set when no path fit within Depth but a longer one exists
+	Reachable bool
+}
+
+// handlePaths renders up to max paths from an object to the GC roots, each
+// at most maxPathDepth pointers long. max defaults to defaultMaxPaths and is
+// capped at maxMaxPaths.
+func (s *Server) handlePaths(w http.ResponseWriter, r *http.Request) {
+	obj := s.objectParam(w, r)
+	if obj == nil {
+		return
+	}
+
+	limit := defaultMaxPaths
+	if v := r.URL.Query().Get("max"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid max", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxMaxPaths)
+	}
+
+	data := pathsPage{
+		pageData: s.newPageData(fmt.Sprintf("HeapLens - Paths for #%d", obj.ID)),
+		Object:   obj,
+		Max:      limit,
+		Depth:    maxPathDepth,
+	}
+	for _, path := range graph.PathsToRootsBounded(s.g, obj.ID, limit, maxPathDepth) {
+		data.Paths = append(data.Paths, s.refs(path.IDs))
+	}
+	if data.Paths == nil {
+		_, data.Reachable = graph.ShortestPathToRoot(s.g, obj.ID)
+	}
+
+	s.render(w, "paths", data)
+}
+
+type queryPage struct {
+	pageData
+	Query      string
+	Error      string
+	Matched    int
+	NumObjects int
+	Results    []objectRef
+}
+
+// handleQuery renders the objects matching the q query parameter, a
+// graph.Query expression, listing at most maxQueryResults of them. An
+// invalid expression is reported on the page so it can be corrected.
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	data := queryPage{
+		pageData:   s.newPageData("HeapLens - Query"),
+		Query:      r.URL.Query().Get("q"),
+		NumObjects: s.g.NumObjects(),
+	}
+	if data.Query != "" {
+		ids, err := graph.QueryContext(r.Context(), s.g, data.Query, s.retained)
+		if err != nil {
+			data.Error = err.Error()
+		}
+		data.Matched = len(ids)
+		data.Results = s.refs(ids[:min(len(ids), maxQueryResults)])
+	}
+
+	s.render(w, "query", data)
+}
+
+// typeRow is one line of the top types table
+type typeRow struct {
+	Type      string
+	Count     int
+	TotalSize uint64
+	AvgSize   uint64
+	AvgPtrs   float64
+	Percent   float64
+}
+
+type typesPage struct {
+	pageData
+	TopTypes   []typeRow
+	NumObjects int
+	NumEdges   int
+	TotalSize  uint64
+	SortBy     string
+	SortOrder  string
+}
+
+// handleTypes renders the top types table. The sort query parameter
+// selects type, count, fanout, or size (the default) and order selects asc
+// or desc.
+func (s *Server) handleTypes(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy != "type" && sortBy != "count" && sortBy != "fanout" {
+		sortBy = "size"
+	}
+	order := r.URL.Query().Get("order")
+	if order != "asc" {
+		order = "desc"
+	}
+
+	rows := make([]typeRow, len(s.histogram))
+	for i, stat := range s.histogram {
+		rows[i] = typeRow{Type: stat.Type, Count: stat.Count, TotalSize: stat.TotalSize, AvgSize: stat.AvgSize(), AvgPtrs: s.fanout[stat.Type], Percent: stat.PercentOfTotal}
+	}
+	sortTypeRows(rows, sortBy, order == "asc")
+
+	s.render(w, "types", typesPage{
+		pageData:   s.newPageData("HeapLens - Top Types"),
+		TopTypes:   rows,
+		NumObjects: s.g.NumObjects(),
+		NumEdges:   graph.NumEdges(s.g),
+		TotalSize:  s.totalSize,
+		SortBy:     sortBy,
+		SortOrder:  order,
+	})
+}
+
+// sortTypeRows orders rows by the given column. Ties within the count,
+// size, and fanout columns are broken by type name so the order is stable across requests.
+func sortTypeRows(rows []typeRow, sortBy string, asc bool) {
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		switch {
+		case sortBy == "count" && a.Count != b.Count:
+			return (a.Count < b.Count) == asc
+		case sortBy == "size" && a.TotalSize != b.TotalSize:
+			return (a.TotalSize < b.TotalSize) == asc
+		case sortBy == "fanout" && a.AvgPtrs != b.AvgPtrs:
+			return (a.AvgPtrs < b.AvgPtrs) == asc
+		case sortBy == "type":
+			return (a.Type < b.Type) == asc
+		}
+		return a.Type < b.Type
+	})
+}
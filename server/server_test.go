@@ -0,0 +1,258 @@
+// ABOUTME: Tests for the HTTP analysis server
+// ABOUTME: Renders pages against an in-memory graph via httptest
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prateek/heaplens/graph"
+)
+
+func testGraph() graph.Graph {
+	g := graph.NewMemGraph()
+	g.AddObject(&graph.Object{ID: 1, Type: "*Session", Size: 100, Ptrs: []graph.ObjID{2, 3}})
+	g.AddObject(&graph.Object{ID: 2, Type: "[]byte", Size: 500, HasFinalizer: true})
+	g.AddObject(&graph.Object{ID: 3, Type: "string", Size: 16, Data: []byte("hi <there>")})
+	g.AddObject(&graph.Object{ID: 4, Type: "string", Size: 16})
+	g.AddObject(&graph.Object{ID: 5, Type: "string", Size: 16})
+	g.SetRoots(graph.Roots{IDs: []graph.ObjID{1}})
+	return g
+}
+
+// get performs a request against a fresh server and returns status and body
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	s, err := New(testGraph(), "test.heap")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	body, _ := io.ReadAll(rec.Body)
+	return rec.Code, string(body)
+}
+
+// assertOrder checks that each string first appears after the previous one
+func assertOrder(t *testing.T, body string, want ...string) {
+	t.Helper()
+	last := -1
+	for _, s := range want {
+		idx := strings.Index(body, s)
+		if idx < 0 {
+			t.Fatalf("body missing %q", s)
+		}
+		if idx < last {
+			t.Errorf("%q appears out of order", s)
+		}
+		last = idx
+	}
+}
+
+func TestTypesPage(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		order []string
+	}{
+		{name: "default is size desc", url: "/", order: []string{"[]byte", "*Session", "string"}},
+		{name: "size asc", url: "/?sort=size&order=asc", order: []string{"string", "*Session", "[]byte"}},
+		{name: "count desc", url: "/?sort=count&order=desc", order: []string{"string", "*Session", "[]byte"}},
+		{name: "type asc", url: "/?sort=type&order=asc", order: []string{"*Session", "[]byte", "string"}},
+		{name: "fanout desc", url: "/?sort=fanout&order=desc", order: []string{"*Session", "[]byte", "string"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := get(t, tt.url)
+			if code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", code)
+			}
+			// Restrict ordering checks to the table body
+			body = body[strings.Index(body, "<tbody>"):]
+			assertOrder(t, body, tt.order...)
+		})
+	}
+
+	_, body := get(t, "/")
+	if !strings.Contains(body, "test.heap") {
+		t.Error("page does not mention the dump file")
+	}
+	if !strings.Contains(body, "5 objects, 2 pointers, 648 bytes across 3 types") {
+		t.Error("page is missing the summary line")
+	}
+}
+
+func TestStaticAndNotFound(t *testing.T) {
+	code, body := get(t, "/static/css")
+	if code != http.StatusOK || !strings.Contains(body, ".container") {
+		t.Errorf("unexpected css response: %d", code)
+	}
+
+	if code, _ := get(t, "/nope"); code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", code)
+	}
+}
+
+func TestObjectPage(t *testing.T) {
+	code, body := get(t, "/object?id=2")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	for _, want := range []string{
+		"Object #2",
+		"<code>[]byte</code>",
+		"500 bytes",
+		`<a href="/object?id=1">#1</a>`, // referrer
+		"This object has a finalizer",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("object page missing %q", want)
+		}
+	}
+	assertOrder(t, body, "Shortest path", `href="/object?id=2"`, `href="/object?id=1"`, "Pointers (0)", "Referrers (1)")
+
+	_, body = get(t, "/object?id=1")
+	for _, want := range []string{"Retained size</td><td class=\"number\">616 bytes", "Pointers (2)", `href="/object?id=3"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("root object page missing %q", want)
+		}
+	}
+	if strings.Contains(body, "has a finalizer") {
+		t.Error("object without a finalizer should not mention one")
+	}
+	if strings.Contains(body, "Contents") {
+		t.Error("object without a string payload should not show contents")
+	}
+
+	_, body = get(t, "/object?id=3")
+	if !strings.Contains(body, "<td>Contents</td><td><code>hi &lt;there&gt;</code>") {
+		t.Error("string object page should show its escaped contents")
+	}
+
+	_, body = get(t, "/object?id=4")
+	if !strings.Contains(body, "Not retained by any root") {
+		t.Error("unreachable object should say it is not retained")
+	}
+}
+
+func TestObjectPageWithDominators(t *testing.T) {
+	g := testGraph()
+	s, err := NewWithDominators(g, "test.heap", graph.ComputeDominators(g))
+	if err != nil {
+		t.Fatalf("NewWithDominators() error = %v", err)
+	}
+
+	for id, want := range map[int]string{1: "616 bytes", 4: "Not retained by any root"} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/object?id=%d", id), nil))
+		if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, want) {
+			t.Errorf("object %d page: status %d, want 200 and %q in body", id, rec.Code, want)
+		}
+	}
+}
+
+func TestObjectPageErrors(t *testing.T) {
+	tests := []struct {
+		url  string
+		code int
+	}{
+		{url: "/object", code: http.StatusBadRequest},
+		{url: "/object?id=abc", code: http.StatusBadRequest},
+		{url: "/object?id=99", code: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if code, _ := get(t, tt.url); code != tt.code {
+			t.Errorf("%s: status = %d, want %d", tt.url, code, tt.code)
+		}
+	}
+
+	// A client that has gone away does not get the retained size computed
+	s, err := New(testGraph(), "test.heap")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/object?id=1", nil).WithContext(ctx))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("canceled request: status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestPathsPage(t *testing.T) {
+	code, body := get(t, "/paths?id=3")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	assertOrder(t, body, "Path 1 (2 objects)")
+	assertOrder(t, body[strings.Index(body, "Path 1"):], `href="/object?id=3"`, `href="/object?id=1"`)
+	if !strings.Contains(body, "Showing up to 5 shortest paths of at most 100 pointers") {
+		t.Error("paths page should use the default max")
+	}
+
+	_, body = get(t, "/paths?id=3&max=1000")
+	if !strings.Contains(body, "Showing up to 50 shortest paths") {
+		t.Error("paths page should cap max")
+	}
+
+	_, body = get(t, "/paths?id=4")
+	if !strings.Contains(body, "Not retained by any root") {
+		t.Error("unreachable object should say it is not retained")
+	}
+
+	for _, url := range []string{"/paths?id=3&max=0", "/paths?id=3&max=x", "/paths?id=x"} {
+		if code, _ := get(t, url); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", url, code)
+		}
+	}
+}
+
+func TestQueryPage(t *testing.T) {
+	code, body := get(t, "/query?q="+url.QueryEscape(`type == "string" && indegree > 0 || size >= 500`))
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	assertOrder(t, body, "2 of 5 objects match", `href="/object?id=2"`, `href="/object?id=3"`)
+	if strings.Contains(body, `href="/object?id=4"`) {
+		t.Error("query page lists an object that does not match")
+	}
+
+	_, body = get(t, "/query?q="+url.QueryEscape("size >"))
+	if !strings.Contains(body, "size needs a number") || strings.Contains(body, "objects match") {
+		t.Errorf("invalid query should show the parse error and no results:\n%s", body)
+	}
+
+	_, body = get(t, "/query")
+	if !strings.Contains(body, `name="q"`) || strings.Contains(body, "objects match") {
+		t.Error("empty query should show only the search box")
+	}
+}
+
+func TestPathsPageDepthLimit(t *testing.T) {
+	g := graph.NewMemGraph()
+	for id := graph.ObjID(1); id <= maxPathDepth+2; id++ {
+		g.AddObject(&graph.Object{ID: id, Type: "node", Ptrs: []graph.ObjID{id + 1}})
+	}
+	g.SetRoots(graph.Roots{IDs: []graph.ObjID{1}})
+	s, err := New(g, "chain.heap")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/paths?id=%d", maxPathDepth+2), nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "longer than 100 pointers") || strings.Contains(body, "Not retained") {
+		t.Errorf("deep object should be reported as reachable beyond the depth limit:\n%s", body)
+	}
+}